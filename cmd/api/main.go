@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
@@ -14,12 +15,18 @@ import (
 	"github.com/slinet/ehdb/internal/config"
 	"github.com/slinet/ehdb/internal/database"
 	"github.com/slinet/ehdb/internal/handler"
+	"github.com/slinet/ehdb/internal/jobs"
 	"github.com/slinet/ehdb/internal/logger"
 	"github.com/slinet/ehdb/internal/middleware"
 	"github.com/slinet/ehdb/internal/scheduler"
+	"github.com/slinet/ehdb/internal/tagrule"
 	"go.uber.org/zap"
 )
 
+// shutdownTimeout bounds graceful shutdown on SIGINT/SIGTERM: draining HTTP
+// requests and stopping scheduled and admin jobs
+const shutdownTimeout = 15 * time.Second
+
 func main() {
 	// Parse command line flags
 	configPath := flag.String("config", "config.yaml", "path to config file")
@@ -52,6 +59,16 @@ func main() {
 	}
 	defer database.Close()
 
+	// Load tag aliases and implications used by search
+	rulesCtx, stopRules := context.WithCancel(context.Background())
+	defer stopRules()
+	if err := tagrule.Refresh(rulesCtx, log); err != nil {
+		log.Warn("failed to load tag rules, searching without aliases and implications", zap.Error(err))
+	}
+	if cfg.API.TagRulesRefreshSeconds > 0 {
+		go tagrule.Watch(rulesCtx, time.Duration(cfg.API.TagRulesRefreshSeconds)*time.Second, log)
+	}
+
 	// Initialize Gin
 	if !cfg.API.Debug {
 		gin.SetMode(gin.ReleaseMode)
@@ -62,54 +79,8 @@ func main() {
 	router.Use(middleware.Recovery(log))
 	router.Use(middleware.CORS(cfg.API.CORS, cfg.API.CORSOrigin))
 
-	// Initialize handlers
-	galleryHandler := handler.NewGalleryHandler(log)
-	listHandler := handler.NewListHandler(log)
-	searchHandler := handler.NewSearchHandler(log)
-	tagHandler := handler.NewTagHandler(log)
-	categoryHandler := handler.NewCategoryHandler(log)
-	uploaderHandler := handler.NewUploaderHandler(log)
-
 	// Setup routes
-	router.GET("/", func(c *gin.Context) {
-		c.String(http.StatusOK, "ehdb-api is running")
-	})
-
-	// Health check endpoint (no logging)
-	router.GET("/health", func(c *gin.Context) {
-		c.String(http.StatusOK, "ok")
-	})
-
-	api := router.Group("/api")
-	{
-		// Gallery routes
-		api.GET("/gallery/:gid/:token", galleryHandler.GetGallery)
-		api.GET("/gallery/:gid", galleryHandler.GetGallery)
-		api.GET("/gallery", galleryHandler.GetGallery)
-		api.GET("/g/:gid/:token", galleryHandler.GetGallery)
-		api.GET("/g/:gid", galleryHandler.GetGallery)
-		api.GET("/g", galleryHandler.GetGallery)
-
-		// List route
-		api.GET("/list", listHandler.GetList)
-
-		// Search route
-		api.GET("/search", searchHandler.Search)
-
-		// Tag routes
-		api.GET("/tag/:tag", tagHandler.GetByTag)
-		api.GET("/tag", tagHandler.GetByTag)
-
-		// Category routes
-		api.GET("/category/:category", categoryHandler.GetByCategory)
-		api.GET("/category", categoryHandler.GetByCategory)
-		api.GET("/cat/:category", categoryHandler.GetByCategory)
-		api.GET("/cat", categoryHandler.GetByCategory)
-
-		// Uploader routes
-		api.GET("/uploader/:uploader", uploaderHandler.GetByUploader)
-		api.GET("/uploader", uploaderHandler.GetByUploader)
-	}
+	handler.RegisterRoutes(router, log)
 
 	// Start scheduler if enabled
 	var sched *scheduler.Scheduler
@@ -118,10 +89,19 @@ func main() {
 		if err := sched.Start(); err != nil {
 			log.Fatal("failed to start scheduler", zap.Error(err))
 		}
-		defer sched.Stop()
 		log.Info("scheduler enabled")
 	}
 
+	// Admin job API, only when a token is configured
+	var jobManager *jobs.Manager
+	if cfg.API.AdminToken != "" {
+		jobManager = jobs.NewManager(log)
+		handler.RegisterAdminRoutes(router, log, jobManager, sched, cfg.API.AdminToken)
+		log.Info("admin API enabled")
+	} else {
+		log.Info("admin API is disabled (api.admin_token is not set)")
+	}
+
 	// Start HTTP server
 	srv := &http.Server{
 		Addr:    fmt.Sprintf(":%d", cfg.API.Port),
@@ -143,12 +123,36 @@ func main() {
 
 	log.Info("shutting down server...")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatal("server forced to shutdown", zap.Error(err))
+		log.Warn("server forced to shutdown", zap.Error(err))
+	}
+
+	// Cancel running crawls together; they import what they already fetched
+	// and record the run as cancelled before returning
+	var stopWg sync.WaitGroup
+	if sched != nil {
+		stopWg.Add(1)
+		go func() {
+			defer stopWg.Done()
+			if err := sched.Stop(ctx); err != nil {
+				log.Warn("scheduled job did not stop before shutdown", zap.Error(err))
+			}
+		}()
+	}
+
+	if jobManager != nil {
+		stopWg.Add(1)
+		go func() {
+			defer stopWg.Done()
+			if err := jobManager.Close(ctx); err != nil {
+				log.Warn("running admin job did not stop before shutdown", zap.Error(err))
+			}
+		}()
 	}
+	stopWg.Wait()
 
 	log.Info("server exited")
 }
@@ -5,13 +5,20 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"sort"
 	"strings"
+	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"github.com/slinet/ehdb/internal/config"
 	"github.com/slinet/ehdb/internal/crawler"
 	"github.com/slinet/ehdb/internal/database"
+	"github.com/slinet/ehdb/internal/jobrun"
 	"github.com/slinet/ehdb/internal/logger"
+	"github.com/slinet/ehdb/internal/tagrule"
+	"github.com/slinet/ehdb/internal/tagtranslation"
 	"go.uber.org/zap"
 )
 
@@ -19,6 +26,13 @@ var nowFunc = func() time.Time {
 	return time.Now().UTC()
 }
 
+// signalContext returns a context cancelled by SIGINT or SIGTERM, so an
+// interrupted command stops its crawl, keeps what it already fetched where
+// it can and is recorded as cancelled in job run history
+func signalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
 func main() {
 	if len(os.Args) < 2 {
 		printUsage()
@@ -51,12 +65,28 @@ func main() {
 		runResync(log, os.Args[2:])
 	case "fetch":
 		runFetch(log, os.Args[2:])
+	case "refresh":
+		runRefresh(log, os.Args[2:])
 	case "torrent-sync":
 		runTorrentSync(log, os.Args[2:])
 	case "torrent-import":
 		runTorrentImport(log, os.Args[2:])
 	case "mark-replaced":
 		runMarkReplaced(log, os.Args[2:])
+	case "tag-alias":
+		runTagAlias(log, os.Args[2:])
+	case "tag-implication":
+		runTagImplication(log, os.Args[2:])
+	case "tag-rewrite":
+		runTagRewrite(log, os.Args[2:])
+	case "import-tag-translations":
+		runImportTagTranslations(log, os.Args[2:])
+	case "parse-titles":
+		runParseTitles(log, os.Args[2:])
+	case "derive-language":
+		runDeriveLanguage(log, os.Args[2:])
+	case "jobs":
+		runJobs(log, os.Args[2:])
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", command)
 		printUsage()
@@ -70,30 +100,97 @@ func printUsage() {
 	fmt.Println("  sync              Sync latest galleries from E-Hentai")
 	fmt.Println("                    Options: -config <path> -host <host> -offset <hours>")
 	fmt.Println("  backfill          Backfill missing galleries from the list replay window")
-	fmt.Println("                    Options: -config <path> -host <host> (-offset <hours> | -start <time> [-end <time>])")
+	fmt.Println("                    Options: -config <path> -host <host> (-offset <hours> | -start <time> [-end <time>] | -resume)")
 	fmt.Println("  resync            Resync galleries from recent hours")
-	fmt.Println("                    Options: -config <path> -hours <N>")
+	fmt.Println("                    Options: -config <path> (-hours <N> | -resume) [-details] [-comments]")
 	fmt.Println("  fetch             Manually fetch specific galleries")
 	fmt.Println("                    Usage: sync fetch <gid>/<token> [<gid>/<token> ...]")
 	fmt.Println("                    Or: sync fetch -file <filename>")
+	fmt.Println("                    Options: -config <path> [-details] [-comments]")
+	fmt.Println("  refresh           Resync the galleries synced longest ago within a daily API budget")
+	fmt.Println("                    Options: -config <path> -galleries <N> -budget <requests>")
 	fmt.Println("  torrent-sync      Sync new torrents from /torrents.php page")
 	fmt.Println("                    Options: -config <path> -host <host> -pages <N> -status <s> -search <keyword>")
 	fmt.Println("                    Automatically imports missing galleries")
 	fmt.Println("  torrent-import    Import torrents for existing galleries")
-	fmt.Println("                    Options: -config <path> -host <host> [-offset <hours> | -start <time> [-end <time>]]")
+	fmt.Println("                    Options: -config <path> -host <host> [-offset <hours> | -start <time> [-end <time>] | -resume]")
 	fmt.Println("                    Only processes galleries with root_gid = NULL and removed = false")
 	fmt.Println("  mark-replaced     Mark all replaced galleries")
 	fmt.Println("                    Options: -config <path>")
+	fmt.Println("  tag-alias         Add, update or delete a tag alias")
+	fmt.Println("                    Options: -config <path> -alias <tag> (-canonical <tag> [-canonicalize] | -delete)")
+	fmt.Println("  tag-implication   Add or delete a tag implication")
+	fmt.Println("                    Options: -config <path> -tag <tag> -implies <tag> [-delete]")
+	fmt.Println("  tag-rewrite       Rewrite gallery tags for aliases declared canonical")
+	fmt.Println("                    Options: -config <path>")
+	fmt.Println("  import-tag-translations")
+	fmt.Println("                    Import the EhTagTranslation database from a local file")
+	fmt.Println("                    Options: -config <path> -file <db.full.json>")
+	fmt.Println("  parse-titles      Backfill parsed title columns (event, circle, artist, ...)")
+	fmt.Println("                    Options: -config <path> -batch <N> [-all]")
+	fmt.Println("  derive-language   Backfill language, translated and rewrite columns from tags")
+	fmt.Println("                    Options: -config <path> -batch <N> -start-gid <gid>")
+	fmt.Println("  jobs              Show recorded scheduler, CLI and admin job runs")
+	fmt.Println("                    Options: -config <path> -kind <kind> -status <s> -source <s> -since <time> -before <id> -limit <N>")
+	fmt.Println("\nThe sync, backfill, resync, fetch, refresh, torrent-sync and torrent-import commands also accept")
+	fmt.Println("  -record <dir>     Save crawler requests and responses, with cookies scrubbed, as fixtures")
+	fmt.Println("  -replay <dir>     Serve crawler requests offline from recorded fixtures")
 	fmt.Println("\nExamples:")
 	fmt.Println("  ehdb-sync sync -host e-hentai.org -offset 2")
 	fmt.Println("  ehdb-sync backfill -host e-hentai.org -offset 2160")
 	fmt.Println("  ehdb-sync backfill -host e-hentai.org -start 2026-01-01T00:00:00Z -end 2026-03-31T00:00:00Z")
+	fmt.Println("  ehdb-sync backfill -resume")
 	fmt.Println("  ehdb-sync resync -hours 24")
+	fmt.Println("  ehdb-sync resync -hours 24 -details")
+	fmt.Println("  ehdb-sync resync -hours 24 -comments")
 	fmt.Println("  ehdb-sync fetch 123456/abcdef0123 234567/bcdef01234")
+	fmt.Println("  ehdb-sync refresh -galleries 10000 -budget 0")
+	fmt.Println("  ehdb-sync sync -record fixtures/sync-failure")
+	fmt.Println("  ehdb-sync sync -replay fixtures/sync-failure -config config.test.yaml")
 	fmt.Println("  ehdb-sync torrent-sync")
 	fmt.Println("  ehdb-sync torrent-sync -pages 5")
 	fmt.Println("  ehdb-sync torrent-import")
 	fmt.Println("  ehdb-sync torrent-import -offset 2160")
+	fmt.Println("  ehdb-sync torrent-import -resume")
+	fmt.Println("  ehdb-sync tag-alias -alias \"f:old name\" -canonical \"f:new name\" -canonicalize")
+	fmt.Println("  ehdb-sync tag-implication -tag \"parody:x\" -implies \"character:y\"")
+	fmt.Println("  ehdb-sync import-tag-translations -file db.full.json")
+	fmt.Println("  ehdb-sync parse-titles")
+	fmt.Println("  ehdb-sync derive-language")
+	fmt.Println("  ehdb-sync jobs -kind gallery-sync -status failed -limit 10")
+}
+
+// fixtureFlags are the -record and -replay flags of the crawling commands
+type fixtureFlags struct {
+	record *string
+	replay *string
+}
+
+func addFixtureFlags(fs *flag.FlagSet) *fixtureFlags {
+	return &fixtureFlags{
+		record: fs.String("record", "", "save crawler requests and responses, with cookies scrubbed, to this fixture directory"),
+		replay: fs.String("replay", "", "serve crawler requests offline from this fixture directory"),
+	}
+}
+
+// apply sets the crawler's fixture mode. Replays skip the request delays,
+// since no server is there to rate limit them.
+func (f *fixtureFlags) apply(logger *zap.Logger, cfg *config.CrawlerConfig) error {
+	switch {
+	case *f.record != "" && *f.replay != "":
+		return fmt.Errorf("-record cannot be used together with -replay")
+	case *f.record != "":
+		cfg.FixtureMode = crawler.FixtureModeRecord
+		cfg.FixtureDir = *f.record
+		logger.Info("recording crawler fixtures", zap.String("dir", cfg.FixtureDir))
+	case *f.replay != "":
+		cfg.FixtureMode = crawler.FixtureModeReplay
+		cfg.FixtureDir = *f.replay
+		cfg.PageDelaySeconds = 0
+		cfg.APIDelaySeconds = 0
+		logger.Info("replaying crawler fixtures", zap.String("dir", cfg.FixtureDir))
+	}
+	return nil
 }
 
 // runSync syncs latest galleries
@@ -102,6 +199,7 @@ func runSync(logger *zap.Logger, args []string) {
 	configPath := fs.String("config", "config.yaml", "path to config file")
 	host := fs.String("host", "", "e-hentai.org or exhentai.org (overrides config)")
 	offset := fs.Int("offset", 0, "time offset in hours")
+	fixtures := addFixtureFlags(fs)
 	if err := fs.Parse(args); err != nil {
 		logger.Fatal("failed to parse flags", zap.Error(err))
 	}
@@ -110,6 +208,9 @@ func runSync(logger *zap.Logger, args []string) {
 	if err != nil {
 		logger.Fatal("failed to load config", zap.Error(err))
 	}
+	if err := fixtures.apply(logger, &cfg.Crawler); err != nil {
+		logger.Fatal("invalid flags", zap.Error(err))
+	}
 
 	if *host != "" {
 		cfg.Crawler.Host = *host
@@ -123,13 +224,15 @@ func runSync(logger *zap.Logger, args []string) {
 	}
 	defer database.Close()
 
-	ctx := context.Background()
+	ctx, stop := signalContext()
+	defer stop()
 	galleryCrawler, err := crawler.NewGalleryCrawler(&cfg.Crawler, logger)
 	if err != nil {
 		logger.Fatal("failed to create gallery crawler", zap.Error(err))
 	}
 
-	if err := galleryCrawler.Sync(ctx); err != nil {
+	params := map[string]interface{}{"host": cfg.Crawler.Host, "offset": cfg.Crawler.Offset}
+	if err := jobrun.Track(ctx, logger, "gallery-sync", jobrun.SourceCLI, params, galleryCrawler.Sync); err != nil {
 		logger.Fatal("gallery sync failed", zap.Error(err))
 	}
 	logger.Info("gallery sync completed successfully")
@@ -142,6 +245,8 @@ func runBackfill(logger *zap.Logger, args []string) {
 	start := fs.String("start", "", "backfill window start time (RFC3339, 2006-01-02 15:04, or 2006-01-02)")
 	end := fs.String("end", "", "backfill window end time (RFC3339, 2006-01-02 15:04, or 2006-01-02)")
 	offset := fs.Int("offset", 0, "backfill window offset in hours")
+	resume := fs.Bool("resume", false, "continue the last unfinished backfill from its checkpoint")
+	fixtures := addFixtureFlags(fs)
 	if err := fs.Parse(args); err != nil {
 		logger.Fatal("failed to parse flags", zap.Error(err))
 	}
@@ -150,35 +255,80 @@ func runBackfill(logger *zap.Logger, args []string) {
 	if err != nil {
 		logger.Fatal("failed to load config", zap.Error(err))
 	}
+	if err := fixtures.apply(logger, &cfg.Crawler); err != nil {
+		logger.Fatal("invalid flags", zap.Error(err))
+	}
 
 	if *host != "" {
 		cfg.Crawler.Host = *host
 	}
 
-	startTime, endTime, err := resolveBackfillWindow(*start, *end, *offset)
-	if err != nil {
-		logger.Fatal("failed to resolve backfill window", zap.Error(err))
+	var startTime, endTime time.Time
+	if *resume {
+		if err := checkResumeFlags(*host, *start, *end, *offset); err != nil {
+			logger.Fatal("invalid flags", zap.Error(err))
+		}
+	} else {
+		startTime, endTime, err = resolveBackfillWindow(*start, *end, *offset)
+		if err != nil {
+			logger.Fatal("failed to resolve backfill window", zap.Error(err))
+		}
+		cfg.Crawler.BackfillStart = startTime.Unix()
+		cfg.Crawler.BackfillEnd = endTime.Unix()
 	}
-	cfg.Crawler.BackfillStart = startTime.Unix()
-	cfg.Crawler.BackfillEnd = endTime.Unix()
 
 	if err := database.Init(&cfg.Database, logger); err != nil {
 		logger.Fatal("failed to initialize database", zap.Error(err))
 	}
 	defer database.Close()
 
-	ctx := context.Background()
+	ctx, stop := signalContext()
+	defer stop()
+
+	var state *crawler.BackfillCheckpoint
+	if *resume {
+		var savedAt time.Time
+		state, savedAt, err = crawler.LoadBackfillCheckpoint(ctx)
+		if err != nil {
+			logger.Fatal("failed to load backfill checkpoint", zap.Error(err))
+		}
+		logger.Info("loaded backfill checkpoint", zap.Time("saved_at", savedAt))
+
+		// The saved run's host and window replace the config's
+		cfg.Crawler.Host = state.Host
+		cfg.Crawler.BackfillStart = state.StartPosted
+		cfg.Crawler.BackfillEnd = state.EndPosted
+		startTime = time.Unix(state.StartPosted, 0).UTC()
+		endTime = time.Unix(state.EndPosted, 0).UTC()
+	}
+
 	galleryCrawler, err := crawler.NewGalleryCrawler(&cfg.Crawler, logger)
 	if err != nil {
 		logger.Fatal("failed to create gallery crawler", zap.Error(err))
 	}
 
-	if err := galleryCrawler.Backfill(ctx); err != nil {
+	params := map[string]interface{}{"host": cfg.Crawler.Host, "start": startTime, "end": endTime, "resume": *resume}
+	err = jobrun.Track(ctx, logger, "backfill", jobrun.SourceCLI, params, func(ctx context.Context) error {
+		if state != nil {
+			return galleryCrawler.ResumeBackfill(ctx, state)
+		}
+		return galleryCrawler.Backfill(ctx)
+	})
+	if err != nil {
 		logger.Fatal("gallery backfill failed", zap.Error(err))
 	}
 	logger.Info("gallery backfill completed successfully")
 }
 
+// checkResumeFlags rejects window and host flags next to -resume, which
+// takes both from the saved checkpoint
+func checkResumeFlags(host, start, end string, offset int) error {
+	if host != "" || start != "" || end != "" || offset != 0 {
+		return fmt.Errorf("-resume cannot be used together with -host, -start, -end or -offset")
+	}
+	return nil
+}
+
 func resolveBackfillWindow(startRaw, endRaw string, offsetHours int) (time.Time, time.Time, error) {
 	hasStart := startRaw != ""
 	hasEnd := endRaw != ""
@@ -249,6 +399,10 @@ func runResync(logger *zap.Logger, args []string) {
 	fs := flag.NewFlagSet("resync", flag.ExitOnError)
 	configPath := fs.String("config", "config.yaml", "path to config file")
 	hours := fs.Int("hours", 24, "resync galleries from the last N hours")
+	resume := fs.Bool("resume", false, "continue the last unfinished resync from its checkpoint")
+	details := fs.Bool("details", false, "also fetch each gallery's page for the fields the API does not return")
+	comments := fs.Bool("comments", false, "also fetch each gallery's page and store its comments")
+	fixtures := addFixtureFlags(fs)
 	if err := fs.Parse(args); err != nil {
 		logger.Fatal("failed to parse flags", zap.Error(err))
 	}
@@ -257,15 +411,44 @@ func runResync(logger *zap.Logger, args []string) {
 	if err != nil {
 		logger.Fatal("failed to load config", zap.Error(err))
 	}
+	if err := fixtures.apply(logger, &cfg.Crawler); err != nil {
+		logger.Fatal("invalid flags", zap.Error(err))
+	}
+	if *details {
+		cfg.Crawler.FetchDetails = true
+	}
+	if *comments {
+		cfg.Crawler.FetchComments = true
+	}
 
 	if err := database.Init(&cfg.Database, logger); err != nil {
 		logger.Fatal("failed to initialize database", zap.Error(err))
 	}
 	defer database.Close()
 
-	ctx := context.Background()
+	ctx, stop := signalContext()
+	defer stop()
+
+	var state *crawler.ResyncCheckpoint
+	if *resume {
+		var savedAt time.Time
+		state, savedAt, err = crawler.LoadResyncCheckpoint(ctx)
+		if err != nil {
+			logger.Fatal("failed to load resync checkpoint", zap.Error(err))
+		}
+		logger.Info("loaded resync checkpoint", zap.Time("saved_at", savedAt))
+		*hours = state.Hours
+	}
+
 	resyncer := crawler.NewResyncer(&cfg.Crawler, logger)
-	if err := resyncer.Resync(ctx, *hours); err != nil {
+	params := map[string]interface{}{"hours": *hours, "resume": *resume, "details": cfg.Crawler.FetchDetails, "comments": cfg.Crawler.FetchComments}
+	err = jobrun.Track(ctx, logger, "resync", jobrun.SourceCLI, params, func(ctx context.Context) error {
+		if state != nil {
+			return resyncer.ResumeResync(ctx, state)
+		}
+		return resyncer.Resync(ctx, *hours)
+	})
+	if err != nil {
 		logger.Fatal("resync failed", zap.Error(err))
 	}
 	logger.Info("resync completed successfully")
@@ -276,6 +459,9 @@ func runFetch(logger *zap.Logger, args []string) {
 	fs := flag.NewFlagSet("fetch", flag.ExitOnError)
 	configPath := fs.String("config", "config.yaml", "path to config file")
 	file := fs.String("file", "", "file containing gid/token pairs")
+	details := fs.Bool("details", false, "also fetch each gallery's page for the fields the API does not return")
+	comments := fs.Bool("comments", false, "also fetch each gallery's page and store its comments")
+	fixtures := addFixtureFlags(fs)
 	if err := fs.Parse(args); err != nil {
 		logger.Fatal("failed to parse flags", zap.Error(err))
 	}
@@ -284,6 +470,15 @@ func runFetch(logger *zap.Logger, args []string) {
 	if err != nil {
 		logger.Fatal("failed to load config", zap.Error(err))
 	}
+	if err := fixtures.apply(logger, &cfg.Crawler); err != nil {
+		logger.Fatal("invalid flags", zap.Error(err))
+	}
+	if *details {
+		cfg.Crawler.FetchDetails = true
+	}
+	if *comments {
+		cfg.Crawler.FetchComments = true
+	}
 
 	if err := database.Init(&cfg.Database, logger); err != nil {
 		logger.Fatal("failed to initialize database", zap.Error(err))
@@ -313,14 +508,63 @@ func runFetch(logger *zap.Logger, args []string) {
 		logger.Fatal("no galleries specified")
 	}
 
-	ctx := context.Background()
+	ctx, stop := signalContext()
+	defer stop()
 	fetcher := crawler.NewFetcher(&cfg.Crawler, logger)
-	if err := fetcher.Fetch(ctx, gidTokens); err != nil {
+	params := map[string]interface{}{"galleries": len(gidTokens), "details": cfg.Crawler.FetchDetails, "comments": cfg.Crawler.FetchComments}
+	err = jobrun.Track(ctx, logger, "fetch", jobrun.SourceCLI, params, func(ctx context.Context) error {
+		return fetcher.Fetch(ctx, gidTokens)
+	})
+	if err != nil {
 		logger.Fatal("fetch failed", zap.Error(err))
 	}
 	logger.Info("fetch completed successfully")
 }
 
+// runRefresh resyncs the stalest galleries
+func runRefresh(logger *zap.Logger, args []string) {
+	fs := flag.NewFlagSet("refresh", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "path to config file")
+	galleries := fs.Int("galleries", -1, "resync this many of the stalest galleries (default: scheduler.refresh_galleries)")
+	budget := fs.Int("budget", -1, "gdata requests refresh runs may make per 24 hours, 0 for no limit (default: scheduler.refresh_daily_budget)")
+	fixtures := addFixtureFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		logger.Fatal("failed to parse flags", zap.Error(err))
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		logger.Fatal("failed to load config", zap.Error(err))
+	}
+	if err := fixtures.apply(logger, &cfg.Crawler); err != nil {
+		logger.Fatal("invalid flags", zap.Error(err))
+	}
+	if *galleries < 0 {
+		*galleries = cfg.Scheduler.RefreshGalleries
+	}
+	if *budget < 0 {
+		*budget = cfg.Scheduler.RefreshDailyBudget
+	}
+
+	if err := database.Init(&cfg.Database, logger); err != nil {
+		logger.Fatal("failed to initialize database", zap.Error(err))
+	}
+	defer database.Close()
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	refresher := crawler.NewRefresher(&cfg.Crawler, logger)
+	params := map[string]int{"galleries": *galleries, "daily_budget": *budget}
+	err = jobrun.Track(ctx, logger, crawler.RefreshJobKind, jobrun.SourceCLI, params, func(ctx context.Context) error {
+		return refresher.Refresh(ctx, *galleries, *budget)
+	})
+	if err != nil {
+		logger.Fatal("refresh failed", zap.Error(err))
+	}
+	logger.Info("refresh completed successfully")
+}
+
 // runTorrentSync syncs torrents from torrent list page
 func runTorrentSync(logger *zap.Logger, args []string) {
 	fs := flag.NewFlagSet("torrent-sync", flag.ExitOnError)
@@ -329,6 +573,7 @@ func runTorrentSync(logger *zap.Logger, args []string) {
 	pages := fs.Int("pages", 0, "number of pages to fetch (0 = until reaching existing torrents)")
 	status := fs.String("status", "", "torrent status filter")
 	search := fs.String("search", "", "search keyword")
+	fixtures := addFixtureFlags(fs)
 	if err := fs.Parse(args); err != nil {
 		logger.Fatal("failed to parse flags", zap.Error(err))
 	}
@@ -337,6 +582,9 @@ func runTorrentSync(logger *zap.Logger, args []string) {
 	if err != nil {
 		logger.Fatal("failed to load config", zap.Error(err))
 	}
+	if err := fixtures.apply(logger, &cfg.Crawler); err != nil {
+		logger.Fatal("invalid flags", zap.Error(err))
+	}
 
 	if *host != "" {
 		cfg.Crawler.Host = *host
@@ -347,7 +595,8 @@ func runTorrentSync(logger *zap.Logger, args []string) {
 	}
 	defer database.Close()
 
-	ctx := context.Background()
+	ctx, stop := signalContext()
+	defer stop()
 	torrentCrawler, err := crawler.NewTorrentCrawler(&cfg.Crawler, logger)
 	if err != nil {
 		logger.Fatal("failed to create torrent crawler", zap.Error(err))
@@ -360,7 +609,8 @@ func runTorrentSync(logger *zap.Logger, args []string) {
 		Search:     *search,
 	})
 
-	if err := torrentCrawler.Sync(ctx); err != nil {
+	params := map[string]interface{}{"host": cfg.Crawler.Host, "pages": *pages, "status": *status, "search": *search}
+	if err := jobrun.Track(ctx, logger, "torrent-sync", jobrun.SourceCLI, params, torrentCrawler.Sync); err != nil {
 		logger.Fatal("torrent sync failed", zap.Error(err))
 	}
 	logger.Info("torrent sync completed successfully")
@@ -374,6 +624,8 @@ func runTorrentImport(logger *zap.Logger, args []string) {
 	start := fs.String("start", "", "torrent import window start time (RFC3339, 2006-01-02 15:04, or 2006-01-02)")
 	end := fs.String("end", "", "torrent import window end time (RFC3339, 2006-01-02 15:04, or 2006-01-02)")
 	offset := fs.Int("offset", 0, "torrent import window offset in hours")
+	resume := fs.Bool("resume", false, "continue the last unfinished torrent import from its checkpoint")
+	fixtures := addFixtureFlags(fs)
 	if err := fs.Parse(args); err != nil {
 		logger.Fatal("failed to parse flags", zap.Error(err))
 	}
@@ -382,13 +634,21 @@ func runTorrentImport(logger *zap.Logger, args []string) {
 	if err != nil {
 		logger.Fatal("failed to load config", zap.Error(err))
 	}
+	if err := fixtures.apply(logger, &cfg.Crawler); err != nil {
+		logger.Fatal("invalid flags", zap.Error(err))
+	}
 
 	if *host != "" {
 		cfg.Crawler.Host = *host
 	}
 
 	hasWindowArgs := *offset > 0 || *start != "" || *end != ""
-	if hasWindowArgs {
+	switch {
+	case *resume:
+		if err := checkResumeFlags(*host, *start, *end, *offset); err != nil {
+			logger.Fatal("invalid flags", zap.Error(err))
+		}
+	case hasWindowArgs:
 		startTime, endTime, err := resolveBackfillWindow(*start, *end, *offset)
 		if err != nil {
 			logger.Fatal("failed to resolve torrent import window", zap.Error(err))
@@ -400,7 +660,7 @@ func runTorrentImport(logger *zap.Logger, args []string) {
 			zap.String("start", startTime.UTC().Format(time.RFC3339)),
 			zap.String("end", endTime.UTC().Format(time.RFC3339)),
 		)
-	} else {
+	default:
 		logger.Warn("torrent-import is a heavy operation that will scan all galleries")
 	}
 
@@ -409,13 +669,37 @@ func runTorrentImport(logger *zap.Logger, args []string) {
 	}
 	defer database.Close()
 
-	ctx := context.Background()
+	ctx, stop := signalContext()
+	defer stop()
+
+	var state *crawler.TorrentImportCheckpoint
+	if *resume {
+		var savedAt time.Time
+		state, savedAt, err = crawler.LoadTorrentImportCheckpoint(ctx)
+		if err != nil {
+			logger.Fatal("failed to load torrent import checkpoint", zap.Error(err))
+		}
+		logger.Info("loaded torrent import checkpoint", zap.Time("saved_at", savedAt))
+
+		// The saved run's host and window replace the config's
+		cfg.Crawler.Host = state.Host
+		cfg.Crawler.BackfillStart = state.Start
+		cfg.Crawler.BackfillEnd = state.End
+	}
+
 	importer, err := crawler.NewTorrentImporter(&cfg.Crawler, logger)
 	if err != nil {
 		logger.Fatal("failed to create torrent importer", zap.Error(err))
 	}
 
-	if err := importer.ImportAll(ctx); err != nil {
+	params := map[string]interface{}{"host": cfg.Crawler.Host, "start": cfg.Crawler.BackfillStart, "end": cfg.Crawler.BackfillEnd, "resume": *resume}
+	err = jobrun.Track(ctx, logger, "torrent-import", jobrun.SourceCLI, params, func(ctx context.Context) error {
+		if state != nil {
+			return importer.ResumeImport(ctx, state)
+		}
+		return importer.ImportAll(ctx)
+	})
+	if err != nil {
 		logger.Fatal("torrent import failed", zap.Error(err))
 	}
 	logger.Info("torrent import completed successfully")
@@ -439,10 +723,324 @@ func runMarkReplaced(logger *zap.Logger, args []string) {
 	}
 	defer database.Close()
 
-	ctx := context.Background()
+	ctx, stop := signalContext()
+	defer stop()
 	marker := crawler.NewReplacedMarker(logger)
-	if err := marker.MarkReplaced(ctx); err != nil {
+	if err := jobrun.Track(ctx, logger, "mark-replaced", jobrun.SourceCLI, nil, marker.MarkReplaced); err != nil {
 		logger.Fatal("mark replaced failed", zap.Error(err))
 	}
 	logger.Info("mark replaced completed successfully")
 }
+
+// runTagAlias adds, updates or deletes a tag alias
+func runTagAlias(logger *zap.Logger, args []string) {
+	fs := flag.NewFlagSet("tag-alias", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "path to config file")
+	alias := fs.String("alias", "", "alias tag (e.g. female:old name)")
+	canonical := fs.String("canonical", "", "canonical tag the alias resolves to")
+	canonicalize := fs.Bool("canonicalize", false, "rewrite the alias in stored gallery tags on the next tag-rewrite")
+	remove := fs.Bool("delete", false, "delete the alias")
+	if err := fs.Parse(args); err != nil {
+		logger.Fatal("failed to parse flags", zap.Error(err))
+	}
+
+	if *alias == "" || (!*remove && *canonical == "") {
+		logger.Fatal("-alias and either -canonical or -delete are required")
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		logger.Fatal("failed to load config", zap.Error(err))
+	}
+
+	if err := database.Init(&cfg.Database, logger); err != nil {
+		logger.Fatal("failed to initialize database", zap.Error(err))
+	}
+	defer database.Close()
+
+	ctx, stop := signalContext()
+	defer stop()
+	if *remove {
+		if err := tagrule.DeleteAlias(ctx, logger, *alias); err != nil {
+			logger.Fatal("delete tag alias failed", zap.Error(err))
+		}
+		logger.Info("tag alias deleted", zap.String("alias", *alias))
+		return
+	}
+
+	if err := tagrule.SetAlias(ctx, logger, *alias, *canonical, *canonicalize); err != nil {
+		logger.Fatal("set tag alias failed", zap.Error(err))
+	}
+	logger.Info("tag alias saved",
+		zap.String("alias", *alias),
+		zap.String("canonical", *canonical),
+		zap.Bool("canonicalize", *canonicalize),
+	)
+}
+
+// runTagImplication adds or deletes a tag implication
+func runTagImplication(logger *zap.Logger, args []string) {
+	fs := flag.NewFlagSet("tag-implication", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "path to config file")
+	tag := fs.String("tag", "", "implying tag (e.g. parody:x)")
+	implies := fs.String("implies", "", "implied tag (e.g. character:y)")
+	remove := fs.Bool("delete", false, "delete the implication")
+	if err := fs.Parse(args); err != nil {
+		logger.Fatal("failed to parse flags", zap.Error(err))
+	}
+
+	if *tag == "" || *implies == "" {
+		logger.Fatal("-tag and -implies are required")
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		logger.Fatal("failed to load config", zap.Error(err))
+	}
+
+	if err := database.Init(&cfg.Database, logger); err != nil {
+		logger.Fatal("failed to initialize database", zap.Error(err))
+	}
+	defer database.Close()
+
+	ctx, stop := signalContext()
+	defer stop()
+	if *remove {
+		if err := tagrule.DeleteImplication(ctx, logger, *tag, *implies); err != nil {
+			logger.Fatal("delete tag implication failed", zap.Error(err))
+		}
+		logger.Info("tag implication deleted", zap.String("tag", *tag), zap.String("implies", *implies))
+		return
+	}
+
+	if err := tagrule.AddImplication(ctx, logger, *tag, *implies); err != nil {
+		logger.Fatal("add tag implication failed", zap.Error(err))
+	}
+	logger.Info("tag implication saved", zap.String("tag", *tag), zap.String("implies", *implies))
+}
+
+// runTagRewrite rewrites stored gallery tags for aliases declared canonical
+func runTagRewrite(logger *zap.Logger, args []string) {
+	fs := flag.NewFlagSet("tag-rewrite", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "path to config file")
+	if err := fs.Parse(args); err != nil {
+		logger.Fatal("failed to parse flags", zap.Error(err))
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		logger.Fatal("failed to load config", zap.Error(err))
+	}
+
+	if err := database.Init(&cfg.Database, logger); err != nil {
+		logger.Fatal("failed to initialize database", zap.Error(err))
+	}
+	defer database.Close()
+
+	ctx, stop := signalContext()
+	defer stop()
+	// Load rules so alias chains resolve to their final canonical name
+	if err := tagrule.Refresh(ctx, logger); err != nil {
+		logger.Fatal("failed to load tag rules", zap.Error(err))
+	}
+
+	rewriter := tagrule.NewRewriter(logger)
+	if err := rewriter.Rewrite(ctx); err != nil {
+		logger.Fatal("tag rewrite failed", zap.Error(err))
+	}
+	logger.Info("tag rewrite completed successfully")
+}
+
+// runImportTagTranslations imports the EhTagTranslation database from a local file
+func runImportTagTranslations(logger *zap.Logger, args []string) {
+	fs := flag.NewFlagSet("import-tag-translations", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "path to config file")
+	file := fs.String("file", "", "EhTagTranslation database file (db.full.json)")
+	if err := fs.Parse(args); err != nil {
+		logger.Fatal("failed to parse flags", zap.Error(err))
+	}
+
+	if *file == "" {
+		logger.Fatal("-file is required")
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		logger.Fatal("failed to open file", zap.Error(err))
+	}
+	defer f.Close()
+
+	translations, err := tagtranslation.Parse(f)
+	if err != nil {
+		logger.Fatal("failed to parse translation database", zap.Error(err))
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		logger.Fatal("failed to load config", zap.Error(err))
+	}
+
+	if err := database.Init(&cfg.Database, logger); err != nil {
+		logger.Fatal("failed to initialize database", zap.Error(err))
+	}
+	defer database.Close()
+
+	ctx, stop := signalContext()
+	defer stop()
+	importer := tagtranslation.NewImporter(logger)
+	if err := importer.Import(ctx, translations); err != nil {
+		logger.Fatal("import tag translations failed", zap.Error(err))
+	}
+	logger.Info("import tag translations completed successfully")
+}
+
+// runParseTitles backfills parsed title columns for existing galleries
+func runParseTitles(logger *zap.Logger, args []string) {
+	fs := flag.NewFlagSet("parse-titles", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "path to config file")
+	batch := fs.Int("batch", 5000, "number of galleries updated per batch")
+	all := fs.Bool("all", false, "reparse every gallery instead of only unparsed ones")
+	if err := fs.Parse(args); err != nil {
+		logger.Fatal("failed to parse flags", zap.Error(err))
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		logger.Fatal("failed to load config", zap.Error(err))
+	}
+
+	if err := database.Init(&cfg.Database, logger); err != nil {
+		logger.Fatal("failed to initialize database", zap.Error(err))
+	}
+	defer database.Close()
+
+	ctx, stop := signalContext()
+	defer stop()
+	backfiller := crawler.NewTitleBackfiller(logger, *batch)
+	if err := backfiller.Backfill(ctx, *all); err != nil {
+		logger.Fatal("parse titles failed", zap.Error(err))
+	}
+	logger.Info("parse titles completed successfully")
+}
+
+// runDeriveLanguage backfills language columns for existing galleries
+func runDeriveLanguage(logger *zap.Logger, args []string) {
+	fs := flag.NewFlagSet("derive-language", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "path to config file")
+	batch := fs.Int("batch", 5000, "number of galleries updated per batch")
+	startGid := fs.Int("start-gid", 0, "only process galleries with a gid greater than this (resume)")
+	if err := fs.Parse(args); err != nil {
+		logger.Fatal("failed to parse flags", zap.Error(err))
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		logger.Fatal("failed to load config", zap.Error(err))
+	}
+
+	if err := database.Init(&cfg.Database, logger); err != nil {
+		logger.Fatal("failed to initialize database", zap.Error(err))
+	}
+	defer database.Close()
+
+	ctx, stop := signalContext()
+	defer stop()
+	backfiller := crawler.NewLanguageBackfiller(logger, *batch)
+	if err := backfiller.Backfill(ctx, *startGid); err != nil {
+		logger.Fatal("derive language failed", zap.Error(err))
+	}
+	logger.Info("derive language completed successfully")
+}
+
+// runJobs prints recorded job runs, newest first
+func runJobs(logger *zap.Logger, args []string) {
+	fs := flag.NewFlagSet("jobs", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "path to config file")
+	kind := fs.String("kind", "", "only show runs of this kind (e.g. gallery-sync, torrent-sync, resync)")
+	status := fs.String("status", "", "only show runs with this status (running, succeeded, failed, cancelled)")
+	source := fs.String("source", "", "only show runs started by this source (scheduler, cli, admin)")
+	since := fs.String("since", "", "only show runs started at or after this time (RFC3339, 2006-01-02 15:04, or 2006-01-02)")
+	before := fs.Int64("before", 0, "only show runs with an ID below this (paging)")
+	limit := fs.Int("limit", 20, "maximum number of runs to show")
+	if err := fs.Parse(args); err != nil {
+		logger.Fatal("failed to parse flags", zap.Error(err))
+	}
+
+	filter := jobrun.ListFilter{
+		Kind:   *kind,
+		Status: *status,
+		Source: *source,
+		Before: *before,
+		Limit:  *limit,
+	}
+	if *since != "" {
+		sinceTime, err := parseBackfillTime(*since, true)
+		if err != nil {
+			logger.Fatal("failed to parse -since", zap.Error(err))
+		}
+		filter.Since = sinceTime
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		logger.Fatal("failed to load config", zap.Error(err))
+	}
+
+	if err := database.Init(&cfg.Database, logger); err != nil {
+		logger.Fatal("failed to initialize database", zap.Error(err))
+	}
+	defer database.Close()
+
+	ctx, stop := signalContext()
+	defer stop()
+	runs, err := jobrun.List(ctx, logger, filter)
+	if err != nil {
+		logger.Fatal("failed to list job runs", zap.Error(err))
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tKIND\tSOURCE\tSTATUS\tSTARTED\tDURATION\tCOUNTERS\tERROR")
+	for _, run := range runs {
+		errText := ""
+		if run.Error != nil {
+			errText = truncate(*run.Error, 80)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			run.ID, run.Kind, run.Source, run.Status,
+			run.StartedAt.UTC().Format(time.RFC3339),
+			run.Duration().Round(time.Second),
+			formatCounters(run.Counters),
+			errText,
+		)
+	}
+	if err := w.Flush(); err != nil {
+		logger.Fatal("failed to write job runs", zap.Error(err))
+	}
+}
+
+// formatCounters renders counters as sorted name=value pairs
+func formatCounters(counters map[string]int64) string {
+	names := make([]string, 0, len(counters))
+	for name := range counters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s=%d", name, counters[name]))
+	}
+	if len(parts) == 0 {
+		return "-"
+	}
+	return strings.Join(parts, " ")
+}
+
+// truncate shortens s to at most n runes
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-3]) + "..."
+}
@@ -0,0 +1,70 @@
+// Package checkpoint persists the position of long crawls (backfill, resync,
+// torrent-import) so a run that crashed or was stopped can continue where it
+// died instead of starting over
+package checkpoint
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/slinet/ehdb/internal/database"
+)
+
+// ErrNotFound is returned by Load when no checkpoint is saved for a kind
+var ErrNotFound = errors.New("no checkpoint saved")
+
+// Save stores state as the checkpoint of kind, replacing the previous one.
+// Each kind keeps a single checkpoint, so starting a fresh run overwrites
+// whatever an earlier unfinished run left behind.
+func Save(ctx context.Context, kind string, state interface{}) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("marshal %s checkpoint: %w", kind, err)
+	}
+
+	query := `
+		INSERT INTO crawl_checkpoint (kind, state, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (kind) DO UPDATE SET
+			state = EXCLUDED.state,
+			updated_at = EXCLUDED.updated_at
+	`
+	if _, err := database.GetPool().Exec(ctx, query, kind, data); err != nil {
+		return fmt.Errorf("save %s checkpoint: %w", kind, err)
+	}
+	return nil
+}
+
+// Load reads the checkpoint of kind into state and returns when it was saved.
+// It returns ErrNotFound if there is none.
+func Load(ctx context.Context, kind string, state interface{}) (time.Time, error) {
+	var (
+		data      []byte
+		updatedAt time.Time
+	)
+	query := `SELECT state, updated_at FROM crawl_checkpoint WHERE kind = $1`
+	err := database.GetPool().QueryRow(ctx, query, kind).Scan(&data, &updatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return time.Time{}, fmt.Errorf("%s: %w", kind, ErrNotFound)
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("load %s checkpoint: %w", kind, err)
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return time.Time{}, fmt.Errorf("decode %s checkpoint: %w", kind, err)
+	}
+	return updatedAt, nil
+}
+
+// Clear deletes the checkpoint of kind once its run has completed
+func Clear(ctx context.Context, kind string) error {
+	if _, err := database.GetPool().Exec(ctx, `DELETE FROM crawl_checkpoint WHERE kind = $1`, kind); err != nil {
+		return fmt.Errorf("clear %s checkpoint: %w", kind, err)
+	}
+	return nil
+}
@@ -33,6 +33,10 @@ type APIConfig struct {
 	CORS       bool            `mapstructure:"cors"`
 	CORSOrigin string          `mapstructure:"cors_origin"`
 	Limits     APILimitsConfig `mapstructure:"limits"`
+	// Interval for reloading tag aliases and implications (0 = load once at startup)
+	TagRulesRefreshSeconds int `mapstructure:"tag_rules_refresh_seconds"`
+	// Bearer token for the /admin endpoints (empty = admin API disabled)
+	AdminToken string `mapstructure:"admin_token"`
 }
 
 // APILimitsConfig holds query limits for different API endpoints
@@ -46,32 +50,58 @@ type APILimitsConfig struct {
 
 // CrawlerConfig holds crawler settings
 type CrawlerConfig struct {
-	Host                string `mapstructure:"host"`
-	Cookies             string `mapstructure:"cookies"`
-	ConfigDir           string
-	Proxy               string `mapstructure:"proxy"`
-	RetryTimes          int    `mapstructure:"retry_times"`
-	TransientRetryTimes int    `mapstructure:"transient_retry_times"`
-	WaitForIPUnban      bool   `mapstructure:"wait_for_ip_unban"`
-	PageDelaySeconds    int    `mapstructure:"page_delay_seconds"`   // Delay between page fetches
-	APIDelaySeconds     int    `mapstructure:"api_delay_seconds"`    // Delay between API calls
-	FlareSolverrEnabled bool   `mapstructure:"flaresolverr_enabled"` // Enable FlareSolverr for Cloudflare bypass
-	FlareSolverrURL     string `mapstructure:"flaresolverr_url"`     // FlareSolverr service URL
-	Offset              int    // Temporary parameter, not from config file
-	BackfillStart       int64  // Temporary parameter, not from config file
-	BackfillEnd         int64  // Temporary parameter, not from config file
+	Host                      string `mapstructure:"host"`
+	Cookies                   string `mapstructure:"cookies"`
+	ConfigDir                 string
+	Credentials               []CredentialConfig `mapstructure:"credentials"`                 // More cookie sets rotated through on auth failures and bans
+	CredentialCooldownMinutes int                `mapstructure:"credential_cooldown_minutes"` // How long a failed credential sits out
+	Proxy                     string             `mapstructure:"proxy"`
+	Proxies                   []string           `mapstructure:"proxies"` // More proxies rotated through on temporary bans
+	RetryTimes                int                `mapstructure:"retry_times"`
+	TransientRetryTimes       int                `mapstructure:"transient_retry_times"`
+	WaitForIPUnban            bool               `mapstructure:"wait_for_ip_unban"`
+	PageDelaySeconds          int                `mapstructure:"page_delay_seconds"`   // Interval between page fetches per host
+	APIDelaySeconds           int                `mapstructure:"api_delay_seconds"`    // Interval between API calls per host
+	MetadataWorkers           int                `mapstructure:"metadata_workers"`     // Concurrent API batches in resync and fetch
+	FetchDetails              bool               `mapstructure:"fetch_details"`        // Also scrape gallery pages in resync and fetch
+	FetchComments             bool               `mapstructure:"fetch_comments"`       // Also store gallery comments in resync and fetch
+	FlareSolverrEnabled       bool               `mapstructure:"flaresolverr_enabled"` // Enable FlareSolverr for Cloudflare bypass
+	FlareSolverrURL           string             `mapstructure:"flaresolverr_url"`     // FlareSolverr service URL
+	Offset                    int                // Temporary parameter, not from config file
+	BackfillStart             int64              // Temporary parameter, not from config file
+	BackfillEnd               int64              // Temporary parameter, not from config file
+	FixtureMode               string             // Temporary parameter, not from config file: record, replay or empty
+	FixtureDir                string             // Temporary parameter, not from config file
+}
+
+// CredentialConfig is one named set of account cookies
+type CredentialConfig struct {
+	Name    string `mapstructure:"name"`
+	Cookies string `mapstructure:"cookies"`
 }
 
 // SchedulerConfig holds scheduler settings
 type SchedulerConfig struct {
-	GallerySyncCron    string `mapstructure:"gallery_sync_cron"`
-	GallerySyncEnabled bool   `mapstructure:"gallery_sync_enabled"`
-	GallerySyncOffset  int    `mapstructure:"gallery_sync_offset"`
-	TorrentSyncCron    string `mapstructure:"torrent_sync_cron"`
-	TorrentSyncEnabled bool   `mapstructure:"torrent_sync_enabled"`
-	ResyncCron         string `mapstructure:"resync_cron"`
-	ResyncEnabled      bool   `mapstructure:"resync_enabled"`
-	ResyncHours        int    `mapstructure:"resync_hours"`
+	GallerySyncCron     string `mapstructure:"gallery_sync_cron"`
+	GallerySyncEnabled  bool   `mapstructure:"gallery_sync_enabled"`
+	GallerySyncOffset   int    `mapstructure:"gallery_sync_offset"`
+	GallerySyncPolicy   string `mapstructure:"gallery_sync_policy"`   // skip, queue or parallel when the previous run is still going
+	GallerySyncPriority int    `mapstructure:"gallery_sync_priority"` // Lower priority jobs pause while higher ones run
+	TorrentSyncCron     string `mapstructure:"torrent_sync_cron"`
+	TorrentSyncEnabled  bool   `mapstructure:"torrent_sync_enabled"`
+	TorrentSyncPolicy   string `mapstructure:"torrent_sync_policy"`
+	TorrentSyncPriority int    `mapstructure:"torrent_sync_priority"`
+	ResyncCron          string `mapstructure:"resync_cron"`
+	ResyncEnabled       bool   `mapstructure:"resync_enabled"`
+	ResyncHours         int    `mapstructure:"resync_hours"`
+	ResyncPolicy        string `mapstructure:"resync_policy"`
+	ResyncPriority      int    `mapstructure:"resync_priority"`
+	RefreshCron         string `mapstructure:"refresh_cron"`
+	RefreshEnabled      bool   `mapstructure:"refresh_enabled"`
+	RefreshGalleries    int    `mapstructure:"refresh_galleries"`    // Stalest galleries resynced per run
+	RefreshDailyBudget  int    `mapstructure:"refresh_daily_budget"` // gdata requests refresh runs may make per 24 hours, 0 for no limit
+	RefreshPolicy       string `mapstructure:"refresh_policy"`
+	RefreshPriority     int    `mapstructure:"refresh_priority"`
 }
 
 var globalConfig *Config
@@ -93,12 +123,18 @@ func Load(configPath string) (*Config, error) {
 	v.SetDefault("api.limits.list_max_limit", 25)
 	v.SetDefault("api.limits.uploader_max_limit", 25)
 	v.SetDefault("api.limits.tag_max_limit", 25)
+	v.SetDefault("api.tag_rules_refresh_seconds", 300)
+	v.SetDefault("api.admin_token", "")
 	v.SetDefault("crawler.host", "e-hentai.org")
 	v.SetDefault("crawler.retry_times", 3)
 	v.SetDefault("crawler.transient_retry_times", 6)
 	v.SetDefault("crawler.wait_for_ip_unban", false)
 	v.SetDefault("crawler.page_delay_seconds", 1)
 	v.SetDefault("crawler.api_delay_seconds", 1)
+	v.SetDefault("crawler.metadata_workers", 4)
+	v.SetDefault("crawler.fetch_details", false)
+	v.SetDefault("crawler.fetch_comments", false)
+	v.SetDefault("crawler.credential_cooldown_minutes", 30)
 	v.SetDefault("crawler.flaresolverr_enabled", false)
 	v.SetDefault("crawler.flaresolverr_url", "http://localhost:8191")
 	v.SetDefault("scheduler.gallery_sync_cron", "0 * * * *")
@@ -109,6 +145,18 @@ func Load(configPath string) (*Config, error) {
 	v.SetDefault("scheduler.resync_cron", "0 0 * * *")
 	v.SetDefault("scheduler.resync_enabled", false)
 	v.SetDefault("scheduler.resync_hours", 24)
+	v.SetDefault("scheduler.gallery_sync_policy", "skip")
+	v.SetDefault("scheduler.gallery_sync_priority", 10)
+	v.SetDefault("scheduler.torrent_sync_policy", "skip")
+	v.SetDefault("scheduler.torrent_sync_priority", 10)
+	v.SetDefault("scheduler.resync_policy", "skip")
+	v.SetDefault("scheduler.resync_priority", 0)
+	v.SetDefault("scheduler.refresh_cron", "30 * * * *")
+	v.SetDefault("scheduler.refresh_enabled", false)
+	v.SetDefault("scheduler.refresh_galleries", 2500)
+	v.SetDefault("scheduler.refresh_daily_budget", 2000)
+	v.SetDefault("scheduler.refresh_policy", "skip")
+	v.SetDefault("scheduler.refresh_priority", -10)
 	v.SetDefault("log_level", "info")
 
 	// Read config file
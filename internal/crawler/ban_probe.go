@@ -1,13 +1,18 @@
 package crawler
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 
+	"github.com/slinet/ehdb/internal/ehparse"
 	"go.uber.org/zap"
 )
 
-func probeAPITemporaryBan(client *Client, logger *zap.Logger) (string, bool) {
+// probeAPITemporaryBan asks the API whether the IP is temporarily banned,
+// through every proxy of the client. Banned proxies are taken out of
+// rotation for the ban's duration; the result is the active proxy's.
+func probeAPITemporaryBan(ctx context.Context, client *Client, logger *zap.Logger) (string, bool) {
 	if client == nil {
 		return "", false
 	}
@@ -24,9 +29,46 @@ func probeAPITemporaryBan(client *Client, logger *zap.Logger) (string, bool) {
 		return "", false
 	}
 
-	body, err := client.Post(client.apiURL(), requestBody)
+	if client.proxies == nil {
+		return probeAPIThrough(ctx, client, requestBody, -1, logger)
+	}
+
+	active := client.proxies.pick()
+	var activeReason string
+	var activeBanned bool
+	for i := 0; i < client.proxies.size(); i++ {
+		reason, banned := probeAPIThrough(ctx, client, requestBody, i, logger)
+		if !banned {
+			continue
+		}
+		duration, _ := parseIPBanDuration(reason)
+		client.proxies.ban(i, duration)
+		if logger != nil {
+			logger.Warn("API ban probe found proxy banned",
+				zap.String("proxy", client.proxies.name(i)),
+				zap.Duration("ban", duration),
+			)
+		}
+		if i == active {
+			activeReason, activeBanned = reason, true
+		}
+	}
+
+	return activeReason, activeBanned
+}
+
+// probeAPIThrough sends the probe once through proxy i, or the pool's pick
+// when i is -1, without rotating proxies or credentials
+func probeAPIThrough(ctx context.Context, client *Client, requestBody []byte, i int, logger *zap.Logger) (string, bool) {
+	bucket := client.bucket(client.apiURL(), true)
+	if err := waitRate(ctx, bucket); err != nil {
+		return "", false
+	}
+
+	probeCtx, _ := withProxyChoice(ctx, i)
+	body, err := client.post(probeCtx, client.apiURL(), requestBody)
 	if err != nil {
-		if reason, ok := extractTemporaryBanMessage(err.Error()); ok {
+		if reason, ok := ehparse.BanMessage(err.Error()); ok {
 			return reason, true
 		}
 
@@ -36,15 +78,15 @@ func probeAPITemporaryBan(client *Client, logger *zap.Logger) (string, bool) {
 		return "", false
 	}
 
-	if reason, ok := extractTemporaryBanMessage(string(body)); ok {
+	if reason, ok := ehparse.BanMessage(string(body)); ok {
 		return reason, true
 	}
 
 	return "", false
 }
 
-func enrichAbnormalReasonWithAPIProbe(reason string, client *Client, logger *zap.Logger) string {
-	apiReason, ok := probeAPITemporaryBan(client, logger)
+func enrichAbnormalReasonWithAPIProbe(ctx context.Context, reason string, client *Client, logger *zap.Logger) string {
+	apiReason, ok := probeAPITemporaryBan(ctx, client, logger)
 	if !ok {
 		return reason
 	}
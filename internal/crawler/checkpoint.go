@@ -0,0 +1,173 @@
+package crawler
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/slinet/ehdb/internal/checkpoint"
+)
+
+// Checkpoint kinds, matching the job run kinds of the crawls they belong to
+const (
+	BackfillCheckpointKind      = "backfill"
+	ResyncCheckpointKind        = "resync"
+	TorrentImportCheckpointKind = "torrent-import"
+)
+
+// checkpointSaveTimeout bounds saving a checkpoint after the run's context
+// was cancelled, so an interrupted run still records where it stopped
+const checkpointSaveTimeout = 10 * time.Second
+
+// checkpointImportBatch is how many galleries backfill, resync, refresh and
+// fetch collect before importing them and moving their checkpoint past them.
+// Smaller batches lose less work to a crash but commit and save checkpoints
+// more often.
+const checkpointImportBatch = 500
+
+// BackfillCheckpoint is the saved position of a gallery backfill. List pages
+// are crawled newest first, first in normal then in expunged mode, and the
+// checkpoint is saved after every page.
+type BackfillCheckpoint struct {
+	Host        string `json:"host"`
+	StartPosted int64  `json:"start_posted"`
+	EndPosted   int64  `json:"end_posted"`
+	StartNext   string `json:"start_next"` // Cursor both list modes start from
+	Expunged    bool   `json:"expunged"`   // List mode being crawled
+	Next        string `json:"next"`       // Cursor of the next page to fetch
+	// Pending holds galleries listed inside the window that were not
+	// checked and imported yet
+	Pending      []GalleryListItem `json:"pending,omitempty"`
+	Discovered   int               `json:"discovered"`
+	Missing      int               `json:"missing"`
+	Imported     int               `json:"imported"`
+	OldestPosted int64             `json:"oldest_posted,omitempty"`
+	MinGid       int               `json:"min_gid,omitempty"` // Gid range of the galleries already imported or found existing
+	MaxGid       int               `json:"max_gid,omitempty"`
+}
+
+func (s *BackfillCheckpoint) window() backfillWindow {
+	return backfillWindow{
+		startPosted: s.StartPosted,
+		endPosted:   s.EndPosted,
+		startNext:   s.StartNext,
+	}
+}
+
+// addPage records a listed page: items inside the window not seen earlier in
+// the run become pending and next becomes the resume cursor. It returns how
+// many items were new.
+func (s *BackfillCheckpoint) addPage(items []GalleryListItem, next string, seen map[string]struct{}, parsePosted func(string) (int64, error)) int {
+	added := 0
+	for _, item := range items {
+		if _, ok := seen[item.Gid]; ok {
+			continue
+		}
+		seen[item.Gid] = struct{}{}
+		s.Pending = append(s.Pending, item)
+		added++
+
+		if posted, err := parsePosted(item.Posted); err == nil && (s.OldestPosted == 0 || posted < s.OldestPosted) {
+			s.OldestPosted = posted
+		}
+	}
+
+	s.Discovered += added
+	s.Next = next
+	return added
+}
+
+// markProcessed moves the pending galleries into the processed gid range
+func (s *BackfillCheckpoint) markProcessed() {
+	for _, item := range s.Pending {
+		gid, err := strconv.Atoi(item.Gid)
+		if err != nil {
+			continue
+		}
+		if s.MinGid == 0 || gid < s.MinGid {
+			s.MinGid = gid
+		}
+		if gid > s.MaxGid {
+			s.MaxGid = gid
+		}
+	}
+	s.Pending = nil
+}
+
+// resumePosted returns the posted time before which the window still has to
+// be crawled: the newest pending gallery if some were not imported, else the
+// oldest gallery listed so far
+func (s *BackfillCheckpoint) resumePosted(parsePosted func(string) (int64, error)) int64 {
+	newestPending := int64(0)
+	for _, item := range s.Pending {
+		posted, err := parsePosted(item.Posted)
+		if err != nil {
+			continue
+		}
+		if posted > newestPending {
+			newestPending = posted
+		}
+	}
+
+	if newestPending > 0 {
+		return newestPending
+	}
+	return s.OldestPosted
+}
+
+// ResyncCheckpoint is the saved position of a resync. Galleries are resynced
+// in gid order and the checkpoint moves after every imported batch.
+type ResyncCheckpoint struct {
+	Hours   int   `json:"hours"`
+	Since   int64 `json:"since"`    // Posted threshold the run started with
+	LastGid int   `json:"last_gid"` // Galleries up to this gid are resynced
+	Done    int   `json:"done"`
+}
+
+// TorrentImportCheckpoint is the saved position of a torrent import.
+// Galleries are processed in gid order and the checkpoint moves after every
+// gallery.
+type TorrentImportCheckpoint struct {
+	Host        string `json:"host"`
+	Start       int64  `json:"start,omitempty"` // Posted window, zero for all galleries
+	End         int64  `json:"end,omitempty"`
+	LastGid     int    `json:"last_gid"` // Galleries up to this gid are processed
+	Processed   int    `json:"processed"`
+	Succeeded   int    `json:"succeeded"`
+	NewTorrents int    `json:"new_torrents"`
+}
+
+// LoadBackfillCheckpoint returns the checkpoint of the last unfinished
+// backfill, or an error wrapping checkpoint.ErrNotFound
+func LoadBackfillCheckpoint(ctx context.Context) (*BackfillCheckpoint, time.Time, error) {
+	return loadCheckpoint[BackfillCheckpoint](ctx, BackfillCheckpointKind)
+}
+
+// LoadResyncCheckpoint returns the checkpoint of the last unfinished resync,
+// or an error wrapping checkpoint.ErrNotFound
+func LoadResyncCheckpoint(ctx context.Context) (*ResyncCheckpoint, time.Time, error) {
+	return loadCheckpoint[ResyncCheckpoint](ctx, ResyncCheckpointKind)
+}
+
+// LoadTorrentImportCheckpoint returns the checkpoint of the last unfinished
+// torrent import, or an error wrapping checkpoint.ErrNotFound
+func LoadTorrentImportCheckpoint(ctx context.Context) (*TorrentImportCheckpoint, time.Time, error) {
+	return loadCheckpoint[TorrentImportCheckpoint](ctx, TorrentImportCheckpointKind)
+}
+
+func loadCheckpoint[T any](ctx context.Context, kind string) (*T, time.Time, error) {
+	var state T
+	savedAt, err := checkpoint.Load(ctx, kind, &state)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return &state, savedAt, nil
+}
+
+// saveCheckpoint saves state even if ctx was cancelled, so the position
+// reached by an interrupted run is kept
+func saveCheckpoint(ctx context.Context, kind string, state interface{}) error {
+	saveCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), checkpointSaveTimeout)
+	defer cancel()
+	return checkpoint.Save(saveCtx, kind, state)
+}
@@ -0,0 +1,55 @@
+package crawler
+
+import (
+	"testing"
+	"time"
+)
+
+func parseTestPosted(posted string) (int64, error) {
+	t, err := time.Parse("2006-01-02 15:04", posted)
+	if err != nil {
+		return 0, err
+	}
+	return t.Unix(), nil
+}
+
+func TestBackfillCheckpointTracksPagesAndProcessedRange(t *testing.T) {
+	state := &BackfillCheckpoint{}
+	seen := map[string]struct{}{}
+
+	added := state.addPage([]GalleryListItem{
+		{Gid: "3865624", Posted: "2026-03-30 12:00"},
+		{Gid: "3865500", Posted: "2026-03-30 10:00"},
+	}, "3865500", seen, parseTestPosted)
+	if added != 2 || state.Next != "3865500" || len(state.Pending) != 2 {
+		t.Fatalf("expected 2 pending items and cursor 3865500, got %d added, %+v", added, state)
+	}
+
+	// The expunged listing repeats galleries seen in normal mode
+	added = state.addPage([]GalleryListItem{
+		{Gid: "3865500", Posted: "2026-03-30 10:00"},
+		{Gid: "3865400", Posted: "2026-03-30 08:00"},
+	}, "3865400", seen, parseTestPosted)
+	if added != 1 || state.Discovered != 3 {
+		t.Fatalf("expected 1 new item and 3 discovered, got %d added, %+v", added, state)
+	}
+
+	oldest, _ := parseTestPosted("2026-03-30 08:00")
+	newest, _ := parseTestPosted("2026-03-30 12:00")
+	if state.OldestPosted != oldest {
+		t.Fatalf("expected oldest posted %d, got %d", oldest, state.OldestPosted)
+	}
+
+	// Until pending galleries are imported the window left reaches the newest of them
+	if got := state.resumePosted(parseTestPosted); got != newest {
+		t.Fatalf("expected resume posted %d, got %d", newest, got)
+	}
+
+	state.markProcessed()
+	if len(state.Pending) != 0 || state.MinGid != 3865400 || state.MaxGid != 3865624 {
+		t.Fatalf("expected processed range 3865400-3865624 and nothing pending, got %+v", state)
+	}
+	if got := state.resumePosted(parseTestPosted); got != oldest {
+		t.Fatalf("expected resume posted %d, got %d", oldest, got)
+	}
+}
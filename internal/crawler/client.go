@@ -3,12 +3,9 @@ package crawler
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
 	"fmt"
 	"io"
-	"net"
 	"net/http"
-	"net/url"
 	"sort"
 	"strconv"
 	"strings"
@@ -16,7 +13,8 @@ import (
 	"time"
 
 	"github.com/slinet/ehdb/internal/config"
-	"golang.org/x/net/proxy"
+	"github.com/slinet/ehdb/internal/ehparse"
+	"go.uber.org/zap"
 )
 
 // Client is an HTTP client with proxy and cookie support
@@ -28,66 +26,84 @@ type Client struct {
 	flareSolverrEnabled bool
 	flareSolverrURL     string
 
+	// Record or replay fixtures instead of plain requests; nil when disabled
+	fixtureMode string
+	fixtures    *fixtureStore
+
+	logger *zap.Logger
+
 	mu      sync.RWMutex
 	cookies map[string]string
+	// Credentials to rotate through; nil for a client with a single cookie
+	// set, as built by tests
+	pool *cookiePool
+	// Proxies requests go through; nil when httpClient is used as is
+	proxies *proxyPool
+
+	// Base spacing of page and API requests per host, see limiterFor; zero
+	// for no limit
+	pageDelay time.Duration
+	apiDelay  time.Duration
 }
 
 // NewClient creates a new crawler client
-func NewClient(cfg *config.CrawlerConfig) (*Client, error) {
-	cookiesPath := resolveCookiesFilePath(cfg.ConfigDir)
-
-	fileCookies, err := loadCookiesFromFile(cookiesPath)
+func NewClient(cfg *config.CrawlerConfig, logger *zap.Logger) (*Client, error) {
+	pool, err := newCookiePool(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("load cookies: %w", err)
+		return nil, err
 	}
 
 	client := &Client{
 		host:                cfg.Host,
-		cookiesPath:         cookiesPath,
-		cookies:             mergeCookies(parseCookieHeader(cfg.Cookies), fileCookies),
+		cookiesPath:         pool.current().path,
+		cookies:             pool.current().cookies,
+		pool:                pool,
+		logger:              logger,
+		pageDelay:           time.Duration(cfg.PageDelaySeconds) * time.Second,
+		apiDelay:            time.Duration(cfg.APIDelaySeconds) * time.Second,
 		flareSolverrEnabled: cfg.FlareSolverrEnabled,
 		flareSolverrURL:     cfg.FlareSolverrURL,
 	}
 
-	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: false,
-		},
-		MaxIdleConns:        100,
-		MaxIdleConnsPerHost: 10,
-		IdleConnTimeout:     90 * time.Second,
-	}
-
-	// Setup proxy if configured
-	if cfg.Proxy != "" {
-		proxyURL, err := url.Parse(cfg.Proxy)
+	if cfg.FixtureMode != "" {
+		client.fixtures, err = newFixtureStore(cfg.FixtureMode, cfg.FixtureDir)
 		if err != nil {
-			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+			return nil, err
 		}
+		client.fixtureMode = cfg.FixtureMode
+	}
 
-		if proxyURL.Scheme == "socks5" {
-			// SOCKS5 proxy
-			dialer, err := proxy.SOCKS5("tcp", proxyURL.Host, nil, proxy.Direct)
-			if err != nil {
-				return nil, fmt.Errorf("failed to create SOCKS5 dialer: %w", err)
-			}
-			transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
-				return dialer.Dial(network, address)
-			}
-		} else {
-			// HTTP/HTTPS proxy
-			transport.Proxy = http.ProxyURL(proxyURL)
-		}
+	// Proxy comes first, then the Proxies list; neither means direct
+	var proxyURLs []string
+	if cfg.Proxy != "" {
+		proxyURLs = append(proxyURLs, cfg.Proxy)
+	}
+	proxyURLs = append(proxyURLs, cfg.Proxies...)
+	client.proxies, err = newProxyPool(proxyURLs)
+	if err != nil {
+		return nil, err
 	}
 
 	client.httpClient = &http.Client{
-		Transport: transport,
+		Transport: client.fixtureTransport(&proxyTransport{pool: client.proxies}),
 		Timeout:   30 * time.Second,
 	}
 
 	return client, nil
 }
 
+// fixtureTransport wraps next to record fixtures, or replaces it to replay
+// them, when a fixture mode is set
+func (c *Client) fixtureTransport(next http.RoundTripper) http.RoundTripper {
+	if c.fixtures == nil {
+		return next
+	}
+	if c.fixtureMode == FixtureModeReplay {
+		return &replayTransport{store: c.fixtures}
+	}
+	return &recordTransport{next: next, store: c.fixtures}
+}
+
 func parseCookieHeader(raw string) map[string]string {
 	cookies := make(map[string]string)
 
@@ -152,6 +168,65 @@ func (c *Client) cookieValue(name string) (string, bool) {
 	return value, ok
 }
 
+// Credentials returns the health of each credential the client rotates
+// through, in configured order
+func (c *Client) Credentials() []CredentialStatus {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.pool == nil {
+		return nil
+	}
+	return c.pool.status()
+}
+
+// rotateCredential checks a response for an auth failure or temporary ban.
+// On one it quarantines the active credential and switches to the next
+// available, reporting whether the request should be sent again with it.
+func (c *Client) rotateCredential(body []byte, err error) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.pool == nil {
+		return false
+	}
+	reason, wait, failed := credentialFailure(body, err)
+	if !failed {
+		if err == nil {
+			c.pool.recordSuccess()
+		}
+		return false
+	}
+
+	// Keep the active credential's refreshed cookies before swapping
+	from := c.pool.current()
+	from.cookies = c.cookies
+	next, ok := c.pool.quarantine(reason, wait)
+
+	logger := c.logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	if !ok {
+		logger.Warn("credential quarantined, no other credential available",
+			zap.String("credential", from.name),
+			zap.Time("until", from.quarantinedUntil),
+			zap.String("reason", reason),
+		)
+		return false
+	}
+
+	logger.Warn("credential quarantined, rotating",
+		zap.String("credential", from.name),
+		zap.String("next", next.name),
+		zap.Time("until", from.quarantinedUntil),
+		zap.String("reason", reason),
+	)
+	c.cookies = next.cookies
+	c.cookiesPath = next.path
+	return true
+}
+
 func (c *Client) cookiesWithResponse(resp *http.Response) map[string]string {
 	c.mu.RLock()
 	cookies := normalizeCookies(c.cookies)
@@ -160,6 +235,9 @@ func (c *Client) cookiesWithResponse(resp *http.Response) map[string]string {
 	if resp == nil {
 		return cookies
 	}
+	if cookies == nil {
+		cookies = make(map[string]string)
+	}
 
 	for _, cookie := range resp.Cookies() {
 		if cookie.Name == "" {
@@ -219,6 +297,11 @@ func (c *Client) updateCookies(resp *http.Response) error {
 	cookiesPath := c.cookiesPath
 	c.mu.Unlock()
 
+	// Replayed cookies are scrubbed and must not replace the real ones
+	if c.fixtureMode == FixtureModeReplay {
+		return nil
+	}
+
 	if !changed {
 		return c.persistCookiesSnapshotIfMissing()
 	}
@@ -235,6 +318,10 @@ func (c *Client) updateCookies(resp *http.Response) error {
 }
 
 func (c *Client) persistCookiesSnapshotIfMissing() error {
+	if c.fixtureMode == FixtureModeReplay {
+		return nil
+	}
+
 	c.mu.RLock()
 	snapshot := normalizeCookies(c.cookies)
 	cookiesPath := c.cookiesPath
@@ -365,9 +452,30 @@ func (c *Client) apiURL() string {
 	}
 }
 
-// Get performs a GET request
-func (c *Client) Get(url string) ([]byte, error) {
-	req, err := http.NewRequest("GET", url, nil)
+// Get performs a GET request once the host's page rate limit allows it.
+// Cancelling ctx aborts the request or the wait. A temporary
+// ban sends the request again through another proxy while one is not
+// banned; after that, an auth failure or ban quarantines the active
+// credential and sends it again with the next one, until none is left.
+func (c *Client) Get(ctx context.Context, url string) ([]byte, error) {
+	for {
+		bucket := c.bucket(url, false)
+		if err := waitRate(ctx, bucket); err != nil {
+			return nil, err
+		}
+
+		reqCtx, choice := withProxyChoice(ctx, -1)
+		body, err := c.get(reqCtx, url)
+		c.adaptRate(bucket, url, body, err)
+		if c.rotateProxy(choice, body, err) || c.rotateCredential(body, err) {
+			continue
+		}
+		return body, err
+	}
+}
+
+func (c *Client) get(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("create request: %w", err)
 	}
@@ -404,11 +512,11 @@ func (c *Client) Get(url string) ([]byte, error) {
 
 	// If FlareSolverr is configured and the response looks like a Cloudflare challenge, retry through it
 	if c.flareSolverrEnabled && c.flareSolverrURL != "" {
-		if _, isCF := suspectedAbnormalWebPageReason(body); isCF {
+		if _, isCF := ehparse.ErrorPageReason(body); isCF {
 			if err := c.updateCookies(resp); err != nil {
 				return nil, err
 			}
-			return c.flareSolverrGet(url, c.flareSolverrURL)
+			return c.flareSolverrGet(ctx, url, c.flareSolverrURL)
 		}
 	}
 
@@ -423,9 +531,64 @@ func (c *Client) Get(url string) ([]byte, error) {
 	return body, nil
 }
 
-// Post performs a POST request with JSON body
-func (c *Client) Post(url string, jsonData []byte) ([]byte, error) {
-	req, err := http.NewRequest("POST", url, bytes.NewReader(jsonData))
+// Post performs a POST request with JSON body once the host's API rate limit
+// allows it. Cancelling ctx aborts the request or the wait. Proxies and
+// credentials rotate as in Get.
+func (c *Client) Post(ctx context.Context, url string, jsonData []byte) ([]byte, error) {
+	for {
+		bucket := c.bucket(url, true)
+		if err := waitRate(ctx, bucket); err != nil {
+			return nil, err
+		}
+
+		reqCtx, choice := withProxyChoice(ctx, -1)
+		body, err := c.post(reqCtx, url, jsonData)
+		c.adaptRate(bucket, url, body, err)
+		if c.rotateProxy(choice, body, err) || c.rotateCredential(body, err) {
+			continue
+		}
+		return body, err
+	}
+}
+
+// Proxies returns the health of each proxy the client sends requests
+// through, in configured order, or the direct connection without proxies
+func (c *Client) Proxies() []ProxyStatus {
+	if c.proxies == nil {
+		return nil
+	}
+	return c.proxies.status()
+}
+
+// rotateProxy bans the proxy a response came through when it is a
+// temporary ban page, for the ban's duration, and reports whether the
+// request should be sent again through another proxy
+func (c *Client) rotateProxy(choice *proxyChoice, body []byte, err error) bool {
+	if c.proxies == nil || choice.used < 0 {
+		return false
+	}
+	banErr := bannedResponse(body, err)
+	if banErr == nil {
+		return false
+	}
+
+	duration, _ := parseIPBanDuration(banErr.Error())
+	available := c.proxies.ban(choice.used, duration)
+
+	logger := c.logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	logger.Warn("proxy temporarily banned",
+		zap.String("proxy", c.proxies.name(choice.used)),
+		zap.Duration("ban", duration),
+		zap.Bool("rotating", available),
+	)
+	return available
+}
+
+func (c *Client) post(ctx context.Context, url string, jsonData []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("create request: %w", err)
 	}
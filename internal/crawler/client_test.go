@@ -1,6 +1,7 @@
 package crawler
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
@@ -12,6 +13,7 @@ import (
 	"testing"
 
 	"github.com/slinet/ehdb/internal/config"
+	"go.uber.org/zap"
 )
 
 func TestClientValidateResponseRejectsBlankExHentaiPageWithMysteryIgneous(t *testing.T) {
@@ -125,7 +127,7 @@ func TestNewClientPrefersCookiesFileOverConfig(t *testing.T) {
 		Host:      "exhentai.org",
 		Cookies:   "igneous=stale; ipb_member_id=1; ipb_pass_hash=hash",
 		ConfigDir: filepath.Dir(cookiesFilePath),
-	})
+	}, zap.NewNop())
 	if err != nil {
 		t.Fatalf("new client: %v", err)
 	}
@@ -302,7 +304,7 @@ func TestClientGetRejectsEmptyExHentaiShellAndDoesNotPersistMysteryCookie(t *tes
 		cookies:    parseCookieHeader("igneous=fresh; ipb_member_id=1; ipb_pass_hash=hash"),
 	}
 
-	_, err := client.Get(server.URL)
+	_, err := client.Get(context.Background(), server.URL)
 	if err == nil {
 		t.Fatal("expected auth error, got nil")
 	}
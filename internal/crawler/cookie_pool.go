@@ -0,0 +1,196 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/slinet/ehdb/internal/config"
+	"github.com/slinet/ehdb/internal/ehparse"
+)
+
+// defaultCredentialName names the credential made of crawler.cookies and
+// cookies.json, which existing setups use
+const defaultCredentialName = "default"
+
+// defaultCredentialCooldown is how long a credential sits out after an auth
+// failure when no cooldown is configured
+const defaultCredentialCooldown = 30 * time.Minute
+
+var credentialNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// CredentialStatus is the health of one credential of a client's pool
+type CredentialStatus struct {
+	Name             string
+	Active           bool
+	QuarantinedUntil time.Time // Zero when not quarantined
+	Successes        int
+	Failures         int
+	LastError        string
+}
+
+// credential is one named cookie set with its own cookies file
+type credential struct {
+	name             string
+	cookies          map[string]string
+	path             string
+	quarantinedUntil time.Time
+	successes        int
+	failures         int
+	lastError        string
+}
+
+// cookiePool holds the credentials of a client. The active credential's
+// cookies and path are mirrored in Client.cookies and Client.cookiesPath, so
+// the cookie refresh and persistence code works on whichever is active; the
+// pool only swaps them. Callers hold Client.mu.
+type cookiePool struct {
+	credentials []*credential
+	active      int
+	cooldown    time.Duration
+	now         func() time.Time
+}
+
+// newCookiePool loads the credentials of cfg. crawler.cookies and the
+// cookies.json file form the "default" credential, which is the only one
+// when crawler.credentials is empty; each named credential keeps its cookies
+// in cookies.<name>.json next to it.
+func newCookiePool(cfg *config.CrawlerConfig) (*cookiePool, error) {
+	defaultPath := resolveCookiesFilePath(cfg.ConfigDir)
+	fileCookies, err := loadCookiesFromFile(defaultPath)
+	if err != nil {
+		return nil, fmt.Errorf("load cookies: %w", err)
+	}
+
+	pool := &cookiePool{
+		cooldown: time.Duration(cfg.CredentialCooldownMinutes) * time.Minute,
+		now:      time.Now,
+	}
+	if pool.cooldown <= 0 {
+		pool.cooldown = defaultCredentialCooldown
+	}
+
+	defaultCookies := mergeCookies(parseCookieHeader(cfg.Cookies), fileCookies)
+	if len(cfg.Credentials) == 0 || len(defaultCookies) > 0 {
+		pool.credentials = append(pool.credentials, &credential{
+			name:    defaultCredentialName,
+			cookies: defaultCookies,
+			path:    defaultPath,
+		})
+	}
+
+	seen := map[string]bool{defaultCredentialName: true}
+	for _, cred := range cfg.Credentials {
+		if !credentialNamePattern.MatchString(cred.Name) {
+			return nil, fmt.Errorf("invalid credential name %q: use letters, digits, - and _", cred.Name)
+		}
+		if seen[cred.Name] {
+			return nil, fmt.Errorf("duplicate credential name %q", cred.Name)
+		}
+		seen[cred.Name] = true
+
+		path := credentialCookiesPath(defaultPath, cred.Name)
+		fileCookies, err := loadCookiesFromFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("load cookies of credential %s: %w", cred.Name, err)
+		}
+		pool.credentials = append(pool.credentials, &credential{
+			name:    cred.Name,
+			cookies: mergeCookies(parseCookieHeader(cred.Cookies), fileCookies),
+			path:    path,
+		})
+	}
+
+	return pool, nil
+}
+
+// credentialCookiesPath returns the cookies file of a named credential:
+// cookies.json becomes cookies.<name>.json in the same directory
+func credentialCookiesPath(defaultPath, name string) string {
+	ext := filepath.Ext(defaultPath)
+	return strings.TrimSuffix(defaultPath, ext) + "." + name + ext
+}
+
+func (p *cookiePool) current() *credential {
+	return p.credentials[p.active]
+}
+
+// quarantine takes the active credential out of rotation for at least the
+// pool cooldown, or wait if longer, and activates the next available one.
+// It reports false, keeping the active credential, when none is available.
+func (p *cookiePool) quarantine(reason string, wait time.Duration) (*credential, bool) {
+	if wait < p.cooldown {
+		wait = p.cooldown
+	}
+
+	cur := p.current()
+	cur.failures++
+	cur.lastError = reason
+	cur.quarantinedUntil = p.now().Add(wait)
+
+	for i := 1; i < len(p.credentials); i++ {
+		next := (p.active + i) % len(p.credentials)
+		if !p.credentials[next].quarantinedUntil.After(p.now()) {
+			p.active = next
+			return p.credentials[next], true
+		}
+	}
+	return nil, false
+}
+
+func (p *cookiePool) recordSuccess() {
+	p.current().successes++
+}
+
+func (p *cookiePool) status() []CredentialStatus {
+	statuses := make([]CredentialStatus, 0, len(p.credentials))
+	for i, cred := range p.credentials {
+		status := CredentialStatus{
+			Name:      cred.name,
+			Active:    i == p.active,
+			Successes: cred.successes,
+			Failures:  cred.failures,
+			LastError: cred.lastError,
+		}
+		if cred.quarantinedUntil.After(p.now()) {
+			status.QuarantinedUntil = cred.quarantinedUntil
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// credentialFailure reports whether a response should take the active
+// credential out of rotation: an auth failure, or a temporary ban page, with
+// the ban's remaining time as the minimum quarantine
+func credentialFailure(body []byte, err error) (string, time.Duration, bool) {
+	if errors.Is(err, ErrAuthRequired) {
+		return err.Error(), 0, true
+	}
+
+	banErr := bannedResponse(body, err)
+	if banErr == nil {
+		return "", 0, false
+	}
+	wait, _ := parseIPBanDuration(banErr.Error())
+	return banErr.Error(), wait, true
+}
+
+// bannedResponse returns the temporary IP ban a response reports, either as
+// its error or as a ban page, or nil
+func bannedResponse(body []byte, err error) error {
+	if err != nil {
+		if isTemporaryBanError(err) {
+			return err
+		}
+		return nil
+	}
+
+	if message, ok := ehparse.BanMessage(string(body)); ok {
+		return errors.New(message)
+	}
+	return nil
+}
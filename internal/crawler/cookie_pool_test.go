@@ -0,0 +1,214 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slinet/ehdb/internal/config"
+	"go.uber.org/zap"
+)
+
+// newPoolClient returns a client with the given credentials whose cookie
+// files live in a temporary directory
+func newPoolClient(t *testing.T, credentials ...config.CredentialConfig) *Client {
+	t.Helper()
+	setCookiesFilePathForTest(t, filepath.Join(t.TempDir(), "cookies.json"))
+
+	client, err := NewClient(&config.CrawlerConfig{
+		Host:        "e-hentai.org",
+		ConfigDir:   filepath.Dir(cookiesFilePath),
+		Credentials: credentials,
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	return client
+}
+
+// memberServer answers with failure for requests from member ids in failing
+// and a normal page for the rest, recording the member id of each request
+func memberServer(t *testing.T, failure func(w http.ResponseWriter), failing ...string) (*httptest.Server, *[]string) {
+	t.Helper()
+	var members []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		member := ""
+		if cookie, err := r.Cookie("ipb_member_id"); err == nil {
+			member = cookie.Value
+		}
+		members = append(members, member)
+		for _, m := range failing {
+			if m == member {
+				failure(w)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("<html><body>ok</body></html>"))
+	}))
+	t.Cleanup(server.Close)
+	return server, &members
+}
+
+func forbidden(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusForbidden)
+}
+
+func banned(w http.ResponseWriter) {
+	_, _ = w.Write([]byte("Your IP address has been temporarily banned for excessive pageloads which indicates that you are using automated mirroring/harvesting software. (The ban expires in 1 hour and 30 minutes)"))
+}
+
+func TestClientRotatesCredentialOnAuthFailure(t *testing.T) {
+	server, members := memberServer(t, forbidden, "1")
+	client := newPoolClient(t,
+		config.CredentialConfig{Name: "first", Cookies: "ipb_member_id=1; ipb_pass_hash=a"},
+		config.CredentialConfig{Name: "second", Cookies: "ipb_member_id=2; ipb_pass_hash=b"},
+	)
+
+	if _, err := client.Get(context.Background(), server.URL); err != nil {
+		t.Fatalf("expected the second credential to succeed, got %v", err)
+	}
+	if strings.Join(*members, ",") != "1,2" {
+		t.Fatalf("expected requests from members 1 then 2, got %v", *members)
+	}
+
+	statuses := client.Credentials()
+	if len(statuses) != 2 {
+		t.Fatalf("expected 2 credentials, got %#v", statuses)
+	}
+	first, second := statuses[0], statuses[1]
+	if first.Active || first.Failures != 1 || first.QuarantinedUntil.Before(time.Now().Add(29*time.Minute)) {
+		t.Fatalf("expected first credential quarantined for the default cooldown, got %#v", first)
+	}
+	if !second.Active || second.Successes != 1 || !second.QuarantinedUntil.IsZero() {
+		t.Fatalf("expected second credential active and healthy, got %#v", second)
+	}
+
+	// The quarantined credential is not used again
+	if _, err := client.Get(context.Background(), server.URL); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if (*members)[len(*members)-1] != "2" {
+		t.Fatalf("expected the second credential to stay active, got %v", *members)
+	}
+}
+
+func TestClientQuarantinesBannedCredentialForBan(t *testing.T) {
+	server, _ := memberServer(t, banned, "1")
+	client := newPoolClient(t,
+		config.CredentialConfig{Name: "first", Cookies: "ipb_member_id=1"},
+		config.CredentialConfig{Name: "second", Cookies: "ipb_member_id=2"},
+	)
+
+	body, err := client.Get(context.Background(), server.URL)
+	if err != nil || !strings.Contains(string(body), "ok") {
+		t.Fatalf("expected the second credential's page, got %q, %v", body, err)
+	}
+
+	first := client.Credentials()[0]
+	if first.QuarantinedUntil.Before(time.Now().Add(89 * time.Minute)) {
+		t.Fatalf("expected quarantine to last the ban, got %v", first.QuarantinedUntil)
+	}
+	if !strings.Contains(first.LastError, "ban expires in 1 hour and 30 minutes") {
+		t.Fatalf("expected the ban message as last error, got %q", first.LastError)
+	}
+}
+
+func TestClientReturnsErrorWhenAllCredentialsFail(t *testing.T) {
+	server, members := memberServer(t, forbidden, "1", "2")
+	client := newPoolClient(t,
+		config.CredentialConfig{Name: "first", Cookies: "ipb_member_id=1"},
+		config.CredentialConfig{Name: "second", Cookies: "ipb_member_id=2"},
+	)
+
+	if _, err := client.Get(context.Background(), server.URL); !errors.Is(err, ErrAuthRequired) {
+		t.Fatalf("expected %v, got %v", ErrAuthRequired, err)
+	}
+	if len(*members) != 2 {
+		t.Fatalf("expected one request per credential, got %v", *members)
+	}
+	for _, status := range client.Credentials() {
+		if status.Failures != 1 || status.QuarantinedUntil.IsZero() {
+			t.Fatalf("expected every credential quarantined, got %#v", status)
+		}
+	}
+}
+
+func TestClientPersistsCookiesPerCredential(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cookie, _ := r.Cookie("ipb_member_id"); cookie != nil && cookie.Value == "1" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "igneous", Value: "fresh"})
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client := newPoolClient(t,
+		config.CredentialConfig{Name: "first", Cookies: "ipb_member_id=1"},
+		config.CredentialConfig{Name: "second", Cookies: "ipb_member_id=2"},
+	)
+	if _, err := client.Get(context.Background(), server.URL); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	dir := filepath.Dir(cookiesFilePath)
+	second, err := loadCookiesFromFile(filepath.Join(dir, "cookies.second.json"))
+	if err != nil {
+		t.Fatalf("load cookies: %v", err)
+	}
+	if second["ipb_member_id"] != "2" || second["igneous"] != "fresh" {
+		t.Fatalf("expected the second credential's refreshed cookies, got %v", second)
+	}
+	for _, name := range []string{"cookies.json", "cookies.first.json"} {
+		if exists, _ := cookiesFileExists(filepath.Join(dir, name)); exists {
+			t.Fatalf("expected no %s, as no other credential got cookies", name)
+		}
+	}
+
+	// A new client picks the persisted cookies up
+	reloaded := newPoolClientInDir(t, dir,
+		config.CredentialConfig{Name: "first", Cookies: "ipb_member_id=1"},
+		config.CredentialConfig{Name: "second", Cookies: "ipb_member_id=2"},
+	)
+	if igneous := reloaded.pool.credentials[1].cookies["igneous"]; igneous != "fresh" {
+		t.Fatalf("expected persisted igneous, got %q", igneous)
+	}
+}
+
+func TestNewClientRejectsBadCredentialNames(t *testing.T) {
+	tests := []struct {
+		name        string
+		credentials []config.CredentialConfig
+	}{
+		{name: "empty", credentials: []config.CredentialConfig{{Cookies: "ipb_member_id=1"}}},
+		{name: "path", credentials: []config.CredentialConfig{{Name: "../other"}}},
+		{name: "duplicate", credentials: []config.CredentialConfig{{Name: "a"}, {Name: "a"}}},
+		{name: "default", credentials: []config.CredentialConfig{{Name: "default"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setCookiesFilePathForTest(t, filepath.Join(t.TempDir(), "cookies.json"))
+			_, err := NewClient(&config.CrawlerConfig{ConfigDir: filepath.Dir(cookiesFilePath), Credentials: tt.credentials}, zap.NewNop())
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func newPoolClientInDir(t *testing.T, dir string, credentials ...config.CredentialConfig) *Client {
+	t.Helper()
+	client, err := NewClient(&config.CrawlerConfig{ConfigDir: dir, Credentials: credentials}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	return client
+}
@@ -0,0 +1,217 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/slinet/ehdb/internal/config"
+	"github.com/slinet/ehdb/internal/database"
+	"github.com/slinet/ehdb/internal/ehparse"
+	"github.com/slinet/ehdb/internal/jobrun"
+	"github.com/slinet/ehdb/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// GetDetail fetches the page of a gallery for the fields the gdata API does
+// not return. Removed galleries and wrong tokens are reported through the
+// detail's Unavailable and InvalidKey flags rather than as errors.
+func (c *GalleryCrawler) GetDetail(ctx context.Context, gid int, token string) (*ehparse.GalleryDetail, error) {
+	// nw=always skips the content warning shown instead of some galleries;
+	// hc=1 shows the comments below the viewing threshold
+	url := fmt.Sprintf("https://%s/g/%d/%s/?hc=1&nw=always", c.cfg.Host, gid, token)
+
+	body, err := c.client.Get(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	detail, err := ehparse.ParseGalleryDetail(body)
+	if err == nil {
+		return detail, nil
+	}
+
+	if reason, ok := ehparse.ErrorPageReason(body); ok {
+		reason = enrichAbnormalReasonWithAPIProbe(ctx, reason, c.client, c.logger)
+		return nil, fmt.Errorf("gallery page abnormal: %s: %w", reason, ErrAbnormalPage)
+	}
+
+	return nil, fmt.Errorf("gallery page unparseable: %w", err)
+}
+
+// detailScraper stores the gallery page fields of imported galleries, and
+// their comments when enabled, one page per gallery with the page delay
+// between them
+type detailScraper struct {
+	crawler  *GalleryCrawler
+	comments bool
+	logger   *zap.Logger
+}
+
+// newDetailScraper returns a scraper when cfg enables details or comments,
+// nil otherwise. Details come with the same page, so they are stored either
+// way.
+func newDetailScraper(cfg *config.CrawlerConfig, crawler *GalleryCrawler, logger *zap.Logger) *detailScraper {
+	if !cfg.FetchDetails && !cfg.FetchComments {
+		return nil
+	}
+	return &detailScraper{crawler: crawler, comments: cfg.FetchComments, logger: logger}
+}
+
+// Scrape fetches and stores the details of each gallery in metadataList,
+// skipping entries the API returned an error for. A gallery whose page
+// cannot be fetched is logged and skipped; only an auth failure or ctx
+// ending stops the run.
+func (s *detailScraper) Scrape(ctx context.Context, metadataList []database.GalleryMetadata) error {
+	for _, metadata := range metadataList {
+		if metadata.Error != "" {
+			continue
+		}
+		if err := jobrun.Yield(ctx); err != nil {
+			return err
+		}
+
+		detail, err := Retry(RetryConfig{
+			Context:             ctx,
+			MaxRetries:          s.crawler.retryTimes,
+			Logger:              s.logger,
+			TransientRetryTimes: s.crawler.cfg.TransientRetryTimes,
+			WaitForIPUnban:      s.crawler.cfg.WaitForIPUnban,
+		}, func() (*ehparse.GalleryDetail, error) {
+			return s.crawler.GetDetail(ctx, metadata.Gid, metadata.Token)
+		})
+
+		switch {
+		case err != nil:
+			if errors.Is(err, ErrAuthRequired) {
+				return fmt.Errorf("auth failed while fetching details of gallery %d: %w", metadata.Gid, err)
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			s.logger.Error("failed to fetch gallery details", zap.Int("gid", metadata.Gid), zap.Error(err))
+		case detail.Unavailable || detail.InvalidKey:
+			s.logger.Warn("gallery page unavailable, skipping details",
+				zap.Int("gid", metadata.Gid),
+				zap.Bool("invalid_key", detail.InvalidKey),
+			)
+		default:
+			if err := s.saveDetail(ctx, metadata.Gid, detail); err != nil {
+				s.logger.Error("failed to save gallery details", zap.Int("gid", metadata.Gid), zap.Error(err))
+			} else {
+				jobrun.Add(ctx, jobrun.DetailsUpdated, 1)
+			}
+			if s.comments {
+				if err := s.saveComments(ctx, metadata.Gid, detail.Comments); err != nil {
+					s.logger.Error("failed to save gallery comments", zap.Int("gid", metadata.Gid), zap.Error(err))
+				} else {
+					jobrun.Add(ctx, jobrun.CommentsSaved, int64(len(detail.Comments)))
+				}
+			}
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// saveDetail stores the details of a gallery. The page's language replaces
+// the one derived from tags, except for "N/A" which keeps it.
+func (s *detailScraper) saveDetail(ctx context.Context, gid int, detail *ehparse.GalleryDetail) error {
+	pool := database.GetPool()
+
+	var parentGid *int
+	if detail.ParentGid != 0 {
+		parentGid = &detail.ParentGid
+	}
+	newerGids := make([]int, 0, len(detail.Newer))
+	for _, version := range detail.Newer {
+		newerGids = append(newerGids, version.Gid)
+	}
+
+	query := `
+		UPDATE gallery SET
+			favorite_count = $2,
+			rating_count = $3,
+			parent_gid = $4,
+			newer_gids = $5,
+			visible = $6,
+			hidden_reason = $7,
+			language = COALESCE($8, language),
+			translated = $9,
+			rewrite = $10,
+			detail_synced_at = NOW()
+		WHERE gid = $1
+	`
+	args := []interface{}{
+		gid,
+		detail.Favorites,
+		detail.RatingCount,
+		parentGid,
+		newerGids,
+		detail.Visible,
+		nullIfEmpty(detail.HiddenReason),
+		nullIfEmpty(detail.Language),
+		detail.Translated,
+		detail.Rewrite,
+	}
+
+	s.logger.Debug("executing update query",
+		zap.String("sql", utils.FormatSQL(query, args...)),
+	)
+
+	if _, err := pool.Exec(ctx, query, args...); err != nil {
+		return fmt.Errorf("update gallery details: %w", err)
+	}
+
+	return nil
+}
+
+// saveComments replaces the stored comments of a gallery with comments, so
+// deleted comments disappear and edits and scores are refreshed
+func (s *detailScraper) saveComments(ctx context.Context, gid int, comments []ehparse.Comment) error {
+	tx, err := database.GetPool().Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "DELETE FROM gallery_comment WHERE gid = $1", gid); err != nil {
+		return fmt.Errorf("delete comments: %w", err)
+	}
+
+	query := `
+		INSERT INTO gallery_comment (gid, comment_id, author, uploader, score, posted, edited, body)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (gid, comment_id) DO NOTHING
+	`
+	for _, comment := range comments {
+		var score *int
+		if !comment.Uploader {
+			score = &comment.Score
+		}
+		var edited *time.Time
+		if !comment.Edited.IsZero() {
+			edited = &comment.Edited
+		}
+
+		args := []interface{}{gid, comment.ID, comment.Author, comment.Uploader, score, comment.Posted, edited, comment.Body}
+		if _, err := tx.Exec(ctx, query, args...); err != nil {
+			return fmt.Errorf("insert comment %d: %w", comment.ID, err)
+		}
+	}
+
+	if _, err := tx.Exec(ctx, "UPDATE gallery SET comments_synced_at = NOW() WHERE gid = $1", gid); err != nil {
+		return fmt.Errorf("update comments sync time: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit comments: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,705 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slinet/ehdb/internal/config"
+	"github.com/slinet/ehdb/internal/database"
+	"github.com/slinet/ehdb/internal/ehmock"
+	"github.com/slinet/ehdb/internal/jobrun"
+	"go.uber.org/zap"
+)
+
+// The end-to-end tests run the crawlers against ehmock and a real database.
+// Set EHDB_E2E_CONFIG to the config file of a disposable database with the
+// schema and migrations applied to enable them. They use gids from e2eFirstGid
+// down and galleries posted in 2090, so the sync thresholds derived from the
+// database come from the test data, and delete those gids before and after
+// every test.
+const e2eFirstGid = 1900000000
+
+var e2eNewest = time.Date(2090, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// e2eDatabase connects to the end-to-end database, or skips the test
+func e2eDatabase(t testing.TB, galleries []ehmock.Gallery) {
+	t.Helper()
+
+	configPath := os.Getenv("EHDB_E2E_CONFIG")
+	if configPath == "" {
+		t.Skip("EHDB_E2E_CONFIG not set")
+	}
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if err := database.Init(&cfg.Database, zap.NewNop()); err != nil {
+		t.Fatalf("failed to initialize database: %v", err)
+	}
+	t.Cleanup(database.Close)
+
+	gids := make([]int, 0, len(galleries))
+	for _, g := range galleries {
+		gids = append(gids, g.Gid)
+	}
+	purge := func() {
+		ctx := context.Background()
+		pool := database.GetPool()
+		if _, err := pool.Exec(ctx, `DELETE FROM torrent WHERE id = ANY($1) OR gid = ANY($1)`, gids); err != nil {
+			t.Fatalf("failed to delete test torrents: %v", err)
+		}
+		if _, err := pool.Exec(ctx, `DELETE FROM gallery_comment WHERE gid = ANY($1)`, gids); err != nil {
+			t.Fatalf("failed to delete test comments: %v", err)
+		}
+		if _, err := pool.Exec(ctx, `DELETE FROM gallery WHERE gid = ANY($1)`, gids); err != nil {
+			t.Fatalf("failed to delete test galleries: %v", err)
+		}
+	}
+	purge()
+	t.Cleanup(purge)
+}
+
+// seedGalleries imports galleries through the mock API
+func seedGalleries(t *testing.T, crawler *GalleryCrawler, galleries ...ehmock.Gallery) {
+	t.Helper()
+
+	var gidlist [][2]interface{}
+	for _, g := range galleries {
+		gidlist = append(gidlist, [2]interface{}{g.Gid, g.Token})
+	}
+
+	ctx := context.Background()
+	metadata, err := crawler.GetMetadatas(ctx, gidlist)
+	if err != nil {
+		t.Fatalf("failed to fetch seed metadata: %v", err)
+	}
+	if err := NewImporter(zap.NewNop()).Import(ctx, metadata, false); err != nil {
+		t.Fatalf("failed to import seed galleries: %v", err)
+	}
+}
+
+// storedGalleries returns the expunged flag of each stored gallery by gid
+func storedGalleries(t *testing.T, galleries []ehmock.Gallery) map[int]bool {
+	t.Helper()
+
+	gids := make([]int, 0, len(galleries))
+	for _, g := range galleries {
+		gids = append(gids, g.Gid)
+	}
+
+	rows, err := database.GetPool().Query(context.Background(), `SELECT gid, expunged FROM gallery WHERE gid = ANY($1)`, gids)
+	if err != nil {
+		t.Fatalf("failed to query galleries: %v", err)
+	}
+	defer rows.Close()
+
+	stored := make(map[int]bool)
+	for rows.Next() {
+		var gid int
+		var expunged bool
+		if err := rows.Scan(&gid, &expunged); err != nil {
+			t.Fatalf("failed to scan gallery: %v", err)
+		}
+		stored[gid] = expunged
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("failed to read galleries: %v", err)
+	}
+	return stored
+}
+
+func TestE2EGallerySync(t *testing.T) {
+	galleries := ehmock.Generate(14, e2eFirstGid, e2eNewest, time.Hour)
+	galleries[1].Expunged = true
+	e2eDatabase(t, galleries)
+
+	server := ehmock.New(galleries)
+	defer server.Close()
+	server.SetPageSize(2)
+	shrinkBackoff(t)
+
+	crawler := mockGalleryCrawler(server, "e-hentai.org")
+	seedGalleries(t, crawler, galleries[6:]...)
+	server.Inject(ehmock.RouteList, 1, ehmock.Failure{Kind: ehmock.RateLimited})
+	server.Inject(ehmock.RouteAPI, 1, ehmock.Failure{Kind: ehmock.Unavailable})
+
+	if err := crawler.Sync(context.Background()); err != nil {
+		t.Fatalf("expected sync to succeed, got %v", err)
+	}
+
+	stored := storedGalleries(t, galleries)
+	if len(stored) != len(galleries) {
+		t.Fatalf("expected %d galleries, got %d", len(galleries), len(stored))
+	}
+	if !stored[galleries[1].Gid] {
+		t.Fatalf("expected gallery %d to be expunged", galleries[1].Gid)
+	}
+}
+
+func TestE2EGalleryBackfill(t *testing.T) {
+	galleries := ehmock.Generate(14, e2eFirstGid, e2eNewest, time.Hour)
+	e2eDatabase(t, galleries)
+
+	server := ehmock.New(galleries)
+	defer server.Close()
+	server.SetPageSize(2)
+	shrinkBackoff(t)
+
+	crawler := mockGalleryCrawler(server, "e-hentai.org")
+	crawler.cfg.BackfillStart = galleries[6].Posted.Unix()
+	crawler.cfg.BackfillEnd = galleries[2].Posted.Unix()
+	// Gallery 0 is newer than the window and gives the start cursor
+	seedGalleries(t, crawler, galleries[0], galleries[4])
+	server.Inject(ehmock.RouteList, 1, ehmock.Failure{Kind: ehmock.TemporaryBan})
+
+	if err := crawler.Backfill(context.Background()); err != nil {
+		t.Fatalf("expected backfill to succeed, got %v", err)
+	}
+
+	stored := storedGalleries(t, galleries)
+	for i, g := range galleries {
+		inWindow := i == 0 || (i >= 2 && i <= 6)
+		if _, ok := stored[g.Gid]; ok != inWindow {
+			t.Fatalf("expected gallery %d (index %d) stored=%v, got %v", g.Gid, i, inWindow, ok)
+		}
+	}
+}
+
+func TestE2ETorrentSync(t *testing.T) {
+	galleries := ehmock.Generate(4, e2eFirstGid, e2eNewest, time.Hour)
+	galleries[1].RootGid = galleries[3].Gid
+	e2eDatabase(t, galleries)
+
+	server := ehmock.New(galleries)
+	defer server.Close()
+	server.SetPageSize(2)
+	server.Inject(ehmock.RouteGalleryTorrents, 1, ehmock.Failure{Kind: ehmock.CloudflareChallenge})
+	shrinkBackoff(t)
+
+	crawler := mockTorrentCrawler(server, "e-hentai.org")
+	crawler.SetOptions(TorrentCrawlerOptions{MaxPages: 2})
+
+	if err := crawler.Sync(context.Background()); err != nil {
+		t.Fatalf("expected torrent sync to succeed, got %v", err)
+	}
+
+	ctx := context.Background()
+	pool := database.GetPool()
+	for _, g := range galleries {
+		rootGid := g.Gid
+		if g.RootGid != 0 {
+			rootGid = g.RootGid
+		}
+
+		var count int
+		if err := pool.QueryRow(ctx, `SELECT COUNT(*) FROM torrent WHERE id = $1 AND gid = $2`, g.Torrents[0].ID, rootGid).Scan(&count); err != nil {
+			t.Fatalf("failed to query torrent: %v", err)
+		}
+		if count != 1 {
+			t.Fatalf("expected torrent %d under gid %d, got %d rows", g.Torrents[0].ID, rootGid, count)
+		}
+
+		var storedRoot *int
+		var bytorrent bool
+		if err := pool.QueryRow(ctx, `SELECT root_gid, bytorrent FROM gallery WHERE gid = $1`, g.Gid).Scan(&storedRoot, &bytorrent); err != nil {
+			t.Fatalf("failed to query gallery %d: %v", g.Gid, err)
+		}
+		if storedRoot == nil || *storedRoot != rootGid || !bytorrent {
+			t.Fatalf("expected gallery %d imported by torrent with root gid %d, got root %v bytorrent %v", g.Gid, rootGid, storedRoot, bytorrent)
+		}
+	}
+}
+
+func TestE2EResync(t *testing.T) {
+	galleries := ehmock.Generate(3, e2eFirstGid, e2eNewest, time.Hour)
+	e2eDatabase(t, galleries)
+
+	server := ehmock.New(galleries)
+	defer server.Close()
+	shrinkBackoff(t)
+
+	crawler := mockGalleryCrawler(server, "e-hentai.org")
+	seedGalleries(t, crawler, galleries...)
+
+	updated := append([]ehmock.Gallery(nil), galleries...)
+	updated[1].Title = "[Mock Circle] Renamed Gallery [English]"
+	updated[1].Expunged = true
+	server.SetGalleries(updated)
+	server.Inject(ehmock.RouteAPI, 1, ehmock.Failure{Kind: ehmock.TemporaryBan})
+
+	resyncer := &Resyncer{crawler: crawler, logger: zap.NewNop()}
+	if err := resyncer.Resync(context.Background(), 1); err != nil {
+		t.Fatalf("expected resync to succeed, got %v", err)
+	}
+
+	var title string
+	var expunged bool
+	err := database.GetPool().QueryRow(context.Background(), `SELECT title, expunged FROM gallery WHERE gid = $1`, updated[1].Gid).Scan(&title, &expunged)
+	if err != nil {
+		t.Fatalf("failed to query gallery: %v", err)
+	}
+	if title != updated[1].Title || !expunged {
+		t.Fatalf("expected resynced title and expunged flag, got %q expunged=%v", title, expunged)
+	}
+}
+
+func TestE2EResyncDetails(t *testing.T) {
+	galleries := ehmock.Generate(3, e2eFirstGid, e2eNewest, time.Hour)
+	galleries[0].Parent = galleries[2].Gid
+	e2eDatabase(t, galleries)
+
+	server := ehmock.New(galleries)
+	defer server.Close()
+	shrinkBackoff(t)
+
+	crawler := mockGalleryCrawler(server, "e-hentai.org")
+	seedGalleries(t, crawler, galleries...)
+	server.Inject(ehmock.RouteGallery, 1, ehmock.Failure{Kind: ehmock.TemporaryBan})
+
+	cfg := &config.CrawlerConfig{FetchDetails: true}
+	resyncer := &Resyncer{crawler: crawler, details: newDetailScraper(cfg, crawler, zap.NewNop()), logger: zap.NewNop()}
+	if err := resyncer.Resync(context.Background(), 1); err != nil {
+		t.Fatalf("expected resync to succeed, got %v", err)
+	}
+	if got := server.Requests(ehmock.RouteGallery); got != len(galleries)+1 {
+		t.Fatalf("expected %d gallery page requests, got %d", len(galleries)+1, got)
+	}
+
+	query := `
+		SELECT favorite_count, rating_count, parent_gid, COALESCE(newer_gids, '{}'), visible, hidden_reason
+		FROM gallery WHERE gid = $1 AND detail_synced_at IS NOT NULL
+	`
+	tests := []struct {
+		gallery ehmock.Gallery
+		parent  *int
+		newer   []int
+		visible bool
+		reason  *string
+	}{
+		{gallery: galleries[0], parent: &galleries[2].Gid, visible: true},
+		{gallery: galleries[1], visible: true},
+		{gallery: galleries[2], newer: []int{galleries[0].Gid}, reason: nullIfEmpty("Replaced")},
+	}
+
+	for _, tt := range tests {
+		var favorites, ratings int
+		var parent *int
+		var newer []int
+		var visible bool
+		var reason *string
+		err := database.GetPool().QueryRow(context.Background(), query, tt.gallery.Gid).Scan(&favorites, &ratings, &parent, &newer, &visible, &reason)
+		if err != nil {
+			t.Fatalf("failed to query details of gallery %d: %v", tt.gallery.Gid, err)
+		}
+		if favorites != tt.gallery.Favorites || ratings != tt.gallery.RatingCount || visible != tt.visible {
+			t.Fatalf("unexpected details of gallery %d: favorites=%d ratings=%d visible=%v", tt.gallery.Gid, favorites, ratings, visible)
+		}
+		if (parent == nil) != (tt.parent == nil) || (parent != nil && *parent != *tt.parent) {
+			t.Fatalf("unexpected parent of gallery %d: %v", tt.gallery.Gid, parent)
+		}
+		if len(newer) != len(tt.newer) || (len(newer) > 0 && newer[0] != tt.newer[0]) {
+			t.Fatalf("unexpected newer versions of gallery %d: %v", tt.gallery.Gid, newer)
+		}
+		if (reason == nil) != (tt.reason == nil) || (reason != nil && *reason != *tt.reason) {
+			t.Fatalf("unexpected hidden reason of gallery %d: %v", tt.gallery.Gid, reason)
+		}
+	}
+}
+
+func TestE2EResyncComments(t *testing.T) {
+	galleries := ehmock.Generate(2, e2eFirstGid, e2eNewest, time.Hour)
+	e2eDatabase(t, galleries)
+
+	server := ehmock.New(galleries)
+	defer server.Close()
+	shrinkBackoff(t)
+
+	crawler := mockGalleryCrawler(server, "e-hentai.org")
+	seedGalleries(t, crawler, galleries...)
+
+	cfg := &config.CrawlerConfig{FetchComments: true}
+	resyncer := &Resyncer{crawler: crawler, details: newDetailScraper(cfg, crawler, zap.NewNop()), logger: zap.NewNop()}
+	if err := resyncer.Resync(context.Background(), 1); err != nil {
+		t.Fatalf("expected resync to succeed, got %v", err)
+	}
+
+	// A second resync replaces the comments: deleted ones go, edits show
+	updated := append([]ehmock.Gallery(nil), galleries...)
+	updated[0].Comments = []ehmock.Comment{galleries[0].Comments[0], galleries[0].Comments[1]}
+	updated[0].Comments[1].Body = "Thanks, edited"
+	server.SetGalleries(updated)
+	if err := resyncer.Resync(context.Background(), 1); err != nil {
+		t.Fatalf("expected resync to succeed, got %v", err)
+	}
+
+	for _, gallery := range updated {
+		rows, err := database.GetPool().Query(context.Background(),
+			`SELECT comment_id, author, score, body FROM gallery_comment WHERE gid = $1 ORDER BY posted`, gallery.Gid)
+		if err != nil {
+			t.Fatalf("failed to query comments of gallery %d: %v", gallery.Gid, err)
+		}
+		var got []ehmock.Comment
+		for rows.Next() {
+			var c ehmock.Comment
+			var score *int
+			if err := rows.Scan(&c.ID, &c.Author, &score, &c.Body); err != nil {
+				t.Fatalf("failed to scan comment: %v", err)
+			}
+			if (score == nil) != (c.ID == 0) {
+				t.Fatalf("expected a score for all but the uploader comment, got %v for comment %d", score, c.ID)
+			}
+			if score != nil {
+				c.Score = *score
+			}
+			got = append(got, c)
+		}
+		rows.Close()
+
+		if len(got) != len(gallery.Comments) {
+			t.Fatalf("expected %d comments of gallery %d, got %#v", len(gallery.Comments), gallery.Gid, got)
+		}
+		for i, c := range got {
+			want := gallery.Comments[i]
+			if c.ID != want.ID || c.Author != want.Author || c.Score != want.Score || c.Body != want.Body {
+				t.Fatalf("expected comment %d of gallery %d to be %#v, got %#v", i, gallery.Gid, want, c)
+			}
+		}
+	}
+}
+
+func TestE2EResyncRemovedGalleries(t *testing.T) {
+	galleries := ehmock.Generate(3, e2eFirstGid, e2eNewest, time.Hour)
+	e2eDatabase(t, galleries)
+
+	server := ehmock.New(galleries)
+	defer server.Close()
+	shrinkBackoff(t)
+
+	crawler := mockGalleryCrawler(server, "e-hentai.org")
+	seedGalleries(t, crawler, galleries...)
+
+	removed := func(gid int) (bool, *string) {
+		t.Helper()
+		var isRemoved bool
+		var reason *string
+		err := database.GetPool().QueryRow(context.Background(), `SELECT removed, removed_reason FROM gallery WHERE gid = $1`, gid).Scan(&isRemoved, &reason)
+		if err != nil {
+			t.Fatalf("failed to query gallery: %v", err)
+		}
+		return isRemoved, reason
+	}
+
+	// The gallery disappears from gdata and its page is unavailable
+	server.SetGalleries([]ehmock.Gallery{galleries[0], galleries[2]})
+	ctx, counters := jobrun.WithCounters(context.Background())
+	resyncer := &Resyncer{crawler: crawler, logger: zap.NewNop()}
+	if err := resyncer.Resync(ctx, 1); err != nil {
+		t.Fatalf("expected resync to succeed, got %v", err)
+	}
+
+	isRemoved, reason := removed(galleries[1].Gid)
+	if !isRemoved || reason == nil || !strings.Contains(*reason, "Key missing") || !strings.Contains(*reason, "gallery page unavailable") {
+		t.Fatalf("expected gallery %d removed with its reason, got %v %v", galleries[1].Gid, isRemoved, reason)
+	}
+	if isRemoved, _ := removed(galleries[0].Gid); isRemoved {
+		t.Fatal("expected the other galleries untouched")
+	}
+	if got := counters.Snapshot()[jobrun.GalleriesRemoved]; got != 1 {
+		t.Fatalf("expected 1 removal counted, got %d", got)
+	}
+
+	// A second resync does not count the removal again
+	if err := resyncer.Resync(ctx, 1); err != nil {
+		t.Fatalf("expected resync to succeed, got %v", err)
+	}
+	if got := counters.Snapshot()[jobrun.GalleriesRemoved]; got != 1 {
+		t.Fatalf("expected the removal counted once, got %d", got)
+	}
+
+	// The gallery comes back
+	server.SetGalleries(galleries)
+	if err := resyncer.Resync(ctx, 1); err != nil {
+		t.Fatalf("expected resync to succeed, got %v", err)
+	}
+	if isRemoved, reason := removed(galleries[1].Gid); isRemoved || reason != nil {
+		t.Fatalf("expected gallery %d unmarked, got %v %v", galleries[1].Gid, isRemoved, reason)
+	}
+	if got := counters.Snapshot()[jobrun.GalleriesRestored]; got != 1 {
+		t.Fatalf("expected 1 restore counted, got %d", got)
+	}
+}
+
+func TestE2ERefreshStalest(t *testing.T) {
+	galleries := ehmock.Generate(3, e2eFirstGid, e2eNewest, time.Hour)
+	e2eDatabase(t, galleries)
+
+	server := ehmock.New(galleries)
+	defer server.Close()
+	shrinkBackoff(t)
+
+	crawler := mockGalleryCrawler(server, "e-hentai.org")
+	seedGalleries(t, crawler, galleries...)
+
+	// The first two test galleries are the stalest in the database
+	ctx := context.Background()
+	pool := database.GetPool()
+	gids := []int{galleries[0].Gid, galleries[1].Gid, galleries[2].Gid}
+	if _, err := pool.Exec(ctx, `UPDATE gallery SET last_synced_at = NOW() WHERE NOT (gid = ANY($1))`, gids); err != nil {
+		t.Fatalf("failed to touch other galleries: %v", err)
+	}
+	for i, synced := range []time.Time{e2eNewest.AddDate(-80, 0, 0), e2eNewest.AddDate(-79, 0, 0)} {
+		if _, err := pool.Exec(ctx, `UPDATE gallery SET last_synced_at = $2 WHERE gid = $1`, gids[i], synced); err != nil {
+			t.Fatalf("failed to age gallery: %v", err)
+		}
+	}
+
+	updated := append([]ehmock.Gallery(nil), galleries...)
+	for i := range updated {
+		updated[i].Title = fmt.Sprintf("[Mock Circle] Refreshed %d [English]", i)
+	}
+	server.SetGalleries(updated)
+
+	titles := func() []string {
+		t.Helper()
+		var got []string
+		for _, gid := range gids {
+			var title string
+			if err := pool.QueryRow(ctx, `SELECT title FROM gallery WHERE gid = $1`, gid).Scan(&title); err != nil {
+				t.Fatalf("failed to query gallery: %v", err)
+			}
+			got = append(got, title)
+		}
+		return got
+	}
+
+	// A spent budget skips the run
+	var runID int64
+	err := pool.QueryRow(ctx, `
+		INSERT INTO job_run (kind, source, status, counters, started_at, finished_at)
+		VALUES ($1, 'cli', 'succeeded', '{"metadata_requests": 5}', NOW(), NOW())
+		RETURNING id
+	`, RefreshJobKind).Scan(&runID)
+	if err != nil {
+		t.Fatalf("failed to record a refresh run: %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = pool.Exec(context.Background(), `DELETE FROM job_run WHERE id = $1`, runID)
+	})
+
+	refresher := &Refresher{crawler: crawler, logger: zap.NewNop()}
+	if err := refresher.Refresh(ctx, 2, 5); err != nil {
+		t.Fatalf("expected refresh to succeed, got %v", err)
+	}
+	if got := titles(); got[0] != galleries[0].Title {
+		t.Fatalf("expected no refresh with the budget spent, got %v", got)
+	}
+
+	if err := refresher.Refresh(ctx, 2, 6); err != nil {
+		t.Fatalf("expected refresh to succeed, got %v", err)
+	}
+	got := titles()
+	if got[0] != updated[0].Title || got[1] != updated[1].Title || got[2] != galleries[2].Title {
+		t.Fatalf("expected the two stalest galleries refreshed, got %v", got)
+	}
+
+	var stale int
+	if err := pool.QueryRow(ctx, `SELECT COUNT(*) FROM gallery WHERE gid = ANY($1) AND last_synced_at < NOW() - INTERVAL '1 hour'`, gids).Scan(&stale); err != nil {
+		t.Fatalf("failed to count stale galleries: %v", err)
+	}
+	if stale != 0 {
+		t.Fatalf("expected the refreshed galleries' last_synced_at updated, got %d stale", stale)
+	}
+}
+
+func TestE2EImportCancelled(t *testing.T) {
+	galleries := ehmock.Generate(3, e2eFirstGid, e2eNewest, time.Hour)
+	e2eDatabase(t, galleries)
+
+	// An import cut short reports it, so callers do not move past it
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := NewImporter(zap.NewNop()).Import(ctx, mockMetadata(galleries), false)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the import to fail with context.Canceled, got %v", err)
+	}
+
+	gids := []int{galleries[0].Gid, galleries[1].Gid, galleries[2].Gid}
+	var count int
+	if err := database.GetPool().QueryRow(context.Background(), `SELECT COUNT(*) FROM gallery WHERE gid = ANY($1)`, gids).Scan(&count); err != nil {
+		t.Fatalf("failed to count galleries: %v", err)
+	}
+	if count != 0 {
+		t.Fatalf("expected no galleries written, got %d", count)
+	}
+}
+
+// BenchmarkE2EImport measures imports of a fetch batch and an import batch
+// against the database: inserting new galleries, force-updating stored ones,
+// and skipping current ones
+func BenchmarkE2EImport(b *testing.B) {
+	for _, size := range []int{metadataBatchSize, importBatchSize} {
+		galleries := ehmock.Generate(size, e2eFirstGid, e2eNewest, time.Hour)
+		metadata := mockMetadata(galleries)
+		gids := make([]int, 0, size)
+		for _, g := range galleries {
+			gids = append(gids, g.Gid)
+		}
+
+		importer := NewImporter(zap.NewNop())
+		ctx := context.Background()
+		importAll := func(b *testing.B, force bool) {
+			if err := importer.Import(ctx, metadata, force); err != nil {
+				b.Fatalf("expected import to succeed, got %v", err)
+			}
+		}
+
+		b.Run(fmt.Sprintf("insert/%d", size), func(b *testing.B) {
+			e2eDatabase(b, galleries)
+			for b.Loop() {
+				b.StopTimer()
+				if _, err := database.GetPool().Exec(ctx, `DELETE FROM gallery WHERE gid = ANY($1)`, gids); err != nil {
+					b.Fatalf("failed to delete test galleries: %v", err)
+				}
+				b.StartTimer()
+				importAll(b, false)
+			}
+		})
+		b.Run(fmt.Sprintf("update/%d", size), func(b *testing.B) {
+			e2eDatabase(b, galleries)
+			importAll(b, false)
+			for b.Loop() {
+				importAll(b, true)
+			}
+		})
+		b.Run(fmt.Sprintf("unchanged/%d", size), func(b *testing.B) {
+			e2eDatabase(b, galleries)
+			importAll(b, false)
+			for b.Loop() {
+				importAll(b, false)
+			}
+		})
+	}
+}
+
+func TestE2EGalleryStatCounters(t *testing.T) {
+	galleries := ehmock.Generate(6, e2eFirstGid, e2eNewest, time.Hour)
+	for i := range galleries {
+		galleries[i].Uploader = "e2estatuploader"
+	}
+	galleries[5].Category = "Manga"
+	e2eDatabase(t, galleries)
+
+	ctx := context.Background()
+	pool := database.GetPool()
+	keys := []string{"total_active", "total_expunged", "category_doujinshi", "category_manga", "language_english"}
+	counts := func() map[string]int64 {
+		t.Helper()
+		counts := make(map[string]int64, len(keys)+1)
+		for _, key := range keys {
+			var value int64
+			if err := pool.QueryRow(ctx, `SELECT COALESCE((SELECT stat_value FROM gallery_stat WHERE stat_key = $1), 0)`, key).Scan(&value); err != nil {
+				t.Fatalf("failed to query stat %s: %v", key, err)
+			}
+			counts[key] = value
+		}
+		var uploader int64
+		if err := pool.QueryRow(ctx, `SELECT COALESCE((SELECT gallery_count FROM uploader_stat WHERE uploader = $1), 0)`, "e2estatuploader").Scan(&uploader); err != nil {
+			t.Fatalf("failed to query uploader stat: %v", err)
+		}
+		counts["uploader"] = uploader
+		return counts
+	}
+	expectDelta := func(step string, before map[string]int64, want map[string]int64) {
+		t.Helper()
+		after := counts()
+		for key, value := range after {
+			if got := value - before[key]; got != want[key] {
+				t.Fatalf("%s: expected %s to change by %d, got %d", step, key, want[key], got)
+			}
+		}
+	}
+
+	before := counts()
+	if err := NewImporter(zap.NewNop()).Import(ctx, mockMetadata(galleries), false); err != nil {
+		t.Fatalf("expected import to succeed, got %v", err)
+	}
+	expectDelta("import", before, map[string]int64{
+		"total_active":       6,
+		"category_doujinshi": 5,
+		"category_manga":     1,
+		"language_english":   6,
+		"uploader":           6,
+	})
+
+	if _, err := pool.Exec(ctx, `UPDATE gallery SET expunged = true WHERE gid = $1`, galleries[5].Gid); err != nil {
+		t.Fatalf("failed to expunge gallery: %v", err)
+	}
+	if _, err := pool.Exec(ctx, `UPDATE gallery SET last_synced_at = NOW() WHERE gid = ANY($1)`, []int{galleries[0].Gid, galleries[1].Gid}); err != nil {
+		t.Fatalf("failed to touch galleries: %v", err)
+	}
+	expectDelta("expunge", before, map[string]int64{
+		"total_active":       5,
+		"total_expunged":     1,
+		"category_doujinshi": 5,
+		"language_english":   5,
+		"uploader":           5,
+	})
+
+	if _, err := pool.Exec(ctx, `DELETE FROM gallery WHERE gid = ANY($1)`, []int{galleries[0].Gid, galleries[5].Gid}); err != nil {
+		t.Fatalf("failed to delete galleries: %v", err)
+	}
+	expectDelta("delete", before, map[string]int64{
+		"total_active":       4,
+		"category_doujinshi": 4,
+		"language_english":   4,
+		"uploader":           4,
+	})
+}
+
+func TestE2EResyncKeepsOtherRemovals(t *testing.T) {
+	galleries := ehmock.Generate(2, e2eFirstGid, e2eNewest, time.Hour)
+	e2eDatabase(t, galleries)
+
+	server := ehmock.New(galleries)
+	defer server.Close()
+	shrinkBackoff(t)
+
+	crawler := mockGalleryCrawler(server, "e-hentai.org")
+	seedGalleries(t, crawler, galleries...)
+
+	ctx, counters := jobrun.WithCounters(context.Background())
+	pool := database.GetPool()
+
+	// One removal by torrent-import, one from before removed_source existed
+	if _, err := markGalleryRemoved(ctx, zap.NewNop(), galleries[0].Gid, removalSourceTorrent, "torrent page unavailable"); err != nil {
+		t.Fatalf("failed to mark gallery removed: %v", err)
+	}
+	if _, err := pool.Exec(ctx, `UPDATE gallery SET removed = true WHERE gid = $1`, galleries[1].Gid); err != nil {
+		t.Fatalf("failed to mark gallery removed: %v", err)
+	}
+
+	// gdata still returns both galleries
+	resyncer := &Resyncer{crawler: crawler, logger: zap.NewNop()}
+	if err := resyncer.Resync(ctx, 1); err != nil {
+		t.Fatalf("expected resync to succeed, got %v", err)
+	}
+
+	for _, g := range galleries {
+		var removed bool
+		if err := pool.QueryRow(ctx, `SELECT removed FROM gallery WHERE gid = $1`, g.Gid).Scan(&removed); err != nil {
+			t.Fatalf("failed to query gallery: %v", err)
+		}
+		if !removed {
+			t.Fatalf("expected gallery %d to stay removed", g.Gid)
+		}
+	}
+	if got := counters.Snapshot()[jobrun.GalleriesRestored]; got != 0 {
+		t.Fatalf("expected no restores counted, got %d", got)
+	}
+}
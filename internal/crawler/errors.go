@@ -3,9 +3,10 @@ package crawler
 import (
 	"errors"
 	"fmt"
-	"regexp"
 	"strings"
 	"time"
+
+	"github.com/slinet/ehdb/internal/ehparse"
 )
 
 var (
@@ -74,7 +75,7 @@ func (e *PartialBackfillError) Error() string {
 	}
 
 	return fmt.Sprintf(
-		"partial backfill interrupted after importing %d of %d missing galleries (%d discovered total); rerun overlapping window with -start %s -end %s: %v",
+		"partial backfill interrupted after importing %d of %d missing galleries (%d discovered total); resume with -resume or rerun overlapping window with -start %s -end %s: %v",
 		e.ImportedCount,
 		e.MissingCount,
 		e.DiscoveredCount,
@@ -92,27 +93,12 @@ func (e *PartialBackfillError) Unwrap() error {
 	return e.Cause
 }
 
-var temporaryBanPattern = regexp.MustCompile(`(?i)your ip address has been temporarily banned.*?ban expires in [^<\n]+`)
-
-func extractTemporaryBanMessage(content string) (string, bool) {
-	if content == "" {
-		return "", false
-	}
-
-	match := temporaryBanPattern.FindString(content)
-	if match == "" {
-		return "", false
-	}
-
-	return strings.TrimSpace(match), true
-}
-
 func isTemporaryBanError(err error) bool {
 	if err == nil {
 		return false
 	}
 
-	_, ok := extractTemporaryBanMessage(err.Error())
+	_, ok := ehparse.BanMessage(err.Error())
 	return ok
 }
 
@@ -138,93 +124,26 @@ func isAuthFailureBody(body []byte) (string, bool) {
 	return "", false
 }
 
+// abnormalGalleryListPageReason reports why body is not a gallery list page
 func abnormalGalleryListPageReason(body []byte) (string, bool) {
-	trimmed := strings.TrimSpace(string(body))
-	if trimmed == "" {
-		return "received blank gallery list page", true
-	}
-
-	lowerContent := strings.ToLower(trimmed)
-	if match, ok := extractTemporaryBanMessage(trimmed); ok {
-		return match, true
-	}
-
-	abnormalMarkers := []string{
-		"your ip address has been temporarily banned",
-		"ban expires in",
-		"attention required",
-		"just a moment",
-		"checking your browser before accessing",
-		"captcha",
-		"cf-browser-verification",
-		"cf_chl_opt",
-		"ddos-guard",
-		"access denied",
-	}
-
-	for _, marker := range abnormalMarkers {
-		if strings.Contains(lowerContent, marker) {
-			return marker, true
-		}
+	if reason, ok := ehparse.ErrorPageReason(body); ok {
+		return reason, true
 	}
 
-	hasGalleryListStructure := strings.Contains(lowerContent, "searchnav") ||
-		strings.Contains(lowerContent, "nexturl=") ||
-		strings.Contains(lowerContent, "class=\"itg") ||
-		strings.Contains(lowerContent, "class=\"gl1t") ||
-		strings.Contains(lowerContent, "class=\"gl3t") ||
-		strings.Contains(lowerContent, "posted_")
-
-	if !hasGalleryListStructure {
+	if _, err := ehparse.ParseGalleryList(body); errors.Is(err, ehparse.ErrNoGalleryList) {
 		return "missing expected gallery list structure", true
 	}
 
 	return "", false
 }
 
-func suspectedAbnormalWebPageReason(body []byte) (string, bool) {
-	trimmed := strings.TrimSpace(string(body))
-	if trimmed == "" {
-		return "received blank page", true
-	}
-
-	if match, ok := extractTemporaryBanMessage(trimmed); ok {
-		return match, true
-	}
-
-	lowerContent := strings.ToLower(trimmed)
-	abnormalMarkers := []string{
-		"your ip address has been temporarily banned",
-		"ban expires in",
-		"attention required",
-		"just a moment",
-		"checking your browser before accessing",
-		"captcha",
-		"cf-browser-verification",
-		"cf_chl_opt",
-		"ddos-guard",
-		"access denied",
-	}
-
-	for _, marker := range abnormalMarkers {
-		if strings.Contains(lowerContent, marker) {
-			return marker, true
-		}
-	}
-
-	return "", false
-}
-
+// abnormalTorrentListPageReason reports why body is not a torrent list page
 func abnormalTorrentListPageReason(body []byte) (string, bool) {
-	if reason, ok := suspectedAbnormalWebPageReason(body); ok {
+	if reason, ok := ehparse.ErrorPageReason(body); ok {
 		return reason, true
 	}
 
-	lowerContent := strings.ToLower(strings.TrimSpace(string(body)))
-	hasTorrentListStructure := strings.Contains(lowerContent, "gallerytorrents.php?gid=") ||
-		strings.Contains(lowerContent, "gtid=")
-
-	if !hasTorrentListStructure {
+	if _, err := ehparse.ParseTorrentList(body); errors.Is(err, ehparse.ErrNoTorrentList) {
 		return "missing expected torrent list structure", true
 	}
 
@@ -1,10 +1,13 @@
 package crawler
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"testing"
 	"time"
+
+	"github.com/slinet/ehdb/internal/jobrun"
 )
 
 func TestIsAuthFailureBody(t *testing.T) {
@@ -204,6 +207,67 @@ func TestRetryNormalErrorKeepsSmallBudget(t *testing.T) {
 	}
 }
 
+func TestRetryCountsIPBans(t *testing.T) {
+	ctx, counters := jobrun.WithCounters(context.Background())
+
+	err := RetryVoid(RetryConfig{Context: ctx, MaxRetries: 1}, func() error {
+		return errors.New("gallery list abnormal: Your IP address has been temporarily banned. (The ban expires in 4 minutes and 58 seconds)")
+	})
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	// Without WaitForIPUnban the ban is returned, but still counted for the run
+	if got := counters.Snapshot()[jobrun.BansHit]; got != 1 {
+		t.Fatalf("expected 1 ban counted, got %d", got)
+	}
+}
+
+func TestRetryStopsWhenCancelled(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  RetryConfig
+		err  error
+	}{
+		{
+			name: "linear backoff",
+			cfg:  RetryConfig{MaxRetries: 3},
+			err:  fmt.Errorf("parse failure"),
+		},
+		{
+			name: "IP ban wait",
+			cfg:  RetryConfig{MaxRetries: 3, WaitForIPUnban: true},
+			err:  errors.New("Your IP address has been temporarily banned. (The ban expires in 59 minutes and 43 seconds)"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			tt.cfg.Context = ctx
+
+			attempts := 0
+			time.AfterFunc(50*time.Millisecond, cancel)
+
+			started := time.Now()
+			err := RetryVoid(tt.cfg, func() error {
+				attempts++
+				return tt.err
+			})
+
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("expected context.Canceled, got %v", err)
+			}
+			if elapsed := time.Since(started); elapsed > 2*time.Second {
+				t.Fatalf("expected the wait to end on cancel, took %s", elapsed)
+			}
+			if attempts != 1 {
+				t.Fatalf("expected 1 attempt, got %d", attempts)
+			}
+		})
+	}
+}
+
 func TestIsTransientStatusCode(t *testing.T) {
 	transient := []int{429, 500, 502, 503, 504}
 	for _, code := range transient {
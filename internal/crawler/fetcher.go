@@ -2,12 +2,10 @@ package crawler
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"regexp"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/slinet/ehdb/internal/config"
 	"github.com/slinet/ehdb/internal/database"
@@ -17,16 +15,19 @@ import (
 // Fetcher manually fetches specific galleries
 type Fetcher struct {
 	crawler *GalleryCrawler
+	details *detailScraper // nil unless cfg.FetchDetails or cfg.FetchComments
 	logger  *zap.Logger
 }
 
 // NewFetcher creates a new fetcher
 func NewFetcher(cfg *config.CrawlerConfig, logger *zap.Logger) *Fetcher {
 	crawler, _ := NewGalleryCrawler(cfg, logger)
-	return &Fetcher{
+	f := &Fetcher{
 		crawler: crawler,
 		logger:  logger,
 	}
+	f.details = newDetailScraper(cfg, crawler, logger)
+	return f
 }
 
 // Fetch fetches specific galleries by gid/token pairs
@@ -58,48 +59,35 @@ func (f *Fetcher) Fetch(ctx context.Context, gidTokens []string) error {
 
 	f.logger.Debug("parsed gid/token pairs", zap.Int("count", len(fetchList)))
 
-	// Fetch metadata in batches
-	var allMetadata []database.GalleryMetadata
-	for i := 0; i < len(fetchList); i += 25 {
-		end := i + 25
-		if end > len(fetchList) {
-			end = len(fetchList)
-		}
-
-		batch := fetchList[i:end]
-
-		f.logger.Debug("fetching metadata batch", zap.Int("from", i), zap.Int("to", end))
-
-		metadata, err := Retry(RetryConfig{
-			MaxRetries:          f.crawler.retryTimes,
-			Logger:              f.logger,
-			TransientRetryTimes: f.crawler.cfg.TransientRetryTimes,
-			WaitForIPUnban:      f.crawler.cfg.WaitForIPUnban,
-		}, func() ([]database.GalleryMetadata, error) {
-			return f.crawler.GetMetadatas(batch)
-		})
-
-		if err != nil {
-			if errors.Is(err, ErrAuthRequired) {
-				return fmt.Errorf("auth failed while fetching metadata batch %d-%d: %w", i, end, err)
+	removals := newRemovalTracker(f.crawler, f.logger)
+	syncer := &metadataSync{
+		crawler:  f.crawler,
+		importer: NewImporter(f.logger),
+		removals: removals,
+		logger:   f.logger,
+	}
+	// Details are stored after the import, which creates new galleries
+	if f.details != nil {
+		syncer.imported = func(ctx context.Context, metadata []database.GalleryMetadata, done int) error {
+			if len(metadata) == 0 {
+				return nil
 			}
-			f.logger.Error("failed to fetch metadata batch", zap.Error(err))
-			continue
+			if err := f.details.Scrape(ctx, metadata); err != nil {
+				return fmt.Errorf("fetch details: %w", err)
+			}
+			return nil
 		}
-
-		allMetadata = append(allMetadata, metadata...)
-
-		// Rate limiting for API calls
-		time.Sleep(time.Duration(f.crawler.cfg.APIDelaySeconds) * time.Second)
 	}
-
-	f.logger.Debug("fetched all metadata", zap.Int("count", len(allMetadata)))
-
-	// Import data with force flag
-	importer := NewImporter(f.logger)
-	if err := importer.Import(ctx, allMetadata, true); err != nil {
-		return fmt.Errorf("import data: %w", err)
+	done, err := syncer.run(ctx, fetchList)
+	if err != nil {
+		return err
 	}
 
+	f.logger.Info("fetch completed",
+		zap.Int("fetched", done),
+		zap.Int("removed", removals.removed),
+		zap.Int("restored", removals.restored),
+	)
+
 	return nil
 }
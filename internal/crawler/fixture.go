@@ -0,0 +1,326 @@
+package crawler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Fixture modes of CrawlerConfig.FixtureMode
+const (
+	// FixtureModeRecord saves every request/response pair to the fixture directory
+	FixtureModeRecord = "record"
+	// FixtureModeReplay serves requests from the fixture directory without network access
+	FixtureModeReplay = "replay"
+)
+
+// scrubbedValue replaces cookie values in recorded fixtures
+const scrubbedValue = "scrubbed"
+
+// errFixtureNotFound is returned in replay mode for a request that was not recorded
+var errFixtureNotFound = errors.New("no recorded response")
+
+// fixture is one recorded request/response pair, stored as one JSON file.
+// Request headers are not kept, so the Cookie header never reaches disk.
+type fixture struct {
+	Request  fixtureRequest  `json:"request"`
+	Response fixtureResponse `json:"response"`
+}
+
+type fixtureRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Body   string `json:"body,omitempty"`
+}
+
+type fixtureResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body"`
+	BodyBase64 bool        `json:"body_base64,omitempty"` // Body is base64 because it is not valid UTF-8
+}
+
+// fixtureStore maps requests to fixture files. Repeats of the same request
+// are numbered, so a replay returns the recorded sequence of responses, e.g.
+// a ban page and then the page that succeeded on retry.
+type fixtureStore struct {
+	dir string
+
+	mu   sync.Mutex
+	seen map[string]int
+}
+
+func newFixtureStore(mode, dir string) (*fixtureStore, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("fixture %s mode requires a fixture directory", mode)
+	}
+
+	switch mode {
+	case FixtureModeRecord:
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("create fixture directory: %w", err)
+		}
+	case FixtureModeReplay:
+		info, err := os.Stat(dir)
+		if err != nil {
+			return nil, fmt.Errorf("open fixture directory: %w", err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("fixture path %s is not a directory", dir)
+		}
+	default:
+		return nil, fmt.Errorf("unknown fixture mode %q (want record or replay)", mode)
+	}
+
+	return &fixtureStore{dir: dir, seen: make(map[string]int)}, nil
+}
+
+var fixtureNameUnsafe = regexp.MustCompile(`[^a-z0-9.]+`)
+
+// nextPath returns the file of the next occurrence of a request
+func (s *fixtureStore) nextPath(method, rawURL, body string) string {
+	sum := sha256.Sum256([]byte(method + " " + rawURL + "\n" + body))
+
+	host := rawURL
+	if _, rest, ok := strings.Cut(rawURL, "://"); ok {
+		host, _, _ = strings.Cut(rest, "/")
+	}
+	key := fmt.Sprintf("%s-%s-%x", strings.ToLower(method), fixtureNameUnsafe.ReplaceAllString(strings.ToLower(host), "-"), sum[:6])
+
+	s.mu.Lock()
+	s.seen[key]++
+	n := s.seen[key]
+	s.mu.Unlock()
+
+	return filepath.Join(s.dir, fmt.Sprintf("%s-%03d.json", key, n))
+}
+
+// recordTransport passes requests to next and saves each exchange with
+// cookie values scrubbed. Requests that fail before a response are not
+// recorded.
+type recordTransport struct {
+	next  http.RoundTripper
+	store *fixtureStore
+}
+
+func (t *recordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := requestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("read response for fixture: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	scrubbedReqBody := string(scrubCookieJSON([]byte(reqBody)))
+	recorded := fixture{
+		Request: fixtureRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Body:   scrubbedReqBody,
+		},
+		Response: fixtureResponse{
+			StatusCode: resp.StatusCode,
+			Header:     scrubSetCookie(resp.Header),
+		},
+	}
+
+	scrubbedBody := scrubCookieJSON(body)
+	if utf8.Valid(scrubbedBody) {
+		recorded.Response.Body = string(scrubbedBody)
+	} else {
+		recorded.Response.Body = base64.StdEncoding.EncodeToString(scrubbedBody)
+		recorded.Response.BodyBase64 = true
+	}
+
+	data, err := json.MarshalIndent(recorded, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshal fixture: %w", err)
+	}
+
+	path := t.store.nextPath(req.Method, req.URL.String(), scrubbedReqBody)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return nil, fmt.Errorf("write fixture: %w", err)
+	}
+
+	return resp, nil
+}
+
+// replayTransport answers requests from recorded fixtures
+type replayTransport struct {
+	store *fixtureStore
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	reqBody, err := requestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	path := t.store.nextPath(req.Method, req.URL.String(), string(scrubCookieJSON([]byte(reqBody))))
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w for %s %s (%s)", errFixtureNotFound, req.Method, req.URL, filepath.Base(path))
+		}
+		return nil, fmt.Errorf("read fixture: %w", err)
+	}
+
+	var recorded fixture
+	if err := json.Unmarshal(data, &recorded); err != nil {
+		return nil, fmt.Errorf("parse fixture %s: %w", filepath.Base(path), err)
+	}
+
+	body := []byte(recorded.Response.Body)
+	if recorded.Response.BodyBase64 {
+		body, err = base64.StdEncoding.DecodeString(recorded.Response.Body)
+		if err != nil {
+			return nil, fmt.Errorf("decode fixture %s body: %w", filepath.Base(path), err)
+		}
+	}
+
+	header := recorded.Response.Header
+	if header == nil {
+		header = http.Header{}
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.Response.StatusCode, http.StatusText(recorded.Response.StatusCode)),
+		StatusCode:    recorded.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// requestBody reads a copy of the request body without consuming it
+func requestBody(req *http.Request) (string, error) {
+	if req.Body == nil || req.GetBody == nil {
+		return "", nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return "", fmt.Errorf("copy request body: %w", err)
+	}
+	defer func() { _ = body.Close() }()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", fmt.Errorf("read request body: %w", err)
+	}
+	return string(data), nil
+}
+
+// keepCookieValue reports whether a cookie value carries no credential and
+// is kept in fixtures: empty values and igneous=mystery, which marks a denied
+// ExHentai session that the auth checks look for
+func keepCookieValue(value string) bool {
+	return value == "" || value == "mystery"
+}
+
+// scrubSetCookie returns a copy of header with Set-Cookie values scrubbed
+func scrubSetCookie(header http.Header) http.Header {
+	scrubbed := header.Clone()
+	for i, line := range scrubbed.Values("Set-Cookie") {
+		pair, attrs, _ := strings.Cut(line, ";")
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || keepCookieValue(strings.TrimSpace(value)) {
+			continue
+		}
+
+		line = strings.TrimSpace(name) + "=" + scrubbedValue
+		if attrs != "" {
+			line += ";" + attrs
+		}
+		scrubbed["Set-Cookie"][i] = line
+	}
+	return scrubbed
+}
+
+// scrubCookieJSON scrubs cookie values in JSON bodies, i.e. the "cookies"
+// lists of FlareSolverr requests and solutions. Other bodies are returned
+// unchanged.
+func scrubCookieJSON(body []byte) []byte {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 || trimmed[0] != '{' || !bytes.Contains(trimmed, []byte(`"cookies"`)) {
+		return body
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(trimmed, &doc); err != nil {
+		return body
+	}
+
+	if !scrubCookieValues(doc) {
+		return body
+	}
+
+	scrubbed, err := json.Marshal(doc)
+	if err != nil {
+		return body
+	}
+	return scrubbed
+}
+
+// scrubCookieValues replaces the value of every object in a "cookies" list
+// below v and reports whether anything changed
+func scrubCookieValues(v interface{}) bool {
+	changed := false
+	switch node := v.(type) {
+	case map[string]interface{}:
+		for key, child := range node {
+			if cookies, ok := child.([]interface{}); ok && key == "cookies" {
+				for _, cookie := range cookies {
+					entry, ok := cookie.(map[string]interface{})
+					if !ok {
+						continue
+					}
+					if value, _ := entry["value"].(string); !keepCookieValue(value) {
+						entry["value"] = scrubbedValue
+						changed = true
+					}
+				}
+				continue
+			}
+			if scrubCookieValues(child) {
+				changed = true
+			}
+		}
+	case []interface{}:
+		for _, child := range node {
+			if scrubCookieValues(child) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
@@ -0,0 +1,119 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newFixtureClient(t *testing.T, mode, dir string, httpClient *http.Client, host string) *Client {
+	t.Helper()
+
+	store, err := newFixtureStore(mode, dir)
+	if err != nil {
+		t.Fatalf("create fixture store: %v", err)
+	}
+
+	client := &Client{
+		host:        host,
+		cookiesPath: filepath.Join(t.TempDir(), "cookies.json"),
+		cookies:     parseCookieHeader("ipb_member_id=1; ipb_pass_hash=secrethash"),
+		fixtureMode: mode,
+		fixtures:    store,
+	}
+	client.httpClient = &http.Client{Transport: client.fixtureTransport(httpClient.Transport)}
+	return client
+}
+
+func TestClientRecordsAndReplaysFixtures(t *testing.T) {
+	requests := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		if requests == 1 {
+			http.SetCookie(w, &http.Cookie{Name: "sk", Value: "secretsession", Path: "/"})
+			_, _ = w.Write([]byte("first page"))
+			return
+		}
+		_, _ = w.Write([]byte("second page"))
+	}))
+	defer server.Close()
+
+	hostURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("parse server url: %v", err)
+	}
+
+	dir := t.TempDir()
+	recorder := newFixtureClient(t, FixtureModeRecord, dir, server.Client(), hostURL.Host)
+	for _, expected := range []string{"first page", "second page"} {
+		body, err := recorder.Get(context.Background(), server.URL+"/?next=1")
+		if err != nil || string(body) != expected {
+			t.Fatalf("expected %q while recording, got %q (%v)", expected, body, err)
+		}
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil || len(files) != 2 {
+		t.Fatalf("expected 2 fixture files, got %d (%v)", len(files), err)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			t.Fatalf("read fixture: %v", err)
+		}
+		if strings.Contains(string(data), "secretsession") || strings.Contains(string(data), "secrethash") {
+			t.Fatalf("expected cookies to be scrubbed from %s, got %s", file.Name(), data)
+		}
+	}
+
+	// Replay serves the same sequence without the server
+	server.Close()
+	replayer := newFixtureClient(t, FixtureModeReplay, dir, http.DefaultClient, hostURL.Host)
+	for _, expected := range []string{"first page", "second page"} {
+		body, err := replayer.Get(context.Background(), server.URL+"/?next=1")
+		if err != nil || string(body) != expected {
+			t.Fatalf("expected %q while replaying, got %q (%v)", expected, body, err)
+		}
+	}
+
+	if _, err := replayer.Get(context.Background(), server.URL+"/?next=1"); !errors.Is(err, errFixtureNotFound) {
+		t.Fatalf("expected errFixtureNotFound after the recorded responses, got %v", err)
+	}
+
+	// Replayed cookies stay in memory and never replace the cookie file
+	if _, err := os.Stat(replayer.cookiesPath); !os.IsNotExist(err) {
+		t.Fatalf("expected replay not to write cookies, got %v", err)
+	}
+}
+
+func TestScrubCookies(t *testing.T) {
+	header := http.Header{"Set-Cookie": []string{
+		"ipb_pass_hash=secret; Path=/; Domain=.e-hentai.org",
+		"igneous=mystery; Path=/",
+	}}
+
+	scrubbed := scrubSetCookie(header)
+	if got := scrubbed.Values("Set-Cookie"); got[0] != "ipb_pass_hash=scrubbed; Path=/; Domain=.e-hentai.org" || got[1] != "igneous=mystery; Path=/" {
+		t.Fatalf("expected secret scrubbed and mystery kept, got %q", got)
+	}
+	if header.Get("Set-Cookie") != "ipb_pass_hash=secret; Path=/; Domain=.e-hentai.org" {
+		t.Fatalf("expected original header untouched, got %q", header.Get("Set-Cookie"))
+	}
+
+	body := scrubCookieJSON([]byte(`{"solution":{"cookies":[{"name":"cf_clearance","value":"secret"}],"response":"<html>"}}`))
+	if strings.Contains(string(body), "secret") || !strings.Contains(string(body), `"value":"scrubbed"`) {
+		t.Fatalf("expected FlareSolverr cookies scrubbed, got %s", body)
+	}
+
+	plain := []byte(`{"gmetadata":[]}`)
+	if got := scrubCookieJSON(plain); string(got) != string(plain) {
+		t.Fatalf("expected body without cookies unchanged, got %s", got)
+	}
+}
@@ -2,10 +2,12 @@ package crawler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"sort"
 	"strings"
 	"time"
 )
@@ -38,13 +40,15 @@ type flareSolverrResponse struct {
 
 // flareSolverrGet sends a GET request through FlareSolverr and returns the response body.
 // It also syncs any cookies returned by FlareSolverr back into the client.
-func (c *Client) flareSolverrGet(targetURL, serviceURL string) ([]byte, error) {
+func (c *Client) flareSolverrGet(ctx context.Context, targetURL, serviceURL string) ([]byte, error) {
 	c.mu.RLock()
 	var cookies []flareSolverrCookie
 	for name, value := range c.cookies {
 		cookies = append(cookies, flareSolverrCookie{Name: name, Value: value})
 	}
 	c.mu.RUnlock()
+	// Keep the payload stable for recorded fixtures
+	sort.Slice(cookies, func(i, j int) bool { return cookies[i].Name < cookies[j].Name })
 
 	payload, err := json.Marshal(flareSolverrRequest{
 		CMD:        "request.get",
@@ -56,13 +60,13 @@ func (c *Client) flareSolverrGet(targetURL, serviceURL string) ([]byte, error) {
 		return nil, fmt.Errorf("marshal flaresolverr request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", serviceURL+"/v1", bytes.NewReader(payload))
+	req, err := http.NewRequestWithContext(ctx, "POST", serviceURL+"/v1", bytes.NewReader(payload))
 	if err != nil {
 		return nil, fmt.Errorf("create flaresolverr request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	httpClient := &http.Client{Timeout: 90 * time.Second}
+	httpClient := &http.Client{Timeout: 90 * time.Second, Transport: c.fixtureTransport(http.DefaultTransport)}
 	resp, err := httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("flaresolverr request: %w", err)
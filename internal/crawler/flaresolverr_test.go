@@ -1,6 +1,7 @@
 package crawler
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
@@ -51,7 +52,7 @@ func TestFlareSolverrGetRejectsEmptyExHentaiShellAndDoesNotPersistMysteryCookie(
 		cookies:     parseCookieHeader("igneous=fresh; ipb_member_id=1; ipb_pass_hash=hash"),
 	}
 
-	_, err := client.flareSolverrGet("https://exhentai.org/", server.URL)
+	_, err := client.flareSolverrGet(context.Background(), "https://exhentai.org/", server.URL)
 	if err == nil {
 		t.Fatal("expected auth error, got nil")
 	}
@@ -114,7 +115,7 @@ func TestFlareSolverrGetPersistsCookiesAfterValidResponse(t *testing.T) {
 		cookies:     parseCookieHeader("igneous=old; ipb_member_id=1; ipb_pass_hash=hash"),
 	}
 
-	body, err := client.flareSolverrGet("https://exhentai.org/", server.URL)
+	body, err := client.flareSolverrGet(context.Background(), "https://exhentai.org/", server.URL)
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -5,13 +5,15 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"regexp"
 	"strconv"
 	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/slinet/ehdb/internal/checkpoint"
 	"github.com/slinet/ehdb/internal/config"
 	"github.com/slinet/ehdb/internal/database"
+	"github.com/slinet/ehdb/internal/ehparse"
+	"github.com/slinet/ehdb/internal/jobrun"
 	"github.com/slinet/ehdb/pkg/utils"
 	"go.uber.org/zap"
 )
@@ -26,7 +28,7 @@ type GalleryCrawler struct {
 
 // NewGalleryCrawler creates a new gallery crawler
 func NewGalleryCrawler(cfg *config.CrawlerConfig, logger *zap.Logger) (*GalleryCrawler, error) {
-	client, err := NewClient(cfg)
+	client, err := NewClient(cfg, logger)
 	if err != nil {
 		return nil, err
 	}
@@ -49,7 +51,7 @@ type GalleryListItem struct {
 const consecutiveOldPagesLimit = 3
 
 // GetPages fetches a page of galleries
-func (c *GalleryCrawler) GetPages(next string, expunged bool) ([]GalleryListItem, error) {
+func (c *GalleryCrawler) GetPages(ctx context.Context, next string, expunged bool) ([]GalleryListItem, error) {
 	url := fmt.Sprintf("https://%s/?next=%s&f_cats=0&advsearch=1&f_sname=on&f_stags=on", c.cfg.Host, next)
 
 	if expunged {
@@ -57,42 +59,38 @@ func (c *GalleryCrawler) GetPages(next string, expunged bool) ([]GalleryListItem
 	}
 	url += "&f_spf=&f_spt=&f_sfl=on&f_sfu=on&f_sft=on"
 
-	body, err := c.client.Get(url)
+	body, err := c.client.Get(ctx, url)
 	if err != nil {
 		return nil, err
 	}
 
-	// Parse gallery list from HTML
-	firstPattern := regexp.MustCompile(`gid=\d+&amp;t=[0-9a-f]{10}&.*?posted_.*?>\d{4}-\d{2}-\d{2}\s\d{2}:\d{2}<`)
-	firstMatches := firstPattern.FindAll(body, -1)
-	secondPattern := regexp.MustCompile(`gid=(\d+).*?t=([0-9a-f]{10}).*?>(\d{4}-\d{2}-\d{2}\s\d{2}:\d{2})<`)
-
-	var items []GalleryListItem
-	for _, entry := range firstMatches {
-		match := secondPattern.FindSubmatch(entry)
-		if len(match) >= 4 {
+	list, err := ehparse.ParseGalleryList(body)
+	if err == nil && len(list.Items) > 0 {
+		items := make([]GalleryListItem, 0, len(list.Items))
+		for _, item := range list.Items {
 			items = append(items, GalleryListItem{
-				Gid:    string(match[1]),
-				Token:  string(match[2]),
-				Posted: string(match[3]),
+				Gid:    strconv.Itoa(item.Gid),
+				Token:  item.Token,
+				Posted: item.Posted.Format(ehparse.PostedLayout),
 			})
 		}
+		return items, nil
 	}
 
-	if len(items) == 0 {
-		if reason, ok := abnormalGalleryListPageReason(body); ok {
-			reason = enrichAbnormalReasonWithAPIProbe(reason, c.client, c.logger)
-			return nil, fmt.Errorf("gallery list page abnormal: %s: %w", reason, ErrAbnormalPage)
-		}
+	if reason, ok := abnormalGalleryListPageReason(body); ok {
+		reason = enrichAbnormalReasonWithAPIProbe(ctx, reason, c.client, c.logger)
+		return nil, fmt.Errorf("gallery list page abnormal: %s: %w", reason, ErrAbnormalPage)
+	}
 
-		return nil, fmt.Errorf("gallery list page returned no parseable items: %w", ErrAbnormalPage)
+	if err != nil {
+		return nil, fmt.Errorf("gallery list page unparseable: %w: %w", err, ErrAbnormalPage)
 	}
 
-	return items, nil
+	return nil, fmt.Errorf("gallery list page returned no parseable items: %w", ErrAbnormalPage)
 }
 
 // GetMetadatas fetches metadata for a list of galleries from E-Hentai API
-func (c *GalleryCrawler) GetMetadatas(gidlist [][2]interface{}) ([]database.GalleryMetadata, error) {
+func (c *GalleryCrawler) GetMetadatas(ctx context.Context, gidlist [][2]interface{}) ([]database.GalleryMetadata, error) {
 	requestData := map[string]interface{}{
 		"method":    "gdata",
 		"gidlist":   gidlist,
@@ -104,7 +102,8 @@ func (c *GalleryCrawler) GetMetadatas(gidlist [][2]interface{}) ([]database.Gall
 		return nil, fmt.Errorf("marshal request: %w", err)
 	}
 
-	body, err := c.client.Post(c.client.apiURL(), jsonData)
+	jobrun.Add(ctx, jobrun.MetadataRequests, 1)
+	body, err := c.client.Post(ctx, c.client.apiURL(), jsonData)
 	if err != nil {
 		return nil, err
 	}
@@ -159,7 +158,7 @@ func (c *GalleryCrawler) Sync(ctx context.Context) error {
 		return err
 	}
 
-	allItems, err := c.collectGalleryItems(thresholdPosted)
+	allItems, err := c.collectGalleryItems(ctx, thresholdPosted)
 	if err != nil {
 		return err
 	}
@@ -170,9 +169,10 @@ func (c *GalleryCrawler) Sync(ctx context.Context) error {
 	}
 
 	c.logger.Info("found new galleries", zap.Int("count", len(allItems)))
+	jobrun.Add(ctx, jobrun.GalleriesDiscovered, int64(len(allItems)))
 	c.logGidRange("total", allItems)
 
-	allMetadata, err := c.fetchMetadataForItems(allItems)
+	allMetadata, err := c.fetchMetadataForItems(ctx, allItems)
 	if err != nil {
 		return err
 	}
@@ -186,6 +186,9 @@ func (c *GalleryCrawler) Sync(ctx context.Context) error {
 	return nil
 }
 
+// Backfill imports the galleries missing inside the configured window. The
+// position is checkpointed after every list page, so ResumeBackfill can
+// continue a run that died.
 func (c *GalleryCrawler) Backfill(ctx context.Context) error {
 	c.logger.Info("starting gallery backfill")
 
@@ -194,65 +197,159 @@ func (c *GalleryCrawler) Backfill(ctx context.Context) error {
 		return err
 	}
 
-	allItems, collectErr := c.collectGalleryItemsInWindow(window)
-	if collectErr != nil && errors.Is(collectErr, ErrAuthRequired) {
-		return collectErr
-	}
+	return c.runBackfill(ctx, &BackfillCheckpoint{
+		Host:        c.cfg.Host,
+		StartPosted: window.startPosted,
+		EndPosted:   window.endPosted,
+		StartNext:   window.startNext,
+		Next:        window.startNext,
+	})
+}
+
+// ResumeBackfill continues the backfill saved in state from its last list
+// page, importing the galleries it had listed but not imported yet
+func (c *GalleryCrawler) ResumeBackfill(ctx context.Context, state *BackfillCheckpoint) error {
+	c.logger.Info("resuming gallery backfill",
+		zap.Int64("start_posted", state.StartPosted),
+		zap.Int64("end_posted", state.EndPosted),
+		zap.Bool("expunged", state.Expunged),
+		zap.String("next", state.Next),
+		zap.Int("pending", len(state.Pending)),
+		zap.Int("discovered", state.Discovered),
+		zap.Int("imported", state.Imported),
+	)
+
+	return c.runBackfill(ctx, state)
+}
 
-	if collectErr != nil && len(allItems) == 0 {
-		return collectErr
+func (c *GalleryCrawler) runBackfill(ctx context.Context, state *BackfillCheckpoint) error {
+	seen := make(map[string]struct{}, len(state.Pending))
+	for _, item := range state.Pending {
+		seen[item.Gid] = struct{}{}
 	}
 
-	if len(allItems) == 0 {
-		if collectErr != nil {
-			return collectErr
+	for _, expunged := range []bool{false, true} {
+		if !expunged && state.Expunged {
+			continue // Normal pages were listed before the resumed run died
+		}
+		if expunged && !state.Expunged {
+			state.Expunged = true
+			state.Next = state.StartNext
 		}
 
+		mode := "normal"
+		if expunged {
+			mode = "expunged"
+		}
+		c.logger.Debug("fetching "+mode+" pages for backfill window", zap.String("next", state.Next))
+
+		// Errors of the page callback are import or checkpoint failures, not
+		// list failures, and are returned as they are
+		var pageErr error
+		_, err := c.fetchPages(ctx, expunged, state.StartPosted, state.EndPosted, state.Next, func(items []GalleryListItem, next string) error {
+			added := state.addPage(items, next, seen, c.parsePostedTime)
+			jobrun.Add(ctx, jobrun.GalleriesDiscovered, int64(added))
+
+			if len(state.Pending) >= checkpointImportBatch {
+				if pageErr = c.importBackfillPending(ctx, state); pageErr != nil {
+					return pageErr
+				}
+			}
+			if pageErr = saveCheckpoint(ctx, BackfillCheckpointKind, state); pageErr != nil {
+				return pageErr
+			}
+			return nil
+		})
+		if pageErr != nil {
+			if err := saveCheckpoint(ctx, BackfillCheckpointKind, state); err != nil {
+				return errors.Join(pageErr, err)
+			}
+			return pageErr
+		}
+		if err != nil {
+			return c.stopBackfill(ctx, state, fmt.Errorf("fetch %s pages: %w", mode, err))
+		}
+	}
+
+	if err := c.importBackfillPending(ctx, state); err != nil {
+		return err
+	}
+
+	if state.Discovered == 0 {
 		c.logger.Info("no galleries discovered for backfill")
-		return nil
+	} else {
+		c.logger.Info("gallery backfill completed",
+			zap.Int("discovered", state.Discovered),
+			zap.Int("missing", state.Missing),
+			zap.Int("imported", state.Imported),
+			zap.Int("min_gid", state.MinGid),
+			zap.Int("max_gid", state.MaxGid),
+		)
 	}
 
-	c.logger.Info("discovered galleries for backfill", zap.Int("count", len(allItems)))
-	c.logGidRange("backfill_discovered", allItems)
+	if err := checkpoint.Clear(ctx, BackfillCheckpointKind); err != nil {
+		c.logger.Warn("failed to clear backfill checkpoint", zap.Error(err))
+	}
+	return nil
+}
 
-	missingItems, err := c.filterMissingItems(ctx, allItems)
+// importBackfillPending imports the pending galleries missing from the
+// database and moves them into the processed range
+func (c *GalleryCrawler) importBackfillPending(ctx context.Context, state *BackfillCheckpoint) error {
+	if len(state.Pending) == 0 {
+		return nil
+	}
+
+	missingItems, err := c.filterMissingItems(ctx, state.Pending)
 	if err != nil {
 		return fmt.Errorf("filter missing galleries: %w", err)
 	}
 
 	c.logger.Info("identified missing galleries for backfill",
 		zap.Int("missing", len(missingItems)),
-		zap.Int("existing", len(allItems)-len(missingItems)),
+		zap.Int("existing", len(state.Pending)-len(missingItems)),
 	)
 
-	if len(missingItems) == 0 {
-		c.logger.Info("no missing galleries found during backfill")
-		if collectErr != nil {
-			return c.buildPartialBackfillError(window, allItems, 0, 0, collectErr)
+	if len(missingItems) > 0 {
+		c.logGidRange("backfill_missing", missingItems)
+
+		metadata, err := c.fetchMetadataForItems(ctx, missingItems)
+		if err != nil {
+			return err
 		}
 
-		return nil
+		importer := NewImporter(c.logger)
+		if err := importer.Import(ctx, metadata, false); err != nil {
+			return fmt.Errorf("import backfill data: %w", err)
+		}
+
+		state.Missing += len(missingItems)
+		state.Imported += countImportableMetadata(metadata)
 	}
 
-	c.logGidRange("backfill_missing", missingItems)
+	state.markProcessed()
+	return nil
+}
 
-	allMetadata, err := c.fetchMetadataForItems(missingItems)
-	if err != nil {
-		return err
+// stopBackfill checkpoints a backfill whose listing failed. Unless the run
+// was cancelled or lost its auth, the galleries listed so far are imported
+// first; the result reports the window left to crawl.
+func (c *GalleryCrawler) stopBackfill(ctx context.Context, state *BackfillCheckpoint, cause error) error {
+	if !errors.Is(cause, ErrAuthRequired) && ctx.Err() == nil {
+		if err := c.importBackfillPending(ctx, state); err != nil {
+			return errors.Join(cause, err)
+		}
 	}
 
-	importedCount := countImportableMetadata(allMetadata)
-
-	importer := NewImporter(c.logger)
-	if err := importer.Import(ctx, allMetadata, false); err != nil {
-		return fmt.Errorf("import backfill data: %w", err)
+	if err := saveCheckpoint(ctx, BackfillCheckpointKind, state); err != nil {
+		return errors.Join(cause, err)
 	}
 
-	if collectErr != nil {
-		return c.buildPartialBackfillError(window, allItems, len(missingItems), importedCount, collectErr)
+	if errors.Is(cause, ErrAuthRequired) || state.Discovered == 0 {
+		return cause
 	}
 
-	return nil
+	return c.buildPartialBackfillError(state, cause)
 }
 
 type backfillWindow struct {
@@ -341,18 +438,18 @@ func normalizePostedThreshold(posted int64) int64 {
 	return posted - (posted % 60)
 }
 
-func (c *GalleryCrawler) collectGalleryItems(thresholdPosted int64) ([]GalleryListItem, error) {
+func (c *GalleryCrawler) collectGalleryItems(ctx context.Context, thresholdPosted int64) ([]GalleryListItem, error) {
 	var allItems []GalleryListItem
 
 	c.logger.Debug("fetching normal pages")
-	items, err := c.fetchPages(false, thresholdPosted, 0, "")
+	items, err := c.fetchPages(ctx, false, thresholdPosted, 0, "", nil)
 	if err != nil {
 		return nil, fmt.Errorf("fetch normal pages: %w", err)
 	}
 	allItems = append(allItems, items...)
 
 	c.logger.Debug("fetching expunged pages")
-	items, err = c.fetchPages(true, thresholdPosted, 0, "")
+	items, err = c.fetchPages(ctx, true, thresholdPosted, 0, "", nil)
 	if err != nil {
 		return nil, fmt.Errorf("fetch expunged pages: %w", err)
 	}
@@ -361,40 +458,6 @@ func (c *GalleryCrawler) collectGalleryItems(thresholdPosted int64) ([]GalleryLi
 	return dedupeGalleryItems(allItems), nil
 }
 
-func (c *GalleryCrawler) collectGalleryItemsInWindow(window backfillWindow) ([]GalleryListItem, error) {
-	var allItems []GalleryListItem
-
-	c.logger.Debug("fetching normal pages for backfill window")
-	items, err := c.fetchPages(false, window.startPosted, window.endPosted, window.startNext)
-	if err != nil {
-		if errors.Is(err, ErrAuthRequired) || len(items) == 0 {
-			return nil, fmt.Errorf("fetch normal pages: %w", err)
-		}
-
-		allItems = append(allItems, items...)
-		return dedupeGalleryItems(allItems), fmt.Errorf("fetch normal pages: %w", err)
-	}
-	allItems = append(allItems, items...)
-
-	c.logger.Debug("fetching expunged pages for backfill window")
-	items, err = c.fetchPages(true, window.startPosted, window.endPosted, window.startNext)
-	if err != nil {
-		if errors.Is(err, ErrAuthRequired) {
-			return nil, fmt.Errorf("fetch expunged pages: %w", err)
-		}
-
-		allItems = append(allItems, items...)
-		if len(allItems) == 0 {
-			return nil, fmt.Errorf("fetch expunged pages: %w", err)
-		}
-
-		return dedupeGalleryItems(allItems), fmt.Errorf("fetch expunged pages: %w", err)
-	}
-	allItems = append(allItems, items...)
-
-	return dedupeGalleryItems(allItems), nil
-}
-
 func countImportableMetadata(metadataList []database.GalleryMetadata) int {
 	count := 0
 	for _, metadata := range metadataList {
@@ -408,13 +471,14 @@ func countImportableMetadata(metadataList []database.GalleryMetadata) int {
 	return count
 }
 
-func (c *GalleryCrawler) buildPartialBackfillError(window backfillWindow, allItems []GalleryListItem, missingCount, importedCount int, cause error) error {
-	resumeStart, resumeEnd, resumeOK := buildBackfillResumeWindow(window, allItems, c.parsePostedTime)
+func (c *GalleryCrawler) buildPartialBackfillError(state *BackfillCheckpoint, cause error) error {
+	resumeStart, resumeEnd, resumeOK := buildBackfillResumeWindow(state.StartPosted, state.resumePosted(c.parsePostedTime))
 	if resumeOK {
 		c.logger.Warn("gallery backfill partially completed",
-			zap.Int("discovered", len(allItems)),
-			zap.Int("missing", missingCount),
-			zap.Int("imported", importedCount),
+			zap.Int("discovered", state.Discovered),
+			zap.Int("missing", state.Missing),
+			zap.Int("imported", state.Imported),
+			zap.Int("pending", len(state.Pending)),
 			zap.String("resume_start", resumeStart.UTC().Format(time.RFC3339)),
 			zap.String("resume_end", resumeEnd.UTC().Format(time.RFC3339)),
 			zap.Error(cause),
@@ -422,48 +486,36 @@ func (c *GalleryCrawler) buildPartialBackfillError(window backfillWindow, allIte
 
 		return &PartialBackfillError{
 			Cause:           cause,
-			ImportedCount:   importedCount,
-			DiscoveredCount: len(allItems),
-			MissingCount:    missingCount,
+			ImportedCount:   state.Imported,
+			DiscoveredCount: state.Discovered,
+			MissingCount:    state.Missing,
 			ResumeStart:     resumeStart,
 			ResumeEnd:       resumeEnd,
 		}
 	}
 
 	c.logger.Warn("gallery backfill partially completed without resumable window",
-		zap.Int("discovered", len(allItems)),
-		zap.Int("missing", missingCount),
-		zap.Int("imported", importedCount),
+		zap.Int("discovered", state.Discovered),
+		zap.Int("missing", state.Missing),
+		zap.Int("imported", state.Imported),
 		zap.Error(cause),
 	)
 
-	return fmt.Errorf("partial backfill interrupted after importing %d of %d missing galleries (%d discovered total): %w",
-		importedCount,
-		missingCount,
-		len(allItems),
+	return fmt.Errorf("partial backfill interrupted after importing %d of %d missing galleries (%d discovered total); resume with -resume: %w",
+		state.Imported,
+		state.Missing,
+		state.Discovered,
 		cause,
 	)
 }
 
-func buildBackfillResumeWindow(window backfillWindow, items []GalleryListItem, parsePosted func(string) (int64, error)) (time.Time, time.Time, bool) {
-	oldestPosted := int64(0)
-	for _, item := range items {
-		posted, err := parsePosted(item.Posted)
-		if err != nil {
-			continue
-		}
-
-		if oldestPosted == 0 || posted < oldestPosted {
-			oldestPosted = posted
-		}
-	}
-
-	if oldestPosted <= 0 {
+func buildBackfillResumeWindow(startPosted, resumePosted int64) (time.Time, time.Time, bool) {
+	if resumePosted <= 0 {
 		return time.Time{}, time.Time{}, false
 	}
 
-	resumeStart := time.Unix(window.startPosted, 0).UTC()
-	resumeEnd := time.Unix(oldestPosted, 0).UTC()
+	resumeStart := time.Unix(startPosted, 0).UTC()
+	resumeEnd := time.Unix(resumePosted, 0).UTC()
 	if !resumeStart.Before(resumeEnd) {
 		return time.Time{}, time.Time{}, false
 	}
@@ -486,9 +538,13 @@ func dedupeGalleryItems(items []GalleryListItem) []GalleryListItem {
 	return result
 }
 
-func (c *GalleryCrawler) fetchMetadataForItems(items []GalleryListItem) ([]database.GalleryMetadata, error) {
+func (c *GalleryCrawler) fetchMetadataForItems(ctx context.Context, items []GalleryListItem) ([]database.GalleryMetadata, error) {
 	var allMetadata []database.GalleryMetadata
 	for i := 0; i < len(items); i += 25 {
+		if err := jobrun.Yield(ctx); err != nil {
+			return nil, err
+		}
+
 		end := i + 25
 		if end > len(items) {
 			end = len(items)
@@ -504,12 +560,13 @@ func (c *GalleryCrawler) fetchMetadataForItems(items []GalleryListItem) ([]datab
 		c.logger.Debug("fetching metadata batch", zap.Int("from", i), zap.Int("to", end))
 
 		metadata, err := Retry(RetryConfig{
+			Context:             ctx,
 			MaxRetries:          c.retryTimes,
 			Logger:              c.logger,
 			TransientRetryTimes: c.cfg.TransientRetryTimes,
 			WaitForIPUnban:      c.cfg.WaitForIPUnban,
 		}, func() ([]database.GalleryMetadata, error) {
-			return c.GetMetadatas(gidlist)
+			return c.GetMetadatas(ctx, gidlist)
 		})
 
 		if err != nil {
@@ -521,7 +578,9 @@ func (c *GalleryCrawler) fetchMetadataForItems(items []GalleryListItem) ([]datab
 		}
 
 		allMetadata = append(allMetadata, metadata...)
-		time.Sleep(time.Duration(c.cfg.APIDelaySeconds) * time.Second)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 	}
 
 	c.logger.Debug("fetched all metadata", zap.Int("count", len(allMetadata)))
@@ -600,8 +659,10 @@ func (c *GalleryCrawler) getExistingGalleryIDs(ctx context.Context, items []Gall
 	return existing, nil
 }
 
-// fetchPages fetches all pages until reaching lastPosted
-func (c *GalleryCrawler) fetchPages(expunged bool, lastPosted int64, endPosted int64, startNext string) ([]GalleryListItem, error) {
+// fetchPages fetches all pages until reaching lastPosted. If onPage is set it
+// is called after every page with the page's items inside the window and the
+// cursor of the following page, and an error from it stops the crawl.
+func (c *GalleryCrawler) fetchPages(ctx context.Context, expunged bool, lastPosted int64, endPosted int64, startNext string, onPage func(items []GalleryListItem, next string) error) ([]GalleryListItem, error) {
 	var allItems []GalleryListItem
 	next := startNext
 	page := 0
@@ -615,12 +676,13 @@ func (c *GalleryCrawler) fetchPages(expunged bool, lastPosted int64, endPosted i
 		)
 
 		items, err := Retry(RetryConfig{
+			Context:             ctx,
 			MaxRetries:          c.retryTimes,
 			Logger:              c.logger,
 			TransientRetryTimes: c.cfg.TransientRetryTimes,
 			WaitForIPUnban:      c.cfg.WaitForIPUnban,
 		}, func() ([]GalleryListItem, error) {
-			return c.GetPages(next, expunged)
+			return c.GetPages(ctx, next, expunged)
 		})
 
 		if err != nil {
@@ -636,6 +698,7 @@ func (c *GalleryCrawler) fetchPages(expunged bool, lastPosted int64, endPosted i
 
 		pageHasRecentItems := false
 		pageHasItemsAfterStart := false
+		var pageItems []GalleryListItem
 		for _, item := range items {
 			// Parse posted time: "2024-01-01 12:00" -> timestamp
 			posted, err := c.parsePostedTime(item.Posted)
@@ -649,10 +712,17 @@ func (c *GalleryCrawler) fetchPages(expunged bool, lastPosted int64, endPosted i
 			}
 
 			if posted >= lastPosted && (endPosted == 0 || posted <= endPosted) {
-				allItems = append(allItems, item)
+				pageItems = append(pageItems, item)
 				pageHasRecentItems = true
 			}
 		}
+		allItems = append(allItems, pageItems...)
+
+		if onPage != nil && len(items) > 0 {
+			if err := onPage(pageItems, items[len(items)-1].Gid); err != nil {
+				return allItems, err
+			}
+		}
 
 		if pageHasItemsAfterStart {
 			consecutiveOldPages = 0
@@ -680,8 +750,9 @@ func (c *GalleryCrawler) fetchPages(expunged bool, lastPosted int64, endPosted i
 		next = items[len(items)-1].Gid
 		page++
 
-		// Rate limiting for page fetches
-		time.Sleep(time.Duration(c.cfg.PageDelaySeconds) * time.Second)
+		if err := ctx.Err(); err != nil {
+			return allItems, fmt.Errorf("fetch page %d: %w", page, err)
+		}
 	}
 
 	return allItems, nil
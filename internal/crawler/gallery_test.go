@@ -1,6 +1,7 @@
 package crawler
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"net/http/httptest"
@@ -30,7 +31,7 @@ func TestGalleryCrawlerGetPagesRejectsAbnormalPage(t *testing.T) {
 		logger: zap.NewNop(),
 	}
 
-	_, err = crawler.GetPages("", false)
+	_, err = crawler.GetPages(context.Background(), "", false)
 	if err == nil {
 		t.Fatal("expected abnormal page error, got nil")
 	}
@@ -41,7 +42,7 @@ func TestGalleryCrawlerGetPagesRejectsAbnormalPage(t *testing.T) {
 }
 
 func TestGalleryCrawlerGetPagesParsesValidPage(t *testing.T) {
-	body := `<html><body><div class="searchnav"></div><script>var nexturl="https://e-hentai.org/?next=3865455";</script><a href="https://e-hentai.org/g/3865624/abcdef0123/" gid=3865624&amp;t=abcdef0123&foo=1 class="posted_foo">2026-03-30 12:00</a></body></html>`
+	body := `<html><body><div class="searchnav"></div><script>var nexturl="https://e-hentai.org/?next=3865455";</script><table class="itg gltc"><tr><td class="gl2c"><div onclick="popUp('https://e-hentai.org/gallerypopups.php?gid=3865624&amp;t=abcdef0123&amp;act=addfav',675,415)" id="posted_3865624">2026-03-30 12:00</div></td><td class="gl3c glname"><a href="https://e-hentai.org/g/3865624/abcdef0123/"><div class="glink">Title</div></a></td></tr></table></body></html>`
 	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
 		_, _ = w.Write([]byte(body))
@@ -59,7 +60,7 @@ func TestGalleryCrawlerGetPagesParsesValidPage(t *testing.T) {
 		logger: zap.NewNop(),
 	}
 
-	items, err := crawler.GetPages("", false)
+	items, err := crawler.GetPages(context.Background(), "", false)
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -97,7 +98,7 @@ func TestGalleryCrawlerGetPagesEnrichesAbnormalPageWithAPIBanProbe(t *testing.T)
 		logger: zap.NewNop(),
 	}
 
-	_, err = crawler.GetPages("", false)
+	_, err = crawler.GetPages(context.Background(), "", false)
 	if err == nil {
 		t.Fatal("expected abnormal page error, got nil")
 	}
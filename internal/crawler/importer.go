@@ -2,13 +2,16 @@ package crawler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sort"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/slinet/ehdb/internal/database"
+	"github.com/slinet/ehdb/internal/jobrun"
 	"github.com/slinet/ehdb/pkg/utils"
 	"go.uber.org/zap"
 )
@@ -23,275 +26,519 @@ func NewImporter(logger *zap.Logger) *Importer {
 	return &Importer{logger: logger}
 }
 
-// Import imports gallery metadata to database
+// interruptImportTimeout bounds the import of already fetched metadata after
+// a run is cancelled, so shutdown still finishes within seconds
+const interruptImportTimeout = 10 * time.Second
+
+// errFetchedImport marks an ImportInterrupted error whose import of the
+// fetched metadata failed, so callers know not to move past it
+var errFetchedImport = errors.New("import fetched metadata")
+
+// ImportInterrupted imports metadata fetched before ctx was cancelled, so a
+// stopped run keeps the work it already did, and returns ctx's error noting
+// how far the run got
+func (imp *Importer) ImportInterrupted(ctx context.Context, metadataList []database.GalleryMetadata, force bool, done, total int) error {
+	cause := ctx.Err()
+	if cause == nil {
+		cause = context.Canceled
+	}
+
+	if len(metadataList) > 0 {
+		importCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), interruptImportTimeout)
+		defer cancel()
+
+		imp.logger.Info("run interrupted, importing fetched metadata",
+			zap.Int("fetched", len(metadataList)),
+			zap.Int("done", done),
+			zap.Int("total", total),
+		)
+		if err := imp.Import(importCtx, metadataList, force); err != nil {
+			return fmt.Errorf("interrupted after %d of %d galleries, %w: %w", done, total, errFetchedImport, errors.Join(cause, err))
+		}
+	}
+
+	return fmt.Errorf("interrupted after %d of %d galleries: %w", done, total, cause)
+}
+
+// importBatchSize is how many galleries one import transaction stages and
+// upserts at once
+const importBatchSize = 1000
+
+// galleryImportColumns are the gallery columns an import writes, in the
+// order of galleryImportRow.values
+var galleryImportColumns = []string{
+	"gid", "token", "archiver_key", "title", "title_jpn", "category", "thumb", "uploader",
+	"posted", "filecount", "filesize", "expunged", "rating", "torrentcount", "tags",
+	"title_event", "title_circle", "title_artist", "title_base", "title_parody",
+	"title_language", "title_digital", "title_decensored", "title_ongoing",
+	"language", "translated", "rewrite",
+}
+
+// galleryImportRow is a gallery parsed from gdata metadata, ready to be
+// written
+type galleryImportRow struct {
+	metadata     database.GalleryMetadata
+	posted       time.Time
+	filecount    int
+	rating       float64
+	torrentcount int
+	tags         []string
+	parsedTitle  utils.ParsedTitle
+	language     utils.GalleryLanguage
+	exists       bool // Whether the gallery was stored before the import
+}
+
+// values returns the row's column values in galleryImportColumns order
+func (r *galleryImportRow) values() ([]interface{}, error) {
+	// Convert tags to JSONB array
+	tagsJSON, err := tagsToJSON(r.tags)
+	if err != nil {
+		return nil, fmt.Errorf("convert tags to JSON: %w", err)
+	}
+
+	return []interface{}{
+		r.metadata.Gid,
+		r.metadata.Token,
+		r.metadata.ArchiverKey,
+		r.metadata.Title,
+		r.metadata.TitleJpn,
+		r.metadata.Category,
+		r.metadata.Thumb,
+		r.metadata.Uploader,
+		r.posted,
+		r.filecount,
+		r.metadata.Filesize,
+		r.metadata.Expunged,
+		r.rating,
+		r.torrentcount,
+		tagsJSON,
+		nullIfEmpty(r.parsedTitle.Event),
+		nullIfEmpty(r.parsedTitle.Circle),
+		nullIfEmpty(r.parsedTitle.Artist),
+		r.parsedTitle.Title,
+		nullIfEmpty(r.parsedTitle.Parody),
+		nullIfEmpty(r.parsedTitle.Language),
+		r.parsedTitle.Digital,
+		r.parsedTitle.Decensored,
+		r.parsedTitle.Ongoing,
+		nullIfEmpty(r.language.Language),
+		r.language.Translated,
+		r.language.Rewrite,
+	}, nil
+}
+
+// Import imports gallery metadata to database. Galleries are looked up and
+// written in batches of importBatchSize, each staged with COPY and upserted
+// by one statement; existing galleries are only updated when gdata reports a
+// newer posted time, unless force is set. It returns an error when ctx ends
+// before every gallery was written, so callers keep their position.
 func (imp *Importer) Import(ctx context.Context, metadataList []database.GalleryMetadata, force bool) error {
 	imp.logger.Info("starting data import", zap.Int("count", len(metadataList)))
 
 	imported := 0
+	rows, unchanged := imp.parseGalleryRows(metadataList)
+	rows = dedupeGalleryRows(rows)
+
+	var importErr error
+	for from := 0; from < len(rows); from += importBatchSize {
+		to := min(from+importBatchSize, len(rows))
+		batch := rows[from:to]
+
+		// Look up only this batch's galleries, so memory and latency grow
+		// with the batch rather than the table
+		gids := make([]int, 0, len(batch))
+		for _, row := range batch {
+			gids = append(gids, row.metadata.Gid)
+		}
+		existing, err := imp.loadExistingPosted(ctx, gids)
+		if err != nil {
+			importErr = fmt.Errorf("load existing galleries: %w", err)
+			break
+		}
 
-	// Load existing galleries
-	existingGalleries, err := imp.loadGalleries(ctx)
-	if err != nil {
-		return fmt.Errorf("load galleries: %w", err)
+		changed, current := filterChangedRows(batch, existing, force)
+		unchanged = append(unchanged, current...)
+
+		batchImported, batchUnchanged, err := imp.importRows(ctx, changed, force)
+		imported += batchImported
+		unchanged = append(unchanged, batchUnchanged...)
+		if err != nil {
+			importErr = err
+			break
+		}
+
+		if to < len(rows) {
+			imp.logger.Info("import progress", zap.Int("processed", to), zap.Int("imported", imported))
+		}
+	}
+
+	if err := imp.touchSynced(ctx, unchanged); err != nil {
+		imp.logger.Error("failed to update last synced time", zap.Error(err))
+	}
+
+	imp.logger.Info("import completed", zap.Int("imported", imported))
+
+	if imported > 0 && importErr == nil {
+		imp.refreshTagStats(ctx)
 	}
 
-	for idx, metadata := range metadataList {
+	return importErr
+}
+
+// parseGalleryRows parses gdata metadata into rows to import. Galleries gdata
+// answered with an error are returned as gids to mark synced; galleries whose
+// metadata cannot be parsed are logged and dropped.
+func (imp *Importer) parseGalleryRows(metadataList []database.GalleryMetadata) ([]galleryImportRow, []int) {
+	rows := make([]galleryImportRow, 0, len(metadataList))
+	var failed []int
+	for _, metadata := range metadataList {
 		if metadata.Error != "" {
 			imp.logger.Warn("metadata has error, skipping", zap.Int("gid", metadata.Gid), zap.String("error", metadata.Error))
+			failed = append(failed, metadata.Gid)
 			continue
 		}
 
-		// Normalize tags
-		var normalizedTags []string
-		for _, tag := range metadata.Tags {
-			normalizedTags = append(normalizedTags, utils.NormalizeTag(tag))
-		}
-
 		// Parse posted time (format: "1609459200" Unix timestamp string)
 		postedInt, err := strconv.ParseInt(metadata.Posted, 10, 64)
 		if err != nil {
 			imp.logger.Error("failed to parse posted time", zap.Int("gid", metadata.Gid), zap.Error(err))
 			continue
 		}
-		posted := time.Unix(postedInt, 0).UTC()
+
+		// Normalize tags; aliases are kept as gdata reports them, since searches
+		// expand them at query time and the tag alias rewrite job canonicalizes
+		// stored tags only for aliases flagged canonicalize
+		var normalizedTags []string
+		for _, tag := range metadata.Tags {
+			normalizedTags = append(normalizedTags, utils.NormalizeTagName(tag))
+		}
 
 		// Parse numeric fields
 		filecount, _ := strconv.Atoi(metadata.Filecount)
 		rating, _ := strconv.ParseFloat(metadata.Rating, 64)
 		torrentcount, _ := strconv.Atoi(metadata.Torrentcount)
 
-		// Check if gallery exists
-		existingPosted, exists := existingGalleries[metadata.Gid]
-
-		if !exists {
-			// Insert new gallery
-			imp.logger.Debug("inserting new gallery", zap.Int("gid", metadata.Gid))
-
-			err := imp.insertGallery(ctx, metadata, posted, filecount, rating, torrentcount, normalizedTags)
-			if err != nil {
-				imp.logger.Error("failed to insert gallery", zap.Int("gid", metadata.Gid), zap.Error(err))
-				continue
-			}
-
-			imported++
-		} else if force || postedInt > existingPosted {
-			// Update existing gallery
-			imp.logger.Debug("updating existing gallery", zap.Int("gid", metadata.Gid))
-
-			err := imp.updateGallery(ctx, metadata, posted, filecount, rating, torrentcount, normalizedTags)
-			if err != nil {
-				imp.logger.Error("failed to update gallery", zap.Int("gid", metadata.Gid), zap.Error(err))
-				continue
-			}
-
-			imported++
-		}
+		rows = append(rows, galleryImportRow{
+			metadata:     metadata,
+			posted:       time.Unix(postedInt, 0).UTC(),
+			filecount:    filecount,
+			rating:       rating,
+			torrentcount: torrentcount,
+			tags:         normalizedTags,
+			// Parse structured title parts
+			parsedTitle: utils.ParseGalleryTitles(metadata.Title, metadata.TitleJpn),
+			// Derive primary language and translation flags from language: tags
+			language: utils.DeriveLanguage(normalizedTags, metadata.Category),
+		})
+	}
+	return rows, failed
+}
 
-		if (idx+1)%1000 == 0 {
-			imp.logger.Info("import progress", zap.Int("processed", idx+1), zap.Int("imported", imported))
+// filterChangedRows returns the rows to write given the posted times of the
+// stored galleries, setting their exists flag, and the gids of stored
+// galleries that are current. Without force, a stored gallery is only
+// written when gdata reports it posted later.
+func filterChangedRows(rows []galleryImportRow, existing map[int]int64, force bool) ([]galleryImportRow, []int) {
+	changed := make([]galleryImportRow, 0, len(rows))
+	var current []int
+	for _, row := range rows {
+		existingPosted, exists := existing[row.metadata.Gid]
+		if exists && !force && row.posted.Unix() <= existingPosted {
+			current = append(current, row.metadata.Gid)
+			continue
 		}
+		row.exists = exists
+		changed = append(changed, row)
 	}
+	return changed, current
+}
 
-	imp.logger.Info("import completed", zap.Int("imported", imported))
+// dedupeGalleryRows keeps the last row of each gid, since one upsert
+// statement cannot write the same gallery twice
+func dedupeGalleryRows(rows []galleryImportRow) []galleryImportRow {
+	last := make(map[int]int, len(rows))
+	for i, row := range rows {
+		last[row.metadata.Gid] = i
+	}
+	if len(last) == len(rows) {
+		return rows
+	}
 
-	// Refresh statistics if data was imported
-	if imported > 0 {
-		imp.logger.Debug("refreshing statistics views")
-		if err := imp.refreshStats(ctx); err != nil {
-			imp.logger.Error("failed to refresh stats", zap.Error(err))
+	deduped := make([]galleryImportRow, 0, len(last))
+	for i, row := range rows {
+		if last[row.metadata.Gid] == i {
+			deduped = append(deduped, row)
 		}
 	}
-
-	return nil
+	return deduped
 }
 
-// loadGalleries loads existing galleries from database
-func (imp *Importer) loadGalleries(ctx context.Context) (map[int]int64, error) {
-	pool := database.GetPool()
+// importRows writes one batch of rows and returns how many galleries were
+// inserted or updated, and the gids left unchanged because a newer version
+// was stored meanwhile. When the batch fails its rows are retried one by
+// one, so the galleries at fault are reported and the rest still written.
+// It returns an error only when ctx ends before the rows were written.
+func (imp *Importer) importRows(ctx context.Context, rows []galleryImportRow, force bool) (int, []int, error) {
+	if len(rows) == 0 {
+		return 0, nil, nil
+	}
 
-	query := `SELECT gid, EXTRACT(EPOCH FROM posted)::bigint FROM gallery`
-	imp.logger.Debug("executing query",
-		zap.String("sql", utils.FormatSQL(query)),
-	)
-	rows, err := pool.Query(ctx, query)
+	written, err := imp.upsertGalleries(ctx, rows, force)
 	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+		if ctx.Err() != nil {
+			return 0, nil, fmt.Errorf("import %d galleries: %w", len(rows), errors.Join(ctx.Err(), err))
+		}
+		if len(rows) == 1 {
+			if rows[0].exists {
+				imp.logger.Error("failed to update gallery", zap.Int("gid", rows[0].metadata.Gid), zap.Error(err))
+			} else {
+				imp.logger.Error("failed to insert gallery", zap.Int("gid", rows[0].metadata.Gid), zap.Error(err))
+			}
+			return 0, nil, nil
+		}
 
-	galleries := make(map[int]int64)
-	for rows.Next() {
-		var gid int
-		var posted int64
-		if err := rows.Scan(&gid, &posted); err != nil {
-			return nil, err
+		imp.logger.Warn("batch import failed, importing galleries one by one", zap.Int("count", len(rows)), zap.Error(err))
+		imported := 0
+		var unchanged []int
+		for i := range rows {
+			rowImported, rowUnchanged, err := imp.importRows(ctx, rows[i:i+1], force)
+			imported += rowImported
+			unchanged = append(unchanged, rowUnchanged...)
+			if err != nil {
+				return imported, unchanged, err
+			}
 		}
-		galleries[gid] = posted
+		return imported, unchanged, nil
 	}
 
-	return galleries, nil
+	imported := 0
+	var unchanged []int
+	for _, row := range rows {
+		inserted, ok := written[row.metadata.Gid]
+		switch {
+		case !ok:
+			unchanged = append(unchanged, row.metadata.Gid)
+		case inserted:
+			imported++
+			jobrun.Add(ctx, jobrun.GalleriesImported, 1)
+		default:
+			imported++
+			jobrun.Add(ctx, jobrun.GalleriesUpdated, 1)
+		}
+	}
+	return imported, unchanged, nil
 }
 
-// insertGallery inserts a new gallery
-func (imp *Importer) insertGallery(ctx context.Context, metadata database.GalleryMetadata, posted time.Time, filecount int, rating float64, torrentcount int, tags []string) error {
+// upsertGalleries stages rows in a temporary table with COPY, upserts their
+// tags, and inserts or updates the galleries in one statement, all in one
+// transaction. It returns the written gids, mapped to whether each was
+// inserted rather than updated.
+func (imp *Importer) upsertGalleries(ctx context.Context, rows []galleryImportRow, force bool) (map[int]bool, error) {
 	pool := database.GetPool()
 	tx, err := pool.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("begin transaction: %w", err)
+		return nil, fmt.Errorf("begin transaction: %w", err)
 	}
 	defer tx.Rollback(ctx)
 
-	// Convert tags to JSONB array
-	tagsJSON, err := tagsToJSON(tags)
-	if err != nil {
-		return fmt.Errorf("convert tags to JSON: %w", err)
+	// Text columns are unbounded here, so length violations are reported by
+	// the upsert into gallery
+	stageQuery := `
+		CREATE TEMP TABLE gallery_import (
+			gid              INTEGER NOT NULL,
+			token            TEXT NOT NULL,
+			archiver_key     TEXT NOT NULL,
+			title            TEXT NOT NULL,
+			title_jpn        TEXT NOT NULL,
+			category         TEXT NOT NULL,
+			thumb            TEXT NOT NULL,
+			uploader         TEXT,
+			posted           TIMESTAMPTZ NOT NULL,
+			filecount        INTEGER NOT NULL,
+			filesize         BIGINT NOT NULL,
+			expunged         BOOLEAN NOT NULL,
+			rating           NUMERIC NOT NULL,
+			torrentcount     INTEGER NOT NULL,
+			tags             JSONB NOT NULL,
+			title_event      TEXT,
+			title_circle     TEXT,
+			title_artist     TEXT,
+			title_base       TEXT,
+			title_parody     TEXT,
+			title_language   TEXT,
+			title_digital    BOOLEAN NOT NULL,
+			title_decensored BOOLEAN NOT NULL,
+			title_ongoing    BOOLEAN NOT NULL,
+			language         TEXT,
+			translated       BOOLEAN NOT NULL,
+			rewrite          BOOLEAN NOT NULL
+		) ON COMMIT DROP
+	`
+
+	imp.logger.Debug("executing staging query",
+		zap.String("sql", utils.FormatSQL(stageQuery)),
+	)
+
+	if _, err := tx.Exec(ctx, stageQuery); err != nil {
+		return nil, fmt.Errorf("create staging table: %w", err)
+	}
+
+	copyRows := make([][]interface{}, 0, len(rows))
+	var tags []string
+	for i := range rows {
+		values, err := rows[i].values()
+		if err != nil {
+			return nil, fmt.Errorf("gallery %d: %w", rows[i].metadata.Gid, err)
+		}
+		copyRows = append(copyRows, values)
+		tags = append(tags, rows[i].tags...)
+	}
+
+	imp.logger.Debug("staging galleries", zap.Int("count", len(copyRows)))
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"gallery_import"}, galleryImportColumns, pgx.CopyFromRows(copyRows)); err != nil {
+		return nil, fmt.Errorf("stage galleries: %w", err)
 	}
 
 	if err := imp.upsertTags(ctx, tx, tags); err != nil {
-		return err
+		return nil, err
 	}
 
+	// xmax is zero for rows the statement inserted, and set for rows it
+	// updated. Staged rows are sorted so concurrent imports lock galleries
+	// in the same order.
 	query := `
 		INSERT INTO gallery (
 			gid, token, archiver_key, title, title_jpn, category, thumb, uploader,
-			posted, filecount, filesize, expunged, rating, torrentcount, tags
-		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15
+			posted, filecount, filesize, expunged, rating, torrentcount, tags,
+			title_event, title_circle, title_artist, title_base, title_parody,
+			title_language, title_digital, title_decensored, title_ongoing,
+			language, translated, rewrite, last_synced_at
 		)
+		SELECT
+			gid, token, archiver_key, title, title_jpn, category, thumb, uploader,
+			posted, filecount, filesize, expunged, rating, torrentcount, tags,
+			title_event, title_circle, title_artist, title_base, title_parody,
+			title_language, title_digital, title_decensored, title_ongoing,
+			language, translated, rewrite, NOW()
+		FROM gallery_import
+		ORDER BY gid
+		ON CONFLICT (gid) DO UPDATE SET
+			token = EXCLUDED.token,
+			archiver_key = EXCLUDED.archiver_key,
+			title = EXCLUDED.title,
+			title_jpn = EXCLUDED.title_jpn,
+			category = EXCLUDED.category,
+			thumb = EXCLUDED.thumb,
+			uploader = EXCLUDED.uploader,
+			posted = EXCLUDED.posted,
+			filecount = EXCLUDED.filecount,
+			filesize = EXCLUDED.filesize,
+			expunged = EXCLUDED.expunged,
+			rating = EXCLUDED.rating,
+			torrentcount = EXCLUDED.torrentcount,
+			bytorrent = false,
+			tags = EXCLUDED.tags,
+			title_event = EXCLUDED.title_event,
+			title_circle = EXCLUDED.title_circle,
+			title_artist = EXCLUDED.title_artist,
+			title_base = EXCLUDED.title_base,
+			title_parody = EXCLUDED.title_parody,
+			title_language = EXCLUDED.title_language,
+			title_digital = EXCLUDED.title_digital,
+			title_decensored = EXCLUDED.title_decensored,
+			title_ongoing = EXCLUDED.title_ongoing,
+			language = EXCLUDED.language,
+			translated = EXCLUDED.translated,
+			rewrite = EXCLUDED.rewrite,
+			last_synced_at = NOW()
+		WHERE $1::boolean OR EXCLUDED.posted > gallery.posted
+		RETURNING gid, (xmax = 0) AS inserted
 	`
 
-	imp.logger.Debug("executing insert query",
-		zap.String("sql", utils.FormatSQL(query,
-			metadata.Gid,
-			metadata.Token,
-			metadata.ArchiverKey,
-			metadata.Title,
-			metadata.TitleJpn,
-			metadata.Category,
-			metadata.Thumb,
-			metadata.Uploader,
-			posted,
-			filecount,
-			metadata.Filesize,
-			metadata.Expunged,
-			rating,
-			torrentcount,
-			tagsJSON,
-		)),
+	imp.logger.Debug("executing upsert query",
+		zap.String("sql", utils.FormatSQL(query, force)),
+		zap.Int("gallery_count", len(rows)),
 	)
 
-	_, err = tx.Exec(ctx, query,
-		metadata.Gid,
-		metadata.Token,
-		metadata.ArchiverKey,
-		metadata.Title,
-		metadata.TitleJpn,
-		metadata.Category,
-		metadata.Thumb,
-		metadata.Uploader,
-		posted,
-		filecount,
-		metadata.Filesize,
-		metadata.Expunged,
-		rating,
-		torrentcount,
-		tagsJSON,
-	)
+	result, err := tx.Query(ctx, query, force)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("upsert galleries: %w", err)
+	}
+
+	written := make(map[int]bool, len(rows))
+	for result.Next() {
+		var gid int
+		var inserted bool
+		if err := result.Scan(&gid, &inserted); err != nil {
+			result.Close()
+			return nil, fmt.Errorf("scan upserted gallery: %w", err)
+		}
+		written[gid] = inserted
+	}
+	result.Close()
+	if err := result.Err(); err != nil {
+		return nil, fmt.Errorf("upsert galleries: %w", err)
 	}
 
 	if err := tx.Commit(ctx); err != nil {
-		return fmt.Errorf("commit transaction: %w", err)
+		return nil, fmt.Errorf("commit transaction: %w", err)
 	}
 
-	return nil
+	return written, nil
 }
 
-// updateGallery updates an existing gallery
-func (imp *Importer) updateGallery(ctx context.Context, metadata database.GalleryMetadata, posted time.Time, filecount int, rating float64, torrentcount int, tags []string) error {
+// loadExistingPosted returns the posted time, as a Unix timestamp, of the
+// stored galleries among gids
+func (imp *Importer) loadExistingPosted(ctx context.Context, gids []int) (map[int]int64, error) {
+	existing := make(map[int]int64, len(gids))
+	if len(gids) == 0 {
+		return existing, nil
+	}
+
 	pool := database.GetPool()
-	tx, err := pool.Begin(ctx)
+	query := `SELECT gid, EXTRACT(EPOCH FROM posted)::bigint FROM gallery WHERE gid = ANY($1)`
+	imp.logger.Debug("executing query",
+		zap.String("sql", utils.FormatSQL(query, gids)),
+	)
+	rows, err := pool.Query(ctx, query, gids)
 	if err != nil {
-		return fmt.Errorf("begin transaction: %w", err)
+		return nil, err
 	}
-	defer tx.Rollback(ctx)
+	defer rows.Close()
 
-	// Convert tags to JSONB array
-	tagsJSON, err := tagsToJSON(tags)
-	if err != nil {
-		return fmt.Errorf("convert tags to JSON: %w", err)
+	for rows.Next() {
+		var gid int
+		var posted int64
+		if err := rows.Scan(&gid, &posted); err != nil {
+			return nil, err
+		}
+		existing[gid] = posted
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	if err := imp.upsertTags(ctx, tx, tags); err != nil {
-		return err
+	return existing, nil
+}
+
+// touchSynced sets last_synced_at of galleries gdata returned without
+// changes, or with an error, which were checked all the same
+func (imp *Importer) touchSynced(ctx context.Context, gids []int) error {
+	if len(gids) == 0 {
+		return nil
 	}
 
-	query := `
-		UPDATE gallery SET
-			token = $2,
-			archiver_key = $3,
-			title = $4,
-			title_jpn = $5,
-			category = $6,
-			thumb = $7,
-			uploader = $8,
-			posted = $9,
-			filecount = $10,
-			filesize = $11,
-			expunged = $12,
-			rating = $13,
-			torrentcount = $14,
-			bytorrent = false,
-			tags = $15
-		WHERE gid = $1
-	`
+	pool := database.GetPool()
+	query := `UPDATE gallery SET last_synced_at = NOW() WHERE gid = ANY($1)`
 
 	imp.logger.Debug("executing update query",
-		zap.String("sql", utils.FormatSQL(query,
-			metadata.Gid,
-			metadata.Token,
-			metadata.ArchiverKey,
-			metadata.Title,
-			metadata.TitleJpn,
-			metadata.Category,
-			metadata.Thumb,
-			metadata.Uploader,
-			posted,
-			filecount,
-			metadata.Filesize,
-			metadata.Expunged,
-			rating,
-			torrentcount,
-			tagsJSON,
-		)),
+		zap.String("sql", utils.FormatSQL(query, gids)),
 	)
 
-	_, err = tx.Exec(ctx, query,
-		metadata.Gid,
-		metadata.Token,
-		metadata.ArchiverKey,
-		metadata.Title,
-		metadata.TitleJpn,
-		metadata.Category,
-		metadata.Thumb,
-		metadata.Uploader,
-		posted,
-		filecount,
-		metadata.Filesize,
-		metadata.Expunged,
-		rating,
-		torrentcount,
-		tagsJSON,
-	)
-	if err != nil {
-		return err
-	}
-
-	if err := tx.Commit(ctx); err != nil {
-		return fmt.Errorf("commit transaction: %w", err)
+	if _, err := pool.Exec(ctx, query, gids); err != nil {
+		return fmt.Errorf("touch last synced time: %w", err)
 	}
-
 	return nil
 }
 
@@ -321,20 +568,37 @@ func (imp *Importer) upsertTags(ctx context.Context, tx pgx.Tx, tags []string) e
 	return nil
 }
 
-// refreshStats refreshes statistics materialized views
-func (imp *Importer) refreshStats(ctx context.Context) error {
-	pool := database.GetPool()
+// tagStatsRefreshInterval is the least time between refreshes of
+// tag_stats_mv after imports. The view is rebuilt from the tags of every
+// gallery, so refreshing it after every import would cost more than the
+// imports themselves.
+const tagStatsRefreshInterval = 10 * time.Minute
+
+// tagStatsRefresh records when this process last refreshed tag_stats_mv
+var tagStatsRefresh struct {
+	mu   sync.Mutex
+	last time.Time
+}
 
-	query := "SELECT refresh_all_stats(false)"
+// refreshTagStats refreshes tag_stats_mv, which backs tag prefix search, at
+// most once per tagStatsRefreshInterval. The refresh runs concurrently, so
+// tag searches keep reading the previous counts meanwhile.
+func (imp *Importer) refreshTagStats(ctx context.Context) {
+	tagStatsRefresh.mu.Lock()
+	if time.Since(tagStatsRefresh.last) < tagStatsRefreshInterval {
+		tagStatsRefresh.mu.Unlock()
+		return
+	}
+	tagStatsRefresh.last = time.Now()
+	tagStatsRefresh.mu.Unlock()
+
+	query := "SELECT refresh_all_stats(true)"
 	imp.logger.Debug("executing stats refresh",
 		zap.String("sql", utils.FormatSQL(query)),
 	)
-	_, err := pool.Exec(ctx, query)
-	if err != nil {
-		return fmt.Errorf("refresh stats: %w", err)
+	if _, err := database.GetPool().Exec(ctx, query); err != nil {
+		imp.logger.Error("failed to refresh tag stats", zap.Error(err))
 	}
-
-	return nil
 }
 
 // tagsToJSON converts tag array to JSON string
@@ -377,3 +641,11 @@ func prepareTagsForUpsert(tags []string) []string {
 
 	return preparedTags
 }
+
+// nullIfEmpty stores empty optional text columns as NULL
+func nullIfEmpty(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
@@ -2,7 +2,14 @@ package crawler
 
 import (
 	"reflect"
+	"strconv"
 	"testing"
+	"time"
+
+	"github.com/slinet/ehdb/internal/database"
+	"github.com/slinet/ehdb/internal/ehmock"
+	"github.com/slinet/ehdb/pkg/utils"
+	"go.uber.org/zap"
 )
 
 func TestPrepareTagsForUpsert(t *testing.T) {
@@ -35,3 +42,184 @@ func TestPrepareTagsForUpsert(t *testing.T) {
 		}
 	})
 }
+
+func TestGalleryImportRowValues(t *testing.T) {
+	row := galleryImportRow{
+		metadata: database.GalleryMetadata{Gid: 42, Token: "abcdef0123", Title: "[Circle (Artist)] Title"},
+		tags:     []string{"artist:artist", "language:english"},
+		parsedTitle: utils.ParsedTitle{
+			Circle: "Circle",
+			Artist: "Artist",
+			Title:  "Title",
+		},
+	}
+
+	values, err := row.values()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(values) != len(galleryImportColumns) {
+		t.Fatalf("expected %d values, got %d", len(galleryImportColumns), len(values))
+	}
+
+	column := func(name string) interface{} {
+		for i, c := range galleryImportColumns {
+			if c == name {
+				return values[i]
+			}
+		}
+		t.Fatalf("expected column %s", name)
+		return nil
+	}
+
+	if got := column("gid"); got != 42 {
+		t.Fatalf("expected gid 42, got %v", got)
+	}
+	if got := column("tags"); got != `["artist:artist","language:english"]` {
+		t.Fatalf("expected tags JSON, got %v", got)
+	}
+	if got := column("title_circle").(*string); got == nil || *got != "Circle" {
+		t.Fatalf("expected title_circle Circle, got %v", got)
+	}
+	if got := column("title_event").(*string); got != nil {
+		t.Fatalf("expected NULL title_event, got %v", *got)
+	}
+}
+
+func TestDedupeGalleryRows(t *testing.T) {
+	rows := []galleryImportRow{
+		{metadata: database.GalleryMetadata{Gid: 1, Title: "first"}},
+		{metadata: database.GalleryMetadata{Gid: 2, Title: "only"}},
+		{metadata: database.GalleryMetadata{Gid: 1, Title: "second"}},
+	}
+
+	got := dedupeGalleryRows(rows)
+	var titles []string
+	for _, row := range got {
+		titles = append(titles, row.metadata.Title)
+	}
+
+	want := []string{"only", "second"}
+	if !reflect.DeepEqual(titles, want) {
+		t.Fatalf("expected %v, got %v", want, titles)
+	}
+}
+
+func TestFilterChangedRows(t *testing.T) {
+	posted := time.Unix(1700000000, 0).UTC()
+	rows := []galleryImportRow{
+		{metadata: database.GalleryMetadata{Gid: 1}, posted: posted},
+		{metadata: database.GalleryMetadata{Gid: 2}, posted: posted},
+		{metadata: database.GalleryMetadata{Gid: 3}, posted: posted},
+	}
+	existing := map[int]int64{
+		1: posted.Unix(),     // Current
+		2: posted.Unix() - 1, // Stored with an earlier posted time
+	}
+
+	tests := []struct {
+		name        string
+		force       bool
+		wantChanged []int
+		wantExists  []bool
+		wantCurrent []int
+	}{
+		{"newer and new galleries", false, []int{2, 3}, []bool{true, false}, []int{1}},
+		{"force writes all", true, []int{1, 2, 3}, []bool{true, true, false}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed, current := filterChangedRows(rows, existing, tt.force)
+
+			var gids []int
+			var exists []bool
+			for _, row := range changed {
+				gids = append(gids, row.metadata.Gid)
+				exists = append(exists, row.exists)
+			}
+			if !reflect.DeepEqual(gids, tt.wantChanged) {
+				t.Fatalf("expected changed %v, got %v", tt.wantChanged, gids)
+			}
+			if !reflect.DeepEqual(exists, tt.wantExists) {
+				t.Fatalf("expected exists %v, got %v", tt.wantExists, exists)
+			}
+			if !reflect.DeepEqual(current, tt.wantCurrent) {
+				t.Fatalf("expected current %v, got %v", tt.wantCurrent, current)
+			}
+		})
+	}
+}
+
+// mockMetadata converts mock galleries into the metadata gdata returns for
+// them
+func mockMetadata(galleries []ehmock.Gallery) []database.GalleryMetadata {
+	metadata := make([]database.GalleryMetadata, 0, len(galleries))
+	for _, g := range galleries {
+		metadata = append(metadata, database.GalleryMetadata{
+			Gid:          g.Gid,
+			Token:        g.Token,
+			Title:        g.Title,
+			TitleJpn:     g.TitleJpn,
+			Category:     g.Category,
+			Uploader:     g.Uploader,
+			Posted:       strconv.FormatInt(g.Posted.Unix(), 10),
+			Filecount:    strconv.Itoa(g.Filecount),
+			Filesize:     g.Filesize,
+			Expunged:     g.Expunged,
+			Rating:       strconv.FormatFloat(g.Rating, 'f', 2, 64),
+			Torrentcount: strconv.Itoa(len(g.Torrents)),
+			Tags:         g.Tags,
+		})
+	}
+	return metadata
+}
+
+// BenchmarkImportPrepare measures the in-memory part of an import: parsing
+// metadata, picking the rows to write against the stored posted times, and
+// building the COPY values
+func BenchmarkImportPrepare(b *testing.B) {
+	for _, size := range []int{metadataBatchSize, importBatchSize} {
+		b.Run(strconv.Itoa(size), func(b *testing.B) {
+			metadata := mockMetadata(ehmock.Generate(size, 3865624, mockNewest, time.Hour))
+			existing := make(map[int]int64, size/2)
+			for _, m := range metadata[:size/2] {
+				posted, _ := strconv.ParseInt(m.Posted, 10, 64)
+				existing[m.Gid] = posted
+			}
+			imp := NewImporter(zap.NewNop())
+
+			b.ReportAllocs()
+			for b.Loop() {
+				rows, _ := imp.parseGalleryRows(metadata)
+				changed, _ := filterChangedRows(dedupeGalleryRows(rows), existing, false)
+				for i := range changed {
+					if _, err := changed[i].values(); err != nil {
+						b.Fatalf("expected no error, got %v", err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestParseGalleryRowsKeepsAliasTags(t *testing.T) {
+	utils.SetTagRules(utils.NewTagRules(map[string]string{"female:old name": "female:new name"}, nil))
+	t.Cleanup(func() { utils.SetTagRules(nil) })
+
+	imp := NewImporter(zap.NewNop())
+	rows, _ := imp.parseGalleryRows([]database.GalleryMetadata{{
+		Gid:      1,
+		Posted:   "1700000000",
+		Category: "Doujinshi",
+		Tags:     []string{"F:Old  Name"},
+	}})
+
+	if len(rows) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(rows))
+	}
+	want := []string{"female:old name"}
+	if !reflect.DeepEqual(rows[0].tags, want) {
+		t.Fatalf("expected %v, got %v", want, rows[0].tags)
+	}
+}
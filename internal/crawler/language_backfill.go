@@ -0,0 +1,126 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/slinet/ehdb/internal/database"
+	"github.com/slinet/ehdb/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// defaultLanguageBackfillBatchSize is the number of galleries derived per update
+const defaultLanguageBackfillBatchSize = 5000
+
+// LanguageBackfiller derives the language columns for galleries imported
+// before the Importer started setting them
+type LanguageBackfiller struct {
+	logger    *zap.Logger
+	batchSize int
+}
+
+// NewLanguageBackfiller creates a new language backfiller
+func NewLanguageBackfiller(logger *zap.Logger, batchSize int) *LanguageBackfiller {
+	if batchSize <= 0 {
+		batchSize = defaultLanguageBackfillBatchSize
+	}
+	return &LanguageBackfiller{logger: logger, batchSize: batchSize}
+}
+
+// Backfill derives languages for every gallery with gid > startGid in gid
+// order. Language can legitimately stay NULL, so there is no "pending" marker;
+// an interrupted run is resumed by passing the last logged gid as startGid.
+// Only rows whose derived values differ are written.
+func (lb *LanguageBackfiller) Backfill(ctx context.Context, startGid int) error {
+	lb.logger.Info("starting language backfill", zap.Int("start_gid", startGid), zap.Int("batch_size", lb.batchSize))
+
+	pool := database.GetPool()
+
+	query := `
+		SELECT gid, category, COALESCE(tags, '[]'::jsonb)
+		FROM gallery
+		WHERE gid > $1
+		ORDER BY gid
+		LIMIT $2
+	`
+
+	lastGid := startGid
+	total := 0
+	updated := int64(0)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		lb.logger.Debug("executing query", zap.String("sql", utils.FormatSQL(query, lastGid, lb.batchSize)))
+
+		rows, err := pool.Query(ctx, query, lastGid, lb.batchSize)
+		if err != nil {
+			return fmt.Errorf("query galleries: %w", err)
+		}
+
+		var (
+			gids       []int
+			languages  []*string
+			translated []bool
+			rewrite    []bool
+		)
+		for rows.Next() {
+			var gid int
+			var category string
+			var tags []string
+			if err := rows.Scan(&gid, &category, &tags); err != nil {
+				rows.Close()
+				return fmt.Errorf("scan gallery: %w", err)
+			}
+
+			language := utils.DeriveLanguage(tags, category)
+			gids = append(gids, gid)
+			languages = append(languages, nullIfEmpty(language.Language))
+			translated = append(translated, language.Translated)
+			rewrite = append(rewrite, language.Rewrite)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("read galleries: %w", err)
+		}
+
+		if len(gids) == 0 {
+			break
+		}
+
+		updateQuery := `
+			UPDATE gallery g SET
+				language = p.language,
+				translated = p.translated,
+				rewrite = p.rewrite
+			FROM unnest($1::int[], $2::text[], $3::bool[], $4::bool[])
+				AS p(gid, language, translated, rewrite)
+			WHERE g.gid = p.gid
+			  AND (g.language IS DISTINCT FROM p.language
+			       OR g.translated <> p.translated
+			       OR g.rewrite <> p.rewrite)
+		`
+		lb.logger.Debug("executing update query",
+			zap.String("sql", utils.FormatSQL(updateQuery, gids[0], gids[len(gids)-1])),
+			zap.Int("galleries", len(gids)),
+		)
+
+		result, err := pool.Exec(ctx, updateQuery, gids, languages, translated, rewrite)
+		if err != nil {
+			return fmt.Errorf("update languages: %w", err)
+		}
+
+		total += len(gids)
+		updated += result.RowsAffected()
+		lastGid = gids[len(gids)-1]
+		lb.logger.Info("language backfill progress",
+			zap.Int("processed", total),
+			zap.Int64("updated", updated),
+			zap.Int("last_gid", lastGid),
+		)
+	}
+
+	lb.logger.Info("language backfill completed", zap.Int("processed", total), zap.Int64("updated", updated))
+	return nil
+}
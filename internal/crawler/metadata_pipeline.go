@@ -0,0 +1,224 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/slinet/ehdb/internal/database"
+	"github.com/slinet/ehdb/internal/jobrun"
+	"go.uber.org/zap"
+)
+
+// metadataBatchSize is how many galleries one gdata request asks for, the
+// most the API accepts
+const metadataBatchSize = 25
+
+// defaultMetadataWorkers is how many gdata requests resync and fetch keep in
+// flight when crawler.metadata_workers is not set
+const defaultMetadataWorkers = 4
+
+// metadataBatch is the outcome of one gdata request of fetchMetadataBatches
+// for the galleries list[from:to]
+type metadataBatch struct {
+	from     int
+	to       int
+	metadata []database.GalleryMetadata
+	err      error
+}
+
+// fetchMetadataBatches requests the metadata of list in batches of
+// metadataBatchSize with up to workers requests in flight, and delivers the
+// batches in list order. Requests still take turns in the API rate limit, so
+// workers overlap the time spent waiting for responses, retries and ban
+// waits rather than sending faster. Fetching runs at most workers batches
+// ahead of the consumer, which reads until the channel is closed or cancels
+// ctx to stop early; the channel is closed after the last batch or once ctx
+// is done.
+func (c *GalleryCrawler) fetchMetadataBatches(ctx context.Context, list [][2]interface{}, workers int) <-chan metadataBatch {
+	if workers <= 0 {
+		workers = defaultMetadataWorkers
+	}
+
+	// Each started request queues its result here in list order; the buffer
+	// and the result being delivered bound the requests in flight
+	started := make(chan chan metadataBatch, workers-1)
+	go func() {
+		defer close(started)
+		for from := 0; from < len(list); from += metadataBatchSize {
+			if err := jobrun.Yield(ctx); err != nil {
+				return
+			}
+
+			to := min(from+metadataBatchSize, len(list))
+			result := make(chan metadataBatch, 1)
+			select {
+			case started <- result:
+			case <-ctx.Done():
+				return
+			}
+
+			go func() {
+				c.logger.Debug("fetching metadata batch", zap.Int("from", from), zap.Int("to", to))
+				metadata, err := c.fetchMetadataBatch(ctx, list[from:to])
+				result <- metadataBatch{from: from, to: to, metadata: metadata, err: err}
+			}()
+		}
+	}()
+
+	out := make(chan metadataBatch)
+	go func() {
+		defer close(out)
+		for result := range started {
+			batch := <-result
+			select {
+			case out <- batch:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// fetchMetadataBatch requests the metadata of one batch with retries
+func (c *GalleryCrawler) fetchMetadataBatch(ctx context.Context, batch [][2]interface{}) ([]database.GalleryMetadata, error) {
+	return Retry(RetryConfig{
+		Context:             ctx,
+		MaxRetries:          c.retryTimes,
+		Logger:              c.logger,
+		TransientRetryTimes: c.cfg.TransientRetryTimes,
+		WaitForIPUnban:      c.cfg.WaitForIPUnban,
+	}, func() ([]database.GalleryMetadata, error) {
+		return c.GetMetadatas(ctx, batch)
+	})
+}
+
+// metadataSync fetches the metadata of a gallery list for resync, refresh and
+// fetch: batches arrive concurrently in list order, removals are tracked as
+// they do, and the fetched metadata is imported every checkpointImportBatch
+// galleries while the next batches are fetched
+type metadataSync struct {
+	crawler  *GalleryCrawler
+	importer *Importer
+	removals *removalTracker
+	logger   *zap.Logger
+
+	// fetched, if set, runs on every fetched batch before it is imported
+	fetched func(ctx context.Context, metadata []database.GalleryMetadata) error
+	// imported, if set, runs after every import with the imported metadata
+	// and how many galleries of the list, fetched or given up on, the
+	// imports covered so far
+	imported func(ctx context.Context, metadata []database.GalleryMetadata, done int) error
+	// maxRequests, if positive, stops fetching once the run's own gdata
+	// requests reach it; requests already in flight still finish
+	maxRequests int64
+}
+
+// run syncs list and returns how many of its galleries were imported or
+// given up on. When ctx is cancelled, the metadata already fetched is still
+// imported and ctx's error returned.
+func (s *metadataSync) run(ctx context.Context, list [][2]interface{}) (int, error) {
+	var pending []database.GalleryMetadata
+	done := 0
+	pendingCount := 0 // Galleries fetched, or given up on, since the last import
+
+	importPending := func() error {
+		if len(pending) > 0 {
+			if err := s.importer.Import(ctx, pending, true); err != nil {
+				return fmt.Errorf("import data: %w", err)
+			}
+		}
+		imported := pending
+		pending = nil
+		done += pendingCount
+		pendingCount = 0
+		if s.imported != nil {
+			return s.imported(ctx, imported, done)
+		}
+		return nil
+	}
+
+	// stop imports what was fetched before an error and returns err with
+	// the import's error, if any
+	stop := func(err error) (int, error) {
+		if importErr := importPending(); importErr != nil {
+			return done, errors.Join(err, importErr)
+		}
+		return done, err
+	}
+
+	// interrupted imports what was fetched before ctx was cancelled
+	interrupted := func() (int, error) {
+		err := s.importer.ImportInterrupted(ctx, pending, true, done+pendingCount, len(list))
+		if !errors.Is(err, errFetchedImport) {
+			done += pendingCount
+		}
+		return done, err
+	}
+
+	// Cancelled on return, which stops the batches still being fetched
+	fetchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for fetched := range s.crawler.fetchMetadataBatches(fetchCtx, list, s.crawler.cfg.MetadataWorkers) {
+		if err := fetched.err; err != nil {
+			if errors.Is(err, ErrAuthRequired) {
+				return stop(fmt.Errorf("auth failed while fetching metadata batch %d-%d: %w", fetched.from, fetched.to, err))
+			}
+			if ctx.Err() != nil {
+				return interrupted()
+			}
+			s.logger.Error("failed to fetch metadata batch", zap.Error(err))
+		} else {
+			pending = append(pending, fetched.metadata...)
+
+			if err := s.removals.Track(ctx, list[fetched.from:fetched.to], fetched.metadata); err != nil {
+				if ctx.Err() != nil {
+					return interrupted()
+				}
+				return stop(err)
+			}
+
+			if s.fetched != nil {
+				if err := s.fetched(ctx, fetched.metadata); err != nil {
+					if ctx.Err() != nil {
+						return interrupted()
+					}
+					return stop(err)
+				}
+			}
+		}
+		pendingCount += fetched.to - fetched.from
+
+		if pendingCount >= checkpointImportBatch {
+			if err := importPending(); err != nil {
+				return done, err
+			}
+		}
+
+		if ctx.Err() != nil {
+			return interrupted()
+		}
+
+		if s.maxRequests > 0 {
+			if requests := jobrun.Value(ctx, jobrun.MetadataRequests); requests >= s.maxRequests {
+				s.logger.Info("metadata request budget reached, stopping",
+					zap.Int64("requests", requests),
+					zap.Int("done", done+pendingCount),
+					zap.Int("total", len(list)),
+				)
+				break
+			}
+		}
+	}
+
+	// The batches stop early only when ctx is done or the budget is reached
+	if ctx.Err() != nil {
+		return interrupted()
+	}
+	if err := importPending(); err != nil {
+		return done, err
+	}
+	return done, nil
+}
@@ -0,0 +1,144 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/slinet/ehdb/internal/ehmock"
+	"github.com/slinet/ehdb/internal/jobrun"
+	"go.uber.org/zap"
+)
+
+// inFlightTransport delays each request and records the most requests that
+// were in flight at once
+type inFlightTransport struct {
+	next  http.RoundTripper
+	delay time.Duration
+
+	mu      sync.Mutex
+	current int
+	max     int
+}
+
+func (t *inFlightTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	t.current++
+	t.max = max(t.max, t.current)
+	t.mu.Unlock()
+
+	defer func() {
+		t.mu.Lock()
+		t.current--
+		t.mu.Unlock()
+	}()
+
+	time.Sleep(t.delay)
+	return t.next.RoundTrip(req)
+}
+
+func mockMetadataList(galleries []ehmock.Gallery) [][2]interface{} {
+	list := make([][2]interface{}, 0, len(galleries))
+	for _, g := range galleries {
+		list = append(list, [2]interface{}{g.Gid, ehmock.Token(g.Gid)})
+	}
+	return list
+}
+
+func TestFetchMetadataBatchesInOrder(t *testing.T) {
+	galleries := ehmock.Generate(130, 3865624, mockNewest, time.Hour)
+	server := ehmock.New(galleries)
+	defer server.Close()
+
+	crawler := mockGalleryCrawler(server, "e-hentai.org")
+	transport := &inFlightTransport{next: crawler.client.httpClient.Transport, delay: 20 * time.Millisecond}
+	crawler.client.httpClient.Transport = transport
+
+	list := mockMetadataList(galleries)
+	next := 0
+	for batch := range crawler.fetchMetadataBatches(context.Background(), list, 3) {
+		if batch.err != nil {
+			t.Fatalf("expected no error, got %v", batch.err)
+		}
+		if batch.from != next || batch.to != min(next+metadataBatchSize, len(list)) {
+			t.Fatalf("expected the batch from %d, got %d-%d", next, batch.from, batch.to)
+		}
+		for i, metadata := range batch.metadata {
+			if want := list[batch.from+i][0]; metadata.Gid != want {
+				t.Fatalf("expected gid %v at %d, got %d", want, batch.from+i, metadata.Gid)
+			}
+		}
+		next = batch.to
+	}
+
+	if next != len(list) {
+		t.Fatalf("expected batches up to %d, got %d", len(list), next)
+	}
+	if transport.max < 2 || transport.max > 3 {
+		t.Fatalf("expected 2 to 3 requests in flight, got %d", transport.max)
+	}
+}
+
+func TestFetchMetadataBatchesStopsOnCancel(t *testing.T) {
+	galleries := ehmock.Generate(500, 3865624, mockNewest, time.Hour)
+	server := ehmock.New(galleries)
+	defer server.Close()
+
+	crawler := mockGalleryCrawler(server, "e-hentai.org")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	batches := crawler.fetchMetadataBatches(ctx, mockMetadataList(galleries), 2)
+	if batch := <-batches; batch.err != nil || batch.from != 0 {
+		t.Fatalf("expected the first batch, got %d, %v", batch.from, batch.err)
+	}
+
+	// Fetching runs at most the worker count ahead of the consumer
+	time.Sleep(50 * time.Millisecond)
+	if got := server.Requests(ehmock.RouteAPI); got > 4 {
+		t.Fatalf("expected fetching to wait for the consumer, got %d requests", got)
+	}
+
+	cancel()
+	for range batches {
+	}
+	if got := server.Requests(ehmock.RouteAPI); got >= 20 {
+		t.Fatalf("expected cancelling to stop fetching, got %d requests", got)
+	}
+}
+
+func TestMetadataSyncStopsAtRequestBudget(t *testing.T) {
+	galleries := ehmock.Generate(250, 3865624, mockNewest, time.Hour)
+	server := ehmock.New(galleries)
+	defer server.Close()
+	shrinkBackoff(t)
+
+	// Every request fails, so the budget is spent on retries and no batch
+	// reaches the database
+	server.Inject(ehmock.RouteAPI, 1000, ehmock.Failure{Kind: ehmock.Unavailable})
+
+	crawler := mockGalleryCrawler(server, "e-hentai.org")
+	crawler.cfg.MetadataWorkers = 1
+	syncer := &metadataSync{
+		crawler:     crawler,
+		importer:    NewImporter(zap.NewNop()),
+		removals:    newRemovalTracker(crawler, zap.NewNop()),
+		logger:      zap.NewNop(),
+		maxRequests: 5,
+	}
+
+	ctx, _ := jobrun.WithCounters(context.Background())
+	done, err := syncer.run(ctx, mockMetadataList(galleries))
+	if err != nil {
+		t.Fatalf("expected the run to stop without error, got %v", err)
+	}
+	if done >= len(galleries) {
+		t.Fatalf("expected the run to stop before the last batch, got %d done", done)
+	}
+	// Only the batch fetched ahead of the one that spent the budget runs over
+	if got := server.Requests(ehmock.RouteAPI); got > 10 {
+		t.Fatalf("expected fetching to stop at the budget, got %d requests", got)
+	}
+}
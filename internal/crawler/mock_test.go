@@ -0,0 +1,320 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/slinet/ehdb/internal/config"
+	"github.com/slinet/ehdb/internal/ehmock"
+	"github.com/slinet/ehdb/internal/ehparse"
+	"go.uber.org/zap"
+)
+
+// mockGalleryCrawler returns a gallery crawler talking to server as host
+func mockGalleryCrawler(server *ehmock.Server, host string) *GalleryCrawler {
+	cfg := &config.CrawlerConfig{Host: host, RetryTimes: 3}
+	return &GalleryCrawler{
+		client:     &Client{httpClient: server.Client(), host: host, cookies: map[string]string{}},
+		cfg:        cfg,
+		logger:     zap.NewNop(),
+		retryTimes: cfg.RetryTimes,
+	}
+}
+
+// mockTorrentCrawler returns a torrent crawler talking to server as host
+func mockTorrentCrawler(server *ehmock.Server, host string) *TorrentCrawler {
+	cfg := &config.CrawlerConfig{Host: host, RetryTimes: 3}
+	return &TorrentCrawler{
+		client:     &Client{httpClient: server.Client(), host: host, cookies: map[string]string{}},
+		cfg:        cfg,
+		logger:     zap.NewNop(),
+		retryTimes: cfg.RetryTimes,
+	}
+}
+
+// shrinkBackoff makes retry waits instant for the duration of a test
+func shrinkBackoff(t *testing.T) {
+	t.Helper()
+	base, max, step := transientBaseBackoff, transientMaxBackoff, normalBackoffStep
+	transientBaseBackoff = time.Millisecond
+	transientMaxBackoff = 5 * time.Millisecond
+	normalBackoffStep = time.Millisecond
+	t.Cleanup(func() {
+		transientBaseBackoff = base
+		transientMaxBackoff = max
+		normalBackoffStep = step
+	})
+}
+
+var mockNewest = time.Date(2026, 3, 30, 12, 0, 0, 0, time.UTC)
+
+func TestMockGetPagesFollowsNextCursor(t *testing.T) {
+	galleries := ehmock.Generate(5, 3865624, mockNewest, time.Hour)
+	galleries[1].Expunged = true
+
+	server := ehmock.New(galleries)
+	defer server.Close()
+	server.SetPageSize(2)
+
+	crawler := mockGalleryCrawler(server, "e-hentai.org")
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		next     string
+		expunged bool
+		gids     []string
+	}{
+		{name: "first page skips expunged", gids: []string{"3865624", "3865622"}},
+		{name: "next page", next: "3865622", gids: []string{"3865621", "3865620"}},
+		{name: "expunged mode", expunged: true, gids: []string{"3865624", "3865623"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, err := crawler.GetPages(ctx, tt.next, tt.expunged)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if len(items) != len(tt.gids) {
+				t.Fatalf("expected %d items, got %#v", len(tt.gids), items)
+			}
+			for i, gid := range tt.gids {
+				if items[i].Gid != gid {
+					t.Fatalf("expected gid %s at %d, got %s", gid, i, items[i].Gid)
+				}
+			}
+		})
+	}
+
+	items, err := crawler.GetPages(ctx, "", false)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if items[0].Token != ehmock.Token(3865624) || items[0].Posted != "2026-03-30 12:00" {
+		t.Fatalf("unexpected parsed item: %#v", items[0])
+	}
+}
+
+func TestMockGetMetadatas(t *testing.T) {
+	galleries := ehmock.Generate(3, 3865624, mockNewest, time.Hour)
+	server := ehmock.New(galleries)
+	defer server.Close()
+
+	crawler := mockGalleryCrawler(server, "e-hentai.org")
+	metadata, err := crawler.GetMetadatas(context.Background(), [][2]interface{}{
+		{3865624, ehmock.Token(3865624)},
+		{3865623, "0000000000"},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(metadata) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(metadata))
+	}
+
+	first := metadata[0]
+	if first.Gid != 3865624 || first.Title != galleries[0].Title || first.Posted != fmt.Sprint(mockNewest.Unix()) || len(first.Tags) != 3 {
+		t.Fatalf("unexpected metadata: %#v", first)
+	}
+	if metadata[1].Error == "" {
+		t.Fatalf("expected error for wrong token, got %#v", metadata[1])
+	}
+	if got := server.Requests(ehmock.RouteAPI); got != 1 {
+		t.Fatalf("expected 1 api request, got %d", got)
+	}
+}
+
+func TestMockTorrentPages(t *testing.T) {
+	galleries := ehmock.Generate(3, 3865624, mockNewest, time.Hour)
+	galleries[1].RootGid = 3800000
+	galleries[1].Torrents = append(galleries[1].Torrents, ehmock.Torrent{
+		ID:       1,
+		Hash:     fmt.Sprintf("%040x", 1),
+		Name:     "Second torrent.zip",
+		Added:    mockNewest,
+		Size:     "1.2 GiB",
+		Uploader: "other user",
+	}, ehmock.Torrent{
+		ID:       2,
+		Name:     "Expunged torrent.zip",
+		Added:    mockNewest,
+		Size:     "1.1 GiB",
+		Uploader: "other user",
+		Expunged: true,
+	})
+
+	server := ehmock.New(galleries)
+	defer server.Close()
+
+	crawler := mockTorrentCrawler(server, "e-hentai.org")
+	ctx := context.Background()
+
+	items, err := crawler.fetchTorrentListPage(ctx, 0)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(items) != 5 || items[0].Gtid != 3865624 || items[4].Gtid != 1 || items[4].Gid != 3865623 {
+		t.Fatalf("unexpected torrent list: %#v", items)
+	}
+
+	body, err := crawler.client.Get(ctx, "https://e-hentai.org/gallerytorrents.php?gid=3865623&t="+ehmock.Token(3865623))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	page, err := ehparse.ParseGalleryTorrents(body)
+	if err != nil {
+		t.Fatalf("expected no parse error, got %v", err)
+	}
+	if page.RootGid != 3800000 {
+		t.Fatalf("expected root gid 3800000, got %d", page.RootGid)
+	}
+
+	torrents := databaseTorrents(page.Torrents, page.RootGid, false)
+	if len(torrents) != 2 {
+		t.Fatalf("expected 2 torrents, got %#v", torrents)
+	}
+	second := torrents[1]
+	if second.ID != 1 || second.Gid != 3800000 || second.Name != "Second torrent.zip" || *second.Fsizestr != "1.2 GiB" || second.Uploader != "other user" || *second.Addedstr != "2026-03-30 12:00" {
+		t.Fatalf("unexpected torrent: %#v", second)
+	}
+	if all := databaseTorrents(page.Torrents, page.RootGid, true); len(all) != 3 || !all[2].Expunged || all[2].Hash != nil || all[2].Name != "Expunged torrent.zip" {
+		t.Fatalf("expected expunged torrent to be kept without hash, got %#v", all)
+	}
+}
+
+func TestMockRetriesInjectedFailures(t *testing.T) {
+	shrinkBackoff(t)
+
+	tests := []struct {
+		name    string
+		failure ehmock.Failure
+	}{
+		{name: "rate limited", failure: ehmock.Failure{Kind: ehmock.RateLimited}},
+		{name: "unavailable", failure: ehmock.Failure{Kind: ehmock.Unavailable, RetryAfter: time.Second}},
+		{name: "temporary ban", failure: ehmock.Failure{Kind: ehmock.TemporaryBan}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := ehmock.New(ehmock.Generate(3, 3865624, mockNewest, time.Hour))
+			defer server.Close()
+			server.Inject(ehmock.RouteList, 2, tt.failure)
+
+			crawler := mockGalleryCrawler(server, "e-hentai.org")
+			items, err := Retry(RetryConfig{
+				Context:    context.Background(),
+				MaxRetries: 3,
+			}, func() ([]GalleryListItem, error) {
+				return crawler.GetPages(context.Background(), "", false)
+			})
+			if err != nil {
+				t.Fatalf("expected success after retries, got %v", err)
+			}
+			if len(items) != 3 {
+				t.Fatalf("expected 3 items, got %d", len(items))
+			}
+			if got := server.Requests(ehmock.RouteList); got != 3 {
+				t.Fatalf("expected 3 list requests, got %d", got)
+			}
+		})
+	}
+}
+
+func TestMockRejectsUnusablePages(t *testing.T) {
+	shrinkBackoff(t)
+
+	tests := []struct {
+		name     string
+		host     string
+		failure  ehmock.Failure
+		expected error
+		requests int
+	}{
+		{name: "exhentai shell", host: "exhentai.org", failure: ehmock.Failure{Kind: ehmock.ExHentaiShell}, expected: ErrAuthRequired, requests: 1},
+		{name: "cloudflare challenge", host: "e-hentai.org", failure: ehmock.Failure{Kind: ehmock.CloudflareChallenge}, expected: ErrAbnormalPage, requests: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := ehmock.New(ehmock.Generate(3, 3865624, mockNewest, time.Hour))
+			defer server.Close()
+			server.Inject(ehmock.RouteList, 10, tt.failure)
+
+			crawler := mockGalleryCrawler(server, tt.host)
+			_, err := Retry(RetryConfig{
+				Context:    context.Background(),
+				MaxRetries: 3,
+			}, func() ([]GalleryListItem, error) {
+				return crawler.GetPages(context.Background(), "", false)
+			})
+			if !errors.Is(err, tt.expected) {
+				t.Fatalf("expected %v, got %v", tt.expected, err)
+			}
+			if got := server.Requests(ehmock.RouteList); got != tt.requests {
+				t.Fatalf("expected %d list requests, got %d", tt.requests, got)
+			}
+		})
+	}
+}
+
+func TestMockGetDetail(t *testing.T) {
+	galleries := ehmock.Generate(3, 3865624, mockNewest, time.Hour)
+	galleries[0].Parent = galleries[2].Gid
+
+	server := ehmock.New(galleries)
+	defer server.Close()
+	shrinkBackoff(t)
+
+	crawler := mockGalleryCrawler(server, "e-hentai.org")
+	ctx := context.Background()
+
+	child, err := crawler.GetDetail(ctx, galleries[0].Gid, galleries[0].Token)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if child.ParentGid != galleries[2].Gid || child.ParentToken != galleries[2].Token || !child.Visible {
+		t.Fatalf("unexpected child detail: %#v", child)
+	}
+	if child.Language != "english" || !child.Translated || child.Favorites != 10 || child.RatingCount != 5 {
+		t.Fatalf("unexpected child detail: %#v", child)
+	}
+
+	// hc=1 includes the comment below the viewing threshold
+	expected := galleries[0].Comments
+	if len(child.Comments) != len(expected) {
+		t.Fatalf("expected %d comments, got %#v", len(expected), child.Comments)
+	}
+	for i, c := range child.Comments {
+		if c.ID != expected[i].ID || c.Author != expected[i].Author || c.Score != expected[i].Score ||
+			!c.Posted.Equal(expected[i].Posted) || !c.Edited.Equal(expected[i].Edited) || c.Body != expected[i].Body {
+			t.Fatalf("expected comment %d to be %#v, got %#v", i, expected[i], c)
+		}
+	}
+	if !child.Comments[0].Uploader || child.Comments[1].Uploader {
+		t.Fatalf("expected only the first comment to be the uploader's, got %#v", child.Comments)
+	}
+
+	parent, err := crawler.GetDetail(ctx, galleries[2].Gid, galleries[2].Token)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if parent.Visible || parent.HiddenReason != "Replaced" || len(parent.Newer) != 1 || parent.Newer[0].Gid != galleries[0].Gid {
+		t.Fatalf("unexpected parent detail: %#v", parent)
+	}
+
+	if detail, err := crawler.GetDetail(ctx, galleries[1].Gid, "0000000000"); err != nil || !detail.InvalidKey {
+		t.Fatalf("expected invalid key, got %#v, %v", detail, err)
+	}
+	if detail, err := crawler.GetDetail(ctx, 1, "0000000000"); err != nil || !detail.Unavailable {
+		t.Fatalf("expected unavailable gallery, got %#v, %v", detail, err)
+	}
+
+	server.Inject(ehmock.RouteGallery, 1, ehmock.Failure{Kind: ehmock.CloudflareChallenge})
+	if _, err := crawler.GetDetail(ctx, galleries[1].Gid, galleries[1].Token); !errors.Is(err, ErrAbnormalPage) {
+		t.Fatalf("expected %v, got %v", ErrAbnormalPage, err)
+	}
+}
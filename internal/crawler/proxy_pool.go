@@ -0,0 +1,280 @@
+package crawler
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// directProxy names the pool entry of a client without proxies
+const directProxy = "direct"
+
+const (
+	// proxyScoreWeight is the weight of the latest outcome in a proxy's
+	// health score, an exponential moving average of successes
+	proxyScoreWeight = 0.2
+	// minProxyScore is the score below which a better proxy is preferred
+	minProxyScore = 0.5
+	// defaultProxyBan is how long a proxy sits out after a ban whose
+	// duration could not be parsed
+	defaultProxyBan = 10 * time.Minute
+)
+
+// ProxyStatus is the health of one proxy of a client's pool
+type ProxyStatus struct {
+	Proxy       string // URL without credentials, or "direct"
+	Active      bool
+	Score       float64 // 1 for a proxy whose requests all succeeded, towards 0 as they fail
+	BannedUntil time.Time
+	Requests    int
+	Bans        int
+}
+
+// proxyEntry is one way out: a proxy, or the direct connection
+type proxyEntry struct {
+	name        string
+	transport   http.RoundTripper
+	score       float64
+	bannedUntil time.Time
+	requests    int
+	bans        int
+}
+
+// proxyPool picks the proxy each request goes through. The active proxy is
+// kept until it is banned or its score drops below minProxyScore, then the
+// best scoring proxy that is not banned takes over.
+type proxyPool struct {
+	mu      sync.Mutex
+	entries []*proxyEntry
+	active  int
+	now     func() time.Time
+}
+
+// newProxyPool builds a transport for each proxy URL, or one direct
+// transport when there are none
+func newProxyPool(proxyURLs []string) (*proxyPool, error) {
+	pool := &proxyPool{now: time.Now}
+	if len(proxyURLs) == 0 {
+		pool.entries = append(pool.entries, &proxyEntry{name: directProxy, transport: newBaseTransport(), score: 1})
+		return pool, nil
+	}
+
+	seen := make(map[string]bool)
+	for _, raw := range proxyURLs {
+		if seen[raw] {
+			continue
+		}
+		seen[raw] = true
+
+		transport, name, err := newProxyTransport(raw)
+		if err != nil {
+			return nil, err
+		}
+		pool.entries = append(pool.entries, &proxyEntry{name: name, transport: transport, score: 1})
+	}
+	return pool, nil
+}
+
+func newBaseTransport() *http.Transport {
+	return &http.Transport{
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: false,
+		},
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     90 * time.Second,
+	}
+}
+
+// newProxyTransport returns a transport through an HTTP or SOCKS5 proxy and
+// the proxy's URL without credentials for logs
+func newProxyTransport(raw string) (*http.Transport, string, error) {
+	proxyURL, err := url.Parse(raw)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid proxy URL: %w", err)
+	}
+
+	transport := newBaseTransport()
+	if proxyURL.Scheme == "socks5" {
+		// SOCKS5 proxy
+		dialer, err := proxy.SOCKS5("tcp", proxyURL.Host, socksAuth(proxyURL), proxy.Direct)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to create SOCKS5 dialer: %w", err)
+		}
+		transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+			return dialer.Dial(network, address)
+		}
+	} else {
+		// HTTP/HTTPS proxy
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	return transport, proxyURL.Redacted(), nil
+}
+
+func socksAuth(proxyURL *url.URL) *proxy.Auth {
+	if proxyURL.User == nil {
+		return nil
+	}
+	password, _ := proxyURL.User.Password()
+	return &proxy.Auth{User: proxyURL.User.Username(), Password: password}
+}
+
+// pick returns the index of the proxy for the next request
+func (p *proxyPool) pick() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	cur := p.entries[p.active]
+	if !cur.bannedUntil.After(now) && cur.score >= minProxyScore {
+		return p.active
+	}
+
+	best := -1
+	for i, entry := range p.entries {
+		if entry.bannedUntil.After(now) {
+			continue
+		}
+		if best < 0 || entry.score > p.entries[best].score {
+			best = i
+		}
+	}
+	if best < 0 {
+		// Every proxy is banned: use the one whose ban ends first
+		best = 0
+		for i, entry := range p.entries {
+			if entry.bannedUntil.Before(p.entries[best].bannedUntil) {
+				best = i
+			}
+		}
+	}
+	p.active = best
+	return best
+}
+
+// record updates the score of proxy i with the outcome of a request
+func (p *proxyPool) record(i int, ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entry := p.entries[i]
+	entry.requests++
+	outcome := 0.0
+	if ok {
+		outcome = 1
+	}
+	entry.score = entry.score*(1-proxyScoreWeight) + outcome*proxyScoreWeight
+}
+
+// ban takes proxy i out of rotation for d and reports whether another proxy
+// is available for the request to be sent again
+func (p *proxyPool) ban(i int, d time.Duration) bool {
+	if d <= 0 {
+		d = defaultProxyBan
+	}
+
+	p.mu.Lock()
+	entry := p.entries[i]
+	entry.bans++
+	entry.score *= 1 - proxyScoreWeight
+	if until := p.now().Add(d); until.After(entry.bannedUntil) {
+		entry.bannedUntil = until
+	}
+	p.mu.Unlock()
+
+	return p.available() > 0
+}
+
+// available counts the proxies that are not banned
+func (p *proxyPool) available() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	count := 0
+	for _, entry := range p.entries {
+		if !entry.bannedUntil.After(p.now()) {
+			count++
+		}
+	}
+	return count
+}
+
+func (p *proxyPool) name(i int) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.entries[i].name
+}
+
+func (p *proxyPool) size() int {
+	return len(p.entries)
+}
+
+func (p *proxyPool) status() []ProxyStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	statuses := make([]ProxyStatus, 0, len(p.entries))
+	for i, entry := range p.entries {
+		status := ProxyStatus{
+			Proxy:    entry.name,
+			Active:   i == p.active,
+			Score:    entry.score,
+			Requests: entry.requests,
+			Bans:     entry.bans,
+		}
+		if entry.bannedUntil.After(p.now()) {
+			status.BannedUntil = entry.bannedUntil
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// proxyChoice is carried by a request's context to pin it to a proxy and to
+// report the proxy it went through
+type proxyChoice struct {
+	pinned int // -1 to let the pool pick
+	used   int
+}
+
+type proxyChoiceKey struct{}
+
+// withProxyChoice returns a context whose requests go through proxy pinned,
+// or the pool's pick when pinned is -1, and the choice to read the used
+// proxy from after the request
+func withProxyChoice(ctx context.Context, pinned int) (context.Context, *proxyChoice) {
+	choice := &proxyChoice{pinned: pinned, used: -1}
+	return context.WithValue(ctx, proxyChoiceKey{}, choice), choice
+}
+
+// proxyTransport sends each request through a proxy of the pool and scores
+// the proxy by whether a response came back
+type proxyTransport struct {
+	pool *proxyPool
+}
+
+func (t *proxyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	i := -1
+	choice, _ := req.Context().Value(proxyChoiceKey{}).(*proxyChoice)
+	if choice != nil && choice.pinned >= 0 {
+		i = choice.pinned
+	}
+	if i < 0 {
+		i = t.pool.pick()
+	}
+	if choice != nil {
+		choice.used = i
+	}
+
+	resp, err := t.pool.entries[i].transport.RoundTrip(req)
+	t.pool.record(i, err == nil)
+	return resp, err
+}
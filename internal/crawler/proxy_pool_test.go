@@ -0,0 +1,197 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slinet/ehdb/internal/config"
+	"go.uber.org/zap"
+)
+
+// fakeProxy is an HTTP proxy that answers every request itself, with a ban
+// page when banned, and counts the requests it got
+type fakeProxy struct {
+	server   *httptest.Server
+	banned   bool
+	requests int
+}
+
+func newFakeProxy(t *testing.T, isBanned bool) *fakeProxy {
+	t.Helper()
+	p := &fakeProxy{banned: isBanned}
+	p.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.requests++
+		w.Header().Set("Content-Type", "text/html")
+		if p.banned {
+			banned(w)
+			return
+		}
+		_, _ = w.Write([]byte(`<html><body>ok via proxy</body></html>`))
+	}))
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+func newProxiedClient(t *testing.T, proxies ...*fakeProxy) *Client {
+	t.Helper()
+	setCookiesFilePathForTest(t, filepath.Join(t.TempDir(), "cookies.json"))
+
+	cfg := &config.CrawlerConfig{Host: "e-hentai.org", ConfigDir: filepath.Dir(cookiesFilePath)}
+	for _, p := range proxies {
+		cfg.Proxies = append(cfg.Proxies, p.server.URL)
+	}
+	client, err := NewClient(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	return client
+}
+
+func TestClientRotatesProxyOnBan(t *testing.T) {
+	first, second := newFakeProxy(t, true), newFakeProxy(t, false)
+	client := newProxiedClient(t, first, second)
+
+	body, err := client.Get(context.Background(), "http://e-hentai.test/")
+	if err != nil || !strings.Contains(string(body), "ok via proxy") {
+		t.Fatalf("expected the second proxy's page, got %q, %v", body, err)
+	}
+	if first.requests != 1 || second.requests != 1 {
+		t.Fatalf("expected one request per proxy, got %d and %d", first.requests, second.requests)
+	}
+
+	statuses := client.Proxies()
+	if statuses[0].Active || statuses[0].Bans != 1 || statuses[0].BannedUntil.Before(time.Now().Add(89*time.Minute)) {
+		t.Fatalf("expected first proxy banned for the ban's duration, got %#v", statuses[0])
+	}
+	if !statuses[1].Active || !statuses[1].BannedUntil.IsZero() {
+		t.Fatalf("expected second proxy active, got %#v", statuses[1])
+	}
+
+	// The banned proxy is skipped, and the credential was never quarantined
+	if _, err := client.Get(context.Background(), "http://e-hentai.test/"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if first.requests != 1 || second.requests != 2 {
+		t.Fatalf("expected the banned proxy to be skipped, got %d and %d", first.requests, second.requests)
+	}
+	if status := client.Credentials()[0]; status.Failures != 0 {
+		t.Fatalf("expected no credential failure, got %#v", status)
+	}
+}
+
+func TestClientReturnsBanWhenAllProxiesBanned(t *testing.T) {
+	first, second := newFakeProxy(t, true), newFakeProxy(t, true)
+	client := newProxiedClient(t, first, second)
+
+	body, err := client.Get(context.Background(), "http://e-hentai.test/")
+	if err != nil {
+		t.Fatalf("expected the ban page, got %v", err)
+	}
+	if !isTemporaryBanError(bannedResponse(body, nil)) {
+		t.Fatalf("expected the ban page, got %q", body)
+	}
+	for _, status := range client.Proxies() {
+		if status.BannedUntil.IsZero() {
+			t.Fatalf("expected every proxy banned, got %#v", status)
+		}
+	}
+}
+
+// proxyResponder answers requests as a proxy would relay them, without a
+// network, so HTTPS requests such as the API probe can be tested
+type proxyResponder struct {
+	body     string
+	requests int
+}
+
+func (p *proxyResponder) RoundTrip(req *http.Request) (*http.Response, error) {
+	p.requests++
+	rec := httptest.NewRecorder()
+	rec.Header().Set("Content-Type", "text/html")
+	_, _ = rec.WriteString(p.body)
+	return rec.Result(), nil
+}
+
+func TestProbeAPITemporaryBanPerProxy(t *testing.T) {
+	ban := "Your IP address has been temporarily banned for excessive pageloads. (The ban expires in 5 minutes and 10 seconds)"
+	first, second := &proxyResponder{body: "{}"}, &proxyResponder{body: ban}
+	pool := &proxyPool{now: time.Now, entries: []*proxyEntry{
+		{name: "first", transport: first, score: 1},
+		{name: "second", transport: second, score: 1},
+	}}
+	client := &Client{
+		httpClient: &http.Client{Transport: &proxyTransport{pool: pool}},
+		host:       "e-hentai.org",
+		proxies:    pool,
+	}
+
+	if reason, ok := probeAPITemporaryBan(context.Background(), client, zap.NewNop()); ok {
+		t.Fatalf("expected the active proxy to be fine, got %q", reason)
+	}
+	if first.requests != 1 || second.requests != 1 {
+		t.Fatalf("expected one probe per proxy, got %d and %d", first.requests, second.requests)
+	}
+	statuses := client.Proxies()
+	if !statuses[0].BannedUntil.IsZero() || statuses[1].BannedUntil.Before(time.Now().Add(5*time.Minute)) {
+		t.Fatalf("expected only the second proxy banned, for its ban, got %#v", statuses)
+	}
+
+	// Once the active proxy is banned too, the probe reports its ban
+	first.body = ban
+	if reason, ok := probeAPITemporaryBan(context.Background(), client, zap.NewNop()); !ok || !strings.Contains(reason, "5 minutes") {
+		t.Fatalf("expected the active proxy's ban, got %q, %v", reason, ok)
+	}
+}
+
+func TestProxyPoolPick(t *testing.T) {
+	now := time.Date(2026, 4, 1, 12, 0, 0, 0, time.UTC)
+	pool := &proxyPool{now: func() time.Time { return now }}
+	for _, name := range []string{"a", "b", "c"} {
+		pool.entries = append(pool.entries, &proxyEntry{name: name, score: 1})
+	}
+
+	if got := pool.pick(); got != 0 {
+		t.Fatalf("expected the first proxy, got %d", got)
+	}
+
+	// Failures drop a proxy's score until a better one takes over
+	for i := 0; i < 3; i++ {
+		pool.record(0, false)
+		if got := pool.pick(); got != 0 {
+			t.Fatalf("expected the first proxy after %d failures, got %d", i+1, got)
+		}
+	}
+	pool.record(0, false)
+	if got := pool.pick(); got != 1 {
+		t.Fatalf("expected the second proxy once the first scores below %v, got %d", minProxyScore, got)
+	}
+
+	// Bans skip a proxy until they expire
+	if !pool.ban(1, time.Minute) {
+		t.Fatal("expected another proxy to be available")
+	}
+	if got := pool.pick(); got != 2 {
+		t.Fatalf("expected the third proxy, got %d", got)
+	}
+	if !pool.ban(2, 2*time.Minute) {
+		t.Fatal("expected the first proxy to be available despite its score")
+	}
+
+	pool.ban(0, 3*time.Minute)
+	if got := pool.pick(); got != 1 {
+		t.Fatalf("expected the proxy whose ban ends first, got %d", got)
+	}
+
+	now = now.Add(90 * time.Second)
+	if got := pool.available(); got != 1 {
+		t.Fatalf("expected one proxy available after its ban, got %d", got)
+	}
+	if got := pool.pick(); got != 1 {
+		t.Fatalf("expected the unbanned proxy, got %d", got)
+	}
+}
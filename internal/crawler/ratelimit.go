@@ -0,0 +1,181 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// rateSlowdownFactor multiplies a bucket's interval on each 429 or ban
+	rateSlowdownFactor = 2
+	// maxRateSlowdown caps how far a bucket slows down
+	maxRateSlowdown = 8
+	// rateRecovery shrinks the slowdown on each successful request, so a
+	// bucket at the cap is back to its configured rate after about 100
+	rateRecovery = 0.98
+)
+
+// tokenBucket spaces requests to one token per interval, stretched by a
+// slowdown that rises on 429s and bans and decays on successes. Waits are
+// reserved in call order, so concurrent callers queue instead of bursting.
+type tokenBucket struct {
+	mu       sync.Mutex
+	interval time.Duration
+	slowdown float64
+	tokens   float64 // Up to one; negative when waits are reserved
+	last     time.Time
+	now      func() time.Time
+}
+
+func newTokenBucket(interval time.Duration) *tokenBucket {
+	return &tokenBucket{interval: interval, slowdown: 1, tokens: 1, now: time.Now}
+}
+
+func (b *tokenBucket) effectiveInterval() time.Duration {
+	return time.Duration(float64(b.interval) * b.slowdown)
+}
+
+// reserve takes a token and returns how long to wait before using it
+func (b *tokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	interval := b.effectiveInterval()
+	if interval <= 0 {
+		return 0
+	}
+
+	now := b.now()
+	if !b.last.IsZero() {
+		b.tokens += float64(now.Sub(b.last)) / float64(interval)
+		if b.tokens > 1 {
+			b.tokens = 1
+		}
+	}
+	b.last = now
+
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens * float64(interval))
+}
+
+// wait blocks until a token is available or ctx is done
+func (b *tokenBucket) wait(ctx context.Context) error {
+	return sleepContext(ctx, b.reserve())
+}
+
+// slowDown stretches the interval after a 429 or ban, returning the new
+// interval and whether it changed
+func (b *tokenBucket) slowDown() (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.interval <= 0 || b.slowdown >= maxRateSlowdown {
+		return b.effectiveInterval(), false
+	}
+	b.slowdown *= rateSlowdownFactor
+	if b.slowdown > maxRateSlowdown {
+		b.slowdown = maxRateSlowdown
+	}
+	return b.effectiveInterval(), true
+}
+
+// recover shrinks the slowdown after a successful request
+func (b *tokenBucket) recover() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.slowdown *= rateRecovery
+	if b.slowdown < 1 {
+		b.slowdown = 1
+	}
+}
+
+// hostLimiter holds the page and API buckets of one upstream host
+type hostLimiter struct {
+	page *tokenBucket
+	api  *tokenBucket
+}
+
+type limiterKey struct {
+	host string
+	page time.Duration
+	api  time.Duration
+}
+
+// limiters is shared by every client in the process, so concurrent jobs
+// against a host take turns. Clients configured with different delays get
+// their own buckets.
+var limiters = struct {
+	sync.Mutex
+	byKey map[limiterKey]*hostLimiter
+}{byKey: make(map[limiterKey]*hostLimiter)}
+
+// limiterFor returns the shared limiter of host for the given delays
+func limiterFor(host string, page, api time.Duration) *hostLimiter {
+	limiters.Lock()
+	defer limiters.Unlock()
+
+	key := limiterKey{host: host, page: page, api: api}
+	limiter, ok := limiters.byKey[key]
+	if !ok {
+		limiter = &hostLimiter{page: newTokenBucket(page), api: newTokenBucket(api)}
+		limiters.byKey[key] = limiter
+	}
+	return limiter
+}
+
+// bucket returns the bucket a request to rawURL waits on, or nil when the
+// client has no delays configured
+func (c *Client) bucket(rawURL string, api bool) *tokenBucket {
+	if c.pageDelay <= 0 && c.apiDelay <= 0 {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+
+	limiter := limiterFor(u.Host, c.pageDelay, c.apiDelay)
+	if api {
+		return limiter.api
+	}
+	return limiter.page
+}
+
+// waitRate waits for a token of bucket, which may be nil for no limit
+func waitRate(ctx context.Context, bucket *tokenBucket) error {
+	if bucket == nil {
+		return nil
+	}
+	return bucket.wait(ctx)
+}
+
+// adaptRate slows bucket down after a 429 or temporary ban and lets it
+// recover after a success
+func (c *Client) adaptRate(bucket *tokenBucket, rawURL string, body []byte, err error) {
+	if bucket == nil {
+		return
+	}
+
+	te, transient := asTransientError(err)
+	if (transient && te.StatusCode == http.StatusTooManyRequests) || bannedResponse(body, err) != nil {
+		if interval, changed := bucket.slowDown(); changed && c.logger != nil {
+			c.logger.Warn("rate limited, slowing down requests",
+				zap.String("url", rawURL),
+				zap.Duration("interval", interval),
+			)
+		}
+		return
+	}
+	if err == nil {
+		bucket.recover()
+	}
+}
@@ -0,0 +1,149 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// fakeClock is a clock tests move by hand
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func newTestBucket(interval time.Duration) (*tokenBucket, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2026, 4, 1, 12, 0, 0, 0, time.UTC)}
+	bucket := newTokenBucket(interval)
+	bucket.now = clock.Now
+	return bucket, clock
+}
+
+func TestTokenBucketSpacesRequests(t *testing.T) {
+	bucket, clock := newTestBucket(time.Second)
+
+	// The first request goes out at once, the ones right after it queue
+	for i, want := range []time.Duration{0, time.Second, 2 * time.Second} {
+		if got := bucket.reserve(); got != want {
+			t.Fatalf("expected reservation %d to wait %v, got %v", i, want, got)
+		}
+	}
+
+	// Once the queue has drained and a second has passed, no wait
+	clock.now = clock.now.Add(3 * time.Second)
+	if got := bucket.reserve(); got != 0 {
+		t.Fatalf("expected no wait after the interval, got %v", got)
+	}
+
+	// Idle time does not build up a burst
+	clock.now = clock.now.Add(time.Minute)
+	bucket.reserve()
+	if got := bucket.reserve(); got != time.Second {
+		t.Fatalf("expected one token at most after idling, got a wait of %v", got)
+	}
+}
+
+func TestTokenBucketSlowsDownAndRecovers(t *testing.T) {
+	bucket, _ := newTestBucket(time.Second)
+
+	for _, want := range []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second} {
+		got, changed := bucket.slowDown()
+		if !changed || got != want {
+			t.Fatalf("expected interval %v, got %v (changed %v)", want, got, changed)
+		}
+	}
+	if got, changed := bucket.slowDown(); changed || got != maxRateSlowdown*time.Second {
+		t.Fatalf("expected the slowdown capped at %dx, got %v (changed %v)", maxRateSlowdown, got, changed)
+	}
+
+	// Recovery is gradual: one success barely moves the interval
+	bucket.recover()
+	if got := bucket.effectiveInterval(); got >= 8*time.Second || got < 7*time.Second {
+		t.Fatalf("expected a small recovery, got %v", got)
+	}
+	for i := 0; i < 200; i++ {
+		bucket.recover()
+	}
+	if got := bucket.effectiveInterval(); got != time.Second {
+		t.Fatalf("expected the configured interval after recovering, got %v", got)
+	}
+}
+
+func TestTokenBucketWithoutInterval(t *testing.T) {
+	bucket, _ := newTestBucket(0)
+	for i := 0; i < 3; i++ {
+		if got := bucket.reserve(); got != 0 {
+			t.Fatalf("expected no wait, got %v", got)
+		}
+	}
+	if _, changed := bucket.slowDown(); changed {
+		t.Fatal("expected a bucket without interval to stay unlimited")
+	}
+}
+
+func TestTokenBucketWaitCancelled(t *testing.T) {
+	bucket := newTokenBucket(time.Hour)
+	bucket.reserve()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := bucket.wait(ctx); err != context.Canceled {
+		t.Fatalf("expected %v, got %v", context.Canceled, err)
+	}
+}
+
+func TestClientSharesAndAdaptsRateLimit(t *testing.T) {
+	status := http.StatusTooManyRequests
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	// Buckets are keyed by the test server's host, so they start fresh
+	newClient := func() *Client {
+		return &Client{
+			httpClient: server.Client(),
+			host:       "e-hentai.org",
+			cookies:    map[string]string{},
+			logger:     zap.NewNop(),
+			pageDelay:  time.Millisecond,
+			apiDelay:   2 * time.Millisecond,
+		}
+	}
+	first, second := newClient(), newClient()
+
+	page := first.bucket(server.URL, false)
+	if page == nil || page != second.bucket(server.URL, false) {
+		t.Fatal("expected clients with the same delays to share the host's page bucket")
+	}
+	if api := first.bucket(server.URL, true); api == page || api.interval != 2*time.Millisecond {
+		t.Fatalf("expected a separate API bucket, got %#v", api)
+	}
+
+	if _, err := first.Get(context.Background(), server.URL); err == nil {
+		t.Fatal("expected the 429 to fail")
+	}
+	if got := second.bucket(server.URL, false).effectiveInterval(); got != 2*time.Millisecond {
+		t.Fatalf("expected the 429 to slow down the shared bucket, got %v", got)
+	}
+
+	status = http.StatusOK
+	if _, err := second.Get(context.Background(), server.URL); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got := page.effectiveInterval(); got >= 2*time.Millisecond {
+		t.Fatalf("expected a success to start the recovery, got %v", got)
+	}
+
+	var unlimited Client
+	if unlimited.bucket(server.URL, false) != nil {
+		t.Fatal("expected no bucket without delays")
+	}
+}
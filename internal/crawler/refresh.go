@@ -0,0 +1,128 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/slinet/ehdb/internal/config"
+	"github.com/slinet/ehdb/internal/database"
+	"github.com/slinet/ehdb/internal/jobrun"
+	"github.com/slinet/ehdb/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// RefreshJobKind is the job_run kind of refresh runs, whose gdata requests
+// count against the daily budget
+const RefreshJobKind = "refresh"
+
+// refreshBudgetWindow is the period the daily budget covers
+const refreshBudgetWindow = 24 * time.Hour
+
+// Refresher resyncs the galleries gdata answered for longest ago. Every
+// import sets last_synced_at, so repeated runs cycle through the whole
+// catalog, and no checkpoint is needed: an interrupted run leaves the
+// galleries it did not reach the stalest.
+type Refresher struct {
+	crawler *GalleryCrawler
+	logger  *zap.Logger
+}
+
+// NewRefresher creates a new refresher
+func NewRefresher(cfg *config.CrawlerConfig, logger *zap.Logger) *Refresher {
+	crawler, _ := NewGalleryCrawler(cfg, logger)
+	return &Refresher{crawler: crawler, logger: logger}
+}
+
+// Refresh resyncs up to limit galleries, stalest first. With a dailyBudget
+// of gdata requests, the run stops once its own requests, retries included,
+// reach what is left of it after the refresh runs of the last 24 hours;
+// zero means no budget.
+func (r *Refresher) Refresh(ctx context.Context, limit, dailyBudget int) error {
+	var maxRequests int64 // Requests this run may send, zero without a budget
+	if dailyBudget > 0 {
+		used, err := jobrun.SumCounter(ctx, r.logger, RefreshJobKind, jobrun.MetadataRequests, time.Now().Add(-refreshBudgetWindow))
+		if err != nil {
+			return fmt.Errorf("load refresh budget: %w", err)
+		}
+		remaining := int64(dailyBudget) - used
+		if remaining <= 0 {
+			r.logger.Info("daily refresh budget spent, skipping refresh",
+				zap.Int("budget", dailyBudget),
+				zap.Int64("used", used),
+			)
+			return nil
+		}
+		maxRequests = remaining
+		if int64(limit) > remaining*metadataBatchSize {
+			limit = int(remaining * metadataBatchSize)
+		}
+	}
+
+	r.logger.Info("starting refresh", zap.Int("limit", limit), zap.Int("daily_budget", dailyBudget))
+
+	gidlist, err := r.stalest(ctx, limit)
+	if err != nil {
+		return err
+	}
+	if len(gidlist) == 0 {
+		r.logger.Info("no galleries to refresh")
+		return nil
+	}
+
+	removals := newRemovalTracker(r.crawler, r.logger)
+	syncer := &metadataSync{
+		crawler:     r.crawler,
+		importer:    NewImporter(r.logger),
+		removals:    removals,
+		logger:      r.logger,
+		maxRequests: maxRequests,
+	}
+	done, err := syncer.run(ctx, gidlist)
+	if err != nil {
+		return err
+	}
+
+	r.logger.Info("refresh completed",
+		zap.Int("refreshed", done),
+		zap.Int("removed", removals.removed),
+		zap.Int("restored", removals.restored),
+	)
+	return nil
+}
+
+// stalest returns the gid/token pairs of the limit galleries synced longest
+// ago, never synced ones first in gid order
+func (r *Refresher) stalest(ctx context.Context, limit int) ([][2]interface{}, error) {
+	pool := database.GetPool()
+	query := `
+		SELECT gid, token
+		FROM gallery
+		ORDER BY last_synced_at ASC NULLS FIRST, gid ASC
+		LIMIT $1
+	`
+
+	r.logger.Debug("executing query",
+		zap.String("sql", utils.FormatSQL(query, limit)),
+	)
+
+	rows, err := pool.Query(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query stalest galleries: %w", err)
+	}
+	defer rows.Close()
+
+	var gidlist [][2]interface{}
+	for rows.Next() {
+		var gid int
+		var token string
+		if err := rows.Scan(&gid, &token); err != nil {
+			return nil, fmt.Errorf("scan gallery: %w", err)
+		}
+		gidlist = append(gidlist, [2]interface{}{gid, token})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query stalest galleries: %w", err)
+	}
+	return gidlist, nil
+}
@@ -0,0 +1,225 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/slinet/ehdb/internal/database"
+	"github.com/slinet/ehdb/internal/ehparse"
+	"github.com/slinet/ehdb/internal/jobrun"
+	"github.com/slinet/ehdb/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// metadataErrorKind classifies the error gdata returns for one gallery
+type metadataErrorKind int
+
+const (
+	// metadataErrorOther says nothing about the gallery itself, e.g. a
+	// malformed request, so the gallery is left alone
+	metadataErrorOther metadataErrorKind = iota
+	// metadataErrorMissing means gdata does not know the gid and token:
+	// the gallery was removed, or the stored token is wrong
+	metadataErrorMissing
+)
+
+// Sources of a removal, stored in gallery.removed_source
+const (
+	// removalSourceGdata marks removals confirmed after a gdata error, which
+	// are cleared when gdata returns the gallery again
+	removalSourceGdata = "gdata"
+	// removalSourceTorrent marks removals from unavailable torrent pages,
+	// which a gdata answer does not disprove
+	removalSourceTorrent = "torrent"
+)
+
+// missingMetadataErrors are lowercased parts of the gdata errors for
+// galleries it does not know, e.g. "Key missing, or incorrect key provided."
+var missingMetadataErrors = []string{"key missing", "incorrect key", "not found", "removed"}
+
+// classifyMetadataError classifies a gdata error message
+func classifyMetadataError(message string) metadataErrorKind {
+	lower := strings.ToLower(message)
+	for _, marker := range missingMetadataErrors {
+		if strings.Contains(lower, marker) {
+			return metadataErrorMissing
+		}
+	}
+	return metadataErrorOther
+}
+
+// removalTracker keeps the removed flag of resynced and fetched galleries
+// current. A gallery gdata reports missing is marked removed once its page
+// confirms it, since a missing key alone could be a bad token; a gallery it
+// marked that gdata returns again is unmarked, while removals from other
+// sources are left alone. It counts both for the run.
+type removalTracker struct {
+	crawler  *GalleryCrawler
+	logger   *zap.Logger
+	removed  int
+	restored int
+}
+
+func newRemovalTracker(crawler *GalleryCrawler, logger *zap.Logger) *removalTracker {
+	return &removalTracker{crawler: crawler, logger: logger}
+}
+
+// Track updates the galleries of one gdata batch: list holds the requested
+// gid/token pairs and metadata the API's answer. A gallery whose removal
+// cannot be confirmed is logged and left alone; only an auth failure or ctx
+// ending stops it.
+func (t *removalTracker) Track(ctx context.Context, list [][2]interface{}, metadata []database.GalleryMetadata) error {
+	tokens := make(map[int]string, len(list))
+	for _, item := range list {
+		gid, _ := item[0].(int)
+		token, _ := item[1].(string)
+		tokens[gid] = token
+	}
+
+	var returned []int
+	for _, m := range metadata {
+		if m.Error == "" {
+			returned = append(returned, m.Gid)
+			continue
+		}
+		if classifyMetadataError(m.Error) != metadataErrorMissing {
+			t.logger.Warn("metadata has unrecognised error, leaving gallery as is", zap.Int("gid", m.Gid), zap.String("error", m.Error))
+			continue
+		}
+
+		reason, err := t.confirmRemoved(ctx, m.Gid, tokens[m.Gid], m.Error)
+		if err != nil {
+			if errors.Is(err, ErrAuthRequired) {
+				return fmt.Errorf("auth failed while confirming removal of gallery %d: %w", m.Gid, err)
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			t.logger.Error("failed to confirm gallery removal", zap.Int("gid", m.Gid), zap.Error(err))
+			continue
+		}
+		if reason == "" {
+			t.logger.Warn("metadata reports gallery missing but its page is available, leaving it as is",
+				zap.Int("gid", m.Gid),
+				zap.String("error", m.Error),
+			)
+			continue
+		}
+
+		marked, err := markGalleryRemoved(ctx, t.logger, m.Gid, removalSourceGdata, reason)
+		if err != nil {
+			t.logger.Error("failed to mark gallery removed", zap.Int("gid", m.Gid), zap.Error(err))
+			continue
+		}
+		if marked {
+			t.removed++
+		}
+	}
+
+	if len(returned) > 0 {
+		restored, err := unmarkGalleriesRemoved(ctx, t.logger, returned)
+		if err != nil {
+			t.logger.Error("failed to unmark returned galleries", zap.Error(err))
+		}
+		t.restored += restored
+	}
+
+	return nil
+}
+
+// confirmRemoved fetches the gallery page and returns the removal reason
+// when the page is unavailable too, or an empty reason when the gallery is
+// still there
+func (t *removalTracker) confirmRemoved(ctx context.Context, gid int, token, metadataError string) (string, error) {
+	detail, err := Retry(RetryConfig{
+		Context:             ctx,
+		MaxRetries:          t.crawler.retryTimes,
+		Logger:              t.logger,
+		TransientRetryTimes: t.crawler.cfg.TransientRetryTimes,
+		WaitForIPUnban:      t.crawler.cfg.WaitForIPUnban,
+	}, func() (*ehparse.GalleryDetail, error) {
+		return t.crawler.GetDetail(ctx, gid, token)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case detail.Unavailable:
+		return metadataError + " (gallery page unavailable)", nil
+	case detail.InvalidKey:
+		return metadataError + " (gallery page key missing)", nil
+	default:
+		return "", nil
+	}
+}
+
+// markGalleryRemoved marks a gallery removed by source with reason and
+// reports whether it was not marked before, counting and logging the removal
+// if so
+func markGalleryRemoved(ctx context.Context, logger *zap.Logger, gid int, source, reason string) (bool, error) {
+	pool := database.GetPool()
+	query := `
+		UPDATE gallery
+		SET removed = true, removed_at = NOW(), removed_reason = $2, removed_source = $3
+		WHERE gid = $1 AND removed = false
+	`
+
+	logger.Debug("executing query",
+		zap.String("sql", utils.FormatSQL(query, gid, reason, source)),
+	)
+
+	tag, err := pool.Exec(ctx, query, gid, reason, source)
+	if err != nil {
+		return false, fmt.Errorf("mark gallery removed: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	logger.Info("gallery removed", zap.Int("gid", gid), zap.String("source", source), zap.String("reason", reason))
+	jobrun.Add(ctx, jobrun.GalleriesRemoved, 1)
+	return true, nil
+}
+
+// unmarkGalleriesRemoved clears the removed flag of those of gids that were
+// marked removed after a gdata error, counting and logging each, and returns
+// how many there were. Removals from torrent pages or from before tracking
+// are kept.
+func unmarkGalleriesRemoved(ctx context.Context, logger *zap.Logger, gids []int) (int, error) {
+	pool := database.GetPool()
+	query := `
+		UPDATE gallery
+		SET removed = false, removed_at = NULL, removed_reason = NULL, removed_source = NULL
+		WHERE gid = ANY($1) AND removed = true AND removed_source = $2
+		RETURNING gid
+	`
+
+	logger.Debug("executing query",
+		zap.String("sql", utils.FormatSQL(query, gids, removalSourceGdata)),
+	)
+
+	rows, err := pool.Query(ctx, query, gids, removalSourceGdata)
+	if err != nil {
+		return 0, fmt.Errorf("unmark galleries removed: %w", err)
+	}
+	defer rows.Close()
+
+	restored := 0
+	for rows.Next() {
+		var gid int
+		if err := rows.Scan(&gid); err != nil {
+			return restored, fmt.Errorf("scan restored gallery: %w", err)
+		}
+		logger.Info("removed gallery is back", zap.Int("gid", gid))
+		restored++
+	}
+	if err := rows.Err(); err != nil {
+		return restored, fmt.Errorf("unmark galleries removed: %w", err)
+	}
+
+	jobrun.Add(ctx, jobrun.GalleriesRestored, int64(restored))
+	return restored, nil
+}
@@ -0,0 +1,22 @@
+package crawler
+
+import "testing"
+
+func TestClassifyMetadataError(t *testing.T) {
+	tests := []struct {
+		message string
+		want    metadataErrorKind
+	}{
+		{message: "Key missing, or incorrect key provided.", want: metadataErrorMissing},
+		{message: "Gallery not found", want: metadataErrorMissing},
+		{message: "This gallery has been removed", want: metadataErrorMissing},
+		{message: "Invalid request.", want: metadataErrorOther},
+		{message: "", want: metadataErrorOther},
+	}
+
+	for _, tt := range tests {
+		if got := classifyMetadataError(tt.message); got != tt.want {
+			t.Fatalf("expected %q classified %v, got %v", tt.message, tt.want, got)
+		}
+	}
+}
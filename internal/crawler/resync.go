@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/slinet/ehdb/internal/checkpoint"
 	"github.com/slinet/ehdb/internal/config"
 	"github.com/slinet/ehdb/internal/database"
 	"github.com/slinet/ehdb/pkg/utils"
@@ -15,38 +16,62 @@ import (
 // Resyncer resyncs galleries from recent hours
 type Resyncer struct {
 	crawler *GalleryCrawler
+	details *detailScraper // nil unless cfg.FetchDetails or cfg.FetchComments
 	logger  *zap.Logger
 }
 
 // NewResyncer creates a new resyncer
 func NewResyncer(cfg *config.CrawlerConfig, logger *zap.Logger) *Resyncer {
 	crawler, _ := NewGalleryCrawler(cfg, logger)
-	return &Resyncer{
+	r := &Resyncer{
 		crawler: crawler,
 		logger:  logger,
 	}
+	r.details = newDetailScraper(cfg, crawler, logger)
+	return r
 }
 
-// Resync resyncs galleries from the last N hours
+// Resync resyncs galleries from the last N hours. The position is
+// checkpointed after every imported batch, so ResumeResync can continue a
+// run that died.
 func (r *Resyncer) Resync(ctx context.Context, hours int) error {
 	r.logger.Info("starting resync", zap.Int("hours", hours))
 
+	return r.runResync(ctx, &ResyncCheckpoint{
+		Hours: hours,
+		Since: time.Now().Unix() - int64(hours*3600),
+	})
+}
+
+// ResumeResync continues the resync saved in state after its last imported gid
+func (r *Resyncer) ResumeResync(ctx context.Context, state *ResyncCheckpoint) error {
+	r.logger.Info("resuming resync",
+		zap.Int("hours", state.Hours),
+		zap.Int64("since", state.Since),
+		zap.Int("last_gid", state.LastGid),
+		zap.Int("done", state.Done),
+	)
+
+	return r.runResync(ctx, state)
+}
+
+func (r *Resyncer) runResync(ctx context.Context, state *ResyncCheckpoint) error {
 	pool := database.GetPool()
 
-	// Get galleries from the last N hours
-	sinceTimestamp := time.Now().Unix() - int64(hours*3600)
+	// Get galleries posted since the threshold that were not resynced yet
 	query := `
 		SELECT gid, token
 		FROM gallery
 		WHERE EXTRACT(EPOCH FROM posted) >= $1
+		  AND gid > $2
 		ORDER BY gid ASC
 	`
 
 	r.logger.Debug("executing query",
-		zap.String("sql", utils.FormatSQL(query, sinceTimestamp)),
+		zap.String("sql", utils.FormatSQL(query, state.Since, state.LastGid)),
 	)
 
-	rows, err := pool.Query(ctx, query, sinceTimestamp)
+	rows, err := pool.Query(ctx, query, state.Since, state.LastGid)
 	if err != nil {
 		return fmt.Errorf("query galleries: %w", err)
 	}
@@ -73,55 +98,65 @@ func (r *Resyncer) Resync(ctx context.Context, hours int) error {
 
 	if len(gidTokens) == 0 {
 		r.logger.Info("no galleries to resync")
-		return nil
+		return r.clearCheckpoint(ctx)
 	}
 
-	// Fetch metadata in batches
-	var allMetadata []database.GalleryMetadata
-	for i := 0; i < len(gidTokens); i += 25 {
-		end := i + 25
-		if end > len(gidTokens) {
-			end = len(gidTokens)
-		}
+	gidlist := make([][2]interface{}, 0, len(gidTokens))
+	for _, item := range gidTokens {
+		gidlist = append(gidlist, [2]interface{}{item.Gid, item.Token})
+	}
 
-		batch := gidTokens[i:end]
-		var gidlist [][2]interface{}
-		for _, item := range batch {
-			gidlist = append(gidlist, [2]interface{}{item.Gid, item.Token})
+	// record moves the checkpoint past the first done galleries
+	startDone := state.Done
+	record := func(done int) {
+		if done > 0 {
+			state.LastGid = gidTokens[done-1].Gid
 		}
+		state.Done = startDone + done
+	}
 
-		r.logger.Debug("fetching metadata batch", zap.Int("from", i), zap.Int("to", end))
-
-		metadata, err := Retry(RetryConfig{
-			MaxRetries:          r.crawler.retryTimes,
-			Logger:              r.logger,
-			TransientRetryTimes: r.crawler.cfg.TransientRetryTimes,
-			WaitForIPUnban:      r.crawler.cfg.WaitForIPUnban,
-		}, func() ([]database.GalleryMetadata, error) {
-			return r.crawler.GetMetadatas(gidlist)
-		})
+	// Fetch metadata in concurrent batches, importing and checkpointing
+	// every checkpointImportBatch galleries in gid order
+	removals := newRemovalTracker(r.crawler, r.logger)
+	syncer := &metadataSync{
+		crawler:  r.crawler,
+		importer: NewImporter(r.logger),
+		removals: removals,
+		logger:   r.logger,
+		imported: func(ctx context.Context, metadata []database.GalleryMetadata, done int) error {
+			record(done)
+			return saveCheckpoint(ctx, ResyncCheckpointKind, state)
+		},
+	}
+	// The galleries already exist, so their details can be stored before
+	// the batch is imported
+	if r.details != nil {
+		syncer.fetched = r.details.Scrape
+	}
 
-		if err != nil {
-			if errors.Is(err, ErrAuthRequired) {
-				return fmt.Errorf("auth failed while fetching metadata batch %d-%d: %w", i, end, err)
+	done, err := syncer.run(ctx, gidlist)
+	if err != nil {
+		// An interrupted run's last import is not checkpointed by the hook
+		if ctx.Err() != nil {
+			record(done)
+			if saveErr := saveCheckpoint(ctx, ResyncCheckpointKind, state); saveErr != nil {
+				return errors.Join(err, saveErr)
 			}
-			r.logger.Error("failed to fetch metadata batch", zap.Error(err))
-			continue
 		}
-
-		allMetadata = append(allMetadata, metadata...)
-
-		// Rate limiting for API calls
-		time.Sleep(time.Duration(r.crawler.cfg.APIDelaySeconds) * time.Second)
+		return err
 	}
 
-	r.logger.Debug("fetched all metadata", zap.Int("count", len(allMetadata)))
+	r.logger.Info("resync completed",
+		zap.Int("resynced", state.Done),
+		zap.Int("removed", removals.removed),
+		zap.Int("restored", removals.restored),
+	)
+	return r.clearCheckpoint(ctx)
+}
 
-	// Import data with force flag
-	importer := NewImporter(r.logger)
-	if err := importer.Import(ctx, allMetadata, true); err != nil {
-		return fmt.Errorf("import data: %w", err)
+func (r *Resyncer) clearCheckpoint(ctx context.Context) error {
+	if err := checkpoint.Clear(ctx, ResyncCheckpointKind); err != nil {
+		r.logger.Warn("failed to clear resync checkpoint", zap.Error(err))
 	}
-
 	return nil
 }
@@ -1,6 +1,7 @@
 package crawler
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math/rand"
@@ -9,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/slinet/ehdb/internal/jobrun"
 	"go.uber.org/zap"
 )
 
@@ -17,17 +19,23 @@ import (
 const defaultTransientRetries = 6
 
 // transientBaseBackoff and transientMaxBackoff control the exponential backoff
-// applied to transient errors. They are vars (not consts) so tests can shrink
-// them to keep retry loops fast.
+// applied to transient errors, normalBackoffStep the linear backoff of other
+// errors. They are vars (not consts) so tests can shrink them to keep retry
+// loops fast.
 var (
 	// transientBaseBackoff is the first backoff step for transient errors.
 	transientBaseBackoff = 10 * time.Second
 	// transientMaxBackoff caps a single transient backoff wait.
 	transientMaxBackoff = 2 * time.Minute
+	// normalBackoffStep is the linear backoff step for other errors.
+	normalBackoffStep = 5 * time.Second
 )
 
 // RetryConfig holds retry configuration
 type RetryConfig struct {
+	// Context of the calling run (optional). Cancelling it stops retries and
+	// backoff or IP ban waits; IP bans are counted against its job run.
+	Context    context.Context
 	MaxRetries int
 	// TransientRetryTimes is a separate retry budget for transient upstream
 	// errors (HTTP 5xx / 429). These errors are usually short-lived overload or
@@ -150,7 +158,13 @@ func RetryVoid(cfg RetryConfig, fn func() error) error {
 //
 // Auth failures abort immediately, and IP bans wait for the reported unban
 // window without consuming either budget (matching the previous behavior).
+// A cancelled Context ends the loop, including any wait, with its error.
 func runWithRetry(cfg RetryConfig, fn func() (any, error)) (any, error) {
+	ctx := cfg.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
 	maxRetries := cfg.MaxRetries
 	if maxRetries <= 0 {
 		maxRetries = 3 // fallback default
@@ -177,6 +191,10 @@ func runWithRetry(cfg RetryConfig, fn func() (any, error)) (any, error) {
 
 		lastErr = err
 
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, fmt.Errorf("retry aborted: %w", ctxErr)
+		}
+
 		if errors.Is(err, ErrAuthRequired) {
 			if cfg.Logger != nil {
 				cfg.Logger.Error("auth failure detected, aborting retries", zap.Error(err))
@@ -184,9 +202,14 @@ func runWithRetry(cfg RetryConfig, fn func() (any, error)) (any, error) {
 			return nil, fmt.Errorf("authentication failure: %w", err)
 		}
 
+		duration, isIPBan := parseIPBanDuration(err.Error())
+		if isIPBan {
+			jobrun.Add(ctx, jobrun.BansHit, 1)
+		}
+
 		// IP bans wait for the reported window and do not consume any retry budget.
 		if cfg.WaitForIPUnban {
-			if duration, isIPBan := parseIPBanDuration(err.Error()); isIPBan {
+			if isIPBan {
 				if cfg.Logger != nil {
 					cfg.Logger.Warn("IP temporarily banned, waiting for unban",
 						zap.Duration("wait_duration", duration),
@@ -195,7 +218,9 @@ func runWithRetry(cfg RetryConfig, fn func() (any, error)) (any, error) {
 				}
 
 				// Wait for ban to expire, plus 10 extra seconds to ensure complete unban
-				time.Sleep(duration + 10*time.Second)
+				if err := sleepContext(ctx, duration+10*time.Second); err != nil {
+					return nil, fmt.Errorf("IP ban wait aborted: %w", err)
+				}
 
 				if cfg.Logger != nil {
 					cfg.Logger.Info("IP ban wait completed, retrying")
@@ -221,7 +246,9 @@ func runWithRetry(cfg RetryConfig, fn func() (any, error)) (any, error) {
 				)
 			}
 			transientAttempts++
-			time.Sleep(sleepDuration)
+			if err := sleepContext(ctx, sleepDuration); err != nil {
+				return nil, fmt.Errorf("retry aborted: %w", err)
+			}
 			continue
 		}
 
@@ -238,8 +265,27 @@ func runWithRetry(cfg RetryConfig, fn func() (any, error)) (any, error) {
 		}
 
 		// Linear backoff: 5s, 10s, 15s...
-		sleepDuration := time.Duration((normalAttempts+1)*5) * time.Second
+		sleepDuration := time.Duration(normalAttempts+1) * normalBackoffStep
 		normalAttempts++
-		time.Sleep(sleepDuration)
+		if err := sleepContext(ctx, sleepDuration); err != nil {
+			return nil, fmt.Errorf("retry aborted: %w", err)
+		}
+	}
+}
+
+// sleepContext pauses for d, returning early with ctx's error if ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
@@ -0,0 +1,136 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/slinet/ehdb/internal/database"
+	"github.com/slinet/ehdb/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// defaultTitleBackfillBatchSize is the number of galleries parsed per update
+const defaultTitleBackfillBatchSize = 5000
+
+// TitleBackfiller fills the parsed title columns for galleries imported before
+// the Importer started setting them
+type TitleBackfiller struct {
+	logger    *zap.Logger
+	batchSize int
+}
+
+// NewTitleBackfiller creates a new title backfiller
+func NewTitleBackfiller(logger *zap.Logger, batchSize int) *TitleBackfiller {
+	if batchSize <= 0 {
+		batchSize = defaultTitleBackfillBatchSize
+	}
+	return &TitleBackfiller{logger: logger, batchSize: batchSize}
+}
+
+// Backfill parses titles in gid order. Only galleries without parsed columns
+// are processed unless all is true, in which case every gallery is reparsed.
+func (tb *TitleBackfiller) Backfill(ctx context.Context, all bool) error {
+	tb.logger.Info("starting title backfill", zap.Bool("all", all), zap.Int("batch_size", tb.batchSize))
+
+	pool := database.GetPool()
+
+	query := `
+		SELECT gid, title, title_jpn
+		FROM gallery
+		WHERE gid > $1 AND ($2 OR title_base IS NULL)
+		ORDER BY gid
+		LIMIT $3
+	`
+
+	lastGid := 0
+	total := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		tb.logger.Debug("executing query", zap.String("sql", utils.FormatSQL(query, lastGid, all, tb.batchSize)))
+
+		rows, err := pool.Query(ctx, query, lastGid, all, tb.batchSize)
+		if err != nil {
+			return fmt.Errorf("query galleries: %w", err)
+		}
+
+		var (
+			gids       []int
+			events     []*string
+			circles    []*string
+			artists    []*string
+			bases      []string
+			parodies   []*string
+			languages  []*string
+			digital    []bool
+			decensored []bool
+			ongoing    []bool
+		)
+		for rows.Next() {
+			var gid int
+			var title, titleJpn string
+			if err := rows.Scan(&gid, &title, &titleJpn); err != nil {
+				rows.Close()
+				return fmt.Errorf("scan gallery: %w", err)
+			}
+
+			parsed := utils.ParseGalleryTitles(title, titleJpn)
+			gids = append(gids, gid)
+			events = append(events, nullIfEmpty(parsed.Event))
+			circles = append(circles, nullIfEmpty(parsed.Circle))
+			artists = append(artists, nullIfEmpty(parsed.Artist))
+			bases = append(bases, parsed.Title)
+			parodies = append(parodies, nullIfEmpty(parsed.Parody))
+			languages = append(languages, nullIfEmpty(parsed.Language))
+			digital = append(digital, parsed.Digital)
+			decensored = append(decensored, parsed.Decensored)
+			ongoing = append(ongoing, parsed.Ongoing)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("read galleries: %w", err)
+		}
+
+		if len(gids) == 0 {
+			break
+		}
+
+		updateQuery := `
+			UPDATE gallery g SET
+				title_event = p.title_event,
+				title_circle = p.title_circle,
+				title_artist = p.title_artist,
+				title_base = p.title_base,
+				title_parody = p.title_parody,
+				title_language = p.title_language,
+				title_digital = p.title_digital,
+				title_decensored = p.title_decensored,
+				title_ongoing = p.title_ongoing
+			FROM unnest(
+				$1::int[], $2::text[], $3::text[], $4::text[], $5::text[],
+				$6::text[], $7::text[], $8::bool[], $9::bool[], $10::bool[]
+			) AS p(gid, title_event, title_circle, title_artist, title_base,
+			       title_parody, title_language, title_digital, title_decensored, title_ongoing)
+			WHERE g.gid = p.gid
+		`
+		tb.logger.Debug("executing update query",
+			zap.String("sql", utils.FormatSQL(updateQuery, gids[0], gids[len(gids)-1])),
+			zap.Int("galleries", len(gids)),
+		)
+
+		if _, err := pool.Exec(ctx, updateQuery,
+			gids, events, circles, artists, bases, parodies, languages, digital, decensored, ongoing,
+		); err != nil {
+			return fmt.Errorf("update parsed titles: %w", err)
+		}
+
+		total += len(gids)
+		lastGid = gids[len(gids)-1]
+		tb.logger.Info("title backfill progress", zap.Int("processed", total), zap.Int("last_gid", lastGid))
+	}
+
+	tb.logger.Info("title backfill completed", zap.Int("processed", total))
+	return nil
+}
@@ -4,14 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"regexp"
 	"sort"
-	"strconv"
 	"strings"
-	"time"
 
 	"github.com/slinet/ehdb/internal/config"
 	"github.com/slinet/ehdb/internal/database"
+	"github.com/slinet/ehdb/internal/ehparse"
+	"github.com/slinet/ehdb/internal/jobrun"
 	"github.com/slinet/ehdb/pkg/utils"
 	"go.uber.org/zap"
 )
@@ -36,7 +35,7 @@ type TorrentCrawlerOptions struct {
 
 // NewTorrentCrawler creates a new torrent crawler
 func NewTorrentCrawler(cfg *config.CrawlerConfig, logger *zap.Logger) (*TorrentCrawler, error) {
-	client, err := NewClient(cfg)
+	client, err := NewClient(cfg, logger)
 	if err != nil {
 		return nil, err
 	}
@@ -99,15 +98,20 @@ func (c *TorrentCrawler) Sync(ctx context.Context) error {
 	finished := false
 
 	for !finished {
+		if err := jobrun.Yield(ctx); err != nil {
+			return err
+		}
+
 		c.logger.Debug("fetching torrent list page", zap.Int("page", page))
 
 		pageItems, err := Retry(RetryConfig{
+			Context:             ctx,
 			MaxRetries:          c.retryTimes,
 			Logger:              c.logger,
 			TransientRetryTimes: c.cfg.TransientRetryTimes,
 			WaitForIPUnban:      c.cfg.WaitForIPUnban,
 		}, func() ([]TorrentListItem, error) {
-			return c.fetchTorrentListPage(page)
+			return c.fetchTorrentListPage(ctx, page)
 		})
 		if err != nil {
 			return fmt.Errorf("fetch page %d: %w", page, err)
@@ -143,8 +147,9 @@ func (c *TorrentCrawler) Sync(ctx context.Context) error {
 			break
 		}
 
-		// Rate limiting for page fetches
-		time.Sleep(time.Duration(c.cfg.PageDelaySeconds) * time.Second)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 	}
 
 	if len(items) == 0 {
@@ -207,9 +212,14 @@ func (c *TorrentCrawler) Sync(ctx context.Context) error {
 	total := len(orderedGIDs)
 
 	for _, gid := range orderedGIDs {
+		if err := jobrun.Yield(ctx); err != nil {
+			return err
+		}
+
 		token := gidMap[gid][0].Token
 
 		count, err := Retry(RetryConfig{
+			Context:             ctx,
 			MaxRetries:          c.retryTimes,
 			Logger:              c.logger,
 			TransientRetryTimes: c.cfg.TransientRetryTimes,
@@ -233,8 +243,9 @@ func (c *TorrentCrawler) Sync(ctx context.Context) error {
 			)
 		}
 
-		// Rate limiting for page fetches
-		time.Sleep(time.Duration(c.cfg.PageDelaySeconds) * time.Second)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 	}
 
 	c.logger.Info("torrent sync completed",
@@ -291,7 +302,7 @@ func orderedGalleryIDsByMaxGTID(gidMap map[int][]TorrentListItem) []int {
 }
 
 // fetchTorrentListPage fetches a single page from torrents.php
-func (c *TorrentCrawler) fetchTorrentListPage(page int) ([]TorrentListItem, error) {
+func (c *TorrentCrawler) fetchTorrentListPage(ctx context.Context, page int) ([]TorrentListItem, error) {
 	params := []string{}
 	if c.search != "" {
 		params = append(params, fmt.Sprintf("search=%s", c.search))
@@ -309,40 +320,34 @@ func (c *TorrentCrawler) fetchTorrentListPage(page int) ([]TorrentListItem, erro
 	}
 
 	url := fmt.Sprintf("https://%s%s", c.cfg.Host, path)
-	body, err := c.client.Get(url)
+	body, err := c.client.Get(ctx, url)
 	if err != nil {
 		return nil, err
 	}
 
-	// Parse torrent items
-	firstPattern := regexp.MustCompile(`gallerytorrents\.php\?gid=\d+&(?:amp;)?t=[0-9a-f]{10}&(?:amp;)?gtid=\d+"`)
-	firstMatches := firstPattern.FindAll(body, -1)
-	secondPattern := regexp.MustCompile(`gallerytorrents\.php\?gid=(\d+)&(?:amp;)?t=([0-9a-f]{10})&(?:amp;)?gtid=(\d+)"`)
-
-	var items []TorrentListItem
-	for _, entry := range firstMatches {
-		match := secondPattern.FindSubmatch(entry)
-		if len(match) >= 4 {
-			gid, _ := strconv.Atoi(string(match[1]))
-			token := string(match[2])
-			gtid, _ := strconv.Atoi(string(match[3]))
-
+	parsed, err := ehparse.ParseTorrentList(body)
+	if err == nil && len(parsed) > 0 {
+		items := make([]TorrentListItem, 0, len(parsed))
+		for _, item := range parsed {
 			items = append(items, TorrentListItem{
-				Gid:   gid,
-				Token: token,
-				Gtid:  gtid,
+				Gid:   item.Gid,
+				Token: item.Token,
+				Gtid:  item.Gtid,
 			})
 		}
+		return items, nil
 	}
 
-	if len(items) == 0 {
-		if reason, ok := abnormalTorrentListPageReason(body); ok {
-			reason = enrichAbnormalReasonWithAPIProbe(reason, c.client, c.logger)
-			return nil, fmt.Errorf("torrent list page abnormal: %s: %w", reason, ErrAbnormalPage)
-		}
+	if reason, ok := abnormalTorrentListPageReason(body); ok {
+		reason = enrichAbnormalReasonWithAPIProbe(ctx, reason, c.client, c.logger)
+		return nil, fmt.Errorf("torrent list page abnormal: %s: %w", reason, ErrAbnormalPage)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("torrent list page unparseable: %w: %w", err, ErrAbnormalPage)
 	}
 
-	return items, nil
+	return nil, nil
 }
 
 // processTorrentsForGallery processes torrents for a single gallery, returns count of new torrents
@@ -351,41 +356,41 @@ func (c *TorrentCrawler) processTorrentsForGallery(ctx context.Context, gid int,
 
 	url := fmt.Sprintf("https://%s/gallerytorrents.php?gid=%d&t=%s", c.cfg.Host, gid, token)
 
-	body, err := c.client.Get(url)
+	body, err := c.client.Get(ctx, url)
 	if err != nil {
 		return 0, fmt.Errorf("fetch torrent page: %w", err)
 	}
 
-	bodyStr := string(body)
-
-	// Check for special cases
-	if strings.Contains(bodyStr, "This gallery is currently unavailable") {
+	page, parseErr := ehparse.ParseGalleryTorrents(body)
+	if parseErr == nil && page.Unavailable {
 		c.logger.Debug("gallery unavailable", zap.Int("gid", gid))
 		return 0, nil
 	}
 
-	if strings.Contains(bodyStr, "Gallery not found") {
+	if parseErr == nil && page.NotFound {
 		c.logger.Debug("gallery not found (pending refresh)", zap.Int("gid", gid))
 		return 0, nil
 	}
 
-	if reason, ok := suspectedAbnormalWebPageReason(body); ok {
-		reason = enrichAbnormalReasonWithAPIProbe(reason, c.client, c.logger)
+	if reason, ok := ehparse.ErrorPageReason(body); ok {
+		reason = enrichAbnormalReasonWithAPIProbe(ctx, reason, c.client, c.logger)
 		return 0, fmt.Errorf("torrent page abnormal: %s: %w", reason, ErrAbnormalPage)
 	}
 
-	// Parse root gid from announce URL
-	announcePattern := regexp.MustCompile(`/(\d+)/announce`)
-	announceMatches := announcePattern.FindStringSubmatch(bodyStr)
-	if len(announceMatches) < 2 {
+	if parseErr != nil {
+		return 0, fmt.Errorf("torrent page unparseable: %w", parseErr)
+	}
+
+	// Root gid comes from the announce URL
+	if page.RootGid == 0 {
 		c.logger.Debug("no torrents found", zap.Int("gid", gid))
 		return 0, nil
 	}
 
-	rootGid, _ := strconv.Atoi(announceMatches[1])
+	rootGid := page.RootGid
 
-	// Parse torrent information (only non-expunged for sync)
-	torrents := c.parseTorrents(body, rootGid)
+	// Only non-expunged torrents are synced
+	torrents := databaseTorrents(page.Torrents, rootGid, false)
 
 	newCount := 0
 	if len(torrents) > 0 {
@@ -425,42 +430,34 @@ func (c *TorrentCrawler) processTorrentsForGallery(ctx context.Context, gid int,
 	return newCount, nil
 }
 
-// parseTorrents parses torrent information from HTML (only non-expunged)
-func (c *TorrentCrawler) parseTorrents(html []byte, gid int) []database.Torrent {
-	var torrents []database.Torrent
-
-	// Pattern matches only non-expunged torrents
-	pattern := regexp.MustCompile(`name="gtid"\svalue="(\d+?)"[\s\S]*?Posted:<.*?(\d{4}-\d{2}-\d{2} \d{2}:\d{2})<\/[\s\S]*?Size:.*>\s?([\d.KMGTiB ]+)<\/[\s\S]*?Uploader:.*?([\S]+)<\/[\s\S]*?([0-9a-f]{40})\.torrent.*?>(.*?)<\/a><\/td>`)
-
-	matches := pattern.FindAllSubmatch(html, -1)
-
-	for _, match := range matches {
-		if len(match) < 7 {
+// databaseTorrents converts parsed torrents to rows of rootGid. Expunged
+// torrents are dropped unless includeExpunged is set.
+func databaseTorrents(torrents []ehparse.Torrent, rootGid int, includeExpunged bool) []database.Torrent {
+	rows := make([]database.Torrent, 0, len(torrents))
+	for _, t := range torrents {
+		if t.Expunged && !includeExpunged {
 			continue
 		}
 
-		gtidStr := string(match[1])
-		posted := string(match[2])
-		size := string(match[3])
-		uploader := string(match[4])
-		hashStr := string(match[5])
-		name := string(match[6])
-
-		gtid, _ := strconv.Atoi(gtidStr)
+		posted, size := t.Posted, t.Size
+		var hash *string
+		if t.Hash != "" {
+			h := t.Hash
+			hash = &h
+		}
 
-		torrents = append(torrents, database.Torrent{
-			ID:       gtid,
-			Gid:      gid,
-			Name:     strings.TrimSpace(name),
-			Hash:     &hashStr,
+		rows = append(rows, database.Torrent{
+			ID:       t.ID,
+			Gid:      rootGid,
+			Name:     t.Name,
+			Hash:     hash,
 			Addedstr: &posted,
 			Fsizestr: &size,
-			Uploader: uploader,
-			Expunged: false,
+			Uploader: t.Uploader,
+			Expunged: t.Expunged,
 		})
 	}
-
-	return torrents
+	return rows
 }
 
 // importMissingGalleries imports galleries that don't exist in database
@@ -493,12 +490,13 @@ func (c *TorrentCrawler) importMissingGalleries(ctx context.Context, items []Tor
 		c.logger.Debug("fetching metadata batch", zap.Int("from", i), zap.Int("to", end))
 
 		metadata, err := Retry(RetryConfig{
+			Context:             ctx,
 			MaxRetries:          c.retryTimes,
 			Logger:              c.logger,
 			TransientRetryTimes: c.cfg.TransientRetryTimes,
 			WaitForIPUnban:      c.cfg.WaitForIPUnban,
 		}, func() ([]database.GalleryMetadata, error) {
-			return c.GetMetadatas(batch)
+			return c.GetMetadatas(ctx, batch)
 		})
 
 		if err != nil {
@@ -514,8 +512,9 @@ func (c *TorrentCrawler) importMissingGalleries(ctx context.Context, items []Tor
 
 		allMetadata = append(allMetadata, metadata...)
 
-		// Rate limiting for API calls
-		time.Sleep(time.Duration(c.cfg.APIDelaySeconds) * time.Second)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 	}
 
 	// Import galleries
@@ -531,14 +530,14 @@ func (c *TorrentCrawler) importMissingGalleries(ctx context.Context, items []Tor
 }
 
 // GetMetadatas fetches metadata from E-Hentai API
-func (c *TorrentCrawler) GetMetadatas(gidlist [][2]interface{}) ([]database.GalleryMetadata, error) {
+func (c *TorrentCrawler) GetMetadatas(ctx context.Context, gidlist [][2]interface{}) ([]database.GalleryMetadata, error) {
 	// Reuse GalleryCrawler's GetMetadatas logic
 	gc := &GalleryCrawler{
 		client: c.client,
 		cfg:    c.cfg,
 		logger: c.logger,
 	}
-	return gc.GetMetadatas(gidlist)
+	return gc.GetMetadatas(ctx, gidlist)
 }
 
 // Database helper functions
@@ -655,6 +654,7 @@ func (c *TorrentCrawler) saveTorrents(ctx context.Context, torrents []database.T
 			fsizestr = EXCLUDED.fsizestr,
 			uploader = EXCLUDED.uploader,
 			expunged = EXCLUDED.expunged
+		RETURNING (xmax = 0)
 	`
 
 	for _, t := range torrents {
@@ -664,12 +664,17 @@ func (c *TorrentCrawler) saveTorrents(ctx context.Context, torrents []database.T
 			)),
 		)
 
-		_, err := pool.Exec(ctx, query,
+		// xmax = 0 only for rows inserted rather than updated by the upsert
+		var inserted bool
+		err := pool.QueryRow(ctx, query,
 			t.ID, t.Gid, t.Name, t.Hash, t.Addedstr, t.Fsizestr, t.Uploader, t.Expunged,
-		)
+		).Scan(&inserted)
 		if err != nil {
 			return fmt.Errorf("insert torrent %d: %w", t.ID, err)
 		}
+		if inserted {
+			jobrun.Add(ctx, jobrun.TorrentsAdded, 1)
+		}
 	}
 
 	return nil
@@ -4,13 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"regexp"
-	"strconv"
-	"strings"
 	"time"
 
+	"github.com/slinet/ehdb/internal/checkpoint"
 	"github.com/slinet/ehdb/internal/config"
 	"github.com/slinet/ehdb/internal/database"
+	"github.com/slinet/ehdb/internal/ehparse"
+	"github.com/slinet/ehdb/internal/jobrun"
 	"github.com/slinet/ehdb/pkg/utils"
 	"go.uber.org/zap"
 )
@@ -31,7 +31,7 @@ type TorrentImporter struct {
 
 // NewTorrentImporter creates a new torrent importer
 func NewTorrentImporter(cfg *config.CrawlerConfig, logger *zap.Logger) (*TorrentImporter, error) {
-	client, err := NewClient(cfg)
+	client, err := NewClient(cfg, logger)
 	if err != nil {
 		return nil, err
 	}
@@ -44,11 +44,33 @@ func NewTorrentImporter(cfg *config.CrawlerConfig, logger *zap.Logger) (*Torrent
 	}, nil
 }
 
-// ImportAll imports torrents from all galleries (heavy operation)
+// ImportAll imports torrents from all galleries (heavy operation). The
+// position is checkpointed after every gallery, so ResumeImport can continue
+// a run that died.
 func (ti *TorrentImporter) ImportAll(ctx context.Context) error {
 	ti.logger.Warn("starting torrent import - this may take a long time")
 
-	galleries, err := ti.loadGalleriesToImport(ctx)
+	return ti.runImport(ctx, &TorrentImportCheckpoint{
+		Host:  ti.cfg.Host,
+		Start: ti.cfg.BackfillStart,
+		End:   ti.cfg.BackfillEnd,
+	})
+}
+
+// ResumeImport continues the torrent import saved in state after its last
+// processed gid. The importer's config must carry the host and window of
+// the saved run.
+func (ti *TorrentImporter) ResumeImport(ctx context.Context, state *TorrentImportCheckpoint) error {
+	ti.logger.Warn("resuming torrent import - this may take a long time",
+		zap.Int("last_gid", state.LastGid),
+		zap.Int("processed", state.Processed),
+	)
+
+	return ti.runImport(ctx, state)
+}
+
+func (ti *TorrentImporter) runImport(ctx context.Context, state *TorrentImportCheckpoint) error {
+	galleries, err := ti.loadGalleriesToImport(ctx, state.LastGid)
 	if err != nil {
 		return err
 	}
@@ -56,12 +78,15 @@ func (ti *TorrentImporter) ImportAll(ctx context.Context) error {
 	ti.logger.Info("found galleries to process", zap.Int("count", len(galleries)))
 
 	// Process each gallery
-	processed := 0
-	succeeded := 0
-	newTorrents := 0
+	total := state.Processed + len(galleries)
 
 	for _, g := range galleries {
+		if err := jobrun.Yield(ctx); err != nil {
+			return err
+		}
+
 		count, err := Retry(RetryConfig{
+			Context:             ctx,
 			MaxRetries:          ti.retryTimes,
 			Logger:              ti.logger,
 			TransientRetryTimes: ti.cfg.TransientRetryTimes,
@@ -73,37 +98,50 @@ func (ti *TorrentImporter) ImportAll(ctx context.Context) error {
 			if errors.Is(err, ErrAuthRequired) {
 				return fmt.Errorf("auth failed while processing gallery %d: %w", g.Gid, err)
 			}
+			if ctx.Err() != nil {
+				return fmt.Errorf("interrupted after %d of %d galleries: %w", state.Processed, total, ctx.Err())
+			}
 			ti.logger.Error("failed to process gallery", zap.Int("gid", g.Gid), zap.Error(err))
 		} else {
-			succeeded++
-			newTorrents += count
+			state.Succeeded++
+			state.NewTorrents += count
 		}
 
-		processed++
-		if processed%100 == 0 {
+		state.Processed++
+		state.LastGid = g.Gid
+		if err := saveCheckpoint(ctx, TorrentImportCheckpointKind, state); err != nil {
+			return err
+		}
+
+		if state.Processed%100 == 0 {
 			ti.logger.Info("progress",
-				zap.Int("processed", processed),
-				zap.Int("succeeded", succeeded),
-				zap.Int("new_torrents", newTorrents),
-				zap.Int("total", len(galleries)),
+				zap.Int("processed", state.Processed),
+				zap.Int("succeeded", state.Succeeded),
+				zap.Int("new_torrents", state.NewTorrents),
+				zap.Int("total", total),
 			)
 		}
 
-		// Rate limiting for page fetches
-		time.Sleep(time.Duration(ti.cfg.PageDelaySeconds) * time.Second)
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("interrupted after %d of %d galleries: %w", state.Processed, total, err)
+		}
 	}
 
 	ti.logger.Info("torrent import completed",
-		zap.Int("processed", processed),
-		zap.Int("succeeded", succeeded),
-		zap.Int("new_torrents", newTorrents),
+		zap.Int("processed", state.Processed),
+		zap.Int("succeeded", state.Succeeded),
+		zap.Int("new_torrents", state.NewTorrents),
 	)
+
+	if err := checkpoint.Clear(ctx, TorrentImportCheckpointKind); err != nil {
+		ti.logger.Warn("failed to clear torrent import checkpoint", zap.Error(err))
+	}
 	return nil
 }
 
-func (ti *TorrentImporter) loadGalleriesToImport(ctx context.Context) ([]torrentImportGallery, error) {
+func (ti *TorrentImporter) loadGalleriesToImport(ctx context.Context, afterGid int) ([]torrentImportGallery, error) {
 	pool := database.GetPool()
-	query, args := buildTorrentImportGalleryQuery(ti.cfg)
+	query, args := buildTorrentImportGalleryQuery(ti.cfg, afterGid)
 
 	logArgs := make([]interface{}, 0, len(args))
 	for _, arg := range args {
@@ -133,7 +171,9 @@ func (ti *TorrentImporter) loadGalleriesToImport(ctx context.Context) ([]torrent
 	return galleries, nil
 }
 
-func buildTorrentImportGalleryQuery(cfg *config.CrawlerConfig) (string, []interface{}) {
+// buildTorrentImportGalleryQuery selects the galleries to import torrents
+// for, in gid order and after afterGid when resuming
+func buildTorrentImportGalleryQuery(cfg *config.CrawlerConfig, afterGid int) (string, []interface{}) {
 	query := `
 		SELECT gid, token, posted
 		FROM gallery
@@ -152,6 +192,13 @@ func buildTorrentImportGalleryQuery(cfg *config.CrawlerConfig) (string, []interf
 		)
 	}
 
+	if afterGid > 0 {
+		args = append(args, afterGid)
+		query += fmt.Sprintf(`
+		  AND gid > $%d
+		`, len(args))
+	}
+
 	query += `
 		ORDER BY gid ASC
 	`
@@ -166,50 +213,53 @@ func (ti *TorrentImporter) processGallery(ctx context.Context, gid int, token st
 	// Fetch torrent page directly
 	url := fmt.Sprintf("https://%s/gallerytorrents.php?gid=%d&t=%s", ti.cfg.Host, gid, token)
 
-	body, err := ti.client.Get(url)
+	body, err := ti.client.Get(ctx, url)
 	if err != nil {
 		return 0, fmt.Errorf("fetch torrent page: %w", err)
 	}
 
-	// Check for special cases
-	bodyStr := string(body)
+	page, parseErr := ehparse.ParseGalleryTorrents(body)
 
 	// Check if gallery is removed
-	if strings.Contains(bodyStr, "This gallery is currently unavailable") {
+	if parseErr == nil && page.Unavailable {
 		ti.logger.Debug("gallery unavailable, marking as removed", zap.Int("gid", gid))
-		return 0, ti.markGalleryRemoved(ctx, gid)
+		_, err := markGalleryRemoved(ctx, ti.logger, gid, removalSourceTorrent, "torrent page unavailable")
+		return 0, err
 	}
 
 	// Check if gallery not found (might be pending if posted within a week)
-	if strings.Contains(bodyStr, "Gallery not found") {
+	if parseErr == nil && page.NotFound {
 		oneWeekAgo := time.Now().Add(-7 * 24 * time.Hour)
 		if posted.Before(oneWeekAgo) {
 			ti.logger.Debug("gallery not found and old, marking as removed", zap.Int("gid", gid))
-			return 0, ti.markGalleryRemoved(ctx, gid)
+			_, err := markGalleryRemoved(ctx, ti.logger, gid, removalSourceTorrent, "torrent page not found")
+			return 0, err
 		} else {
 			ti.logger.Debug("gallery not found but recent (pending refresh)", zap.Int("gid", gid))
 			return 0, fmt.Errorf("gallery pending for cache refresh")
 		}
 	}
 
-	if reason, ok := suspectedAbnormalWebPageReason(body); ok {
-		reason = enrichAbnormalReasonWithAPIProbe(reason, ti.client, ti.logger)
+	if reason, ok := ehparse.ErrorPageReason(body); ok {
+		reason = enrichAbnormalReasonWithAPIProbe(ctx, reason, ti.client, ti.logger)
 		return 0, fmt.Errorf("torrent page abnormal: %s: %w", reason, ErrAbnormalPage)
 	}
 
-	// Parse root gid from announce URL
-	announcePattern := regexp.MustCompile(`/(\d+)/announce`)
-	announceMatches := announcePattern.FindStringSubmatch(bodyStr)
-	if len(announceMatches) < 2 {
+	if parseErr != nil {
+		return 0, fmt.Errorf("torrent page unparseable: %w", parseErr)
+	}
+
+	// Root gid comes from the announce URL
+	if page.RootGid == 0 {
 		// No torrents found, but set root_gid to itself
 		ti.logger.Debug("no torrents found", zap.Int("gid", gid))
 		return 0, ti.updateRootGid(ctx, gid, gid)
 	}
 
-	rootGid, _ := strconv.Atoi(announceMatches[1])
+	rootGid := page.RootGid
 
-	// Parse torrent information
-	torrents := ti.parseTorrents(body, rootGid)
+	// Parse torrent information, expunged torrents included
+	torrents := databaseTorrents(page.Torrents, rootGid, true)
 
 	newCount := 0
 	if len(torrents) > 0 {
@@ -256,51 +306,6 @@ func (ti *TorrentImporter) processGallery(ctx context.Context, gid int, token st
 	return newCount, nil
 }
 
-// parseTorrents parses torrent information from HTML
-func (ti *TorrentImporter) parseTorrents(html []byte, gid int) []database.Torrent {
-	var torrents []database.Torrent
-
-	// Pattern matches both normal and expunged torrents
-	pattern := regexp.MustCompile(`name="gtid"\svalue="(\d+?)"[\s\S]*?Posted:<.*?(\d{4}-\d{2}-\d{2} \d{2}:\d{2})<\/[\s\S]*?Size:.*>\s?([\d.KMGTiB ]+)<\/[\s\S]*?Uploader:.*?([\S]+)<\/[\s\S]*?(?:([0-9a-f]{40})\.torrent|(value="Expunged"))[\s\S]*?>(?:\s*?&nbsp;\s*?)?(.*?)(?:<\/a>)?<\/td>\s*?<\/tr>\s*?<\/table>`)
-
-	matches := pattern.FindAllSubmatch(html, -1)
-
-	for _, match := range matches {
-		if len(match) < 8 {
-			continue
-		}
-
-		gtidStr := string(match[1])
-		posted := string(match[2])
-		size := string(match[3])
-		uploader := string(match[4])
-		hashStr := string(match[5])
-		expungedStr := string(match[6])
-		name := string(match[7])
-
-		gtid, _ := strconv.Atoi(gtidStr)
-		expunged := expungedStr != ""
-
-		var hash *string
-		if hashStr != "" {
-			hash = &hashStr
-		}
-
-		torrents = append(torrents, database.Torrent{
-			ID:       gtid,
-			Gid:      gid,
-			Name:     strings.TrimSpace(name),
-			Hash:     hash,
-			Addedstr: &posted,
-			Fsizestr: &size,
-			Uploader: uploader,
-			Expunged: expunged,
-		})
-	}
-
-	return torrents
-}
-
 // getExistingTorrentHashes gets existing torrent hashes for a gallery
 func (ti *TorrentImporter) getExistingTorrentHashes(ctx context.Context, gid int) ([]string, error) {
 	pool := database.GetPool()
@@ -342,6 +347,7 @@ func (ti *TorrentImporter) saveTorrents(ctx context.Context, torrents []database
 			fsizestr = EXCLUDED.fsizestr,
 			uploader = EXCLUDED.uploader,
 			expunged = EXCLUDED.expunged
+		RETURNING (xmax = 0)
 	`
 
 	for _, t := range torrents {
@@ -351,12 +357,17 @@ func (ti *TorrentImporter) saveTorrents(ctx context.Context, torrents []database
 			)),
 		)
 
-		_, err := pool.Exec(ctx, query,
+		// xmax = 0 only for rows inserted rather than updated by the upsert
+		var inserted bool
+		err := pool.QueryRow(ctx, query,
 			t.ID, t.Gid, t.Name, t.Hash, t.Addedstr, t.Fsizestr, t.Uploader, t.Expunged,
-		)
+		).Scan(&inserted)
 		if err != nil {
 			return fmt.Errorf("insert torrent %d: %w", t.ID, err)
 		}
+		if inserted {
+			jobrun.Add(ctx, jobrun.TorrentsAdded, 1)
+		}
 	}
 
 	return nil
@@ -396,23 +407,6 @@ func (ti *TorrentImporter) updateRootGid(ctx context.Context, gid int, rootGid i
 	return nil
 }
 
-// markGalleryRemoved marks a gallery as removed
-func (ti *TorrentImporter) markGalleryRemoved(ctx context.Context, gid int) error {
-	pool := database.GetPool()
-	query := `UPDATE gallery SET removed = true WHERE gid = $1`
-
-	ti.logger.Debug("executing query",
-		zap.String("sql", utils.FormatSQL(query, gid)),
-	)
-
-	_, err := pool.Exec(ctx, query, gid)
-	if err != nil {
-		return fmt.Errorf("mark gallery removed: %w", err)
-	}
-
-	return nil
-}
-
 // contains checks if a string slice contains a value
 func contains(slice []string, val string) bool {
 	for _, item := range slice {
@@ -10,7 +10,7 @@ import (
 
 func TestBuildTorrentImportGalleryQuery(t *testing.T) {
 	t.Run("without window uses unbounded query", func(t *testing.T) {
-		query, args := buildTorrentImportGalleryQuery(&config.CrawlerConfig{})
+		query, args := buildTorrentImportGalleryQuery(&config.CrawlerConfig{}, 0)
 
 		if len(args) != 0 {
 			t.Fatalf("expected no query args, got %d", len(args))
@@ -27,7 +27,7 @@ func TestBuildTorrentImportGalleryQuery(t *testing.T) {
 			BackfillEnd:   time.Date(2026, 1, 31, 23, 59, 59, 0, time.UTC).Unix(),
 		}
 
-		query, args := buildTorrentImportGalleryQuery(cfg)
+		query, args := buildTorrentImportGalleryQuery(cfg, 0)
 
 		if !strings.Contains(query, "posted >= $1") || !strings.Contains(query, "posted <= $2") {
 			t.Fatalf("expected query with posted window filters, got %q", query)
@@ -53,4 +53,31 @@ func TestBuildTorrentImportGalleryQuery(t *testing.T) {
 			t.Fatalf("expected end arg %s, got %s", time.Unix(cfg.BackfillEnd, 0).UTC(), endTime)
 		}
 	})
+
+	t.Run("resume adds gid bound after window", func(t *testing.T) {
+		cfg := &config.CrawlerConfig{
+			BackfillStart: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC).Unix(),
+			BackfillEnd:   time.Date(2026, 1, 31, 23, 59, 59, 0, time.UTC).Unix(),
+		}
+
+		query, args := buildTorrentImportGalleryQuery(cfg, 3865624)
+
+		if !strings.Contains(query, "gid > $3") {
+			t.Fatalf("expected query with gid bound, got %q", query)
+		}
+		if len(args) != 3 || args[2] != 3865624 {
+			t.Fatalf("expected gid 3865624 as third arg, got %v", args)
+		}
+	})
+
+	t.Run("resume without window uses first placeholder", func(t *testing.T) {
+		query, args := buildTorrentImportGalleryQuery(&config.CrawlerConfig{}, 3865624)
+
+		if !strings.Contains(query, "gid > $1") {
+			t.Fatalf("expected query with gid bound, got %q", query)
+		}
+		if len(args) != 1 || args[0] != 3865624 {
+			t.Fatalf("expected gid 3865624 as only arg, got %v", args)
+		}
+	})
 }
@@ -1,6 +1,7 @@
 package crawler
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
@@ -40,7 +41,7 @@ func TestTorrentCrawlerFetchTorrentListPageEnrichesAbnormalPageWithAPIBanProbe(t
 		logger: zap.NewNop(),
 	}
 
-	_, err = crawler.fetchTorrentListPage(0)
+	_, err = crawler.fetchTorrentListPage(context.Background(), 0)
 	if err == nil {
 		t.Fatal("expected abnormal page error, got nil")
 	}
@@ -15,6 +15,16 @@ func (t UnixTime) MarshalJSON() ([]byte, error) {
 	return json.Marshal(t.Unix())
 }
 
+// UnmarshalJSON parses a Unix timestamp as produced by MarshalJSON
+func (t *UnixTime) UnmarshalJSON(data []byte) error {
+	var seconds int64
+	if err := json.Unmarshal(data, &seconds); err != nil {
+		return err
+	}
+	t.Time = time.Unix(seconds, 0)
+	return nil
+}
+
 // Gallery represents a gallery record
 type Gallery struct {
 	Gid          int       `json:"gid"`
@@ -37,6 +47,8 @@ type Gallery struct {
 	Bytorrent    bool      `json:"bytorrent"`
 	Tags         []string  `json:"tags"`
 	Torrents     []Torrent `json:"torrents"`
+	// Translated tag labels keyed by tag, only filled when requested with ?translate=1
+	TagTranslations map[string]string `json:"tag_translations,omitempty"`
 }
 
 // Tag represents a tag record
@@ -57,6 +69,18 @@ type Torrent struct {
 	Expunged bool    `json:"expunged"`
 }
 
+// Comment represents a gallery comment record
+type Comment struct {
+	ID       int       `json:"id"` // 0 for the uploader comment
+	Gid      int       `json:"gid"`
+	Author   string    `json:"author"`
+	Uploader bool      `json:"uploader"`
+	Score    *int      `json:"score"` // nil for the uploader comment
+	Posted   UnixTime  `json:"posted"`
+	Edited   *UnixTime `json:"edited"`
+	Body     string    `json:"body"`
+}
+
 // GalleryMetadata represents metadata from E-Hentai API
 type GalleryMetadata struct {
 	Gid          int      `json:"gid"`
@@ -0,0 +1,691 @@
+// Package ehmock is a local E-Hentai server for end-to-end crawler tests. It
+// serves gallery list pages, gallery pages, api.php gdata responses,
+// /torrents.php and gallerytorrents.php from a small in-memory dataset, and can inject the
+// failures the crawler has to survive: rate limits, temporary bans, the
+// ExHentai empty shell and Cloudflare challenges.
+package ehmock
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultPageSize is the number of galleries or torrents per list page
+const DefaultPageSize = 25
+
+// Gallery is one gallery of the dataset
+type Gallery struct {
+	Gid       int
+	Token     string
+	Title     string
+	TitleJpn  string
+	Category  string
+	Uploader  string
+	Posted    time.Time
+	Filecount int
+	Filesize  int64
+	Rating    float64
+	Expunged  bool // Listed only in expunged mode (f_sh=on)
+	Tags      []string
+	Torrents  []Torrent
+	// RootGid is the gid of the first version of a replaced gallery, whose
+	// tracker the torrents use; zero means Gid
+	RootGid int
+	// Parent is the gid of the gallery this one is a newer version of, shown
+	// on the gallery page; zero for none. The parent lists it as a newer
+	// version and is shown as replaced.
+	Parent      int
+	Favorites   int
+	RatingCount int
+	// Comments shown on the gallery page in order; the one with ID zero is
+	// the uploader comment
+	Comments []Comment
+}
+
+// Comment is one comment of a gallery. Comments with a negative score are
+// below the viewing threshold and only shown with hc=1.
+type Comment struct {
+	ID     int
+	Author string
+	Score  int
+	Posted time.Time
+	Edited time.Time // Zero for never edited
+	Body   string    // Lines are rendered with <br />
+}
+
+// Torrent is one torrent of a gallery
+type Torrent struct {
+	ID       int
+	Hash     string // 40 hex characters
+	Name     string
+	Added    time.Time
+	Size     string // e.g. "120.5 MiB"
+	Uploader string
+	Expunged bool // Shown without a file link
+}
+
+// Generate returns count galleries with descending gids from firstGid and
+// posted times step apart, newest first, each with one torrent whose id is
+// the gallery's gid
+func Generate(count, firstGid int, newest time.Time, step time.Duration) []Gallery {
+	galleries := make([]Gallery, 0, count)
+	for i := 0; i < count; i++ {
+		gid := firstGid - i
+		posted := newest.Add(-time.Duration(i) * step).UTC().Truncate(time.Minute)
+		galleries = append(galleries, Gallery{
+			Gid:       gid,
+			Token:     Token(gid),
+			Title:     fmt.Sprintf("[Mock Circle (Mock Artist)] Mock Gallery %d [English]", gid),
+			TitleJpn:  fmt.Sprintf("[模擬サークル] 模擬ギャラリー %d", gid),
+			Category:  "Doujinshi",
+			Uploader:  "mockuploader",
+			Posted:    posted,
+			Filecount: 20 + i,
+			Filesize:  int64(1000000 * (i + 1)),
+			Rating:    4.5,
+			Tags:      []string{"language:english", "language:translated", "female:mock tag"},
+			Torrents: []Torrent{{
+				ID:       gid,
+				Hash:     fmt.Sprintf("%040x", gid),
+				Name:     fmt.Sprintf("Mock Gallery %d.zip", gid),
+				Added:    posted.Add(time.Hour),
+				Size:     "120.5 MiB",
+				Uploader: "mockuploader",
+			}},
+			Favorites:   10 * (i + 1),
+			RatingCount: 5 * (i + 1),
+			Comments: []Comment{
+				{ID: 0, Author: "mockuploader", Posted: posted, Edited: posted.Add(time.Hour), Body: "Mock upload\nSource: https://example.com/"},
+				{ID: 1000*(i+1) + 1, Author: "mockreader", Score: 3, Posted: posted.Add(2 * time.Hour), Body: "Thanks!"},
+				{ID: 1000*(i+1) + 2, Author: "mockcritic", Score: -5, Posted: posted.Add(3 * time.Hour), Body: "Hidden below the threshold"},
+			},
+		})
+	}
+	return galleries
+}
+
+// Token returns the deterministic gallery token Generate uses for gid
+func Token(gid int) string {
+	return fmt.Sprintf("%010x", (uint64(gid)*2654435761)%(1<<40))
+}
+
+// Route is a group of endpoints failures can be injected into
+type Route string
+
+const (
+	RouteList            Route = "list"            // Gallery list pages (/)
+	RouteGallery         Route = "gallery"         // Gallery pages (/g/gid/token/)
+	RouteAPI             Route = "api"             // api.php
+	RouteTorrentList     Route = "torrents"        // /torrents.php
+	RouteGalleryTorrents Route = "gallerytorrents" // /gallerytorrents.php
+)
+
+// FailureKind is a failure response the server can inject
+type FailureKind int
+
+const (
+	// RateLimited answers 429 Too Many Requests with Retry-After
+	RateLimited FailureKind = iota
+	// Unavailable answers 503 Service Unavailable with Retry-After
+	Unavailable
+	// TemporaryBan answers with the temporary IP ban page
+	TemporaryBan
+	// ExHentaiShell answers with the empty HTML shell and igneous=mystery
+	// that ExHentai serves to sessions without access
+	ExHentaiShell
+	// CloudflareChallenge answers with a Cloudflare "Just a moment" page
+	CloudflareChallenge
+)
+
+// Failure is an injected failure response
+type Failure struct {
+	Kind       FailureKind
+	RetryAfter time.Duration // Sent as whole seconds with RateLimited and Unavailable
+	BanExpires time.Duration // Remaining ban shown by TemporaryBan, five minutes if zero
+}
+
+// Server is a running mock E-Hentai server. Its Client reaches it under the
+// real host names (e-hentai.org, exhentai.org, api.e-hentai.org), so crawlers
+// run with their production config apart from the HTTP client.
+type Server struct {
+	srv *httptest.Server
+
+	mu        sync.Mutex
+	galleries []Gallery // Newest gid first
+	pageSize  int
+	failures  map[Route][]Failure
+	requests  map[Route]int
+}
+
+// New starts a server serving galleries. Close it when done.
+func New(galleries []Gallery) *Server {
+	s := &Server{
+		pageSize: DefaultPageSize,
+		failures: make(map[Route][]Failure),
+		requests: make(map[Route]int),
+	}
+	s.SetGalleries(galleries)
+	s.srv = httptest.NewTLSServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Close shuts the server down
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// URL returns the server's own base URL
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// Client returns an HTTP client that sends requests for any host to the
+// server. Certificates are not verified, since the test certificate does not
+// cover the E-Hentai host names.
+func (s *Server) Client() *http.Client {
+	addr := s.srv.Listener.Addr().String()
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, addr)
+			},
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+		Timeout: 10 * time.Second,
+	}
+}
+
+// SetGalleries replaces the dataset
+func (s *Server) SetGalleries(galleries []Gallery) {
+	sorted := append([]Gallery(nil), galleries...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Gid > sorted[j].Gid })
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.galleries = sorted
+}
+
+// SetPageSize sets the number of entries per list page
+func (s *Server) SetPageSize(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pageSize = n
+}
+
+// Inject makes the next times requests to route fail with f, after any
+// failures injected earlier
+func (s *Server) Inject(route Route, times int, f Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < times; i++ {
+		s.failures[route] = append(s.failures[route], f)
+	}
+}
+
+// Requests returns how many requests route received, failed ones included
+func (s *Server) Requests(route Route) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[route]
+}
+
+func routeOf(path string) (Route, bool) {
+	switch path {
+	case "/", "":
+		return RouteList, true
+	case "/api.php":
+		return RouteAPI, true
+	case "/torrents.php":
+		return RouteTorrentList, true
+	case "/gallerytorrents.php":
+		return RouteGalleryTorrents, true
+	default:
+		if strings.HasPrefix(path, "/g/") {
+			return RouteGallery, true
+		}
+		return "", false
+	}
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	route, ok := routeOf(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.mu.Lock()
+	s.requests[route]++
+	var failure *Failure
+	if queued := s.failures[route]; len(queued) > 0 {
+		failure = &queued[0]
+		s.failures[route] = queued[1:]
+	}
+	galleries := s.galleries
+	pageSize := s.pageSize
+	s.mu.Unlock()
+
+	if failure != nil {
+		writeFailure(w, *failure)
+		return
+	}
+
+	switch route {
+	case RouteList:
+		s.serveList(w, r, galleries, pageSize)
+	case RouteGallery:
+		s.serveGallery(w, r, galleries)
+	case RouteAPI:
+		s.serveAPI(w, r, galleries)
+	case RouteTorrentList:
+		s.serveTorrentList(w, r, galleries, pageSize)
+	case RouteGalleryTorrents:
+		s.serveGalleryTorrents(w, r, galleries)
+	}
+}
+
+func writeFailure(w http.ResponseWriter, f Failure) {
+	switch f.Kind {
+	case RateLimited, Unavailable:
+		status := http.StatusTooManyRequests
+		if f.Kind == Unavailable {
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(int(f.RetryAfter/time.Second)))
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		w.WriteHeader(status)
+		_, _ = fmt.Fprintf(w, "<html><body>%d %s</body></html>", status, http.StatusText(status))
+	case TemporaryBan:
+		expires := f.BanExpires
+		if expires <= 0 {
+			expires = 5 * time.Minute
+		}
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		_, _ = fmt.Fprintf(w, "Your IP address has been temporarily banned for excessive pageloads which indicates that you are using automated mirroring/harvesting software. (The ban expires in %d minutes and %d seconds)",
+			int(expires/time.Minute), int(expires%time.Minute/time.Second))
+	case ExHentaiShell:
+		http.SetCookie(w, &http.Cookie{Name: "igneous", Value: "mystery", Path: "/", Domain: ".exhentai.org"})
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		_, _ = w.Write([]byte("<html><head></head><body></body></html>"))
+	case CloudflareChallenge:
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		_, _ = w.Write([]byte(`<!DOCTYPE html><html lang="en-US"><head><title>Just a moment...</title></head><body><div id="challenge-running">Checking your browser before accessing e-hentai.org.</div><script>window._cf_chl_opt={cvId: '3'};</script></body></html>`))
+	}
+}
+
+// serveList serves a compact mode gallery list page. Like the site, ?next=N
+// lists galleries with gids below N, newest first, and expunged galleries
+// only appear with f_sh=on.
+func (s *Server) serveList(w http.ResponseWriter, r *http.Request, galleries []Gallery, pageSize int) {
+	query := r.URL.Query()
+	next, _ := strconv.Atoi(query.Get("next"))
+	showExpunged := query.Get("f_sh") == "on"
+
+	var page []Gallery
+	more := false
+	for _, g := range galleries {
+		if next > 0 && g.Gid >= next {
+			continue
+		}
+		if g.Expunged && !showExpunged {
+			continue
+		}
+		if len(page) == pageSize {
+			more = true
+			break
+		}
+		page = append(page, g)
+	}
+
+	host := r.Host
+	var b strings.Builder
+	b.WriteString(`<html><head><title>E-Hentai Galleries</title></head><body><div class="ido">`)
+	b.WriteString(`<div class="searchnav"><div><a id="dnext" href="#">Next &gt;</a></div></div>`)
+	b.WriteString("\n<table class=\"itg gltc\">\n")
+	b.WriteString(`<tr><th>Category</th><th>Published</th><th>Title</th><th>Uploader</th></tr>` + "\n")
+	for _, g := range page {
+		fmt.Fprintf(&b, `<tr><td class="gl1c glcat"><div class="cn ct2" onclick="document.location='https://%[1]s/doujinshi'">%[2]s</div></td>`+
+			`<td class="gl2c"><div class="glthumb" id="it%[3]d"></div><div><div onclick="popUp('https://%[1]s/gallerypopups.php?gid=%[3]d&amp;t=%[4]s&amp;act=addfav',675,415)" id="posted_%[3]d">%[5]s</div></div></td>`+
+			`<td class="gl3c glname"><a href="https://%[1]s/g/%[3]d/%[4]s/"><div class="glink">%[6]s</div></a></td>`+
+			`<td class="gl4c glhide"><div><a href="https://%[1]s/uploader/%[7]s">%[7]s</a></div><div>%[8]d pages</div></td></tr>`+"\n",
+			host, g.Category, g.Gid, g.Token, g.Posted.UTC().Format("2006-01-02 15:04"), html.EscapeString(g.Title), g.Uploader, g.Filecount)
+	}
+	b.WriteString("</table>\n")
+	if len(page) == 0 {
+		b.WriteString(`<p class="ip">No hits found</p>`)
+	}
+	if more {
+		fmt.Fprintf(&b, `<script type="text/javascript">var nexturl="https://%s/?next=%d";</script>`, host, page[len(page)-1].Gid)
+	}
+	b.WriteString("</div></body></html>")
+
+	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+	_, _ = w.Write([]byte(b.String()))
+}
+
+// apiMetadata is one gdata entry in the site's API format
+type apiMetadata struct {
+	Gid          int      `json:"gid"`
+	Token        string   `json:"token,omitempty"`
+	ArchiverKey  string   `json:"archiver_key,omitempty"`
+	Title        string   `json:"title,omitempty"`
+	TitleJpn     string   `json:"title_jpn,omitempty"`
+	Category     string   `json:"category,omitempty"`
+	Thumb        string   `json:"thumb,omitempty"`
+	Uploader     string   `json:"uploader,omitempty"`
+	Posted       string   `json:"posted,omitempty"`
+	Filecount    string   `json:"filecount,omitempty"`
+	Filesize     int64    `json:"filesize,omitempty"`
+	Expunged     bool     `json:"expunged"`
+	Rating       string   `json:"rating,omitempty"`
+	Torrentcount string   `json:"torrentcount,omitempty"`
+	Tags         []string `json:"tags"`
+	Error        string   `json:"error,omitempty"`
+}
+
+func (s *Server) serveAPI(w http.ResponseWriter, r *http.Request, galleries []Gallery) {
+	var request struct {
+		Method  string            `json:"method"`
+		Gidlist []json.RawMessage `json:"gidlist"`
+	}
+	if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&request) != nil || request.Method != "gdata" {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"error":"Invalid request."}`))
+		return
+	}
+
+	byGid := make(map[int]Gallery, len(galleries))
+	for _, g := range galleries {
+		byGid[g.Gid] = g
+	}
+
+	metadata := make([]apiMetadata, 0, len(request.Gidlist))
+	for _, raw := range request.Gidlist {
+		var pair [2]interface{}
+		if err := json.Unmarshal(raw, &pair); err != nil {
+			continue
+		}
+		gidValue, _ := pair[0].(float64)
+		gid := int(gidValue)
+		token, _ := pair[1].(string)
+
+		g, ok := byGid[gid]
+		if !ok || g.Token != token {
+			metadata = append(metadata, apiMetadata{Gid: gid, Error: "Key missing, or incorrect key provided."})
+			continue
+		}
+
+		tags := g.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		metadata = append(metadata, apiMetadata{
+			Gid:          g.Gid,
+			Token:        g.Token,
+			ArchiverKey:  fmt.Sprintf("%d--%s", g.Gid, g.Token),
+			Title:        g.Title,
+			TitleJpn:     g.TitleJpn,
+			Category:     g.Category,
+			Thumb:        fmt.Sprintf("https://ehgt.org/mock/%d_l.jpg", g.Gid),
+			Uploader:     g.Uploader,
+			Posted:       strconv.FormatInt(g.Posted.Unix(), 10),
+			Filecount:    strconv.Itoa(g.Filecount),
+			Filesize:     g.Filesize,
+			Expunged:     g.Expunged,
+			Rating:       strconv.FormatFloat(g.Rating, 'f', 2, 64),
+			Torrentcount: strconv.Itoa(len(g.Torrents)),
+			Tags:         tags,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"gmetadata": metadata})
+}
+
+type listedTorrent struct {
+	gallery Gallery
+	torrent Torrent
+}
+
+// serveTorrentList serves /torrents.php, newest torrent first, with ?page=N
+// counting from zero
+func (s *Server) serveTorrentList(w http.ResponseWriter, r *http.Request, galleries []Gallery, pageSize int) {
+	var all []listedTorrent
+	for _, g := range galleries {
+		for _, t := range g.Torrents {
+			all = append(all, listedTorrent{gallery: g, torrent: t})
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].torrent.ID > all[j].torrent.ID })
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	start := page * pageSize
+	if start > len(all) {
+		start = len(all)
+	}
+	end := start + pageSize
+	if end > len(all) {
+		end = len(all)
+	}
+
+	host := r.Host
+	var b strings.Builder
+	b.WriteString(`<html><head><title>E-Hentai Torrents</title></head><body><div class="ido">` + "\n")
+	b.WriteString(`<table class="itg"><tr><th>Added</th><th>Torrent Name</th><th>Gallery</th><th>Size</th><th>Uploader</th></tr>` + "\n")
+	for _, entry := range all[start:end] {
+		g, t := entry.gallery, entry.torrent
+		fmt.Fprintf(&b, `<tr><td>%s</td><td><a href="https://%s/gallerytorrents.php?gid=%d&amp;t=%s&amp;gtid=%d" onclick="return popUp(this.href, 610, 590)">%s</a></td><td><a href="https://%s/g/%d/%s/">%d</a></td><td>%s</td><td>%s</td></tr>`+"\n",
+			t.Added.UTC().Format("2006-01-02 15:04"), host, g.Gid, g.Token, t.ID, html.EscapeString(t.Name), host, g.Gid, g.Token, g.Gid, t.Size, t.Uploader)
+	}
+	b.WriteString("</table>\n")
+	if start == end {
+		b.WriteString(`<p>No torrents found.</p>`)
+	}
+	b.WriteString("</div></body></html>")
+
+	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+	_, _ = w.Write([]byte(b.String()))
+}
+
+// serveGallery serves the details of a gallery page: parent, visibility,
+// language, favorite and rating counts, the newer versions notice of
+// galleries that are the Parent of others, and the comments
+func (s *Server) serveGallery(w http.ResponseWriter, r *http.Request, galleries []Gallery) {
+	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	var gid int
+	var token string
+	if len(parts) == 3 {
+		gid, _ = strconv.Atoi(parts[1])
+		token = parts[2]
+	}
+
+	var gallery *Gallery
+	var newer []Gallery
+	for i := range galleries {
+		if galleries[i].Gid == gid {
+			gallery = &galleries[i]
+		}
+		if gid != 0 && galleries[i].Parent == gid {
+			newer = append(newer, galleries[i])
+		}
+	}
+	if gallery == nil {
+		_, _ = w.Write([]byte(`<html><body><div class="d"><p>This gallery has been removed or is unavailable.</p></div></body></html>`))
+		return
+	}
+	if gallery.Token != token {
+		_, _ = w.Write([]byte(`<html><body><div class="d"><p>Key missing, or incorrect key provided.</p></div></body></html>`))
+		return
+	}
+	sort.Slice(newer, func(i, j int) bool { return newer[i].Gid < newer[j].Gid })
+
+	host := r.Host
+	parent := "None"
+	if gallery.Parent != 0 {
+		parent = fmt.Sprintf(`<a href="https://%s/g/%d/%s/">%d</a>`, host, gallery.Parent, Token(gallery.Parent), gallery.Parent)
+	}
+	visible := "Yes"
+	switch {
+	case gallery.Expunged:
+		visible = "No (Expunged)"
+	case len(newer) > 0:
+		visible = "No (Replaced)"
+	}
+	language := "Japanese"
+	translated := false
+	for _, tag := range gallery.Tags {
+		value, ok := strings.CutPrefix(tag, "language:")
+		switch {
+		case !ok:
+		case value == "translated":
+			translated = true
+		case language == "Japanese":
+			language = strings.ToUpper(value[:1]) + value[1:]
+		}
+	}
+	if translated {
+		language += ` &nbsp;<span class="halp" title="This gallery has been translated from the original language text.">TR</span>`
+	}
+	favorited := "Never"
+	switch {
+	case gallery.Favorites == 1:
+		favorited = "Once"
+	case gallery.Favorites > 1:
+		favorited = fmt.Sprintf("%d times", gallery.Favorites)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<html><head><title>%s - E-Hentai Galleries</title></head><body>\n<div class=\"gm\">", html.EscapeString(gallery.Title))
+	fmt.Fprintf(&b, `<div id="gd2"><h1 id="gn">%s</h1><h1 id="gj">%s</h1></div>`+"\n", html.EscapeString(gallery.Title), html.EscapeString(gallery.TitleJpn))
+	b.WriteString(`<div id="gmid"><div id="gd3">` + "\n")
+	fmt.Fprintf(&b, `<div id="gdn"><a href="https://%[1]s/uploader/%[2]s">%[2]s</a></div>`+"\n", host, gallery.Uploader)
+	b.WriteString(`<div id="gdd"><table>` + "\n")
+	rows := [][2]string{
+		{"Posted:", gallery.Posted.UTC().Format("2006-01-02 15:04")},
+		{"Parent:", parent},
+		{"Visible:", visible},
+		{"Language:", language},
+		{"Length:", fmt.Sprintf("%d pages", gallery.Filecount)},
+	}
+	for _, row := range rows {
+		fmt.Fprintf(&b, `<tr><td class="gdt1">%s</td><td class="gdt2">%s</td></tr>`+"\n", row[0], row[1])
+	}
+	fmt.Fprintf(&b, `<tr><td class="gdt1">Favorited:</td><td class="gdt2" id="favcount">%s</td></tr>`+"\n", favorited)
+	b.WriteString("</table></div>\n")
+	fmt.Fprintf(&b, `<div id="gdr"><table><tr><td class="grt1">Rating:</td><td class="grt3">(<span id="rating_count">%d</span>)</td></tr>`+
+		`<tr><td id="rating_label" colspan="3">Average: %.2f</td></tr></table></div>`+"\n", gallery.RatingCount, gallery.Rating)
+	b.WriteString("</div></div>\n")
+	if len(newer) > 0 {
+		b.WriteString(`<div id="gnd">There are newer versions of this gallery available:`)
+		for _, g := range newer {
+			fmt.Fprintf(&b, `<br /><a href="https://%s/g/%d/%s/">%s</a>, added %s`, host, g.Gid, g.Token, html.EscapeString(g.Title), g.Posted.UTC().Format("2006-01-02 15:04"))
+		}
+		b.WriteString("</div>\n")
+	}
+	writeComments(&b, host, gallery.Comments, r.URL.Query().Get("hc") == "1")
+	b.WriteString("</div></body></html>")
+
+	_, _ = w.Write([]byte(b.String()))
+}
+
+// writeComments writes the #cdiv comment thread, leaving out comments below
+// the viewing threshold unless showAll
+func writeComments(b *strings.Builder, host string, comments []Comment, showAll bool) {
+	const timeLayout = "2 January 2006, 15:04"
+
+	b.WriteString(`<div id="cdiv" class="gm">` + "\n")
+	hidden := 0
+	for _, c := range comments {
+		if c.Score < 0 && !showAll {
+			hidden++
+			continue
+		}
+		lines := strings.Split(c.Body, "\n")
+		for i := range lines {
+			lines[i] = html.EscapeString(lines[i])
+		}
+
+		fmt.Fprintf(b, `<a name="c%d"></a><div class="c1"><div class="c2"><div class="c3">Posted on %s by: &nbsp; <a href="https://%s/uploader/%s">%s</a></div>`,
+			c.ID, c.Posted.UTC().Format(timeLayout), host, c.Author, html.EscapeString(c.Author))
+		if c.ID == 0 {
+			b.WriteString(`<div class="c4 nosel"><a name="ulcomment"></a>Uploader Comment</div>`)
+		} else {
+			fmt.Fprintf(b, `<div class="c5 nosel">Score <span id="comment_score_%d">%+d</span></div>`, c.ID, c.Score)
+		}
+		fmt.Fprintf(b, `<div class="c"></div></div><div class="c6" id="comment_%d">%s</div>`, c.ID, strings.Join(lines, "<br />"))
+		if !c.Edited.IsZero() {
+			fmt.Fprintf(b, `<div class="c8">Last edited on %s.</div>`, c.Edited.UTC().Format(timeLayout))
+		}
+		b.WriteString("</div>\n")
+	}
+	if hidden > 0 {
+		fmt.Fprintf(b, `<div id="chd"><p>There are %d more comments below the viewing threshold - <a href="?hc=1#comments">click to show all</a>.</p></div>`+"\n", hidden)
+	}
+	b.WriteString("</div>\n")
+}
+
+// serveGalleryTorrents serves the torrent popup of one gallery
+func (s *Server) serveGalleryTorrents(w http.ResponseWriter, r *http.Request, galleries []Gallery) {
+	query := r.URL.Query()
+	gid, _ := strconv.Atoi(query.Get("gid"))
+	token := query.Get("t")
+
+	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+
+	var gallery *Gallery
+	for i := range galleries {
+		if galleries[i].Gid == gid {
+			gallery = &galleries[i]
+			break
+		}
+	}
+	if gallery == nil || gallery.Token != token {
+		_, _ = w.Write([]byte(`<html><body><p>Gallery not found. If you just added this gallery, please wait a few minutes for it to be indexed.</p></body></html>`))
+		return
+	}
+
+	rootGid := gallery.RootGid
+	if rootGid == 0 {
+		rootGid = gallery.Gid
+	}
+
+	host := r.Host
+	var b strings.Builder
+	fmt.Fprintf(&b, "<html><head><title>Torrents for %s</title></head><body>\n<div id=\"torrentinfo\"><div>\n", html.EscapeString(gallery.Title))
+	if len(gallery.Torrents) == 0 {
+		b.WriteString("<p>There are no torrents for this gallery.</p>\n")
+	}
+	for _, t := range gallery.Torrents {
+		fmt.Fprintf(&b, `<form method="post" action="https://%s/gallerytorrents.php?gid=%d&amp;t=%s">`+"\n", host, gallery.Gid, gallery.Token)
+		fmt.Fprintf(&b, `<input type="hidden" name="gtid" value="%d" />`+"\n", t.ID)
+		b.WriteString(`<table style="width:99%">` + "\n<tr>\n")
+		fmt.Fprintf(&b, `<td style="width:190px"><span style="font-weight:bold">Posted:</span> <span>%s</span></td>`+"\n", t.Added.UTC().Format("2006-01-02 15:04"))
+		fmt.Fprintf(&b, `<td style="width:150px"><span style="font-weight:bold">Size:</span> %s</td>`+"\n", t.Size)
+		b.WriteString(`<td style="width:80px"><span style="font-weight:bold">Seeds:</span> 1</td>` + "\n</tr>\n<tr>\n")
+		fmt.Fprintf(&b, `<td colspan="5"><span style="font-weight:bold">Uploader:</span> %s</td>`+"\n</tr>\n<tr>\n", t.Uploader)
+		if t.Expunged {
+			fmt.Fprintf(&b, `<td colspan="5"><input type="submit" name="torrent_info" value="Expunged" disabled="disabled" /> &nbsp; %s</td>`+"\n</tr>\n</table>\n</form>\n",
+				html.EscapeString(t.Name))
+			continue
+		}
+		fmt.Fprintf(&b, `<td colspan="5"><a href="https://ehtracker.org/get/%d/%s.torrent?p=mock" onclick="document.location='https://ehtracker.org/get/%d/%s.torrent?p=mock'; return false">%s</a></td>`+"\n</tr>\n</table>\n</form>\n",
+			rootGid, t.Hash, rootGid, t.Hash, html.EscapeString(t.Name))
+	}
+	fmt.Fprintf(&b, "</div>\n<p>Tracker for personal torrents: https://ehtracker.org/%d/announce</p>\n</div></body></html>", rootGid)
+
+	_, _ = w.Write([]byte(b.String()))
+}
@@ -0,0 +1,396 @@
+package ehparse
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// ErrNoGalleryDetail is returned for gallery pages without the detail table
+var ErrNoGalleryDetail = errors.New("no gallery details on page")
+
+// GalleryDetail is a parsed /g/gid/token/ page, limited to the fields the
+// gdata API does not return
+type GalleryDetail struct {
+	Unavailable bool // "This gallery has been removed or is unavailable", or a copyright claim
+	InvalidKey  bool // "Key missing, or incorrect key provided", i.e. a wrong token
+
+	ParentGid    int    // Zero without a parent
+	ParentToken  string // Empty without a parent
+	Visible      bool
+	HiddenReason string // Why an invisible gallery is hidden, e.g. "Replaced"
+	Language     string // Lowercased, e.g. "english"; empty for "N/A"
+	Translated   bool   // Language marked TR
+	Rewrite      bool   // Language marked RW
+	Favorites    int
+	RatingCount  int
+	Newer        []GalleryVersion // Newer versions, oldest first
+	// Comments in page order, the uploader comment first. Without hc=1 in
+	// the URL the site hides comments below the viewing threshold.
+	Comments []Comment
+}
+
+// Comment is one comment of a gallery
+type Comment struct {
+	ID       int // Zero for the uploader comment
+	Author   string
+	Uploader bool // The uploader comment, which has no score
+	Score    int
+	Posted   time.Time
+	Edited   time.Time // Zero when never edited
+	Body     string    // Text with one line per line break; links keep their URL
+}
+
+// GalleryVersion is a newer version of a gallery
+type GalleryVersion struct {
+	Gid    int
+	Token  string
+	Title  string
+	Posted string // PostedLayout
+}
+
+var (
+	visibilityPattern    = regexp.MustCompile(`^No \((.+)\)$`)
+	favoritesPattern     = regexp.MustCompile(`^([\d,]+) times$`)
+	versionAddedPattern  = regexp.MustCompile(`added (\d{4}-\d{2}-\d{2} \d{2}:\d{2})`)
+	commentPostedPattern = regexp.MustCompile(`Posted on (\d{1,2} \w+ \d{4}, \d{2}:\d{2})`)
+	commentEditedPattern = regexp.MustCompile(`Last edited on (\d{1,2} \w+ \d{4}, \d{2}:\d{2})`)
+)
+
+// commentTimeLayout is the format of comment times, in UTC
+const commentTimeLayout = "2 January 2006, 15:04"
+
+// ParseGalleryDetail parses a gallery page. The fields are read from the
+// labelled rows of its detail table, the rating count and the newer versions
+// notice, so the row order does not matter.
+func ParseGalleryDetail(body []byte) (*GalleryDetail, error) {
+	doc, err := parseDocument(body)
+	if err != nil {
+		return nil, &ParseError{Page: "gallery detail", Field: "document", Err: err}
+	}
+
+	detail := &GalleryDetail{}
+	content := text(doc)
+	if strings.Contains(content, "This gallery has been removed or is unavailable") ||
+		strings.Contains(content, "This gallery is unavailable due to a copyright claim") {
+		detail.Unavailable = true
+		return detail, nil
+	}
+	if strings.Contains(content, "Key missing, or incorrect key provided") {
+		detail.InvalidKey = true
+		return detail, nil
+	}
+
+	table := findByID(doc, "gdd")
+	if table == nil {
+		return nil, ErrNoGalleryDetail
+	}
+	rows := detailRows(table)
+
+	parent, ok := rows["Parent:"]
+	if !ok {
+		return nil, detailError("parent", "", ErrMissing)
+	}
+	if value := text(parent); value != "None" {
+		gid, token, ok := linkedGallery(parent)
+		if !ok {
+			return nil, detailError("parent", value, errors.New("not a gallery link"))
+		}
+		detail.ParentGid, detail.ParentToken = gid, token
+	}
+
+	visible, ok := rows["Visible:"]
+	if !ok {
+		return nil, detailError("visible", "", ErrMissing)
+	}
+	switch value := text(visible); {
+	case value == "Yes":
+		detail.Visible = true
+	case visibilityPattern.MatchString(value):
+		detail.HiddenReason = visibilityPattern.FindStringSubmatch(value)[1]
+	default:
+		return nil, detailError("visible", value, errors.New("not Yes or No (reason)"))
+	}
+
+	language, ok := rows["Language:"]
+	if !ok {
+		return nil, detailError("language", "", ErrMissing)
+	}
+	if err := parseDetailLanguage(detail, text(language)); err != nil {
+		return nil, err
+	}
+
+	favorited, ok := rows["Favorited:"]
+	if !ok {
+		return nil, detailError("favorited", "", ErrMissing)
+	}
+	switch value := text(favorited); {
+	case value == "Never":
+	case value == "Once":
+		detail.Favorites = 1
+	case favoritesPattern.MatchString(value):
+		detail.Favorites, _ = strconv.Atoi(strings.ReplaceAll(favoritesPattern.FindStringSubmatch(value)[1], ",", ""))
+	default:
+		return nil, detailError("favorited", value, errors.New("not a favorite count"))
+	}
+
+	ratingCount := findByID(doc, "rating_count")
+	if ratingCount == nil {
+		return nil, detailError("rating count", "", ErrMissing)
+	}
+	value := text(ratingCount)
+	if detail.RatingCount, err = strconv.Atoi(strings.ReplaceAll(value, ",", "")); err != nil {
+		return nil, detailError("rating count", value, err)
+	}
+
+	if newer := findByID(doc, "gnd"); newer != nil {
+		versions, err := parseNewerVersions(newer)
+		if err != nil {
+			return nil, err
+		}
+		detail.Newer = versions
+	}
+
+	comments, err := parseComments(doc)
+	if err != nil {
+		return nil, err
+	}
+	detail.Comments = comments
+
+	return detail, nil
+}
+
+func detailError(field, value string, err error) *ParseError {
+	return &ParseError{Page: "gallery detail", Field: field, Value: value, Err: err}
+}
+
+// findByID returns the first element below n with the given id
+func findByID(n *html.Node, id string) *html.Node {
+	found := findAll(n, func(node *html.Node) bool { return attr(node, "id") == id })
+	if len(found) == 0 {
+		return nil
+	}
+	return found[0]
+}
+
+// detailRows maps the labels of the detail table, e.g. "Parent:", to their
+// value cells
+func detailRows(table *html.Node) map[string]*html.Node {
+	rows := make(map[string]*html.Node)
+	for _, label := range findAll(table, func(n *html.Node) bool { return hasClass(n, "gdt1") }) {
+		for value := label.NextSibling; value != nil; value = value.NextSibling {
+			if value.Type == html.ElementNode && value.DataAtom == atom.Td {
+				rows[text(label)] = value
+				break
+			}
+		}
+	}
+	return rows
+}
+
+// linkedGallery returns the gallery of the first gallery link below n
+func linkedGallery(n *html.Node) (int, string, bool) {
+	for _, link := range findAll(n, func(node *html.Node) bool { return node.DataAtom == atom.A }) {
+		match := galleryLinkPattern.FindStringSubmatch(attr(link, "href"))
+		if match == nil {
+			continue
+		}
+		gid, err := strconv.Atoi(match[1])
+		if err != nil {
+			continue
+		}
+		return gid, match[2], true
+	}
+	return 0, "", false
+}
+
+// parseDetailLanguage reads a language cell such as "English TR", where the
+// TR and RW markers flag translations and rewrites
+func parseDetailLanguage(detail *GalleryDetail, value string) error {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return detailError("language", value, ErrMissing)
+	}
+	if fields[0] != "N/A" {
+		detail.Language = strings.ToLower(fields[0])
+	}
+	for _, marker := range fields[1:] {
+		switch marker {
+		case "TR":
+			detail.Translated = true
+		case "RW":
+			detail.Rewrite = true
+		default:
+			return detailError("language", value, fmt.Errorf("unknown marker %q", marker))
+		}
+	}
+	return nil
+}
+
+// parseNewerVersions reads the newer versions notice: one gallery link per
+// version, each followed by its ", added <time>" text
+func parseNewerVersions(notice *html.Node) ([]GalleryVersion, error) {
+	var versions []GalleryVersion
+	for _, link := range findAll(notice, func(n *html.Node) bool { return n.DataAtom == atom.A }) {
+		href := attr(link, "href")
+		match := galleryLinkPattern.FindStringSubmatch(href)
+		if match == nil {
+			return nil, detailError("newer version", href, errors.New("not a gallery link"))
+		}
+		gid, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, detailError("newer version", href, err)
+		}
+
+		version := GalleryVersion{Gid: gid, Token: match[2], Title: text(link)}
+		if link.NextSibling != nil && link.NextSibling.Type == html.TextNode {
+			if added := versionAddedPattern.FindStringSubmatch(link.NextSibling.Data); added != nil {
+				version.Posted = added[1]
+			}
+		}
+		versions = append(versions, version)
+	}
+	return versions, nil
+}
+
+// parseComments reads the comments of the #cdiv thread. Each comment is a
+// c1 block: the c3 header with the posted time and author, the score span,
+// the c6 body and an optional c8 edit notice.
+func parseComments(doc *html.Node) ([]Comment, error) {
+	thread := findByID(doc, "cdiv")
+	if thread == nil {
+		return nil, nil
+	}
+
+	var comments []Comment
+	for i, block := range findAll(thread, func(n *html.Node) bool { return hasClass(n, "c1") }) {
+		comment, err := parseComment(block)
+		if err != nil {
+			err.Entry = i + 1
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+	return comments, nil
+}
+
+func parseComment(block *html.Node) (Comment, *ParseError) {
+	var comment Comment
+	fail := func(field, value string, err error) (Comment, *ParseError) {
+		return Comment{}, &ParseError{Page: "gallery comments", Field: field, Value: value, Err: err}
+	}
+
+	body := firstByClass(block, "c6")
+	if body == nil {
+		return fail("body", "", ErrMissing)
+	}
+	rawID, ok := strings.CutPrefix(attr(body, "id"), "comment_")
+	id, err := strconv.Atoi(rawID)
+	if !ok || err != nil || id < 0 {
+		return fail("id", attr(body, "id"), errors.New("not a comment id"))
+	}
+	comment.ID = id
+	comment.Uploader = id == 0
+	comment.Body = multilineText(body)
+
+	header := firstByClass(block, "c3")
+	if header == nil {
+		return fail("posted", "", ErrMissing)
+	}
+	headerText := text(header)
+	match := commentPostedPattern.FindStringSubmatch(headerText)
+	if match == nil {
+		return fail("posted", headerText, ErrMissing)
+	}
+	if comment.Posted, err = time.Parse(commentTimeLayout, match[1]); err != nil {
+		return fail("posted", match[1], err)
+	}
+
+	// The author links to the uploader page; the other link contacts them
+	for _, link := range findAll(header, func(n *html.Node) bool { return n.DataAtom == atom.A }) {
+		if strings.Contains(attr(link, "href"), "/uploader/") {
+			comment.Author = text(link)
+			break
+		}
+	}
+	if comment.Author == "" {
+		_, author, _ := strings.Cut(headerText, "by:")
+		comment.Author = strings.TrimSpace(author)
+	}
+	if comment.Author == "" {
+		return fail("author", headerText, ErrMissing)
+	}
+
+	if !comment.Uploader {
+		score := findByID(block, fmt.Sprintf("comment_score_%d", id))
+		if score == nil {
+			return fail("score", "", ErrMissing)
+		}
+		value := text(score)
+		if comment.Score, err = strconv.Atoi(value); err != nil {
+			return fail("score", value, err)
+		}
+	}
+
+	if edited := firstByClass(block, "c8"); edited != nil {
+		editedText := text(edited)
+		match := commentEditedPattern.FindStringSubmatch(editedText)
+		if match == nil {
+			return fail("edited", editedText, errors.New("not an edit time"))
+		}
+		if comment.Edited, err = time.Parse(commentTimeLayout, match[1]); err != nil {
+			return fail("edited", match[1], err)
+		}
+	}
+
+	return comment, nil
+}
+
+// firstByClass returns the first element below n with the given class
+func firstByClass(n *html.Node, class string) *html.Node {
+	found := findAll(n, func(node *html.Node) bool { return hasClass(node, class) })
+	if len(found) == 0 {
+		return nil
+	}
+	return found[0]
+}
+
+// multilineText returns the text below n with a line per <br>, spaces
+// collapsed within lines. Links whose text is not their URL keep the URL
+// after the text, e.g. "source (https://example.com/)".
+func multilineText(n *html.Node) string {
+	var b strings.Builder
+	var visit func(*html.Node)
+	visit = func(node *html.Node) {
+		switch {
+		case node.Type == html.TextNode:
+			// Source newlines are whitespace; only <br> breaks a line
+			b.WriteString(strings.ReplaceAll(node.Data, "\n", " "))
+			return
+		case node.Type == html.ElementNode && node.DataAtom == atom.Br:
+			b.WriteByte('\n')
+			return
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			visit(child)
+		}
+		if node.Type == html.ElementNode && node.DataAtom == atom.A {
+			if href := attr(node, "href"); href != "" && text(node) != href {
+				fmt.Fprintf(&b, " (%s)", href)
+			}
+		}
+	}
+	visit(n)
+
+	lines := strings.Split(b.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.Join(strings.Fields(line), " ")
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
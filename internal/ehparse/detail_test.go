@@ -0,0 +1,243 @@
+package ehparse
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// detailPage builds a gallery page with the given detail table rows and
+// extra markup after the details
+func detailPage(rows, extra string) string {
+	return `<html><body><div class="gm"><div id="gleft"></div><div id="gd2"><h1 id="gn">Title</h1></div>
+<div id="gmid"><div id="gd3"><div id="gdc"><div class="cs ct2">Doujinshi</div></div><div id="gdn"><a href="https://e-hentai.org/uploader/someone">someone</a></div>
+<div id="gdd"><table>` + rows + `</table></div>
+<div id="gdr"><table><tr><td class="grt1">Rating:</td><td class="grt2"><div class="ir"></div></td><td class="grt3">(<span id="rating_count">456</span>)</td></tr>
+<tr><td id="rating_label" colspan="3">Average: 4.56</td></tr></table></div>
+<div id="gdf"><span id="favoritelink">Add to Favorites</span></div></div></div></div>` + extra + `</body></html>`
+}
+
+const detailRowsFixture = `<tr><td class="gdt1">Posted:</td><td class="gdt2">2026-03-30 12:00</td></tr>
+<tr><td class="gdt1">Parent:</td><td class="gdt2"><a href="https://e-hentai.org/g/3800000/0123456789/">3800000</a></td></tr>
+<tr><td class="gdt1">Visible:</td><td class="gdt2">No (Replaced)</td></tr>
+<tr><td class="gdt1">Language:</td><td class="gdt2">English &nbsp;<span class="halp" title="This gallery has been translated from the original language text.">TR</span></td></tr>
+<tr><td class="gdt1">File Size:</td><td class="gdt2">55.67 MiB</td></tr>
+<tr><td class="gdt1">Length:</td><td class="gdt2">24 pages</td></tr>
+<tr><td class="gdt1">Favorited:</td><td class="gdt2" id="favcount">123 times</td></tr>`
+
+func TestParseGalleryDetail(t *testing.T) {
+	rows := detailRowsFixture
+	newer := `<div id="gnd">There are newer versions of this gallery available:<br /><a href="https://e-hentai.org/g/3865624/abcdef0123/">Title [Decensored]</a>, added 2026-03-30 12:00<br /><a href="https://e-hentai.org/g/3865700/fedcba9876/">Title [Decensored] v2</a>, added 2026-04-02 08:30<br /></div>`
+
+	detail, err := ParseGalleryDetail([]byte(detailPage(rows, newer)))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if detail.ParentGid != 3800000 || detail.ParentToken != "0123456789" {
+		t.Fatalf("unexpected parent: %d %q", detail.ParentGid, detail.ParentToken)
+	}
+	if detail.Visible || detail.HiddenReason != "Replaced" {
+		t.Fatalf("unexpected visibility: %v %q", detail.Visible, detail.HiddenReason)
+	}
+	if detail.Language != "english" || !detail.Translated || detail.Rewrite {
+		t.Fatalf("unexpected language: %q translated=%v rewrite=%v", detail.Language, detail.Translated, detail.Rewrite)
+	}
+	if detail.Favorites != 123 || detail.RatingCount != 456 {
+		t.Fatalf("unexpected counts: favorites=%d ratings=%d", detail.Favorites, detail.RatingCount)
+	}
+
+	expected := []GalleryVersion{
+		{Gid: 3865624, Token: "abcdef0123", Title: "Title [Decensored]", Posted: "2026-03-30 12:00"},
+		{Gid: 3865700, Token: "fedcba9876", Title: "Title [Decensored] v2", Posted: "2026-04-02 08:30"},
+	}
+	if len(detail.Newer) != len(expected) {
+		t.Fatalf("expected %d newer versions, got %#v", len(expected), detail.Newer)
+	}
+	for i := range expected {
+		if detail.Newer[i] != expected[i] {
+			t.Fatalf("expected newer version %d to be %#v, got %#v", i, expected[i], detail.Newer[i])
+		}
+	}
+}
+
+func TestParseGalleryDetailValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		replace  [2]string
+		check    func(*GalleryDetail) bool
+		field    string
+		hasError bool
+	}{
+		{
+			name:    "no parent",
+			replace: [2]string{`<a href="https://e-hentai.org/g/3800000/0123456789/">3800000</a>`, "None"},
+			check:   func(d *GalleryDetail) bool { return d.ParentGid == 0 && d.ParentToken == "" },
+		},
+		{
+			name:    "visible",
+			replace: [2]string{"No (Replaced)", "Yes"},
+			check:   func(d *GalleryDetail) bool { return d.Visible && d.HiddenReason == "" },
+		},
+		{
+			name:    "rewrite without translation",
+			replace: [2]string{`English &nbsp;<span class="halp" title="This gallery has been translated from the original language text.">TR</span>`, `Japanese &nbsp;<span class="halp">RW</span>`},
+			check:   func(d *GalleryDetail) bool { return d.Language == "japanese" && !d.Translated && d.Rewrite },
+		},
+		{
+			name:    "no language",
+			replace: [2]string{`English &nbsp;<span class="halp" title="This gallery has been translated from the original language text.">TR</span>`, "N/A"},
+			check:   func(d *GalleryDetail) bool { return d.Language == "" && !d.Translated },
+		},
+		{
+			name:    "comma grouped favorites",
+			replace: [2]string{"123 times", "1,234 times"},
+			check:   func(d *GalleryDetail) bool { return d.Favorites == 1234 },
+		},
+		{
+			name:    "never favorited",
+			replace: [2]string{"123 times", "Never"},
+			check:   func(d *GalleryDetail) bool { return d.Favorites == 0 },
+		},
+		{
+			name:    "favorited once",
+			replace: [2]string{"123 times", "Once"},
+			check:   func(d *GalleryDetail) bool { return d.Favorites == 1 },
+		},
+		{
+			name:     "unexpected favorite count",
+			replace:  [2]string{"123 times", "many times"},
+			field:    "favorited",
+			hasError: true,
+		},
+		{
+			name:     "unexpected visibility",
+			replace:  [2]string{"No (Replaced)", "Maybe"},
+			field:    "visible",
+			hasError: true,
+		},
+		{
+			name:     "missing language row",
+			replace:  [2]string{`<td class="gdt1">Language:</td>`, `<td class="gdt1">Lang:</td>`},
+			field:    "language",
+			hasError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows := strings.Replace(detailRowsFixture, tt.replace[0], tt.replace[1], 1)
+
+			detail, err := ParseGalleryDetail([]byte(detailPage(rows, "")))
+			if tt.hasError {
+				var parseErr *ParseError
+				if !errors.As(err, &parseErr) || parseErr.Field != tt.field {
+					t.Fatalf("expected %s ParseError, got %v", tt.field, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if !tt.check(detail) {
+				t.Fatalf("unexpected detail: %#v", detail)
+			}
+		})
+	}
+}
+
+func TestParseGalleryDetailStates(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		unavailable bool
+		invalidKey  bool
+		err         error
+	}{
+		{name: "removed", body: `<div class="d"><p>This gallery has been removed or is unavailable.</p></div>`, unavailable: true},
+		{name: "copyright claim", body: `<div class="d"><p>This gallery is unavailable due to a copyright claim by Someone.</p></div>`, unavailable: true},
+		{name: "wrong token", body: `<div class="d"><p>Key missing, or incorrect key provided.</p></div>`, invalidKey: true},
+		{name: "content warning", body: `<div><h1>Content Warning</h1><a href="https://e-hentai.org/g/1/0123456789/?nw=session">View Gallery</a></div>`, err: ErrNoGalleryDetail},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detail, err := ParseGalleryDetail([]byte(tt.body))
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("expected %v, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if detail.Unavailable != tt.unavailable || detail.InvalidKey != tt.invalidKey {
+				t.Fatalf("unexpected detail: %#v", detail)
+			}
+		})
+	}
+}
+
+const commentsFixture = `<div id="cdiv" class="gm">
+<a name="c0"></a><div class="c1"><div class="c2"><div class="c3">Posted on 30 March 2026, 12:00 by: &nbsp; <a href="https://e-hentai.org/uploader/someone">someone</a>&nbsp; &nbsp; <a href="https://forums.e-hentai.org/index.php?showuser=1"><img class="ygm" src="https://ehgt.org/g/ygm.png" alt="PM" title="Contact Poster" /></a></div><div class="c4 nosel"><a name="ulcomment"></a>Uploader Comment</div><div class="c"></div></div><div class="c6" id="comment_0">Source: <a href="https://example.com/source">https://example.com/source</a><br />Replaces the <a href="https://e-hentai.org/g/3800000/0123456789/">old upload</a></div><div class="c8">Last edited on 30 March 2026, 13:05.</div></div>
+<a name="c123"></a><div class="c1"><div class="c2"><div class="c3">Posted on 31 March 2026, 08:15 by: &nbsp; <a href="https://e-hentai.org/uploader/reader">reader</a>&nbsp; &nbsp; <a href="https://forums.e-hentai.org/index.php?showuser=2"><img class="ygm" src="https://ehgt.org/g/ygm.png" alt="PM" title="Contact Poster" /></a></div><div class="c5 nosel">Score <span id="comment_score_123" style="opacity:1">+12</span></div><div class="c4 nosel">[<a id="comment_vote_up_123">Vote+</a>] &nbsp; [<a id="comment_vote_down_123">Vote-</a>]</div><div class="c"></div></div><div class="c6" id="comment_123">Thanks   for the
+upload!</div><div class="c7" id="cvotes_123" style="display:none">Base +3, <span>user +9</span></div></div>
+<a name="c124"></a><div class="c1"><div class="c2"><div class="c3">Posted on 1 April 2026, 23:59 by: &nbsp; <a href="https://e-hentai.org/uploader/critic">critic</a></div><div class="c5 nosel">Score <span id="comment_score_124">-3</span></div><div class="c"></div></div><div class="c6" id="comment_124">No.</div></div>
+<div id="chd"><p>There are 2 more comments below the viewing threshold - <a href="https://e-hentai.org/g/3865624/abcdef0123/?hc=1#comments">click to show all</a>.</p></div>
+</div>`
+
+func TestParseGalleryDetailComments(t *testing.T) {
+	detail, err := ParseGalleryDetail([]byte(detailPage(detailRowsFixture, commentsFixture)))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expected := []Comment{
+		{
+			ID:       0,
+			Author:   "someone",
+			Uploader: true,
+			Posted:   time.Date(2026, 3, 30, 12, 0, 0, 0, time.UTC),
+			Edited:   time.Date(2026, 3, 30, 13, 5, 0, 0, time.UTC),
+			Body:     "Source: https://example.com/source\nReplaces the old upload (https://e-hentai.org/g/3800000/0123456789/)",
+		},
+		{ID: 123, Author: "reader", Score: 12, Posted: time.Date(2026, 3, 31, 8, 15, 0, 0, time.UTC), Body: "Thanks for the upload!"},
+		{ID: 124, Author: "critic", Score: -3, Posted: time.Date(2026, 4, 1, 23, 59, 0, 0, time.UTC), Body: "No."},
+	}
+	if len(detail.Comments) != len(expected) {
+		t.Fatalf("expected %d comments, got %#v", len(expected), detail.Comments)
+	}
+	for i := range expected {
+		if detail.Comments[i] != expected[i] {
+			t.Fatalf("expected comment %d to be %#v, got %#v", i, expected[i], detail.Comments[i])
+		}
+	}
+}
+
+func TestParseGalleryDetailCommentErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		replace [2]string
+		field   string
+	}{
+		{name: "bad posted time", replace: [2]string{"31 March 2026, 08:15", "31 Marchember 2026, 08:15"}, field: "posted"},
+		{name: "bad score", replace: [2]string{">+12<", ">lots<"}, field: "score"},
+		{name: "missing score", replace: [2]string{`id="comment_score_124"`, `id="comment_score"`}, field: "score"},
+		{name: "bad comment id", replace: [2]string{`id="comment_124"`, `id="comment_x"`}, field: "id"},
+		{name: "bad edit time", replace: [2]string{"Last edited on 30 March 2026", "Last edited on March 30"}, field: "edited"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comments := strings.Replace(commentsFixture, tt.replace[0], tt.replace[1], 1)
+
+			_, err := ParseGalleryDetail([]byte(detailPage(detailRowsFixture, comments)))
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) || parseErr.Field != tt.field {
+				t.Fatalf("expected %s ParseError, got %v", tt.field, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+// Package ehparse parses E-Hentai pages: gallery lists in every display
+// mode, gallery detail pages, the torrent list, gallery torrent popups and
+// the error pages served instead of them. Pages are walked as HTML
+// documents, so attribute order, whitespace and nesting changes do not break
+// parsing, and entries that do not parse are reported with a ParseError
+// naming the failed field.
+package ehparse
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// PostedLayout is the format of posted times on list and torrent pages, in UTC
+const PostedLayout = "2006-01-02 15:04"
+
+// ErrMissing is wrapped by a ParseError for a field that is not on the page
+var ErrMissing = errors.New("missing")
+
+// ParseError reports a page or page entry that could not be parsed
+type ParseError struct {
+	Page  string // Page kind, e.g. "gallery list"
+	Entry int    // 1-based entry on the page, zero for the page itself
+	Field string // Field that failed, e.g. "posted"
+	Value string // Offending text, empty when the field is missing
+	Err   error
+}
+
+func (e *ParseError) Error() string {
+	var b strings.Builder
+	b.WriteString("parse ")
+	b.WriteString(e.Page)
+	if e.Entry > 0 {
+		fmt.Fprintf(&b, ": entry %d", e.Entry)
+	}
+	fmt.Fprintf(&b, ": field %s", e.Field)
+	if e.Value != "" {
+		value := e.Value
+		if len(value) > 80 {
+			value = value[:80] + "..."
+		}
+		fmt.Fprintf(&b, " %q", value)
+	}
+	if e.Err != nil {
+		b.WriteString(": ")
+		b.WriteString(e.Err.Error())
+	}
+	return b.String()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+func parseDocument(body []byte) (*html.Node, error) {
+	return html.Parse(bytes.NewReader(body))
+}
+
+// walk calls fn for n and its descendants in document order. Returning false
+// from fn skips the node's children.
+func walk(n *html.Node, fn func(*html.Node) bool) {
+	if !fn(n) {
+		return
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		walk(child, fn)
+	}
+}
+
+// findAll returns the elements below n, n included, matching fn
+func findAll(n *html.Node, fn func(*html.Node) bool) []*html.Node {
+	var found []*html.Node
+	walk(n, func(node *html.Node) bool {
+		if node.Type == html.ElementNode && fn(node) {
+			found = append(found, node)
+		}
+		return true
+	})
+	return found
+}
+
+// closest returns the nearest ancestor of n with tag a
+func closest(n *html.Node, a atom.Atom) *html.Node {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.Type == html.ElementNode && p.DataAtom == a {
+			return p
+		}
+	}
+	return nil
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func hasClass(n *html.Node, class string) bool {
+	for _, c := range strings.Fields(attr(n, "class")) {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// text returns the text below n with whitespace collapsed. strings.Fields
+// also splits on non-breaking spaces, so &nbsp; padding is dropped too.
+func text(n *html.Node) string {
+	var b strings.Builder
+	walk(n, func(node *html.Node) bool {
+		if node.Type == html.TextNode {
+			b.WriteString(node.Data)
+			b.WriteByte(' ')
+		}
+		return true
+	})
+	return strings.Join(strings.Fields(b.String()), " ")
+}
@@ -0,0 +1,124 @@
+package ehparse
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var temporaryBanPattern = regexp.MustCompile(`(?i)your ip address has been temporarily banned.*?ban expires in [^<\n]+`)
+
+// challengeMarkers identify Cloudflare, DDoS-Guard and captcha pages in a
+// page's lowercased text and attributes
+var challengeMarkers = []string{
+	"your ip address has been temporarily banned",
+	"ban expires in",
+	"attention required",
+	"just a moment",
+	"checking your browser before accessing",
+	"captcha",
+	"cf-browser-verification",
+	"cf_chl_opt",
+	"ddos-guard",
+	"access denied",
+}
+
+// BanMessage returns the temporary IP ban message in content, e.g. "Your IP
+// address has been temporarily banned ... (The ban expires in 4 minutes and
+// 58 seconds)". content is plain text such as an error message or page text.
+func BanMessage(content string) (string, bool) {
+	if content == "" {
+		return "", false
+	}
+
+	match := temporaryBanPattern.FindString(content)
+	if match == "" {
+		return "", false
+	}
+
+	return strings.TrimSpace(match), true
+}
+
+// ErrorPageReason reports whether body is an error page served instead of
+// the requested one: a blank page, a temporary IP ban or a bot challenge. The
+// reason is the ban message or the challenge marker found.
+func ErrorPageReason(body []byte) (string, bool) {
+	if strings.TrimSpace(string(body)) == "" {
+		return "received blank page", true
+	}
+
+	content := string(body)
+	searchable := content
+	if doc, err := parseDocument(body); err == nil {
+		content = pageText(doc)
+		searchable = content + "\n" + attributeText(doc)
+	}
+
+	if message, ok := BanMessage(content); ok {
+		return message, true
+	}
+
+	lower := strings.ToLower(searchable)
+	for _, marker := range challengeMarkers {
+		if strings.Contains(lower, marker) {
+			return marker, true
+		}
+	}
+
+	return "", false
+}
+
+// blockAtoms are the elements that start a new line in pageText
+var blockAtoms = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Br: true, atom.Tr: true, atom.Td: true,
+	atom.Th: true, atom.Li: true, atom.H1: true, atom.H2: true, atom.H3: true,
+	atom.Title: true, atom.Script: true, atom.Style: true, atom.Table: true,
+	atom.Form: true, atom.Body: true,
+}
+
+// pageText returns the text of doc, scripts included, with one line per block
+// element so that a message split by inline markup stays on one line
+func pageText(doc *html.Node) string {
+	var b strings.Builder
+	var visit func(n *html.Node)
+	visit = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(strings.Join(strings.Fields(n.Data), " "))
+			b.WriteByte(' ')
+			return
+		}
+		block := n.Type == html.ElementNode && blockAtoms[n.DataAtom]
+		if block {
+			b.WriteByte('\n')
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			visit(c)
+		}
+		if block {
+			b.WriteByte('\n')
+		}
+	}
+	visit(doc)
+
+	var lines []string
+	for _, line := range strings.Split(b.String(), "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// attributeText returns the attribute values of doc, one per line
+func attributeText(doc *html.Node) string {
+	var values []string
+	walk(doc, func(n *html.Node) bool {
+		for _, a := range n.Attr {
+			values = append(values, a.Val)
+		}
+		return true
+	})
+	return strings.Join(values, "\n")
+}
@@ -0,0 +1,63 @@
+package ehparse
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestErrorPageReason(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		wantOk bool
+		reason string
+	}{
+		{
+			name:   "temporary ban",
+			body:   "Your IP address has been temporarily banned for excessive pageloads. (The ban expires in 4 minutes and 58 seconds)",
+			wantOk: true,
+			reason: "ban expires in 4 minutes and 58 seconds",
+		},
+		{
+			name:   "temporary ban split by markup",
+			body:   "<html><body><p>Your IP address has been <b>temporarily banned</b> for excessive pageloads. (The ban expires in 1 hour and 2 minutes)</p></body></html>",
+			wantOk: true,
+			reason: "ban expires in 1 hour and 2 minutes",
+		},
+		{
+			name:   "blank page",
+			body:   "  \n\t ",
+			wantOk: true,
+			reason: "blank",
+		},
+		{
+			name:   "cloudflare challenge",
+			body:   `<html><head><title>Just a moment...</title></head><body><script>window._cf_chl_opt={cvId:'3'};</script></body></html>`,
+			wantOk: true,
+			reason: "just a moment",
+		},
+		{
+			name:   "challenge marker in attribute",
+			body:   `<html><body><div id="cf-browser-verification"></div></body></html>`,
+			wantOk: true,
+			reason: "cf-browser-verification",
+		},
+		{
+			name:   "gallery list",
+			body:   compactPage,
+			wantOk: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, ok := ErrorPageReason([]byte(tt.body))
+			if ok != tt.wantOk {
+				t.Fatalf("expected %v, got %v (%q)", tt.wantOk, ok, reason)
+			}
+			if !strings.Contains(reason, tt.reason) {
+				t.Fatalf("expected reason containing %q, got %q", tt.reason, reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package ehparse
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// ListMode is the display mode of a gallery list page
+type ListMode string
+
+const (
+	ModeUnknown   ListMode = ""
+	ModeMinimal   ListMode = "minimal" // Minimal and Minimal+
+	ModeCompact   ListMode = "compact"
+	ModeExtended  ListMode = "extended"
+	ModeThumbnail ListMode = "thumbnail"
+)
+
+// listModeClasses maps the class of the gallery list container to its mode
+var listModeClasses = map[string]ListMode{
+	"gltm": ModeMinimal,
+	"gltc": ModeCompact,
+	"glte": ModeExtended,
+	"gld":  ModeThumbnail,
+}
+
+// ErrNoGalleryList is returned for pages without a gallery list, typically
+// error pages
+var ErrNoGalleryList = errors.New("no gallery list on page")
+
+// GalleryList is a parsed gallery list page
+type GalleryList struct {
+	Mode  ListMode
+	Items []GalleryListItem // In page order, newest first
+}
+
+// GalleryListItem is one gallery of a list page
+type GalleryListItem struct {
+	Gid      int
+	Token    string
+	Posted   time.Time // UTC, minute precision
+	Expunged bool      // Posted time is struck through
+}
+
+var (
+	// galleryLinkPattern matches gallery URLs, e.g. https://e-hentai.org/g/123/0123456789/
+	galleryLinkPattern = regexp.MustCompile(`/g/(\d+)/([0-9a-f]{10})(?:/|$)`)
+	// galleryPopupPattern matches the favorites popup call on posted times
+	galleryPopupPattern = regexp.MustCompile(`gid=(\d+)&t=([0-9a-f]{10})`)
+)
+
+// ParseGalleryList parses a gallery list page in any display mode. Every
+// gallery is anchored on its posted time (id="posted_<gid>"), which all modes
+// show, and its token is taken from the gallery link or favorites popup.
+// A page with list markup but no galleries, like "No hits found", returns
+// no items; a page without list markup returns ErrNoGalleryList.
+func ParseGalleryList(body []byte) (*GalleryList, error) {
+	doc, err := parseDocument(body)
+	if err != nil {
+		return nil, &ParseError{Page: "gallery list", Field: "document", Err: err}
+	}
+
+	list := &GalleryList{Mode: detectListMode(doc)}
+
+	postedNodes := findAll(doc, func(n *html.Node) bool {
+		return strings.HasPrefix(attr(n, "id"), "posted_")
+	})
+
+	if list.Mode == ModeUnknown && len(postedNodes) == 0 && !hasListNavigation(doc) {
+		return nil, ErrNoGalleryList
+	}
+
+	tokens := galleryTokens(doc)
+
+	for i, node := range postedNodes {
+		entry := i + 1
+
+		rawGid := strings.TrimPrefix(attr(node, "id"), "posted_")
+		gid, err := strconv.Atoi(rawGid)
+		if err != nil || gid <= 0 {
+			return nil, &ParseError{Page: "gallery list", Entry: entry, Field: "gid", Value: rawGid, Err: errors.New("not a gallery id")}
+		}
+
+		token := tokens[gid]
+		if token == "" {
+			if match := galleryPopupPattern.FindStringSubmatch(attr(node, "onclick")); match != nil && match[1] == rawGid {
+				token = match[2]
+			}
+		}
+		if token == "" {
+			return nil, &ParseError{Page: "gallery list", Entry: entry, Field: "token", Err: fmt.Errorf("%w for gallery %d", ErrMissing, gid)}
+		}
+
+		rawPosted := text(node)
+		if rawPosted == "" {
+			return nil, &ParseError{Page: "gallery list", Entry: entry, Field: "posted", Err: fmt.Errorf("%w for gallery %d", ErrMissing, gid)}
+		}
+		posted, err := time.Parse(PostedLayout, rawPosted)
+		if err != nil {
+			return nil, &ParseError{Page: "gallery list", Entry: entry, Field: "posted", Value: rawPosted, Err: err}
+		}
+
+		list.Items = append(list.Items, GalleryListItem{
+			Gid:      gid,
+			Token:    token,
+			Posted:   posted.UTC(),
+			Expunged: len(findAll(node, func(n *html.Node) bool { return n.DataAtom == atom.S })) > 0,
+		})
+	}
+
+	return list, nil
+}
+
+// detectListMode returns the mode of the first gallery list container
+func detectListMode(doc *html.Node) ListMode {
+	mode := ModeUnknown
+	walk(doc, func(n *html.Node) bool {
+		if mode != ModeUnknown {
+			return false
+		}
+		if n.Type == html.ElementNode && hasClass(n, "itg") {
+			for class, m := range listModeClasses {
+				if hasClass(n, class) {
+					mode = m
+					return false
+				}
+			}
+		}
+		return true
+	})
+	return mode
+}
+
+// hasListNavigation reports whether the page has the list navigation, which
+// is also shown when a search has no hits
+func hasListNavigation(doc *html.Node) bool {
+	return len(findAll(doc, func(n *html.Node) bool {
+		return hasClass(n, "searchnav") || hasClass(n, "itg")
+	})) > 0
+}
+
+// galleryTokens maps gids to the tokens of the gallery links on the page
+func galleryTokens(doc *html.Node) map[int]string {
+	tokens := make(map[int]string)
+	for _, link := range findAll(doc, func(n *html.Node) bool { return n.DataAtom == atom.A }) {
+		match := galleryLinkPattern.FindStringSubmatch(attr(link, "href"))
+		if match == nil {
+			continue
+		}
+		gid, err := strconv.Atoi(match[1])
+		if err != nil {
+			continue
+		}
+		if _, ok := tokens[gid]; !ok {
+			tokens[gid] = match[2]
+		}
+	}
+	return tokens
+}
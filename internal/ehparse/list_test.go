@@ -0,0 +1,157 @@
+package ehparse
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+const (
+	minimalPage = `<html><body><div class="searchnav"><a id="dnext" href="https://e-hentai.org/?next=3865623">Next &gt;</a></div>
+<table class="itg gltm"><tr><th>Category</th><th>Published</th><th>Title</th></tr>
+<tr><td class="gl1m glcat"><div class="cn ct2">Doujinshi</div></td><td class="gl2m"><div class="glthumb" id="it3865624"></div><div onclick="popUp('https://e-hentai.org/gallerypopups.php?gid=3865624&amp;t=abcdef0123&amp;act=addfav',675,415)" style="border-color:#000" title="Favorites 1" id="posted_3865624">2026-03-30 12:00</div></td><td class="gl3m glname"><a href="https://e-hentai.org/g/3865624/abcdef0123/"><div class="glink">First</div></a></td><td class="gl5m glhide"><div><a href="https://e-hentai.org/uploader/someone">someone</a></div></td></tr>
+<tr><td class="gl1m glcat"><div class="cn ct3">Manga</div></td><td class="gl2m"><div onclick="popUp('https://e-hentai.org/gallerypopups.php?gid=3865623&amp;t=0123456789&amp;act=addfav',675,415)" id="posted_3865623"><s>2026-03-30 11:00</s></div></td><td class="gl3m glname"><a href="https://e-hentai.org/g/3865623/0123456789/"><div class="glink">Second</div></a></td></tr>
+</table></body></html>`
+
+	compactPage = `<html><body><div class="searchnav"></div>
+<table class="itg gltc"><tr><th>Category</th></tr>
+<tr><td class="gl1c glcat"><div class="cn ct2">Doujinshi</div></td>
+<td class="gl2c"><div class="glthumb" id="it3865624"></div><div>
+<div onclick="popUp('https://e-hentai.org/gallerypopups.php?gid=3865624&amp;t=abcdef0123&amp;act=addfav',675,415)" id="posted_3865624">
+  2026-03-30 12:00
+</div></div></td>
+<td class="gl3c glname"><a href="https://e-hentai.org/g/3865624/abcdef0123/"><div class="glink">First</div><div><div class="gt" title="language:english">english</div></div></a></td>
+<td class="gl4c glhide"><div><a href="https://e-hentai.org/uploader/someone">someone</a></div><div>20 pages</div></td></tr>
+</table><script type="text/javascript">var nexturl="https://e-hentai.org/?next=3865624";</script></body></html>`
+
+	extendedPage = `<html><body><div class="searchnav"></div>
+<table class="itg glte"><tr><td class="gl1e" style="width:250px"><div><a href="https://e-hentai.org/g/3865622/0123456789/"><img src="https://ehgt.org/t.jpg" alt="Third" /></a></div></td>
+<td class="gl2e"><div><div class="gl3e"><div class="cn ct3">Manga</div><div onclick="popUp('https://e-hentai.org/gallerypopups.php?gid=3865622&amp;t=0123456789&amp;act=addfav',675,415)" id="posted_3865622"><s>2026-03-30 10:00</s></div><div class="ir"></div><div><a href="https://e-hentai.org/uploader/someone">someone</a></div><div>24 pages</div></div>
+<a href="https://e-hentai.org/g/3865622/0123456789/"><div class="gl4e glname"><div class="glink">Third</div></div></a></div></td></tr>
+</table></body></html>`
+
+	thumbnailPage = `<html><body><div class="searchnav"></div>
+<div class="itg gld"><div class="gl1t"><a href="https://e-hentai.org/g/3865621/fedcba9876/"><div class="gl4t glname glink">Fourth</div></a><div class="gl3t"><a href="https://e-hentai.org/g/3865621/fedcba9876/"><img src="https://ehgt.org/t.jpg" /></a></div>
+<div class="gl5t"><div><div class="cs ct2">Doujinshi</div><div onclick="popUp('https://e-hentai.org/gallerypopups.php?gid=3865621&amp;t=fedcba9876&amp;act=addfav',675,415)" id="posted_3865621">2026-03-30 09:00</div></div><div><div class="ir"></div><div>20 pages</div></div></div></div></div></body></html>`
+)
+
+func TestParseGalleryListModes(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		mode  ListMode
+		items []GalleryListItem
+	}{
+		{
+			name: "minimal",
+			body: minimalPage,
+			mode: ModeMinimal,
+			items: []GalleryListItem{
+				{Gid: 3865624, Token: "abcdef0123", Posted: time.Date(2026, 3, 30, 12, 0, 0, 0, time.UTC)},
+				{Gid: 3865623, Token: "0123456789", Posted: time.Date(2026, 3, 30, 11, 0, 0, 0, time.UTC), Expunged: true},
+			},
+		},
+		{
+			name: "compact",
+			body: compactPage,
+			mode: ModeCompact,
+			items: []GalleryListItem{
+				{Gid: 3865624, Token: "abcdef0123", Posted: time.Date(2026, 3, 30, 12, 0, 0, 0, time.UTC)},
+			},
+		},
+		{
+			name: "extended",
+			body: extendedPage,
+			mode: ModeExtended,
+			items: []GalleryListItem{
+				{Gid: 3865622, Token: "0123456789", Posted: time.Date(2026, 3, 30, 10, 0, 0, 0, time.UTC), Expunged: true},
+			},
+		},
+		{
+			name: "thumbnail",
+			body: thumbnailPage,
+			mode: ModeThumbnail,
+			items: []GalleryListItem{
+				{Gid: 3865621, Token: "fedcba9876", Posted: time.Date(2026, 3, 30, 9, 0, 0, 0, time.UTC)},
+			},
+		},
+		{
+			name: "token from favorites popup",
+			body: `<table class="itg gltc"><tr><td><div onclick="popUp('https://e-hentai.org/gallerypopups.php?gid=42&amp;t=abcdef0123&amp;act=addfav',675,415)" id="posted_42">2026-03-30 12:00</div></td></tr></table>`,
+			mode: ModeCompact,
+			items: []GalleryListItem{
+				{Gid: 42, Token: "abcdef0123", Posted: time.Date(2026, 3, 30, 12, 0, 0, 0, time.UTC)},
+			},
+		},
+		{
+			name: "no hits",
+			body: `<html><body><div class="searchnav"></div><p class="ip">No hits found</p></body></html>`,
+			mode: ModeUnknown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := ParseGalleryList([]byte(tt.body))
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if list.Mode != tt.mode {
+				t.Fatalf("expected mode %q, got %q", tt.mode, list.Mode)
+			}
+			if len(list.Items) != len(tt.items) {
+				t.Fatalf("expected %d items, got %#v", len(tt.items), list.Items)
+			}
+			for i, want := range tt.items {
+				if got := list.Items[i]; got != want {
+					t.Fatalf("expected item %d to be %#v, got %#v", i, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestParseGalleryListErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		entry int
+		field string
+	}{
+		{
+			name:  "invalid posted",
+			body:  `<table class="itg gltc"><tr><td><div id="posted_42">yesterday</div><a href="https://e-hentai.org/g/42/abcdef0123/">x</a></td></tr></table>`,
+			entry: 1,
+			field: "posted",
+		},
+		{
+			name:  "missing token",
+			body:  `<table class="itg gltc"><tr><td><div id="posted_41">2026-03-30 12:00</div><a href="https://e-hentai.org/g/41/abcdef0123/">x</a></td></tr><tr><td><div id="posted_42">2026-03-30 11:00</div></td></tr></table>`,
+			entry: 2,
+			field: "token",
+		},
+		{
+			name:  "invalid gid",
+			body:  `<table class="itg gltc"><tr><td><div id="posted_x">2026-03-30 12:00</div></td></tr></table>`,
+			entry: 1,
+			field: "gid",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseGalleryList([]byte(tt.body))
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("expected ParseError, got %v", err)
+			}
+			if parseErr.Entry != tt.entry || parseErr.Field != tt.field {
+				t.Fatalf("expected entry %d field %s, got %v", tt.entry, tt.field, err)
+			}
+		})
+	}
+
+	if _, err := ParseGalleryList([]byte("Your IP address has been temporarily banned.")); !errors.Is(err, ErrNoGalleryList) {
+		t.Fatalf("expected ErrNoGalleryList, got %v", err)
+	}
+}
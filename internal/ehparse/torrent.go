@@ -0,0 +1,267 @@
+package ehparse
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// ErrNoTorrentList is returned for torrent list pages without torrent links
+var ErrNoTorrentList = errors.New("no torrent list on page")
+
+// TorrentListItem is one torrent of the /torrents.php list
+type TorrentListItem struct {
+	Gid   int
+	Token string
+	Gtid  int
+}
+
+// ParseTorrentList parses a /torrents.php page from its gallerytorrents.php
+// links, in page order. A page without any returns ErrNoTorrentList.
+func ParseTorrentList(body []byte) ([]TorrentListItem, error) {
+	doc, err := parseDocument(body)
+	if err != nil {
+		return nil, &ParseError{Page: "torrent list", Field: "document", Err: err}
+	}
+
+	var items []TorrentListItem
+	seen := make(map[int]struct{})
+	found := false
+
+	for _, link := range findAll(doc, func(n *html.Node) bool { return n.DataAtom == atom.A }) {
+		href := attr(link, "href")
+		if !strings.Contains(href, "gallerytorrents.php") {
+			continue
+		}
+		found = true
+
+		u, err := url.Parse(href)
+		if err != nil {
+			return nil, &ParseError{Page: "torrent list", Entry: len(items) + 1, Field: "link", Value: href, Err: err}
+		}
+		query := u.Query()
+		if query.Get("gtid") == "" {
+			continue
+		}
+
+		entry := len(items) + 1
+		gid, err := strconv.Atoi(query.Get("gid"))
+		if err != nil || gid <= 0 {
+			return nil, &ParseError{Page: "torrent list", Entry: entry, Field: "gid", Value: query.Get("gid"), Err: errors.New("not a gallery id")}
+		}
+		token := query.Get("t")
+		if !isToken(token) {
+			return nil, &ParseError{Page: "torrent list", Entry: entry, Field: "token", Value: token, Err: errors.New("not a gallery token")}
+		}
+		gtid, err := strconv.Atoi(query.Get("gtid"))
+		if err != nil || gtid <= 0 {
+			return nil, &ParseError{Page: "torrent list", Entry: entry, Field: "gtid", Value: query.Get("gtid"), Err: errors.New("not a torrent id")}
+		}
+
+		if _, ok := seen[gtid]; ok {
+			continue
+		}
+		seen[gtid] = struct{}{}
+		items = append(items, TorrentListItem{Gid: gid, Token: token, Gtid: gtid})
+	}
+
+	if !found {
+		return nil, ErrNoTorrentList
+	}
+	return items, nil
+}
+
+var tokenPattern = regexp.MustCompile(`^[0-9a-f]{10}$`)
+
+func isToken(s string) bool {
+	return tokenPattern.MatchString(s)
+}
+
+// GalleryTorrents is a parsed gallerytorrents.php popup
+type GalleryTorrents struct {
+	Unavailable bool // "This gallery is currently unavailable"
+	NotFound    bool // "Gallery not found", e.g. not indexed yet
+	// RootGid is the gallery of the tracker announce URL, which replaced
+	// galleries share with the first version; zero without torrents
+	RootGid  int
+	Torrents []Torrent
+}
+
+// Torrent is one torrent of a gallery
+type Torrent struct {
+	ID       int
+	Posted   string // PostedLayout
+	Size     string // e.g. "120.5 MiB"
+	Uploader string
+	Hash     string // Empty for expunged torrents without a file link
+	Name     string
+	Expunged bool
+}
+
+var (
+	announcePattern    = regexp.MustCompile(`/(\d+)/announce`)
+	torrentFilePattern = regexp.MustCompile(`([0-9a-f]{40})\.torrent`)
+)
+
+// torrentLabels are the labelled cells of a torrent table
+var torrentLabels = []string{"Posted:", "Size:", "Uploader:", "Seeds:", "Peers:", "Downloads:"}
+
+// ParseGalleryTorrents parses the torrent popup of a gallery. Each torrent
+// is the form holding its gtid input; its cells are found by their labels,
+// so the order and markup of the cells do not matter.
+func ParseGalleryTorrents(body []byte) (*GalleryTorrents, error) {
+	doc, err := parseDocument(body)
+	if err != nil {
+		return nil, &ParseError{Page: "gallery torrents", Field: "document", Err: err}
+	}
+
+	page := &GalleryTorrents{}
+	content := text(doc)
+	if strings.Contains(content, "This gallery is currently unavailable") {
+		page.Unavailable = true
+		return page, nil
+	}
+	if strings.Contains(content, "Gallery not found") {
+		page.NotFound = true
+		return page, nil
+	}
+
+	page.RootGid = findRootGid(doc)
+
+	for i, input := range findAll(doc, func(n *html.Node) bool {
+		return n.DataAtom == atom.Input && attr(n, "name") == "gtid"
+	}) {
+		torrent, err := parseTorrentForm(input)
+		if err != nil {
+			err.Entry = i + 1
+			return nil, err
+		}
+		page.Torrents = append(page.Torrents, torrent)
+	}
+
+	return page, nil
+}
+
+// findRootGid returns the gid of the first announce URL in the page's text
+// or attributes
+func findRootGid(doc *html.Node) int {
+	rootGid := 0
+	walk(doc, func(n *html.Node) bool {
+		if rootGid != 0 {
+			return false
+		}
+
+		candidates := []string{}
+		if n.Type == html.TextNode {
+			candidates = append(candidates, n.Data)
+		}
+		for _, a := range n.Attr {
+			candidates = append(candidates, a.Val)
+		}
+		for _, candidate := range candidates {
+			if match := announcePattern.FindStringSubmatch(candidate); match != nil {
+				if gid, err := strconv.Atoi(match[1]); err == nil {
+					rootGid = gid
+					return false
+				}
+			}
+		}
+		return true
+	})
+	return rootGid
+}
+
+// parseTorrentForm parses the torrent whose gtid input is input. The
+// returned error has no entry set.
+func parseTorrentForm(input *html.Node) (Torrent, *ParseError) {
+	fail := func(field, value string, err error) (Torrent, *ParseError) {
+		return Torrent{}, &ParseError{Page: "gallery torrents", Field: field, Value: value, Err: err}
+	}
+
+	rawID := attr(input, "value")
+	id, err := strconv.Atoi(rawID)
+	if err != nil || id <= 0 {
+		return fail("gtid", rawID, errors.New("not a torrent id"))
+	}
+
+	form := closest(input, atom.Form)
+	if form == nil {
+		return fail("form", "", fmt.Errorf("%w around torrent %d", ErrMissing, id))
+	}
+
+	torrent := Torrent{ID: id}
+	var nameCell string
+	for _, cell := range findAll(form, func(n *html.Node) bool { return n.DataAtom == atom.Td }) {
+		content := text(cell)
+		label, value := splitLabel(content)
+		switch label {
+		case "Posted:":
+			torrent.Posted = value
+		case "Size:":
+			torrent.Size = value
+		case "Uploader:":
+			torrent.Uploader = value
+		case "":
+			if content != "" {
+				nameCell = content
+			}
+		}
+	}
+
+	for _, link := range findAll(form, func(n *html.Node) bool { return n.DataAtom == atom.A }) {
+		if match := torrentFilePattern.FindStringSubmatch(attr(link, "href")); match != nil {
+			torrent.Hash = match[1]
+			torrent.Name = text(link)
+			break
+		}
+	}
+
+	for _, button := range findAll(form, func(n *html.Node) bool { return n.DataAtom == atom.Input }) {
+		if strings.EqualFold(attr(button, "value"), "Expunged") {
+			torrent.Expunged = true
+		}
+	}
+
+	if torrent.Hash == "" {
+		if !torrent.Expunged {
+			return fail("hash", "", fmt.Errorf("%w for torrent %d", ErrMissing, id))
+		}
+		// Expunged torrents show their name without a file link
+		torrent.Name = nameCell
+	}
+
+	if torrent.Posted == "" {
+		return fail("posted", "", fmt.Errorf("%w for torrent %d", ErrMissing, id))
+	}
+	if _, err := time.Parse(PostedLayout, torrent.Posted); err != nil {
+		return fail("posted", torrent.Posted, err)
+	}
+	if torrent.Size == "" {
+		return fail("size", "", fmt.Errorf("%w for torrent %d", ErrMissing, id))
+	}
+	if torrent.Uploader == "" {
+		return fail("uploader", "", fmt.Errorf("%w for torrent %d", ErrMissing, id))
+	}
+	if torrent.Name == "" {
+		return fail("name", "", fmt.Errorf("%w for torrent %d", ErrMissing, id))
+	}
+
+	return torrent, nil
+}
+
+// splitLabel splits a cell like "Size: 120.5 MiB" into its label and value
+func splitLabel(content string) (string, string) {
+	for _, label := range torrentLabels {
+		if strings.HasPrefix(content, label) {
+			return label, strings.TrimSpace(strings.TrimPrefix(content, label))
+		}
+	}
+	return "", content
+}
@@ -0,0 +1,133 @@
+package ehparse
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseTorrentList(t *testing.T) {
+	body := `<html><body><table class="itg">
+<tr><td>2026-03-30 12:00</td><td><a href="https://e-hentai.org/gallerytorrents.php?gid=3865624&amp;t=abcdef0123&amp;gtid=900002" onclick="return popUp(this.href, 610, 590)">Second.zip</a></td><td><a href="https://e-hentai.org/g/3865624/abcdef0123/">3865624</a></td></tr>
+<tr><td>2026-03-30 11:00</td><td><a href="https://e-hentai.org/gallerytorrents.php?gid=3865623&t=0123456789&gtid=900001">First.zip</a></td></tr>
+<tr><td><a href="https://e-hentai.org/gallerytorrents.php?gid=3865623&amp;t=0123456789&amp;gtid=900001">First.zip</a></td></tr>
+</table></body></html>`
+
+	items, err := ParseTorrentList([]byte(body))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expected := []TorrentListItem{
+		{Gid: 3865624, Token: "abcdef0123", Gtid: 900002},
+		{Gid: 3865623, Token: "0123456789", Gtid: 900001},
+	}
+	if len(items) != len(expected) {
+		t.Fatalf("expected %d items, got %#v", len(expected), items)
+	}
+	for i := range expected {
+		if items[i] != expected[i] {
+			t.Fatalf("expected item %d to be %#v, got %#v", i, expected[i], items[i])
+		}
+	}
+
+	if _, err := ParseTorrentList([]byte(`<html><body>Just a moment...</body></html>`)); !errors.Is(err, ErrNoTorrentList) {
+		t.Fatalf("expected ErrNoTorrentList, got %v", err)
+	}
+
+	_, err = ParseTorrentList([]byte(`<a href="/gallerytorrents.php?gid=1&amp;t=nottoken&amp;gtid=2">x</a>`))
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Field != "token" || parseErr.Entry != 1 {
+		t.Fatalf("expected token ParseError for entry 1, got %v", err)
+	}
+}
+
+// torrentForm builds the torrent form of a gallerytorrents.php page, with the
+// cells in the layout the site uses
+func torrentForm(gtid, uploaderCell, nameCell string) string {
+	return `<form method="post" action="https://e-hentai.org/gallerytorrents.php?gid=3865624&amp;t=abcdef0123">
+<div><table style="width:99%">
+<tr>
+<td style="width:190px"><span style="color:#5C0D12">Posted:</span> <span>2026-03-30 12:00</span></td>
+<td style="width:150px"><span style="color:#5C0D12">Size:</span> 120.5 MiB</td>
+<td style="width:80px"><span style="color:#5C0D12">Seeds:</span> 4</td>
+<td rowspan="3"><input type="hidden" name="gtid" value="` + gtid + `" /><input type="submit" name="torrent_info" value="Info" /></td>
+</tr>
+<tr>` + uploaderCell + `</tr>
+<tr>` + nameCell + `</tr>
+</table></div>
+</form>`
+}
+
+func TestParseGalleryTorrents(t *testing.T) {
+	body := `<html><body><div id="torrentinfo"><div>` +
+		torrentForm("900002", `<td colspan="5"><span>Uploader:</span> Some Uploader</td>`,
+			`<td colspan="5"><a href="https://ehtracker.org/get/3800000/`+strings.Repeat("a", 40)+`.torrent?p=x" onclick="return false">[Circle] Title &amp; More.zip</a></td>`) +
+		torrentForm("900001", `<td colspan="5"><span>Uploader:</span> someone</td>`,
+			`<td colspan="5"><input type="submit" value="Expunged" disabled="disabled" /> &nbsp; Old Title.zip</td>`) +
+		`</div><p>Personal tracker: https://ehtracker.org/3800000/announce</p></div></body></html>`
+
+	page, err := ParseGalleryTorrents([]byte(body))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if page.RootGid != 3800000 {
+		t.Fatalf("expected root gid 3800000, got %d", page.RootGid)
+	}
+
+	expected := []Torrent{
+		{ID: 900002, Posted: "2026-03-30 12:00", Size: "120.5 MiB", Uploader: "Some Uploader", Hash: strings.Repeat("a", 40), Name: "[Circle] Title & More.zip"},
+		{ID: 900001, Posted: "2026-03-30 12:00", Size: "120.5 MiB", Uploader: "someone", Name: "Old Title.zip", Expunged: true},
+	}
+	if len(page.Torrents) != len(expected) {
+		t.Fatalf("expected %d torrents, got %#v", len(expected), page.Torrents)
+	}
+	for i := range expected {
+		if page.Torrents[i] != expected[i] {
+			t.Fatalf("expected torrent %d to be %#v, got %#v", i, expected[i], page.Torrents[i])
+		}
+	}
+}
+
+func TestParseGalleryTorrentsStates(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		unavailable bool
+		notFound    bool
+		field       string
+	}{
+		{name: "unavailable", body: `<p>This gallery is currently unavailable.</p>`, unavailable: true},
+		{name: "not found", body: `<p>Gallery not found. If you just added this gallery, please wait.</p>`, notFound: true},
+		{name: "no torrents", body: `<p>There are no torrents for this gallery.</p>`},
+		{
+			name:  "missing hash",
+			body:  torrentForm("900003", `<td colspan="5"><span>Uploader:</span> someone</td>`, `<td colspan="5"><a href="https://ehtracker.org/get/1/broken">Name.zip</a></td>`),
+			field: "hash",
+		},
+		{
+			name:  "missing uploader",
+			body:  torrentForm("900003", `<td colspan="5">someone</td>`, `<td colspan="5"><a href="https://ehtracker.org/get/1/`+strings.Repeat("b", 40)+`.torrent">Name.zip</a></td>`),
+			field: "uploader",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := ParseGalleryTorrents([]byte(tt.body))
+			if tt.field != "" {
+				var parseErr *ParseError
+				if !errors.As(err, &parseErr) || parseErr.Field != tt.field || parseErr.Entry != 1 {
+					t.Fatalf("expected %s ParseError for entry 1, got %v", tt.field, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if page.Unavailable != tt.unavailable || page.NotFound != tt.notFound || len(page.Torrents) != 0 {
+				t.Fatalf("unexpected page: %#v", page)
+			}
+		})
+	}
+}
@@ -0,0 +1,342 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/slinet/ehdb/internal/config"
+	"github.com/slinet/ehdb/internal/crawler"
+	"github.com/slinet/ehdb/internal/jobrun"
+	"github.com/slinet/ehdb/internal/jobs"
+	"github.com/slinet/ehdb/internal/scheduler"
+	"github.com/slinet/ehdb/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// maxFetchGalleries is the largest number of galleries a single fetch job accepts
+const maxFetchGalleries = 10000
+
+// maxJobRunLimit is the largest page of job run history returned at once
+const maxJobRunLimit = 200
+
+// Admin job kinds
+const (
+	JobKindFetch        = "fetch"
+	JobKindResync       = "resync"
+	JobKindBackfill     = "backfill"
+	JobKindMarkReplaced = "mark-replaced"
+)
+
+// AdminHandler starts, lists and cancels crawl jobs and shows the scheduler
+type AdminHandler struct {
+	logger    *zap.Logger
+	jobs      *jobs.Manager
+	scheduler *scheduler.Scheduler // nil when the scheduler is not enabled
+}
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(logger *zap.Logger, manager *jobs.Manager, sched *scheduler.Scheduler) *AdminHandler {
+	return &AdminHandler{
+		logger:    logger,
+		jobs:      manager,
+		scheduler: sched,
+	}
+}
+
+// FetchJobRequest is the body of POST /admin/jobs/fetch
+type FetchJobRequest struct {
+	Galleries []string `json:"galleries"` // "gid/token" pairs, same formats as ehdb-sync fetch
+}
+
+// ResyncJobRequest is the body of POST /admin/jobs/resync
+type ResyncJobRequest struct {
+	Hours int `json:"hours"` // Resync galleries posted in the last N hours (default: 24)
+}
+
+// BackfillJobRequest is the body of POST /admin/jobs/backfill. Either Offset
+// or Start (with an optional End, default now) selects the window.
+type BackfillJobRequest struct {
+	Host   string     `json:"host,omitempty"` // e-hentai.org or exhentai.org (default: config)
+	Start  *time.Time `json:"start,omitempty"`
+	End    *time.Time `json:"end,omitempty"`
+	Offset int        `json:"offset,omitempty"` // Window of the last N hours
+}
+
+// CreateFetchJob handles POST /admin/jobs/fetch
+func (h *AdminHandler) CreateFetchJob(c *gin.Context) {
+	var req FetchJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, utils.GetResponse(nil, 400, "invalid request body", nil))
+		return
+	}
+	if len(req.Galleries) == 0 {
+		c.JSON(400, utils.GetResponse(nil, 400, "galleries is required", nil))
+		return
+	}
+	if len(req.Galleries) > maxFetchGalleries {
+		c.JSON(400, utils.GetResponse(nil, 400, fmt.Sprintf("at most %d galleries per job", maxFetchGalleries), nil))
+		return
+	}
+
+	crawlerCfg, ok := h.crawlerConfig(c)
+	if !ok {
+		return
+	}
+
+	h.submit(c, JobKindFetch, req, func(ctx context.Context, logger *zap.Logger) error {
+		return crawler.NewFetcher(&crawlerCfg, logger).Fetch(ctx, req.Galleries)
+	})
+}
+
+// CreateResyncJob handles POST /admin/jobs/resync
+func (h *AdminHandler) CreateResyncJob(c *gin.Context) {
+	req := ResyncJobRequest{Hours: 24}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, utils.GetResponse(nil, 400, "invalid request body", nil))
+			return
+		}
+	}
+	if req.Hours <= 0 {
+		c.JSON(400, utils.GetResponse(nil, 400, "hours must be greater than 0", nil))
+		return
+	}
+
+	crawlerCfg, ok := h.crawlerConfig(c)
+	if !ok {
+		return
+	}
+
+	h.submit(c, JobKindResync, req, func(ctx context.Context, logger *zap.Logger) error {
+		return crawler.NewResyncer(&crawlerCfg, logger).Resync(ctx, req.Hours)
+	})
+}
+
+// CreateBackfillJob handles POST /admin/jobs/backfill
+func (h *AdminHandler) CreateBackfillJob(c *gin.Context) {
+	var req BackfillJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, utils.GetResponse(nil, 400, "invalid request body", nil))
+		return
+	}
+
+	start, end, err := resolveBackfillJobWindow(req, time.Now().UTC())
+	if err != nil {
+		c.JSON(400, utils.GetResponse(nil, 400, err.Error(), nil))
+		return
+	}
+	if req.Host != "" && req.Host != "e-hentai.org" && req.Host != "exhentai.org" {
+		c.JSON(400, utils.GetResponse(nil, 400, "host must be e-hentai.org or exhentai.org", nil))
+		return
+	}
+
+	crawlerCfg, ok := h.crawlerConfig(c)
+	if !ok {
+		return
+	}
+	if req.Host != "" {
+		crawlerCfg.Host = req.Host
+	}
+	crawlerCfg.BackfillStart = start.Unix()
+	crawlerCfg.BackfillEnd = end.Unix()
+
+	// Record the resolved window so the job shows what it actually covers
+	req.Start, req.End, req.Offset = &start, &end, 0
+
+	h.submit(c, JobKindBackfill, req, func(ctx context.Context, logger *zap.Logger) error {
+		galleryCrawler, err := crawler.NewGalleryCrawler(&crawlerCfg, logger)
+		if err != nil {
+			return fmt.Errorf("create gallery crawler: %w", err)
+		}
+		return galleryCrawler.Backfill(ctx)
+	})
+}
+
+// CreateMarkReplacedJob handles POST /admin/jobs/mark-replaced
+func (h *AdminHandler) CreateMarkReplacedJob(c *gin.Context) {
+	h.submit(c, JobKindMarkReplaced, nil, func(ctx context.Context, logger *zap.Logger) error {
+		return crawler.NewReplacedMarker(logger).MarkReplaced(ctx)
+	})
+}
+
+// ListJobs handles GET /admin/jobs
+// Returns recorded scheduler, CLI and admin runs from job run history, newest
+// first. Query: kind, status, source, since (RFC3339), before (run ID), limit.
+func (h *AdminHandler) ListJobs(c *gin.Context) {
+	filter := jobrun.ListFilter{
+		Kind:   c.Query("kind"),
+		Status: c.Query("status"),
+		Source: c.Query("source"),
+	}
+
+	if since := c.Query("since"); since != "" {
+		sinceTime, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			c.JSON(400, utils.GetResponse(nil, 400, "invalid since", nil))
+			return
+		}
+		filter.Since = sinceTime
+	}
+
+	if before := c.Query("before"); before != "" {
+		beforeID, err := strconv.ParseInt(before, 10, 64)
+		if err != nil || beforeID <= 0 {
+			c.JSON(400, utils.GetResponse(nil, 400, "invalid before", nil))
+			return
+		}
+		filter.Before = beforeID
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 {
+		c.JSON(400, utils.GetResponse(nil, 400, "invalid limit", nil))
+		return
+	}
+	if limit > maxJobRunLimit {
+		limit = maxJobRunLimit
+	}
+	filter.Limit = limit
+
+	runs, err := jobrun.List(c.Request.Context(), h.logger, filter)
+	if err != nil {
+		h.logger.Error("failed to list job runs", zap.Error(err))
+		c.JSON(500, utils.GetResponse(nil, 500, "internal error", nil))
+		return
+	}
+	total := int64(len(runs))
+	c.JSON(200, utils.GetResponse(runs, 200, "success", &total))
+}
+
+// ListQueuedJobs handles GET /admin/jobs/queue
+// Returns the admin jobs held in memory (queued, running and recently finished)
+func (h *AdminHandler) ListQueuedJobs(c *gin.Context) {
+	list := h.jobs.List()
+	total := int64(len(list))
+	c.JSON(200, utils.GetResponse(list, 200, "success", &total))
+}
+
+// GetJob handles GET /admin/jobs/:id
+func (h *AdminHandler) GetJob(c *gin.Context) {
+	job, err := h.jobs.Get(c.Param("id"))
+	if err != nil {
+		h.jobError(c, err)
+		return
+	}
+	c.JSON(200, utils.GetResponse(job, 200, "success", nil))
+}
+
+// GetJobLogs handles GET /admin/jobs/:id/logs
+// Returns log entries with seq greater than ?since= (default 0)
+func (h *AdminHandler) GetJobLogs(c *gin.Context) {
+	since, err := strconv.ParseInt(c.DefaultQuery("since", "0"), 10, 64)
+	if err != nil || since < 0 {
+		c.JSON(400, utils.GetResponse(nil, 400, "invalid since", nil))
+		return
+	}
+
+	logs, err := h.jobs.Logs(c.Param("id"), since)
+	if err != nil {
+		h.jobError(c, err)
+		return
+	}
+	total := int64(len(logs))
+	c.JSON(200, utils.GetResponse(logs, 200, "success", &total))
+}
+
+// CancelJob handles POST /admin/jobs/:id/cancel
+func (h *AdminHandler) CancelJob(c *gin.Context) {
+	job, err := h.jobs.Cancel(c.Param("id"))
+	if err != nil {
+		h.jobError(c, err)
+		return
+	}
+	c.JSON(200, utils.GetResponse(job, 200, "success", nil))
+}
+
+// GetSchedulerStats handles GET /admin/scheduler
+// Returns each scheduled job's policy, priority and run, skip, delay and yield counts
+func (h *AdminHandler) GetSchedulerStats(c *gin.Context) {
+	if h.scheduler == nil {
+		c.JSON(404, utils.GetResponse(nil, 404, "scheduler is not enabled", nil))
+		return
+	}
+	stats := h.scheduler.Stats()
+	total := int64(len(stats))
+	c.JSON(200, utils.GetResponse(stats, 200, "success", &total))
+}
+
+// submit queues a job, recorded in job run history when it runs, and writes its snapshot
+func (h *AdminHandler) submit(c *gin.Context, kind string, params interface{}, run jobs.RunFunc) {
+	tracked := func(ctx context.Context, logger *zap.Logger) error {
+		return jobrun.Track(ctx, logger, kind, jobrun.SourceAdmin, params, func(ctx context.Context) error {
+			return run(ctx, logger)
+		})
+	}
+
+	job, err := h.jobs.Submit(kind, params, tracked)
+	if err != nil {
+		h.jobError(c, err)
+		return
+	}
+	c.JSON(200, utils.GetResponse(job, 200, "success", nil))
+}
+
+// crawlerConfig returns a copy of the crawler config for one job
+func (h *AdminHandler) crawlerConfig(c *gin.Context) (config.CrawlerConfig, bool) {
+	cfg := config.Get()
+	if cfg == nil {
+		c.JSON(500, utils.GetResponse(nil, 500, "config is not loaded", nil))
+		return config.CrawlerConfig{}, false
+	}
+	return cfg.Crawler, true
+}
+
+// jobError maps job manager errors to responses
+func (h *AdminHandler) jobError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		c.JSON(404, utils.GetResponse(nil, 404, "job not found", nil))
+	case errors.Is(err, jobs.ErrFinished):
+		c.JSON(409, utils.GetResponse(nil, 409, "job already finished", nil))
+	case errors.Is(err, jobs.ErrQueueFull), errors.Is(err, jobs.ErrClosed):
+		c.JSON(503, utils.GetResponse(nil, 503, err.Error(), nil))
+	default:
+		h.logger.Error("job request failed", zap.Error(err))
+		c.JSON(500, utils.GetResponse(nil, 500, "internal error", nil))
+	}
+}
+
+// resolveBackfillJobWindow applies the same rules as ehdb-sync backfill's
+// -offset/-start/-end flags to a request body
+func resolveBackfillJobWindow(req BackfillJobRequest, now time.Time) (time.Time, time.Time, error) {
+	if req.Offset < 0 {
+		return time.Time{}, time.Time{}, fmt.Errorf("offset must be greater than 0")
+	}
+	if req.Offset > 0 && (req.Start != nil || req.End != nil) {
+		return time.Time{}, time.Time{}, fmt.Errorf("offset cannot be used together with start or end")
+	}
+	if req.Offset == 0 && req.Start == nil {
+		if req.End != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("end cannot be used without start")
+		}
+		return time.Time{}, time.Time{}, fmt.Errorf("either offset or start must be provided")
+	}
+
+	if req.Offset > 0 {
+		return now.Add(-time.Duration(req.Offset) * time.Hour), now, nil
+	}
+
+	start := req.Start.UTC()
+	end := now
+	if req.End != nil {
+		end = req.End.UTC()
+	}
+	if !start.Before(end) {
+		return time.Time{}, time.Time{}, fmt.Errorf("start must be before end")
+	}
+	return start, end, nil
+}
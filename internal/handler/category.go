@@ -41,6 +41,11 @@ func (h *CategoryHandler) GetByCategory(c *gin.Context) {
 		categoryParam = c.Query("category")
 	}
 
+	lang, ok := languageParam(c, c.Query("lang"))
+	if !ok {
+		return
+	}
+
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "25"))
 	cursor := c.Query("cursor") // Cursor format: "timestamp,gid" (composite cursor to handle duplicate timestamps)
@@ -117,41 +122,43 @@ func (h *CategoryHandler) GetByCategory(c *gin.Context) {
 		if useCursor {
 			// Cursor-based pagination: composite condition to handle duplicate timestamps
 			// WHERE (posted < cursor_posted) OR (posted = cursor_posted AND gid < cursor_gid)
-			query = `
+			query = fmt.Sprintf(`
 				SELECT gid, token, archiver_key, title, title_jpn, category, thumb, uploader,
 				       posted, filecount, filesize, expunged, removed, replaced, rating,
 				       torrentcount, root_gid, bytorrent, COALESCE(tags, '[]'::jsonb)
 				FROM gallery
-				WHERE category = $1 AND expunged = false
+				WHERE category = $1 AND expunged = false%s
 				  AND (posted < to_timestamp($2) OR (posted = to_timestamp($2) AND gid < $3))
 				ORDER BY posted DESC, gid DESC
 				LIMIT $4
-			`
-			args = []interface{}{categories[0], cursorTime, cursorGid, limit}
+			`, languageCondition(lang, 5))
+			args = appendLanguageArg([]interface{}{categories[0], cursorTime, cursorGid, limit}, lang)
 			h.logger.Debug("executing single category query (cursor mode)",
-				zap.String("sql", utils.FormatSQL(query, categories[0], cursorTime, cursorGid, limit)),
+				zap.String("sql", utils.FormatSQL(query, args...)),
 			)
 		} else {
 			// Traditional pagination: OFFSET/LIMIT
 			offset := (page - 1) * limit
-			query = `
+			query = fmt.Sprintf(`
 				SELECT gid, token, archiver_key, title, title_jpn, category, thumb, uploader,
 				       posted, filecount, filesize, expunged, removed, replaced, rating,
 				       torrentcount, root_gid, bytorrent, COALESCE(tags, '[]'::jsonb)
 				FROM gallery
-				WHERE category = $1 AND expunged = false
+				WHERE category = $1 AND expunged = false%s
 				ORDER BY posted DESC, gid DESC
 				LIMIT $2 OFFSET $3
-			`
-			args = []interface{}{categories[0], limit, offset}
+			`, languageCondition(lang, 4))
+			args = appendLanguageArg([]interface{}{categories[0], limit, offset}, lang)
 			h.logger.Debug("executing single category query (page mode)",
-				zap.String("sql", utils.FormatSQL(query, categories[0], limit, offset)),
+				zap.String("sql", utils.FormatSQL(query, args...)),
 			)
 		}
 	} else {
 		// Multiple categories - use UNION ALL for better index usage
 		// Each UNION branch can use the index independently and push down LIMIT
 		var unions []string
+		// The language placeholder follows every other argument in both modes
+		langCondition := languageCondition(lang, len(categories)+4)
 
 		if useCursor {
 			// Cursor-based pagination: each branch uses composite cursor
@@ -161,14 +168,15 @@ func (h *CategoryHandler) GetByCategory(c *gin.Context) {
 					        posted, filecount, filesize, expunged, removed, replaced, rating,
 					        torrentcount, root_gid, bytorrent, COALESCE(tags, '[]'::jsonb)
 					 FROM gallery
-					 WHERE category = $%d AND expunged = false
+					 WHERE category = $%d AND expunged = false%s
 					   AND (posted < to_timestamp($%d) OR (posted = to_timestamp($%d) AND gid < $%d))
 					 ORDER BY posted DESC, gid DESC
 					 LIMIT $%d)
-				`, i+1, len(categories)+1, len(categories)+1, len(categories)+2, len(categories)+3))
+				`, i+1, langCondition, len(categories)+1, len(categories)+1, len(categories)+2, len(categories)+3))
 				args = append(args, cat)
 			}
 			args = append(args, cursorTime, cursorGid, limit)
+			args = appendLanguageArg(args, lang)
 
 			query = strings.Join(unions, " UNION ALL ") + `
 				ORDER BY posted DESC, gid DESC
@@ -189,10 +197,10 @@ func (h *CategoryHandler) GetByCategory(c *gin.Context) {
 					        posted, filecount, filesize, expunged, removed, replaced, rating,
 					        torrentcount, root_gid, bytorrent, COALESCE(tags, '[]'::jsonb)
 					 FROM gallery
-					 WHERE category = $%d AND expunged = false
+					 WHERE category = $%d AND expunged = false%s
 					 ORDER BY posted DESC, gid DESC
 					 LIMIT $%d)
-				`, i+1, len(categories)+1)) // All branches use the same fetchLimit parameter
+				`, i+1, langCondition, len(categories)+1)) // All branches use the same fetchLimit parameter
 				args = append(args, cat)
 			}
 
@@ -204,6 +212,7 @@ func (h *CategoryHandler) GetByCategory(c *gin.Context) {
 				LIMIT $%d OFFSET $%d
 			`, len(categories)+2, len(categories)+3)
 			args = append(args, limit, offset)
+			args = appendLanguageArg(args, lang)
 
 			h.logger.Debug("executing multi-category query (page mode)",
 				zap.String("sql", utils.FormatSQL(query, args...)),
@@ -249,22 +258,35 @@ func (h *CategoryHandler) GetByCategory(c *gin.Context) {
 		zap.Int("root_gids", len(rootGids)),
 	)
 
-	// Count total - use materialized view for all categories
+	// Count total - use the trigger-maintained counters for all categories
 	// Since categories are mutually exclusive in E-Hentai, we can sum the counts
 	var total int64
 
-	if len(categories) == 1 {
-		// Single category - direct query from materialized view
+	if lang != "" {
+		// Language filter - stats table has no per-category language rows, count directly
+		countQuery := "SELECT COUNT(*) FROM gallery WHERE category = ANY($1) AND expunged = false" + languageCondition(lang, 2)
+		h.logger.Debug("executing count query (direct, language)",
+			zap.String("sql", utils.FormatSQL(countQuery, categories, lang)),
+		)
+		err = pool.QueryRow(ctx, countQuery, categories, lang).Scan(&total)
+		if err != nil {
+			h.logger.Error("failed to count galleries", zap.Error(err))
+			c.JSON(500, utils.GetResponse(nil, 500, "database error", nil))
+			return
+		}
+		h.logger.Debug("count result (direct)", zap.Int64("total", total))
+	} else if len(categories) == 1 {
+		// Single category - direct query from stats table
 		statKey := "category_" + strings.ToLower(categories[0])
-		statsQuery := "SELECT COALESCE(stat_value, 0) FROM gallery_stats_mv WHERE stat_key = $1"
-		h.logger.Debug("executing count query (materialized view, single)",
+		statsQuery := "SELECT COALESCE((SELECT stat_value FROM gallery_stat WHERE stat_key = $1), 0)"
+		h.logger.Debug("executing count query (stats table, single)",
 			zap.String("sql", utils.FormatSQL(statsQuery, statKey)),
 		)
 
 		err = pool.QueryRow(ctx, statsQuery, statKey).Scan(&total)
 
-		if err != nil || total == 0 {
-			h.logger.Warn("failed to get count from stats view or got 0, falling back to COUNT", zap.Error(err))
+		if err != nil {
+			h.logger.Warn("failed to get count from stats table, falling back to COUNT", zap.Error(err))
 			countQuery := "SELECT COUNT(*) FROM gallery WHERE category = $1 AND expunged = false"
 			h.logger.Debug("executing count query (direct, single)",
 				zap.String("sql", utils.FormatSQL(countQuery, categories[0])),
@@ -277,24 +299,24 @@ func (h *CategoryHandler) GetByCategory(c *gin.Context) {
 			}
 			h.logger.Debug("count result (direct)", zap.Int64("total", total))
 		} else {
-			h.logger.Debug("count result (materialized view)", zap.Int64("total", total))
+			h.logger.Debug("count result (stats table)", zap.Int64("total", total))
 		}
 	} else {
-		// Multiple categories - sum from materialized view
+		// Multiple categories - sum from stats table
 		statKeys := make([]string, len(categories))
 		for i, cat := range categories {
 			statKeys[i] = "category_" + strings.ToLower(cat)
 		}
 
-		statsQuery := "SELECT COALESCE(SUM(stat_value), 0) FROM gallery_stats_mv WHERE stat_key = ANY($1)"
-		h.logger.Debug("executing count query (materialized view, multi)",
+		statsQuery := "SELECT COALESCE(SUM(stat_value), 0) FROM gallery_stat WHERE stat_key = ANY($1)"
+		h.logger.Debug("executing count query (stats table, multi)",
 			zap.String("sql", utils.FormatSQL(statsQuery, statKeys)),
 		)
 
 		err = pool.QueryRow(ctx, statsQuery, statKeys).Scan(&total)
 
-		if err != nil || total == 0 {
-			h.logger.Warn("failed to get count from stats view or got 0, falling back to COUNT", zap.Error(err))
+		if err != nil {
+			h.logger.Warn("failed to get count from stats table, falling back to COUNT", zap.Error(err))
 
 			// Build count query for multiple categories
 			var countArgs []interface{}
@@ -321,7 +343,7 @@ func (h *CategoryHandler) GetByCategory(c *gin.Context) {
 			}
 			h.logger.Debug("count result (direct)", zap.Int64("total", total))
 		} else {
-			h.logger.Debug("count result (materialized view)", zap.Int64("total", total))
+			h.logger.Debug("count result (stats table)", zap.Int64("total", total))
 		}
 	}
 
@@ -342,6 +364,11 @@ func (h *CategoryHandler) GetByCategory(c *gin.Context) {
 		}
 	}
 
+	// Attach translated tag labels if requested
+	if wantsTagTranslations(c) {
+		attachTagTranslations(ctx, h.logger, galleries)
+	}
+
 	if len(galleries) == 0 {
 		c.JSON(200, utils.GetResponse([]database.Gallery{}, 200, "success", &total))
 		return
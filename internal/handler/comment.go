@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/slinet/ehdb/internal/database"
+	"github.com/slinet/ehdb/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// GetComments handles GET /api/gallery/:gid/comments and GET /api/g/:gid/comments.
+// Comments are only stored for galleries crawled with comments enabled, so a
+// known gallery without any returns an empty list.
+func (h *GalleryHandler) GetComments(c *gin.Context) {
+	gid := c.Param("gid")
+
+	gidPattern := regexp.MustCompile(`^\d+$`)
+	if !gidPattern.MatchString(gid) {
+		c.JSON(400, utils.GetResponse(nil, 400, "gid is invalid", nil))
+		return
+	}
+
+	ctx := context.Background()
+	pool := database.GetPool()
+
+	var exists bool
+	if err := pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM gallery WHERE gid = $1)`, gid).Scan(&exists); err != nil {
+		h.logger.Error("failed to query gallery", zap.Error(err), zap.String("gid", gid))
+		c.JSON(500, utils.GetResponse(nil, 500, "database error", nil))
+		return
+	}
+	if !exists {
+		c.JSON(404, utils.GetResponse(nil, 404, "no gallery matches gid", nil))
+		return
+	}
+
+	// The uploader comment comes first, like on the gallery page
+	query := `
+		SELECT comment_id, gid, author, uploader, score, posted, edited, body
+		FROM gallery_comment
+		WHERE gid = $1
+		ORDER BY uploader DESC, posted, comment_id
+	`
+
+	h.logger.Debug("executing comment query",
+		zap.String("sql", utils.FormatSQL(query, gid)),
+	)
+
+	rows, err := pool.Query(ctx, query, gid)
+	if err != nil {
+		h.logger.Error("failed to query comments", zap.Error(err), zap.String("gid", gid))
+		c.JSON(500, utils.GetResponse(nil, 500, "database error", nil))
+		return
+	}
+	defer rows.Close()
+
+	comments := []database.Comment{}
+	for rows.Next() {
+		var comment database.Comment
+		var posted time.Time
+		var edited *time.Time
+		err := rows.Scan(&comment.ID, &comment.Gid, &comment.Author, &comment.Uploader,
+			&comment.Score, &posted, &edited, &comment.Body)
+		if err != nil {
+			h.logger.Error("failed to scan comment", zap.Error(err))
+			c.JSON(500, utils.GetResponse(nil, 500, "database error", nil))
+			return
+		}
+		comment.Posted = database.UnixTime{Time: posted}
+		if edited != nil {
+			comment.Edited = &database.UnixTime{Time: *edited}
+		}
+		comments = append(comments, comment)
+	}
+	if err := rows.Err(); err != nil {
+		h.logger.Error("failed to read comments", zap.Error(err), zap.String("gid", gid))
+		c.JSON(500, utils.GetResponse(nil, 500, "database error", nil))
+		return
+	}
+
+	c.JSON(200, utils.GetResponse(comments, 200, "success", nil))
+}
@@ -99,6 +99,13 @@ func (h *GalleryHandler) GetGallery(c *gin.Context) {
 		}
 	}
 
+	// Attach translated tag labels if requested
+	if wantsTagTranslations(c) {
+		galleries := []database.Gallery{gallery}
+		attachTagTranslations(ctx, h.logger, galleries)
+		gallery = galleries[0]
+	}
+
 	c.JSON(200, utils.GetResponse(gallery, 200, "success", nil))
 }
 
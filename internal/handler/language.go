@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/slinet/ehdb/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// LanguageHandler lists galleries by primary language
+type LanguageHandler struct {
+	list *ListHandler
+}
+
+// NewLanguageHandler creates a new language handler
+func NewLanguageHandler(logger *zap.Logger) *LanguageHandler {
+	return &LanguageHandler{list: NewListHandler(logger)}
+}
+
+// GetByLanguage handles GET /api/language/:lang
+// Accepts the same pagination parameters as GET /api/list
+func (h *LanguageHandler) GetByLanguage(c *gin.Context) {
+	langParam := c.Param("lang")
+	if langParam == "" {
+		langParam = c.Query("lang")
+	}
+
+	if langParam == "" {
+		c.JSON(400, utils.GetResponse(nil, 400, "lang is not defined", nil))
+		return
+	}
+
+	lang, ok := languageParam(c, langParam)
+	if !ok {
+		return
+	}
+
+	h.list.list(c, lang)
+}
+
+// languageParam validates the lang filter. An empty value disables the filter.
+// It writes a 400 response and returns false when the value is invalid.
+func languageParam(c *gin.Context, value string) (string, bool) {
+	if value == "" {
+		return "", true
+	}
+
+	lang, ok := utils.NormalizeLanguage(value)
+	if !ok {
+		c.JSON(400, utils.GetResponse(nil, 400, "lang is invalid", nil))
+		return "", false
+	}
+
+	return lang, true
+}
+
+// languageCondition returns an SQL condition restricting galleries to lang using
+// placeholder $argIndex, or an empty string when no language is requested
+func languageCondition(lang string, argIndex int) string {
+	if lang == "" {
+		return ""
+	}
+	return fmt.Sprintf(" AND language = $%d", argIndex)
+}
+
+// appendLanguageArg appends lang to args when a language is requested
+func appendLanguageArg(args []interface{}, lang string) []interface{} {
+	if lang == "" {
+		return args
+	}
+	return append(args, lang)
+}
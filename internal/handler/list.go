@@ -36,6 +36,16 @@ func NewListHandler(logger *zap.Logger) *ListHandler {
 // - Use page/limit for shallow pagination (first few pages)
 // - Use cursor/limit for deep pagination (performance is constant regardless of offset)
 func (h *ListHandler) GetList(c *gin.Context) {
+	lang, ok := languageParam(c, c.Query("lang"))
+	if !ok {
+		return
+	}
+
+	h.list(c, lang)
+}
+
+// list returns the latest galleries, restricted to lang when it is not empty
+func (h *ListHandler) list(c *gin.Context, lang string) {
 	// Parse parameters
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "25"))
@@ -86,35 +96,35 @@ func (h *ListHandler) GetList(c *gin.Context) {
 	if useCursor {
 		// Cursor-based pagination: composite condition to handle duplicate timestamps
 		// WHERE (posted < cursor_posted) OR (posted = cursor_posted AND gid < cursor_gid)
-		query = `
+		query = fmt.Sprintf(`
 			SELECT gid, token, archiver_key, title, title_jpn, category, thumb, uploader,
 			       posted, filecount, filesize, expunged, removed, replaced, rating,
 			       torrentcount, root_gid, bytorrent, COALESCE(tags, '[]'::jsonb)
 			FROM gallery
-			WHERE expunged = false
+			WHERE expunged = false%s
 			  AND (posted < to_timestamp($1) OR (posted = to_timestamp($1) AND gid < $2))
 			ORDER BY posted DESC, gid DESC
 			LIMIT $3
-		`
-		args = []interface{}{cursorTime, cursorGid, limit}
+		`, languageCondition(lang, 4))
+		args = appendLanguageArg([]interface{}{cursorTime, cursorGid, limit}, lang)
 		h.logger.Debug("executing list query (cursor mode)",
-			zap.String("sql", utils.FormatSQL(query, cursorTime, cursorGid, limit)),
+			zap.String("sql", utils.FormatSQL(query, args...)),
 		)
 	} else {
 		// Traditional pagination: OFFSET/LIMIT
 		offset := (page - 1) * limit
-		query = `
+		query = fmt.Sprintf(`
 			SELECT gid, token, archiver_key, title, title_jpn, category, thumb, uploader,
 			       posted, filecount, filesize, expunged, removed, replaced, rating,
 			       torrentcount, root_gid, bytorrent, COALESCE(tags, '[]'::jsonb)
 			FROM gallery
-			WHERE expunged = false
+			WHERE expunged = false%s
 			ORDER BY posted DESC, gid DESC
 			LIMIT $1 OFFSET $2
-		`
-		args = []interface{}{limit, offset}
+		`, languageCondition(lang, 3))
+		args = appendLanguageArg([]interface{}{limit, offset}, lang)
 		h.logger.Debug("executing list query (page mode)",
-			zap.String("sql", utils.FormatSQL(query, limit, offset)),
+			zap.String("sql", utils.FormatSQL(query, args...)),
 		)
 	}
 
@@ -154,21 +164,26 @@ func (h *ListHandler) GetList(c *gin.Context) {
 		zap.Int("root_gids", len(rootGids)),
 	)
 
-	// Query total count - use materialized view for better performance
+	// Query total count - use the trigger-maintained counters for better performance
 	var total int64
-	statsQuery := "SELECT COALESCE(stat_value, 0) FROM gallery_stats_mv WHERE stat_key = 'total_active'"
-	h.logger.Debug("executing count query (materialized view)",
-		zap.String("sql", utils.FormatSQL(statsQuery, "total_active")),
+	statKey := "total_active"
+	if lang != "" {
+		statKey = "language_" + lang
+	}
+	statsQuery := "SELECT COALESCE((SELECT stat_value FROM gallery_stat WHERE stat_key = $1), 0)"
+	h.logger.Debug("executing count query (stats table)",
+		zap.String("sql", utils.FormatSQL(statsQuery, statKey)),
 	)
 
-	err = pool.QueryRow(ctx, statsQuery).Scan(&total)
-	if err != nil || total == 0 {
-		h.logger.Warn("failed to get count from stats view or got 0, falling back to COUNT", zap.Error(err))
-		countQuery := "SELECT COUNT(*) FROM gallery WHERE expunged = false"
+	err = pool.QueryRow(ctx, statsQuery, statKey).Scan(&total)
+	if err != nil {
+		h.logger.Warn("failed to get count from stats table, falling back to COUNT", zap.Error(err))
+		countQuery := "SELECT COUNT(*) FROM gallery WHERE expunged = false" + languageCondition(lang, 1)
+		countArgs := appendLanguageArg(nil, lang)
 		h.logger.Debug("executing count query (direct)",
-			zap.String("sql", utils.FormatSQL(countQuery)),
+			zap.String("sql", utils.FormatSQL(countQuery, countArgs...)),
 		)
-		err = pool.QueryRow(ctx, countQuery).Scan(&total)
+		err = pool.QueryRow(ctx, countQuery, countArgs...).Scan(&total)
 		if err != nil {
 			h.logger.Error("failed to count galleries", zap.Error(err))
 			c.JSON(500, utils.GetResponse(nil, 500, "database error", nil))
@@ -176,7 +191,7 @@ func (h *ListHandler) GetList(c *gin.Context) {
 		}
 		h.logger.Debug("count result (direct)", zap.Int64("total", total))
 	} else {
-		h.logger.Debug("count result (materialized view)", zap.Int64("total", total))
+		h.logger.Debug("count result (stats table)", zap.Int64("total", total))
 	}
 
 	// Query torrents for galleries with root_gid
@@ -199,6 +214,11 @@ func (h *ListHandler) GetList(c *gin.Context) {
 		}
 	}
 
+	// Attach translated tag labels if requested
+	if wantsTagTranslations(c) {
+		attachTagTranslations(ctx, h.logger, galleries)
+	}
+
 	if len(galleries) == 0 {
 		c.JSON(200, utils.GetResponse([]database.Gallery{}, 200, "success", &total))
 		return
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/slinet/ehdb/internal/jobs"
+	"github.com/slinet/ehdb/internal/middleware"
+	"github.com/slinet/ehdb/internal/scheduler"
+	"go.uber.org/zap"
+)
+
+// RegisterRoutes sets up the public API routes on router. It is shared by
+// cmd/api and tests that run the real handlers behind an httptest server.
+func RegisterRoutes(router gin.IRouter, logger *zap.Logger) {
+	// Initialize handlers
+	galleryHandler := NewGalleryHandler(logger)
+	listHandler := NewListHandler(logger)
+	searchHandler := NewSearchHandler(logger)
+	tagHandler := NewTagHandler(logger)
+	categoryHandler := NewCategoryHandler(logger)
+	uploaderHandler := NewUploaderHandler(logger)
+	languageHandler := NewLanguageHandler(logger)
+
+	router.GET("/", func(c *gin.Context) {
+		c.String(http.StatusOK, "ehdb-api is running")
+	})
+
+	// Health check endpoint (no logging)
+	router.GET("/health", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	api := router.Group("/api")
+	{
+		// Gallery routes
+		api.GET("/gallery/:gid/comments", galleryHandler.GetComments)
+		api.GET("/gallery/:gid/:token", galleryHandler.GetGallery)
+		api.GET("/gallery/:gid", galleryHandler.GetGallery)
+		api.GET("/gallery", galleryHandler.GetGallery)
+		api.GET("/g/:gid/comments", galleryHandler.GetComments)
+		api.GET("/g/:gid/:token", galleryHandler.GetGallery)
+		api.GET("/g/:gid", galleryHandler.GetGallery)
+		api.GET("/g", galleryHandler.GetGallery)
+
+		// List route
+		api.GET("/list", listHandler.GetList)
+
+		// Search route
+		api.GET("/search", searchHandler.Search)
+
+		// Tag routes
+		api.GET("/tag/:tag", tagHandler.GetByTag)
+		api.GET("/tag", tagHandler.GetByTag)
+
+		// Category routes
+		api.GET("/category/:category", categoryHandler.GetByCategory)
+		api.GET("/category", categoryHandler.GetByCategory)
+		api.GET("/cat/:category", categoryHandler.GetByCategory)
+		api.GET("/cat", categoryHandler.GetByCategory)
+
+		// Uploader routes
+		api.GET("/uploader/:uploader", uploaderHandler.GetByUploader)
+		api.GET("/uploader", uploaderHandler.GetByUploader)
+
+		// Language routes
+		api.GET("/language/:lang", languageHandler.GetByLanguage)
+		api.GET("/language", languageHandler.GetByLanguage)
+	}
+}
+
+// RegisterAdminRoutes sets up the /admin job endpoints behind bearer token auth.
+// sched may be nil when the scheduler is not enabled.
+func RegisterAdminRoutes(router gin.IRouter, logger *zap.Logger, manager *jobs.Manager, sched *scheduler.Scheduler, token string) {
+	adminHandler := NewAdminHandler(logger, manager, sched)
+
+	admin := router.Group("/admin", middleware.AdminAuth(token))
+	{
+		// Job submission
+		admin.POST("/jobs/fetch", adminHandler.CreateFetchJob)
+		admin.POST("/jobs/resync", adminHandler.CreateResyncJob)
+		admin.POST("/jobs/backfill", adminHandler.CreateBackfillJob)
+		admin.POST("/jobs/mark-replaced", adminHandler.CreateMarkReplacedJob)
+
+		// Job monitoring
+		admin.GET("/jobs", adminHandler.ListJobs)
+		admin.GET("/jobs/queue", adminHandler.ListQueuedJobs)
+		admin.GET("/jobs/:id", adminHandler.GetJob)
+		admin.GET("/jobs/:id/logs", adminHandler.GetJobLogs)
+		admin.POST("/jobs/:id/cancel", adminHandler.CancelJob)
+
+		// Scheduler
+		admin.GET("/scheduler", adminHandler.GetSchedulerStats)
+	}
+}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -10,6 +11,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/slinet/ehdb/internal/config"
 	"github.com/slinet/ehdb/internal/database"
+	"github.com/slinet/ehdb/internal/tagtranslation"
 	"github.com/slinet/ehdb/pkg/utils"
 	"go.uber.org/zap"
 )
@@ -67,6 +69,11 @@ func (h *SearchHandler) Search(c *gin.Context) {
 	maxPage, _ := strconv.Atoi(maxPageParam)
 	minRating, _ := strconv.ParseFloat(minRatingParam, 64)
 
+	lang, ok := languageParam(c, c.Query("lang"))
+	if !ok {
+		return
+	}
+
 	if minRating < 0 {
 		minRating = 0
 	}
@@ -143,6 +150,12 @@ func (h *SearchHandler) Search(c *gin.Context) {
 		zap.Int("keywords", len(searchQuery.Keywords)),
 	)
 
+	// Map translated tag names (e.g. "female:巨乳") back to canonical tags
+	if err := h.resolveTranslatedTags(ctx, searchQuery); err != nil {
+		c.JSON(400, utils.GetResponse(nil, 400, err.Error(), nil))
+		return
+	}
+
 	// Expand tag prefixes by querying tag table
 	// Returns map: prefix -> list of expanded tags
 	expandedTagGroups, hasUnmatchedPrefixes := h.expandTagPrefixesGrouped(ctx, searchQuery.TagPrefixes)
@@ -158,6 +171,10 @@ func (h *SearchHandler) Search(c *gin.Context) {
 		zap.Bool("has_unmatched_prefixes", hasUnmatchedPrefixes),
 	)
 
+	// Apply tag rules: exact tags with aliases or implying tags match any of them
+	rules := utils.CurrentTagRules()
+	containedTags, impliedTagGroups := splitImpliedTags(rules, searchQuery.Tags)
+
 	// Build WHERE conditions
 	var conditions []string
 	var args []interface{}
@@ -213,6 +230,13 @@ func (h *SearchHandler) Search(c *gin.Context) {
 		argIndex++
 	}
 
+	// Language condition
+	if lang != "" {
+		conditions = append(conditions, fmt.Sprintf("language = $%d", argIndex))
+		args = append(args, lang)
+		argIndex++
+	}
+
 	// Date range conditions
 	if maxDate > 0 {
 		conditions = append(conditions, fmt.Sprintf("posted <= to_timestamp($%d)", argIndex))
@@ -228,9 +252,9 @@ func (h *SearchHandler) Search(c *gin.Context) {
 	// Tags condition
 	// Exact tags: all must be present (AND relationship)
 	// Combine into single JSONB containment check for better performance
-	if len(searchQuery.Tags) > 0 {
-		tagArray := make([]string, len(searchQuery.Tags))
-		for i, tag := range searchQuery.Tags {
+	if len(containedTags) > 0 {
+		tagArray := make([]string, len(containedTags))
+		for i, tag := range containedTags {
 			tagArray[i] = `"` + tag + `"`
 		}
 		mergedTags := "[" + strings.Join(tagArray, ", ") + "]"
@@ -239,6 +263,13 @@ func (h *SearchHandler) Search(c *gin.Context) {
 		argIndex++
 	}
 
+	// Exact tags with aliases or implying tags: the tag or any of them must be present
+	for _, impliedTags := range impliedTagGroups {
+		conditions = append(conditions, fmt.Sprintf("tags ?| $%d", argIndex))
+		args = append(args, impliedTags)
+		argIndex++
+	}
+
 	// Prefix tags: each prefix's expanded tags are OR (using ?| operator for better performance)
 	// Different prefixes are AND
 	for prefix, expandedTags := range expandedTagGroups {
@@ -297,10 +328,15 @@ func (h *SearchHandler) Search(c *gin.Context) {
 	for _, exclude := range searchQuery.Excludes {
 		// Check if this is a tag exclusion
 		if strings.HasPrefix(exclude, "TAG_EXACT:") {
-			// Exact tag exclusion: NOT (tags ? 'tag')
+			// Exact tag exclusion: NOT (tags ? 'tag'), or NOT (tags ?| array[...]) when aliased or implied by other tags
 			tagValue := strings.TrimPrefix(exclude, "TAG_EXACT:")
-			conditions = append(conditions, fmt.Sprintf("NOT (tags ? $%d)", argIndex))
-			args = append(args, tagValue)
+			if expandedTags := rules.Expand(tagValue); len(expandedTags) > 1 {
+				conditions = append(conditions, fmt.Sprintf("NOT (tags ?| $%d)", argIndex))
+				args = append(args, expandedTags)
+			} else {
+				conditions = append(conditions, fmt.Sprintf("NOT (tags ? $%d)", argIndex))
+				args = append(args, tagValue)
+			}
 			argIndex++
 		} else if strings.HasPrefix(exclude, "TAG_PREFIX:") {
 			// Tag prefix exclusion: expand and use NOT (tags ?| array[...])
@@ -332,10 +368,15 @@ func (h *SearchHandler) Search(c *gin.Context) {
 		for _, orTerm := range orGroup {
 			// Check if this is a tag OR
 			if strings.HasPrefix(orTerm, "TAG_EXACT:") {
-				// Exact tag OR: tags ? 'tag'
+				// Exact tag OR: tags ? 'tag', or tags ?| array[...] when aliased or implied by other tags
 				tagValue := strings.TrimPrefix(orTerm, "TAG_EXACT:")
-				tagOrConditions = append(tagOrConditions, fmt.Sprintf("(tags ? $%d)", argIndex))
-				args = append(args, tagValue)
+				if expandedTags := rules.Expand(tagValue); len(expandedTags) > 1 {
+					tagOrConditions = append(tagOrConditions, fmt.Sprintf("(tags ?| $%d)", argIndex))
+					args = append(args, expandedTags)
+				} else {
+					tagOrConditions = append(tagOrConditions, fmt.Sprintf("(tags ? $%d)", argIndex))
+					args = append(args, tagValue)
+				}
 				argIndex++
 			} else if strings.HasPrefix(orTerm, "TAG_PREFIX:") {
 				// Tag prefix OR: expand and use tags ?| array[...]
@@ -511,6 +552,11 @@ func (h *SearchHandler) Search(c *gin.Context) {
 			countArgsTemp = append(countArgsTemp, minRating)
 			countArgIndex++
 		}
+		if lang != "" {
+			countConditions = append(countConditions, fmt.Sprintf("language = $%d", countArgIndex))
+			countArgsTemp = append(countArgsTemp, lang)
+			countArgIndex++
+		}
 		// Date range conditions for count
 		if maxDate > 0 {
 			countConditions = append(countConditions, fmt.Sprintf("posted <= to_timestamp($%d)", countArgIndex))
@@ -525,9 +571,9 @@ func (h *SearchHandler) Search(c *gin.Context) {
 		// Tags condition for count (same logic as main query)
 		// Exact tags: all must be present (AND relationship)
 		// Combine into single JSONB containment check for better performance
-		if len(searchQuery.Tags) > 0 {
-			tagArray := make([]string, len(searchQuery.Tags))
-			for i, tag := range searchQuery.Tags {
+		if len(containedTags) > 0 {
+			tagArray := make([]string, len(containedTags))
+			for i, tag := range containedTags {
 				tagArray[i] = `"` + tag + `"`
 			}
 			mergedTags := "[" + strings.Join(tagArray, ", ") + "]"
@@ -535,6 +581,11 @@ func (h *SearchHandler) Search(c *gin.Context) {
 			countArgsTemp = append(countArgsTemp, mergedTags)
 			countArgIndex++
 		}
+		for _, impliedTags := range impliedTagGroups {
+			countConditions = append(countConditions, fmt.Sprintf("tags ?| $%d", countArgIndex))
+			countArgsTemp = append(countArgsTemp, impliedTags)
+			countArgIndex++
+		}
 
 		// Prefix tags: each prefix's expanded tags are OR (using ?| operator)
 		for _, expandedTags := range expandedTagGroups {
@@ -578,10 +629,15 @@ func (h *SearchHandler) Search(c *gin.Context) {
 		for _, exclude := range searchQuery.Excludes {
 			// Check if this is a tag exclusion
 			if strings.HasPrefix(exclude, "TAG_EXACT:") {
-				// Exact tag exclusion: NOT (tags ? 'tag')
+				// Exact tag exclusion: NOT (tags ? 'tag'), or NOT (tags ?| array[...]) when aliased or implied by other tags
 				tagValue := strings.TrimPrefix(exclude, "TAG_EXACT:")
-				countConditions = append(countConditions, fmt.Sprintf("NOT (tags ? $%d)", countArgIndex))
-				countArgsTemp = append(countArgsTemp, tagValue)
+				if expandedTags := rules.Expand(tagValue); len(expandedTags) > 1 {
+					countConditions = append(countConditions, fmt.Sprintf("NOT (tags ?| $%d)", countArgIndex))
+					countArgsTemp = append(countArgsTemp, expandedTags)
+				} else {
+					countConditions = append(countConditions, fmt.Sprintf("NOT (tags ? $%d)", countArgIndex))
+					countArgsTemp = append(countArgsTemp, tagValue)
+				}
 				countArgIndex++
 			} else if strings.HasPrefix(exclude, "TAG_PREFIX:") {
 				// Tag prefix exclusion: expand and use NOT (tags ?| array[...])
@@ -610,10 +666,15 @@ func (h *SearchHandler) Search(c *gin.Context) {
 			for _, orTerm := range orGroup {
 				// Check if this is a tag OR
 				if strings.HasPrefix(orTerm, "TAG_EXACT:") {
-					// Exact tag OR: tags ? 'tag'
+					// Exact tag OR: tags ? 'tag', or tags ?| array[...] when aliased or implied by other tags
 					tagValue := strings.TrimPrefix(orTerm, "TAG_EXACT:")
-					tagOrConditions = append(tagOrConditions, fmt.Sprintf("(tags ? $%d)", countArgIndex))
-					countArgsTemp = append(countArgsTemp, tagValue)
+					if expandedTags := rules.Expand(tagValue); len(expandedTags) > 1 {
+						tagOrConditions = append(tagOrConditions, fmt.Sprintf("(tags ?| $%d)", countArgIndex))
+						countArgsTemp = append(countArgsTemp, expandedTags)
+					} else {
+						tagOrConditions = append(tagOrConditions, fmt.Sprintf("(tags ? $%d)", countArgIndex))
+						countArgsTemp = append(countArgsTemp, tagValue)
+					}
 					countArgIndex++
 				} else if strings.HasPrefix(orTerm, "TAG_PREFIX:") {
 					// Tag prefix OR: expand and use tags ?| array[...]
@@ -694,6 +755,11 @@ func (h *SearchHandler) Search(c *gin.Context) {
 		}
 	}
 
+	// Attach translated tag labels if requested
+	if wantsTagTranslations(c) {
+		attachTagTranslations(ctx, h.logger, galleries)
+	}
+
 	if len(galleries) == 0 {
 		c.JSON(200, utils.GetResponse([]database.Gallery{}, 200, "success", &total))
 		return
@@ -754,7 +820,7 @@ func (h *SearchHandler) expandTagPrefixesGrouped(ctx context.Context, prefixes [
 			h.logger.Debug("no tags matched prefix", zap.String("prefix", prefix))
 			hasUnmatched = true
 		} else {
-			result[prefix] = tags
+			result[prefix] = utils.CurrentTagRules().ExpandAll(tags)
 			h.logger.Debug("expanded tag prefix",
 				zap.String("prefix", prefix),
 				zap.Int("matches", len(tags)),
@@ -804,5 +870,53 @@ func (h *SearchHandler) expandSingleTagPrefix(ctx context.Context, prefix string
 		zap.Int("matches", len(tags)),
 	)
 
-	return tags
+	if len(tags) == 0 {
+		return tags
+	}
+
+	return utils.CurrentTagRules().ExpandAll(tags)
+}
+
+// resolveTranslatedTags rewrites tags written with translated names to their canonical tags.
+// It returns an error only for a translated name several tags share; lookup failures are
+// logged and leave the tags as written.
+func (h *SearchHandler) resolveTranslatedTags(ctx context.Context, searchQuery *utils.SearchQuery) error {
+	resolved, err := tagtranslation.Resolve(ctx, h.logger, searchQuery.AllTags())
+	if err != nil {
+		var ambiguous *tagtranslation.AmbiguousError
+		if errors.As(err, &ambiguous) {
+			return err
+		}
+		h.logger.Warn("failed to resolve translated tags", zap.Error(err))
+		return nil
+	}
+	if len(resolved) == 0 {
+		return nil
+	}
+
+	searchQuery.MapTags(func(tag string) string {
+		if canonical, ok := resolved[tag]; ok {
+			return utils.NormalizeTag(canonical)
+		}
+		return tag
+	})
+	return nil
+}
+
+// splitImpliedTags separates exact tags without aliases or implying tags (safe to merge into
+// one containment check) from tags that must match any of their expanded forms
+func splitImpliedTags(rules *utils.TagRules, tags []string) ([]string, [][]string) {
+	var containedTags []string
+	var impliedTagGroups [][]string
+
+	for _, tag := range tags {
+		expanded := rules.Expand(tag)
+		if len(expanded) > 1 {
+			impliedTagGroups = append(impliedTagGroups, expanded)
+			continue
+		}
+		containedTags = append(containedTags, tag)
+	}
+
+	return containedTags, impliedTagGroups
 }
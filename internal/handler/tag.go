@@ -41,6 +41,11 @@ func (h *TagHandler) GetByTag(c *gin.Context) {
 		tag = c.Query("tag")
 	}
 
+	lang, ok := languageParam(c, c.Query("lang"))
+	if !ok {
+		return
+	}
+
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "25"))
 	cursor := c.Query("cursor") // Cursor format: "timestamp,gid" (composite cursor to handle duplicate timestamps)
@@ -99,34 +104,48 @@ func (h *TagHandler) GetByTag(c *gin.Context) {
 	pool := database.GetPool()
 
 	// Build query for multiple tags (all tags must be present)
-	// Use JSONB containment operator (@>) which can utilize GIN index (idx_gallery_tags)
-	// Merge all tags into a single JSONB array for better performance (one index lookup instead of multiple)
-	var query string
-	var args []interface{}
+	// Tags without aliases or implying tags are merged into one JSONB containment check (@>),
+	// which can utilize GIN index (idx_gallery_tags) with one index lookup instead of multiple.
+	// Stored tags keep alias names until rewritten, so a tag with aliases or implying tags
+	// must match any of its expanded forms (?|), as in search
+	containedTags, impliedTagGroups := splitImpliedTags(utils.CurrentTagRules(), normalizedTags)
 
-	// Build merged tags JSONB array: ["tag1", "tag2", "tag3"]
-	var tagArray []string
-	for _, t := range normalizedTags {
-		tagArray = append(tagArray, `"`+t+`"`)
+	var tagConditions []string
+	var args []interface{}
+	if len(containedTags) > 0 {
+		// Build merged tags JSONB array: ["tag1", "tag2", "tag3"]
+		var tagArray []string
+		for _, t := range containedTags {
+			tagArray = append(tagArray, `"`+t+`"`)
+		}
+		args = append(args, "["+strings.Join(tagArray, ", ")+"]")
+		tagConditions = append(tagConditions, fmt.Sprintf("tags @> $%d::jsonb", len(args)))
+	}
+	for _, impliedTags := range impliedTagGroups {
+		args = append(args, impliedTags)
+		tagConditions = append(tagConditions, fmt.Sprintf("tags ?| $%d", len(args)))
 	}
-	mergedTags := "[" + strings.Join(tagArray, ", ") + "]"
-	args = append(args, mergedTags)
+	tagCondition := strings.Join(tagConditions, " AND ")
+	countArgs := appendLanguageArg(append([]interface{}(nil), args...), lang)
+	argIndex := len(args) + 1
 
+	var query string
 	if useCursor {
 		// Cursor-based pagination: composite condition to handle duplicate timestamps
-		// WHERE tags @> $1::jsonb AND expunged = false
+		// WHERE <tag conditions> AND expunged = false
 		//   AND (posted < cursor_posted OR (posted = cursor_posted AND gid < cursor_gid))
-		query = `
+		query = fmt.Sprintf(`
 			SELECT gid, token, archiver_key, title, title_jpn, category, thumb, uploader,
 			       posted, filecount, filesize, expunged, removed, replaced, rating,
 			       torrentcount, root_gid, bytorrent, COALESCE(tags, '[]'::jsonb)
 			FROM gallery
-			WHERE tags @> $1::jsonb AND expunged = false
-			  AND (posted < to_timestamp($2) OR (posted = to_timestamp($2) AND gid < $3))
+			WHERE %s AND expunged = false%s
+			  AND (posted < to_timestamp($%d) OR (posted = to_timestamp($%d) AND gid < $%d))
 			ORDER BY posted DESC, gid DESC
-			LIMIT $4
-		`
+			LIMIT $%d
+		`, tagCondition, languageCondition(lang, argIndex+3), argIndex, argIndex, argIndex+1, argIndex+2)
 		args = append(args, cursorTime, cursorGid, limit)
+		args = appendLanguageArg(args, lang)
 
 		h.logger.Debug("executing tag query (cursor mode)",
 			zap.String("sql", utils.FormatSQL(query, args...)),
@@ -135,16 +154,17 @@ func (h *TagHandler) GetByTag(c *gin.Context) {
 	} else {
 		// Traditional pagination: OFFSET/LIMIT
 		offset := (page - 1) * limit
-		query = `
+		query = fmt.Sprintf(`
 			SELECT gid, token, archiver_key, title, title_jpn, category, thumb, uploader,
 			       posted, filecount, filesize, expunged, removed, replaced, rating,
 			       torrentcount, root_gid, bytorrent, COALESCE(tags, '[]'::jsonb)
 			FROM gallery
-			WHERE tags @> $1::jsonb AND expunged = false
+			WHERE %s AND expunged = false%s
 			ORDER BY posted DESC, gid DESC
-			LIMIT $2 OFFSET $3
-		`
+			LIMIT $%d OFFSET $%d
+		`, tagCondition, languageCondition(lang, argIndex+2), argIndex, argIndex+1)
 		args = append(args, limit, offset)
+		args = appendLanguageArg(args, lang)
 
 		h.logger.Debug("executing tag query (page mode)",
 			zap.String("sql", utils.FormatSQL(query, args...)),
@@ -188,9 +208,8 @@ func (h *TagHandler) GetByTag(c *gin.Context) {
 		zap.Int("root_gids", len(rootGids)),
 	)
 
-	// Count total - use merged tags for single index lookup
-	countQuery := "SELECT COUNT(*) FROM gallery WHERE tags @> $1::jsonb AND expunged = false"
-	countArgs := []interface{}{mergedTags}
+	// Count total - same tag conditions as the query
+	countQuery := "SELECT COUNT(*) FROM gallery WHERE " + tagCondition + " AND expunged = false" + languageCondition(lang, len(countArgs))
 
 	h.logger.Debug("executing count query",
 		zap.String("sql", utils.FormatSQL(countQuery, countArgs...)),
@@ -223,6 +242,11 @@ func (h *TagHandler) GetByTag(c *gin.Context) {
 		}
 	}
 
+	// Attach translated tag labels if requested
+	if wantsTagTranslations(c) {
+		attachTagTranslations(ctx, h.logger, galleries)
+	}
+
 	if len(galleries) == 0 {
 		c.JSON(200, utils.GetResponse([]database.Gallery{}, 200, "success", &total))
 		return
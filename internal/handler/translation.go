@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/slinet/ehdb/internal/database"
+	"github.com/slinet/ehdb/internal/tagtranslation"
+	"go.uber.org/zap"
+)
+
+// wantsTagTranslations reports whether the request asked for translated tag labels (?translate=1)
+func wantsTagTranslations(c *gin.Context) bool {
+	switch c.Query("translate") {
+	case "1", "true":
+		return true
+	default:
+		return false
+	}
+}
+
+// attachTagTranslations fills TagTranslations for each gallery with the translated
+// names of its tags. Failures are logged and leave the galleries untranslated.
+func attachTagTranslations(ctx context.Context, logger *zap.Logger, galleries []database.Gallery) {
+	seen := make(map[string]struct{})
+	var tags []string
+	for _, g := range galleries {
+		for _, tag := range g.Tags {
+			if _, ok := seen[tag]; ok {
+				continue
+			}
+			seen[tag] = struct{}{}
+			tags = append(tags, tag)
+		}
+	}
+
+	labels, err := tagtranslation.Labels(ctx, logger, tags)
+	if err != nil {
+		logger.Error("failed to query tag translations", zap.Error(err))
+		// Don't fail the request
+		return
+	}
+
+	for i := range galleries {
+		translations := make(map[string]string)
+		for _, tag := range galleries[i].Tags {
+			if label, ok := labels[tag]; ok {
+				translations[tag] = label
+			}
+		}
+		galleries[i].TagTranslations = translations
+	}
+}
@@ -41,6 +41,11 @@ func (h *UploaderHandler) GetByUploader(c *gin.Context) {
 		uploader = c.Query("uploader")
 	}
 
+	lang, ok := languageParam(c, c.Query("lang"))
+	if !ok {
+		return
+	}
+
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "25"))
 	cursor := c.Query("cursor") // Cursor format: "timestamp,gid" (composite cursor to handle duplicate timestamps)
@@ -96,36 +101,36 @@ func (h *UploaderHandler) GetByUploader(c *gin.Context) {
 		// Cursor-based pagination: composite condition to handle duplicate timestamps
 		// WHERE (posted < cursor_posted) OR (posted = cursor_posted AND gid < cursor_gid)
 		// This query uses the idx_gallery_uploader_exp_posted index for optimal performance
-		query = `
+		query = fmt.Sprintf(`
 			SELECT gid, token, archiver_key, title, title_jpn, category, thumb, uploader,
 			       posted, filecount, filesize, expunged, removed, replaced, rating,
 			       torrentcount, root_gid, bytorrent, COALESCE(tags, '[]'::jsonb)
 			FROM gallery
-			WHERE uploader = $1 AND expunged = false
+			WHERE uploader = $1 AND expunged = false%s
 			  AND (posted < to_timestamp($2) OR (posted = to_timestamp($2) AND gid < $3))
 			ORDER BY posted DESC, gid DESC
 			LIMIT $4
-		`
-		args = []interface{}{uploader, cursorTime, cursorGid, limit}
+		`, languageCondition(lang, 5))
+		args = appendLanguageArg([]interface{}{uploader, cursorTime, cursorGid, limit}, lang)
 		h.logger.Debug("executing uploader query (cursor mode)",
-			zap.String("sql", utils.FormatSQL(query, uploader, cursorTime, cursorGid, limit)),
+			zap.String("sql", utils.FormatSQL(query, args...)),
 		)
 	} else {
 		// Traditional pagination: OFFSET/LIMIT
 		// Uses the same index but performance degrades with large offsets
 		offset := (page - 1) * limit
-		query = `
+		query = fmt.Sprintf(`
 			SELECT gid, token, archiver_key, title, title_jpn, category, thumb, uploader,
 			       posted, filecount, filesize, expunged, removed, replaced, rating,
 			       torrentcount, root_gid, bytorrent, COALESCE(tags, '[]'::jsonb)
 			FROM gallery
-			WHERE uploader = $1 AND expunged = false
+			WHERE uploader = $1 AND expunged = false%s
 			ORDER BY posted DESC, gid DESC
 			LIMIT $2 OFFSET $3
-		`
-		args = []interface{}{uploader, limit, offset}
+		`, languageCondition(lang, 4))
+		args = appendLanguageArg([]interface{}{uploader, limit, offset}, lang)
 		h.logger.Debug("executing uploader query (page mode)",
-			zap.String("sql", utils.FormatSQL(query, uploader, limit, offset)),
+			zap.String("sql", utils.FormatSQL(query, args...)),
 		)
 	}
 
@@ -165,22 +170,28 @@ func (h *UploaderHandler) GetByUploader(c *gin.Context) {
 		zap.Int("root_gids", len(rootGids)),
 	)
 
-	// Count total - try materialized view first, fallback to COUNT
+	// Count total - try the trigger-maintained counters first, fallback to COUNT
+	// The stats table has no per-uploader language rows, so a language filter always counts directly
 	var total int64
-	statsQuery := "SELECT COALESCE(gallery_count, 0) FROM uploader_stats_mv WHERE uploader = $1"
-	h.logger.Debug("executing count query (materialized view)",
-		zap.String("sql", utils.FormatSQL(statsQuery, uploader)),
-	)
+	if lang == "" {
+		statsQuery := "SELECT COALESCE((SELECT gallery_count FROM uploader_stat WHERE uploader = $1), 0)"
+		h.logger.Debug("executing count query (stats table)",
+			zap.String("sql", utils.FormatSQL(statsQuery, uploader)),
+		)
 
-	err = pool.QueryRow(ctx, statsQuery, uploader).Scan(&total)
+		err = pool.QueryRow(ctx, statsQuery, uploader).Scan(&total)
+	}
 
-	if err != nil || total == 0 {
-		h.logger.Warn("failed to get count from stats view or got 0, falling back to COUNT", zap.Error(err))
-		countQuery := "SELECT COUNT(*) FROM gallery WHERE uploader = $1 AND expunged = false"
+	if lang != "" || err != nil {
+		if lang == "" {
+			h.logger.Warn("failed to get count from stats table, falling back to COUNT", zap.Error(err))
+		}
+		countQuery := "SELECT COUNT(*) FROM gallery WHERE uploader = $1 AND expunged = false" + languageCondition(lang, 2)
+		countArgs := appendLanguageArg([]interface{}{uploader}, lang)
 		h.logger.Debug("executing count query (direct)",
-			zap.String("sql", utils.FormatSQL(countQuery, uploader)),
+			zap.String("sql", utils.FormatSQL(countQuery, countArgs...)),
 		)
-		err = pool.QueryRow(ctx, countQuery, uploader).Scan(&total)
+		err = pool.QueryRow(ctx, countQuery, countArgs...).Scan(&total)
 		if err != nil {
 			h.logger.Error("failed to count galleries", zap.Error(err))
 			c.JSON(500, utils.GetResponse(nil, 500, "database error", nil))
@@ -188,7 +199,7 @@ func (h *UploaderHandler) GetByUploader(c *gin.Context) {
 		}
 		h.logger.Debug("count result (direct)", zap.Int64("total", total))
 	} else {
-		h.logger.Debug("count result (materialized view)", zap.Int64("total", total))
+		h.logger.Debug("count result (stats table)", zap.Int64("total", total))
 	}
 
 	// Query torrents
@@ -208,6 +219,11 @@ func (h *UploaderHandler) GetByUploader(c *gin.Context) {
 		}
 	}
 
+	// Attach translated tag labels if requested
+	if wantsTagTranslations(c) {
+		attachTagTranslations(ctx, h.logger, galleries)
+	}
+
 	if len(galleries) == 0 {
 		c.JSON(200, utils.GetResponse([]database.Gallery{}, 200, "success", &total))
 		return
@@ -0,0 +1,76 @@
+package jobrun
+
+import (
+	"context"
+	"sync"
+)
+
+// Counter names recorded with each run
+const (
+	GalleriesDiscovered = "galleries_discovered"
+	GalleriesImported   = "galleries_imported"
+	GalleriesUpdated    = "galleries_updated"
+	TorrentsAdded       = "torrents_added"
+	DetailsUpdated      = "details_updated"
+	CommentsSaved       = "comments_saved"
+	BansHit             = "bans_hit"
+	GalleriesRemoved    = "galleries_removed"
+	GalleriesRestored   = "galleries_restored"
+	MetadataRequests    = "metadata_requests"
+)
+
+// Counters accumulates named counts for one run. It is safe for concurrent use.
+type Counters struct {
+	mu     sync.Mutex
+	values map[string]int64
+}
+
+type countersKey struct{}
+
+// WithCounters returns a context carrying a new Counters
+func WithCounters(ctx context.Context) (context.Context, *Counters) {
+	counters := &Counters{values: make(map[string]int64)}
+	return context.WithValue(ctx, countersKey{}, counters), counters
+}
+
+// Add adds delta to the named counter of the run carried by ctx. It does
+// nothing when ctx carries no counters, so crawler code can call it
+// unconditionally.
+func Add(ctx context.Context, name string, delta int64) {
+	if ctx == nil || delta == 0 {
+		return
+	}
+	counters, ok := ctx.Value(countersKey{}).(*Counters)
+	if !ok {
+		return
+	}
+
+	counters.mu.Lock()
+	counters.values[name] += delta
+	counters.mu.Unlock()
+}
+
+// Value returns the named counter of the run carried by ctx so far, or zero
+// when ctx carries no counters
+func Value(ctx context.Context, name string) int64 {
+	counters, ok := ctx.Value(countersKey{}).(*Counters)
+	if !ok {
+		return 0
+	}
+
+	counters.mu.Lock()
+	defer counters.mu.Unlock()
+	return counters.values[name]
+}
+
+// Snapshot returns a copy of the current counter values
+func (c *Counters) Snapshot() map[string]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result := make(map[string]int64, len(c.values))
+	for name, value := range c.values {
+		result[name] = value
+	}
+	return result
+}
@@ -0,0 +1,339 @@
+package jobrun
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/slinet/ehdb/internal/database"
+	"github.com/slinet/ehdb/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// Run sources
+const (
+	SourceScheduler = "scheduler"
+	SourceCLI       = "cli"
+	SourceAdmin     = "admin"
+)
+
+// Run statuses
+const (
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+// finishTimeout bounds the history update and lock release after a run ends,
+// so a run whose context was cancelled can still record its outcome
+const finishTimeout = 10 * time.Second
+
+// Run is one recorded job run
+type Run struct {
+	ID         int64            `json:"id"`
+	Kind       string           `json:"kind"`
+	Source     string           `json:"source"`
+	Params     json.RawMessage  `json:"params,omitempty"`
+	Status     string           `json:"status"`
+	Error      *string          `json:"error,omitempty"`
+	ErrorChain []string         `json:"error_chain,omitempty"`
+	Counters   map[string]int64 `json:"counters"`
+	StartedAt  time.Time        `json:"started_at"`
+	FinishedAt *time.Time       `json:"finished_at,omitempty"`
+}
+
+// Duration returns how long the run took, or has been running so far
+func (r Run) Duration() time.Duration {
+	if r.FinishedAt != nil {
+		return r.FinishedAt.Sub(r.StartedAt)
+	}
+	return time.Since(r.StartedAt)
+}
+
+// Track runs fn as a run of kind and records it in job_run history. Runs of
+// the same kind are serialized across every instance and ehdb-sync process
+// sharing the database by a Postgres advisory lock; if another run holds it,
+// Track returns ErrLocked without running fn or recording anything.
+//
+// The context passed to fn carries Counters that crawler code fills through
+// Add. Failing to write history is logged and never fails the run itself;
+// fn's error is returned unchanged.
+func Track(ctx context.Context, logger *zap.Logger, kind, source string, params interface{}, fn func(ctx context.Context) error) error {
+	return track(ctx, logger, kind, source, params, nil, fn)
+}
+
+// TrackScheduled is Track for the cron firing of kind scheduled at firedAt.
+// Every scheduler instance fires the same cron entry, so while holding the
+// lock the run claims the firing by recording it in job_run, whose unique
+// (kind, scheduled_for) index admits one run per firing however late each
+// instance gets to it. If another instance claimed it the run is skipped
+// with ErrAlreadyRan; if the claim cannot be written the run is skipped with
+// that error, since an unrecorded run would leave the firing to the others.
+func TrackScheduled(ctx context.Context, logger *zap.Logger, kind string, firedAt time.Time, params interface{}, fn func(ctx context.Context) error) error {
+	return track(ctx, logger, kind, SourceScheduler, params, &firedAt, fn)
+}
+
+func track(ctx context.Context, logger *zap.Logger, kind, source string, params interface{}, firedAt *time.Time, fn func(ctx context.Context) error) error {
+	l, err := tryLock(ctx, logger, kind)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), finishTimeout)
+		defer cancel()
+		l.release(releaseCtx)
+	}()
+
+	runCtx, counters := WithCounters(ctx)
+
+	var id int64
+	if firedAt != nil {
+		id, err = claim(ctx, logger, kind, *firedAt, params)
+		if err != nil {
+			return err
+		}
+	} else {
+		id, err = start(ctx, logger, kind, source, params)
+		if err != nil {
+			logger.Warn("failed to record job run start", zap.String("kind", kind), zap.Error(err))
+		}
+	}
+
+	runErr := fn(runCtx)
+
+	if id != 0 {
+		finishCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), finishTimeout)
+		defer cancel()
+
+		status := StatusSucceeded
+		switch {
+		case runErr == nil:
+		case errors.Is(runErr, context.Canceled):
+			status = StatusCancelled
+		default:
+			status = StatusFailed
+		}
+
+		if err := finish(finishCtx, logger, id, status, runErr, counters.Snapshot()); err != nil {
+			logger.Warn("failed to record job run result", zap.Int64("run_id", id), zap.String("kind", kind), zap.Error(err))
+		}
+	}
+
+	return runErr
+}
+
+// claim inserts the running row of the scheduled run for the firing at
+// firedAt and returns its ID, or ErrAlreadyRan if that firing already has one
+func claim(ctx context.Context, logger *zap.Logger, kind string, firedAt time.Time, params interface{}) (int64, error) {
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return 0, fmt.Errorf("marshal params: %w", err)
+	}
+
+	query := `
+		INSERT INTO job_run (kind, source, params, status, started_at, scheduled_for)
+		VALUES ($1, $2, $3, $4, NOW(), $5)
+		ON CONFLICT (kind, scheduled_for) DO NOTHING
+		RETURNING id
+	`
+	logger.Debug("executing insert query", zap.String("sql", utils.FormatSQL(query, kind, SourceScheduler, string(paramsJSON), StatusRunning, firedAt)))
+
+	var id int64
+	err = database.GetPool().QueryRow(ctx, query, kind, SourceScheduler, string(paramsJSON), StatusRunning, firedAt).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, fmt.Errorf("%s firing at %s: %w", kind, firedAt.Format(time.RFC3339), ErrAlreadyRan)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("claim scheduled run: %w", err)
+	}
+	return id, nil
+}
+
+// start inserts a running row and returns its ID
+func start(ctx context.Context, logger *zap.Logger, kind, source string, params interface{}) (int64, error) {
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return 0, fmt.Errorf("marshal params: %w", err)
+	}
+
+	query := `
+		INSERT INTO job_run (kind, source, params, status, started_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		RETURNING id
+	`
+	logger.Debug("executing insert query", zap.String("sql", utils.FormatSQL(query, kind, source, string(paramsJSON), StatusRunning)))
+
+	var id int64
+	if err := database.GetPool().QueryRow(ctx, query, kind, source, string(paramsJSON), StatusRunning).Scan(&id); err != nil {
+		return 0, fmt.Errorf("insert job run: %w", err)
+	}
+	return id, nil
+}
+
+// finish records the outcome of a run
+func finish(ctx context.Context, logger *zap.Logger, id int64, status string, runErr error, counters map[string]int64) error {
+	countersJSON, err := json.Marshal(counters)
+	if err != nil {
+		return fmt.Errorf("marshal counters: %w", err)
+	}
+
+	var errText *string
+	var chainJSON *string
+	if runErr != nil {
+		text := runErr.Error()
+		errText = &text
+
+		encoded, err := json.Marshal(ErrorChain(runErr))
+		if err != nil {
+			return fmt.Errorf("marshal error chain: %w", err)
+		}
+		chain := string(encoded)
+		chainJSON = &chain
+	}
+
+	query := `
+		UPDATE job_run
+		SET status = $2, error = $3, error_chain = $4, counters = $5, finished_at = NOW()
+		WHERE id = $1
+	`
+	logger.Debug("executing update query", zap.String("sql", utils.FormatSQL(query, id, status, errText, chainJSON, string(countersJSON))))
+
+	if _, err := database.GetPool().Exec(ctx, query, id, status, errText, chainJSON, string(countersJSON)); err != nil {
+		return fmt.Errorf("update job run: %w", err)
+	}
+	return nil
+}
+
+// ErrorChain lists the message of err and of every error it wraps, outermost
+// first. Joined errors are walked depth first.
+func ErrorChain(err error) []string {
+	var chain []string
+	var walk func(error)
+	walk = func(err error) {
+		for err != nil {
+			chain = append(chain, err.Error())
+			if joined, ok := err.(interface{ Unwrap() []error }); ok {
+				for _, inner := range joined.Unwrap() {
+					walk(inner)
+				}
+				return
+			}
+			err = errors.Unwrap(err)
+		}
+	}
+	walk(err)
+	return chain
+}
+
+// ListFilter narrows List results. Zero values match everything.
+type ListFilter struct {
+	Kind   string
+	Source string
+	Status string
+	Since  time.Time
+	Before int64 // Only runs with a smaller ID, for paging
+	Limit  int
+}
+
+// List returns recorded runs matching filter, newest first
+func List(ctx context.Context, logger *zap.Logger, filter ListFilter) ([]Run, error) {
+	var conditions []string
+	var args []interface{}
+	add := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.Kind != "" {
+		add("kind = $%d", filter.Kind)
+	}
+	if filter.Source != "" {
+		add("source = $%d", filter.Source)
+	}
+	if filter.Status != "" {
+		add("status = $%d", filter.Status)
+	}
+	if !filter.Since.IsZero() {
+		add("started_at >= $%d", filter.Since)
+	}
+	if filter.Before > 0 {
+		add("id < $%d", filter.Before)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	args = append(args, limit)
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, kind, source, params, status, error, error_chain, counters, started_at, finished_at
+		FROM job_run
+		%s
+		ORDER BY id DESC
+		LIMIT $%d
+	`, where, len(args))
+	logger.Debug("executing job run query", zap.String("sql", utils.FormatSQL(query, args...)))
+
+	rows, err := database.GetPool().Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query job runs: %w", err)
+	}
+	defer rows.Close()
+
+	runs := make([]Run, 0)
+	for rows.Next() {
+		var run Run
+		var params, chain, counters []byte
+		if err := rows.Scan(&run.ID, &run.Kind, &run.Source, &params, &run.Status, &run.Error,
+			&chain, &counters, &run.StartedAt, &run.FinishedAt); err != nil {
+			return nil, fmt.Errorf("scan job run: %w", err)
+		}
+
+		if len(params) > 0 && string(params) != "null" {
+			run.Params = params
+		}
+		if len(chain) > 0 {
+			if err := json.Unmarshal(chain, &run.ErrorChain); err != nil {
+				return nil, fmt.Errorf("decode error chain of run %d: %w", run.ID, err)
+			}
+		}
+		run.Counters = make(map[string]int64)
+		if len(counters) > 0 {
+			if err := json.Unmarshal(counters, &run.Counters); err != nil {
+				return nil, fmt.Errorf("decode counters of run %d: %w", run.ID, err)
+			}
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, rows.Err()
+}
+
+// SumCounter adds up the named counter of the runs of kind started since
+// since, e.g. to spend a daily budget across runs. Running runs count once
+// they finish, as counters are recorded then.
+func SumCounter(ctx context.Context, logger *zap.Logger, kind, name string, since time.Time) (int64, error) {
+	query := `
+		SELECT COALESCE(SUM((counters->>$2)::bigint), 0)
+		FROM job_run
+		WHERE kind = $1 AND started_at >= $3
+	`
+	logger.Debug("executing job run query", zap.String("sql", utils.FormatSQL(query, kind, name, since)))
+
+	var sum int64
+	if err := database.GetPool().QueryRow(ctx, query, kind, name, since).Scan(&sum); err != nil {
+		return 0, fmt.Errorf("sum job run counter %s: %w", name, err)
+	}
+	return sum, nil
+}
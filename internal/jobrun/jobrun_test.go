@@ -0,0 +1,81 @@
+package jobrun
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestErrorChain(t *testing.T) {
+	base := errors.New("status 503")
+	wrapped := fmt.Errorf("fetch page 3: %w", base)
+	joined := errors.Join(errors.New("save torrents"), wrapped)
+
+	tests := []struct {
+		name     string
+		err      error
+		expected []string
+	}{
+		{name: "nil", err: nil, expected: nil},
+		{name: "single", err: base, expected: []string{"status 503"}},
+		{
+			name:     "wrapped",
+			err:      fmt.Errorf("gallery sync: %w", wrapped),
+			expected: []string{"gallery sync: fetch page 3: status 503", "fetch page 3: status 503", "status 503"},
+		},
+		{
+			name:     "joined",
+			err:      joined,
+			expected: []string{joined.Error(), "save torrents", "fetch page 3: status 503", "status 503"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ErrorChain(tt.err)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Fatalf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestCounters(t *testing.T) {
+	// Add without counters in the context is a no-op
+	Add(context.Background(), BansHit, 1)
+
+	ctx, counters := WithCounters(context.Background())
+	Add(ctx, GalleriesImported, 3)
+	Add(ctx, GalleriesImported, 2)
+	Add(ctx, BansHit, 1)
+	Add(ctx, TorrentsAdded, 0)
+
+	expected := map[string]int64{GalleriesImported: 5, BansHit: 1}
+	if got := counters.Snapshot(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if got := Value(ctx, GalleriesImported); got != 5 {
+		t.Fatalf("expected 5 imported, got %d", got)
+	}
+	if got := Value(context.Background(), GalleriesImported); got != 0 {
+		t.Fatalf("expected 0 without counters, got %d", got)
+	}
+}
+
+func TestLockKey(t *testing.T) {
+	// Every instance and ehdb-sync process must derive the same key for a kind
+	if LockKey("gallery-sync") != LockKey("gallery-sync") {
+		t.Fatalf("expected a stable lock key")
+	}
+
+	seen := make(map[int64]string)
+	for _, kind := range []string{"gallery-sync", "torrent-sync", "resync", "backfill", "fetch", "torrent-import", "mark-replaced"} {
+		key := LockKey(kind)
+		if other, ok := seen[key]; ok {
+			t.Fatalf("expected distinct lock keys, %s and %s share %d", kind, other, key)
+		}
+		seen[key] = kind
+	}
+}
@@ -0,0 +1,72 @@
+package jobrun
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/slinet/ehdb/internal/database"
+	"go.uber.org/zap"
+)
+
+// ErrLocked is returned by Track when a run of the same kind holds the job
+// lock, on this or another instance
+var ErrLocked = errors.New("job is already running")
+
+// ErrAlreadyRan is returned by TrackScheduled when another instance already
+// claimed the cron firing of a scheduled job
+var ErrAlreadyRan = errors.New("scheduled job already ran on another instance")
+
+// lockKeyPrefix namespaces job lock keys among other advisory lock users of the database
+const lockKeyPrefix = "ehdb:job:"
+
+// lock is a held session-level advisory lock for one job kind. The lock lives
+// as long as the connection, so it is released even if the process dies.
+type lock struct {
+	conn   *pgxpool.Conn
+	key    int64
+	kind   string
+	logger *zap.Logger
+}
+
+// LockKey returns the Postgres advisory lock key of a job kind
+func LockKey(kind string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(lockKeyPrefix + kind))
+	return int64(h.Sum64())
+}
+
+// tryLock takes the advisory lock of kind without waiting. It returns
+// ErrLocked if another session holds it.
+func tryLock(ctx context.Context, logger *zap.Logger, kind string) (*lock, error) {
+	conn, err := database.GetPool().Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("acquire connection for job lock: %w", err)
+	}
+
+	key := LockKey(kind)
+	var acquired bool
+	if err := conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&acquired); err != nil {
+		conn.Release()
+		return nil, fmt.Errorf("take job lock %s: %w", kind, err)
+	}
+	if !acquired {
+		conn.Release()
+		return nil, fmt.Errorf("%s: %w", kind, ErrLocked)
+	}
+
+	logger.Debug("job lock acquired", zap.String("kind", kind), zap.Int64("lock_key", key))
+	return &lock{conn: conn, key: key, kind: kind, logger: logger}, nil
+}
+
+// release unlocks and returns the connection to the pool. If unlocking
+// fails the connection is closed instead, which drops the lock with it.
+func (l *lock) release(ctx context.Context) {
+	if _, err := l.conn.Exec(ctx, "SELECT pg_advisory_unlock($1)", l.key); err != nil {
+		l.logger.Warn("failed to release job lock, closing its connection", zap.String("kind", l.kind), zap.Error(err))
+		_ = l.conn.Conn().Close(ctx)
+	}
+	l.conn.Release()
+}
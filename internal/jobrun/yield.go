@@ -0,0 +1,29 @@
+package jobrun
+
+import "context"
+
+// YieldFunc blocks while the run should give way to more important work and
+// returns early with ctx's error if ctx is done
+type YieldFunc func(ctx context.Context) error
+
+type yieldKey struct{}
+
+// WithYield returns a context whose runs give way through fn at Yield points
+func WithYield(ctx context.Context, fn YieldFunc) context.Context {
+	return context.WithValue(ctx, yieldKey{}, fn)
+}
+
+// Yield is a point between units of work (pages, metadata batches) where a
+// long run can pause for a higher priority one. It returns ctx's error if
+// ctx is done, and otherwise does nothing when ctx carries no YieldFunc, so
+// crawler code can call it unconditionally.
+func Yield(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	fn, ok := ctx.Value(yieldKey{}).(YieldFunc)
+	if !ok {
+		return nil
+	}
+	return fn(ctx)
+}
@@ -0,0 +1,79 @@
+package jobs
+
+import "go.uber.org/zap/zapcore"
+
+// captureCore is a zap core that records a job's log entries. Entries at
+// info level and above are kept as logs; entries of any level that carry
+// numeric fields (page, from, to, count, ...) update the job's progress.
+type captureCore struct {
+	zapcore.LevelEnabler
+	job    *job
+	fields []zapcore.Field
+}
+
+func (c *captureCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = append(append([]zapcore.Field(nil), c.fields...), fields...)
+	return &clone
+}
+
+func (c *captureCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *captureCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, field := range c.fields {
+		field.AddTo(enc)
+	}
+	for _, field := range fields {
+		field.AddTo(enc)
+	}
+
+	c.job.record(entry, enc.Fields)
+	return nil
+}
+
+func (c *captureCore) Sync() error {
+	return nil
+}
+
+// record stores a log entry and updates progress from its numeric fields
+func (j *job) record(entry zapcore.Entry, fields map[string]interface{}) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	numeric := make(map[string]interface{})
+	for key, value := range fields {
+		switch value.(type) {
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+			numeric[key] = value
+		}
+	}
+	if len(numeric) > 0 {
+		j.info.Progress = &Progress{Message: entry.Message, Fields: numeric, UpdatedAt: entry.Time}
+	}
+
+	if entry.Level < zapcore.InfoLevel {
+		return
+	}
+
+	j.info.LogCount++
+	if len(fields) == 0 {
+		fields = nil
+	}
+	j.logs = append(j.logs, LogEntry{
+		Seq:     j.info.LogCount,
+		Time:    entry.Time,
+		Level:   entry.Level.String(),
+		Message: entry.Message,
+		Fields:  fields,
+	})
+	if len(j.logs) > maxLogEntries {
+		copy(j.logs, j.logs[1:])
+		j.logs = j.logs[:maxLogEntries]
+	}
+}
@@ -0,0 +1,420 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Status is the lifecycle state of a job
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusCancelled Status = "cancelled"
+)
+
+const (
+	// maxLogEntries is the number of log entries kept per job
+	maxLogEntries = 1000
+	// maxFinishedJobs is the number of finished jobs kept for inspection
+	maxFinishedJobs = 100
+	// queueSize is the number of jobs that can wait for the worker
+	queueSize = 100
+)
+
+// Errors returned by Manager
+var (
+	ErrNotFound  = errors.New("job not found")
+	ErrQueueFull = errors.New("job queue is full")
+	ErrFinished  = errors.New("job already finished")
+	ErrClosed    = errors.New("job manager is closed")
+)
+
+// RunFunc performs the work of a job. The logger captures the job's logs and
+// progress; ctx is cancelled when the job is cancelled.
+type RunFunc func(ctx context.Context, logger *zap.Logger) error
+
+// Job is a snapshot of a submitted job
+type Job struct {
+	ID              string      `json:"id"`
+	Kind            string      `json:"kind"`
+	Params          interface{} `json:"params,omitempty"`
+	Status          Status      `json:"status"`
+	Error           string      `json:"error,omitempty"`
+	CancelRequested bool        `json:"cancel_requested"`
+	Progress        *Progress   `json:"progress,omitempty"`
+	LogCount        int64       `json:"log_count"`
+	CreatedAt       time.Time   `json:"created_at"`
+	StartedAt       *time.Time  `json:"started_at,omitempty"`
+	FinishedAt      *time.Time  `json:"finished_at,omitempty"`
+}
+
+// Progress is the latest numeric state reported by the job's log entries,
+// e.g. {"message": "fetching metadata batch", "fields": {"from": 50, "to": 75}}
+type Progress struct {
+	Message   string                 `json:"message"`
+	Fields    map[string]interface{} `json:"fields"`
+	UpdatedAt time.Time              `json:"updated_at"`
+}
+
+// LogEntry is one captured log line. Seq increases by one per entry so
+// clients can poll for entries after the last one they saw.
+type LogEntry struct {
+	Seq     int64                  `json:"seq"`
+	Time    time.Time              `json:"time"`
+	Level   string                 `json:"level"`
+	Message string                 `json:"message"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+}
+
+// job is the mutable state behind a Job snapshot
+type job struct {
+	mu       sync.Mutex
+	info     Job
+	logs     []LogEntry
+	run      RunFunc
+	ctx      context.Context
+	cancel   context.CancelFunc
+	finished chan struct{}
+}
+
+// Manager runs submitted jobs one at a time in submission order and keeps
+// their state, progress and logs in memory
+type Manager struct {
+	logger *zap.Logger
+
+	mu       sync.Mutex
+	jobs     map[string]*job
+	order    []string // Job IDs in submission order
+	queue    chan *job
+	closed   bool
+	ctx      context.Context
+	cancel   context.CancelFunc
+	workerWg sync.WaitGroup
+}
+
+// NewManager creates a job manager and starts its worker
+func NewManager(logger *zap.Logger) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &Manager{
+		logger: logger,
+		jobs:   make(map[string]*job),
+		queue:  make(chan *job, queueSize),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	m.workerWg.Add(1)
+	go m.worker()
+
+	return m
+}
+
+// Submit queues a job of kind with params (included in snapshots) and returns its snapshot
+func (m *Manager) Submit(kind string, params interface{}, run RunFunc) (Job, error) {
+	id, err := newID()
+	if err != nil {
+		return Job{}, fmt.Errorf("generate job id: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(m.ctx)
+	j := &job{
+		info: Job{
+			ID:        id,
+			Kind:      kind,
+			Params:    params,
+			Status:    StatusQueued,
+			CreatedAt: time.Now(),
+		},
+		run:      run,
+		ctx:      ctx,
+		cancel:   cancel,
+		finished: make(chan struct{}),
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		cancel()
+		return Job{}, ErrClosed
+	}
+
+	select {
+	case m.queue <- j:
+	default:
+		cancel()
+		return Job{}, ErrQueueFull
+	}
+
+	m.jobs[id] = j
+	m.order = append(m.order, id)
+	m.pruneLocked()
+
+	m.logger.Info("job queued", zap.String("job_id", id), zap.String("job_kind", kind))
+	return j.snapshot(), nil
+}
+
+// Get returns the snapshot of a job
+func (m *Manager) Get(id string) (Job, error) {
+	j, err := m.lookup(id)
+	if err != nil {
+		return Job{}, err
+	}
+	return j.snapshot(), nil
+}
+
+// List returns snapshots of all known jobs, newest first
+func (m *Manager) List() []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make([]Job, 0, len(m.order))
+	for i := len(m.order) - 1; i >= 0; i-- {
+		result = append(result, m.jobs[m.order[i]].snapshot())
+	}
+	return result
+}
+
+// Logs returns the captured log entries of a job with Seq greater than since
+func (m *Manager) Logs(id string, since int64) ([]LogEntry, error) {
+	j, err := m.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	result := make([]LogEntry, 0)
+	for _, entry := range j.logs {
+		if entry.Seq > since {
+			result = append(result, entry)
+		}
+	}
+	return result, nil
+}
+
+// Cancel cancels a queued or running job. A queued job is cancelled
+// immediately; a running job stops at the next point where its operation
+// checks its context.
+func (m *Manager) Cancel(id string) (Job, error) {
+	j, err := m.lookup(id)
+	if err != nil {
+		return Job{}, err
+	}
+
+	j.mu.Lock()
+	switch j.info.Status {
+	case StatusQueued:
+		now := time.Now()
+		j.info.Status = StatusCancelled
+		j.info.CancelRequested = true
+		j.info.FinishedAt = &now
+		close(j.finished)
+	case StatusRunning:
+		j.info.CancelRequested = true
+	default:
+		j.mu.Unlock()
+		return j.snapshot(), ErrFinished
+	}
+	j.mu.Unlock()
+
+	j.cancel()
+	m.logger.Info("job cancel requested", zap.String("job_id", id))
+	return j.snapshot(), nil
+}
+
+// Wait blocks until the job finishes or ctx is done
+func (m *Manager) Wait(ctx context.Context, id string) (Job, error) {
+	j, err := m.lookup(id)
+	if err != nil {
+		return Job{}, err
+	}
+
+	select {
+	case <-j.finished:
+		return j.snapshot(), nil
+	case <-ctx.Done():
+		return j.snapshot(), ctx.Err()
+	}
+}
+
+// Close cancels all queued and running jobs and waits for the worker to
+// exit, or until ctx is done if the running job is slow to stop
+func (m *Manager) Close(ctx context.Context) error {
+	m.mu.Lock()
+	if !m.closed {
+		m.closed = true
+		close(m.queue)
+	}
+	m.mu.Unlock()
+
+	m.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		m.workerWg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (m *Manager) lookup(id string) (*job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return j, nil
+}
+
+// pruneLocked drops the oldest finished jobs beyond maxFinishedJobs
+func (m *Manager) pruneLocked() {
+	finished := 0
+	for _, id := range m.order {
+		if m.jobs[id].isFinished() {
+			finished++
+		}
+	}
+
+	kept := m.order[:0]
+	for _, id := range m.order {
+		if finished > maxFinishedJobs && m.jobs[id].isFinished() {
+			delete(m.jobs, id)
+			finished--
+			continue
+		}
+		kept = append(kept, id)
+	}
+	m.order = kept
+}
+
+func (m *Manager) worker() {
+	defer m.workerWg.Done()
+
+	for j := range m.queue {
+		m.runJob(j)
+	}
+}
+
+// runJob runs a queued job unless it was cancelled while waiting
+func (m *Manager) runJob(j *job) {
+	j.mu.Lock()
+	if j.info.Status != StatusQueued {
+		j.mu.Unlock()
+		return
+	}
+	if err := j.ctx.Err(); err != nil {
+		// Manager closed while the job was queued
+		now := time.Now()
+		j.info.Status = StatusCancelled
+		j.info.FinishedAt = &now
+		close(j.finished)
+		j.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	j.info.Status = StatusRunning
+	j.info.StartedAt = &now
+	id, kind := j.info.ID, j.info.Kind
+	j.mu.Unlock()
+
+	logger := m.logger.With(zap.String("job_id", id), zap.String("job_kind", kind)).
+		WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return zapcore.NewTee(core, &captureCore{LevelEnabler: zapcore.DebugLevel, job: j})
+		}))
+
+	logger.Info("job started")
+	err := m.safeRun(j, logger)
+
+	j.mu.Lock()
+	finishedAt := time.Now()
+	j.info.FinishedAt = &finishedAt
+	switch {
+	case err == nil:
+		j.info.Status = StatusSucceeded
+	case j.ctx.Err() != nil:
+		j.info.Status = StatusCancelled
+		j.info.Error = err.Error()
+	default:
+		j.info.Status = StatusFailed
+		j.info.Error = err.Error()
+	}
+	status := j.info.Status
+	j.mu.Unlock()
+
+	if err != nil {
+		logger.Error("job finished", zap.String("status", string(status)), zap.Error(err))
+	} else {
+		logger.Info("job finished", zap.String("status", string(status)))
+	}
+	j.cancel()
+	close(j.finished)
+
+	m.mu.Lock()
+	m.pruneLocked()
+	m.mu.Unlock()
+}
+
+// safeRun runs the job and turns a panic into an error so one bad job does not stop the worker
+func (m *Manager) safeRun(j *job, logger *zap.Logger) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return j.run(j.ctx, logger)
+}
+
+func (j *job) snapshot() Job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	info := j.info
+	if info.Progress != nil {
+		progress := *info.Progress
+		info.Progress = &progress
+	}
+	return info
+}
+
+func (j *job) isFinished() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	switch j.info.Status {
+	case StatusSucceeded, StatusFailed, StatusCancelled:
+		return true
+	default:
+		return false
+	}
+}
+
+// newID returns a random 16 character hex job ID
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,149 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func waitJob(t *testing.T, m *Manager, id string) Job {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	job, err := m.Wait(ctx, id)
+	if err != nil {
+		t.Fatalf("expected job %s to finish, got %v", id, err)
+	}
+	return job
+}
+
+func TestManagerCapturesLogsAndProgress(t *testing.T) {
+	m := NewManager(zap.NewNop())
+	defer m.Close(context.Background())
+
+	submitted, err := m.Submit("test", map[string]int{"hours": 1}, func(ctx context.Context, logger *zap.Logger) error {
+		logger.Debug("fetching metadata batch", zap.Int("from", 25), zap.Int("to", 50))
+		logger.Info("found galleries", zap.Int("count", 3), zap.String("source", "list"))
+		logger.Debug("executing query", zap.String("sql", "SELECT 1"))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected submit to succeed, got %v", err)
+	}
+	if submitted.Status != StatusQueued {
+		t.Fatalf("expected status %q, got %q", StatusQueued, submitted.Status)
+	}
+
+	job := waitJob(t, m, submitted.ID)
+	if job.Status != StatusSucceeded {
+		t.Fatalf("expected status %q, got %q", StatusSucceeded, job.Status)
+	}
+	if job.Progress == nil || job.Progress.Message != "found galleries" || job.Progress.Fields["count"] != int64(3) {
+		t.Fatalf("expected progress from the last numeric entry, got %+v", job.Progress)
+	}
+
+	logs, err := m.Logs(job.ID, 0)
+	if err != nil {
+		t.Fatalf("expected logs, got %v", err)
+	}
+	// job started, found galleries, job finished; debug entries are not kept
+	if len(logs) != 3 || logs[1].Message != "found galleries" || logs[1].Fields["source"] != "list" {
+		t.Fatalf("expected 3 info entries, got %+v", logs)
+	}
+
+	later, err := m.Logs(job.ID, logs[1].Seq)
+	if err != nil {
+		t.Fatalf("expected logs, got %v", err)
+	}
+	if len(later) != 1 || later[0].Message != "job finished" {
+		t.Fatalf("expected only the last entry after seq %d, got %+v", logs[1].Seq, later)
+	}
+}
+
+func TestManagerFailedJob(t *testing.T) {
+	m := NewManager(zap.NewNop())
+	defer m.Close(context.Background())
+
+	submitted, err := m.Submit("test", nil, func(ctx context.Context, logger *zap.Logger) error {
+		return errors.New("upstream unavailable")
+	})
+	if err != nil {
+		t.Fatalf("expected submit to succeed, got %v", err)
+	}
+
+	job := waitJob(t, m, submitted.ID)
+	if job.Status != StatusFailed || job.Error != "upstream unavailable" {
+		t.Fatalf("expected failed job with error, got %+v", job)
+	}
+
+	if _, err := m.Cancel(job.ID); !errors.Is(err, ErrFinished) {
+		t.Fatalf("expected ErrFinished, got %v", err)
+	}
+}
+
+func TestManagerCancel(t *testing.T) {
+	m := NewManager(zap.NewNop())
+	defer m.Close(context.Background())
+
+	started := make(chan struct{})
+	running, err := m.Submit("test", nil, func(ctx context.Context, logger *zap.Logger) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err != nil {
+		t.Fatalf("expected submit to succeed, got %v", err)
+	}
+
+	ran := false
+	queued, err := m.Submit("test", nil, func(ctx context.Context, logger *zap.Logger) error {
+		ran = true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected submit to succeed, got %v", err)
+	}
+
+	<-started
+
+	// The second job waits behind the first and is cancelled without running
+	job, err := m.Cancel(queued.ID)
+	if err != nil {
+		t.Fatalf("expected cancel to succeed, got %v", err)
+	}
+	if job.Status != StatusCancelled {
+		t.Fatalf("expected queued job to be cancelled at once, got %q", job.Status)
+	}
+
+	job, err = m.Cancel(running.ID)
+	if err != nil {
+		t.Fatalf("expected cancel to succeed, got %v", err)
+	}
+	if !job.CancelRequested {
+		t.Fatalf("expected cancel_requested on the running job")
+	}
+
+	job = waitJob(t, m, running.ID)
+	if job.Status != StatusCancelled {
+		t.Fatalf("expected status %q, got %q", StatusCancelled, job.Status)
+	}
+
+	// Submit a final job to make sure the worker has moved past the cancelled one
+	last, err := m.Submit("test", nil, func(ctx context.Context, logger *zap.Logger) error { return nil })
+	if err != nil {
+		t.Fatalf("expected submit to succeed, got %v", err)
+	}
+	waitJob(t, m, last.ID)
+	if ran {
+		t.Fatalf("expected cancelled queued job not to run")
+	}
+
+	if _, err := m.Get("missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
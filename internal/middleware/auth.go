@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/slinet/ehdb/pkg/utils"
+)
+
+// AdminAuth returns a middleware that requires "Authorization: Bearer <token>"
+func AdminAuth(token string) gin.HandlerFunc {
+	expected := []byte(token)
+	return func(c *gin.Context) {
+		provided, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || token == "" || subtle.ConstantTimeCompare([]byte(provided), expected) != 1 {
+			c.JSON(401, utils.GetResponse(nil, 401, "unauthorized", nil))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,268 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/slinet/ehdb/internal/jobrun"
+	"go.uber.org/zap"
+)
+
+// Policy decides what happens when a job fires while its previous run is still going
+type Policy string
+
+const (
+	// PolicySkip drops the new firing
+	PolicySkip Policy = "skip"
+	// PolicyQueue keeps one firing to run after the current run; further firings are dropped
+	PolicyQueue Policy = "queue"
+	// PolicyParallel starts the new firing alongside the current run
+	PolicyParallel Policy = "parallel"
+)
+
+// ParsePolicy parses a policy name from config, defaulting to PolicySkip
+func ParsePolicy(value string) (Policy, error) {
+	switch Policy(value) {
+	case "", PolicySkip:
+		return PolicySkip, nil
+	case PolicyQueue:
+		return PolicyQueue, nil
+	case PolicyParallel:
+		return PolicyParallel, nil
+	default:
+		return "", fmt.Errorf("unknown policy %q (want skip, queue or parallel)", value)
+	}
+}
+
+// JobStats is a snapshot of one scheduled job's counters since the scheduler started
+type JobStats struct {
+	Kind     string `json:"kind"`
+	Cron     string `json:"cron"`
+	Policy   Policy `json:"policy"`
+	Priority int    `json:"priority"`
+	Running  int    `json:"running"`
+	Queued   bool   `json:"queued"`
+	// Started counts runs that started on this instance
+	Started int64 `json:"started"`
+	// Skipped counts firings dropped by the policy or because another
+	// instance or ehdb-sync process ran the job
+	Skipped int64 `json:"skipped"`
+	// Delayed counts firings queued behind the previous run or held back
+	// by a higher priority job before starting
+	Delayed int64 `json:"delayed"`
+	// Yielded counts pauses of a running job for a higher priority job
+	Yielded       int64      `json:"yielded"`
+	Failed        int64      `json:"failed"`
+	LastStartedAt *time.Time `json:"last_started_at,omitempty"`
+}
+
+// trackFunc records and locks the run of one firing; jobrun.TrackScheduled outside of tests
+type trackFunc func(ctx context.Context, logger *zap.Logger, kind string, firedAt time.Time, params interface{}, fn func(ctx context.Context) error) error
+
+// job is one registered cron job with its policy and counters
+type job struct {
+	name     string // Human readable name used in logs, e.g. "gallery sync"
+	kind     string // Job run kind, e.g. "gallery-sync"
+	cron     string
+	policy   Policy
+	priority int
+	params   func() interface{}
+	run      func(ctx context.Context) error
+	track    trackFunc
+
+	logger *zap.Logger
+	gate   *gate
+
+	mu       sync.Mutex
+	stats    JobStats
+	queuedAt time.Time // Firing time of the queued run
+}
+
+// fire handles the cron firing scheduled at firedAt according to the job's policy
+func (j *job) fire(ctx context.Context, firedAt time.Time) {
+	j.mu.Lock()
+	if j.stats.Running > 0 {
+		switch j.policy {
+		case PolicySkip:
+			j.stats.Skipped++
+			j.mu.Unlock()
+			j.logger.Warn(j.name+" skipped, previous run is still running", zap.String("policy", string(j.policy)))
+			return
+		case PolicyQueue:
+			if j.stats.Queued {
+				j.stats.Skipped++
+				j.mu.Unlock()
+				j.logger.Warn(j.name+" skipped, a run is already queued", zap.String("policy", string(j.policy)))
+				return
+			}
+			j.stats.Queued = true
+			j.queuedAt = firedAt
+			j.stats.Delayed++
+			j.mu.Unlock()
+			j.logger.Info(j.name+" delayed until the previous run finishes", zap.String("policy", string(j.policy)))
+			return
+		}
+	}
+	j.stats.Running++
+	j.mu.Unlock()
+
+	for {
+		j.runOnce(ctx, firedAt)
+
+		// Run the queued firing, if any, now that this run is done
+		j.mu.Lock()
+		if !j.stats.Queued || ctx.Err() != nil {
+			j.stats.Queued = false
+			j.stats.Running--
+			j.mu.Unlock()
+			return
+		}
+		j.stats.Queued = false
+		firedAt = j.queuedAt
+		j.mu.Unlock()
+	}
+}
+
+// runOnce waits for higher priority jobs to finish, then runs the firing
+// scheduled at firedAt unless another instance already ran it
+func (j *job) runOnce(ctx context.Context, firedAt time.Time) {
+	if j.gate.higherRunning(j.priority) {
+		j.mu.Lock()
+		j.stats.Delayed++
+		j.mu.Unlock()
+		j.logger.Info(j.name+" delayed by a higher priority job", zap.Int("priority", j.priority))
+		if err := j.gate.wait(ctx, j.priority); err != nil {
+			return
+		}
+	}
+
+	j.gate.enter(j.priority)
+	defer j.gate.leave(j.priority)
+
+	runCtx := jobrun.WithYield(ctx, j.yield)
+
+	params := j.params()
+	j.logger.Info("starting scheduled "+j.name, zap.Any("params", params))
+	err := j.track(runCtx, j.logger, j.kind, firedAt, params, func(ctx context.Context) error {
+		now := time.Now()
+		j.mu.Lock()
+		j.stats.Started++
+		j.stats.LastStartedAt = &now
+		j.mu.Unlock()
+
+		return j.run(ctx)
+	})
+	switch {
+	case err == nil:
+		j.logger.Info(j.name + " completed")
+	case errors.Is(err, jobrun.ErrLocked) || errors.Is(err, jobrun.ErrAlreadyRan):
+		j.mu.Lock()
+		j.stats.Skipped++
+		j.mu.Unlock()
+		j.logger.Info(j.name+" skipped", zap.String("reason", err.Error()))
+	default:
+		j.mu.Lock()
+		j.stats.Failed++
+		j.mu.Unlock()
+		j.logger.Error(j.name+" failed", zap.Error(err))
+	}
+}
+
+// yield is the job's jobrun.YieldFunc: it pauses while a higher priority job runs
+func (j *job) yield(ctx context.Context) error {
+	if !j.gate.higherRunning(j.priority) {
+		return nil
+	}
+
+	j.mu.Lock()
+	j.stats.Yielded++
+	j.mu.Unlock()
+
+	j.logger.Info(j.name+" paused for a higher priority job", zap.Int("priority", j.priority))
+	started := time.Now()
+	if err := j.gate.wait(ctx, j.priority); err != nil {
+		return err
+	}
+	j.logger.Info(j.name+" resumed", zap.Duration("paused", time.Since(started)))
+	return nil
+}
+
+func (j *job) snapshot() JobStats {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.stats
+}
+
+// gate tracks the priorities of running jobs so lower priority jobs can wait for higher ones
+type gate struct {
+	mu      sync.Mutex
+	running map[int]int   // Priority -> number of running jobs
+	changed chan struct{} // Closed and replaced whenever running changes
+}
+
+func newGate() *gate {
+	return &gate{
+		running: make(map[int]int),
+		changed: make(chan struct{}),
+	}
+}
+
+func (g *gate) enter(priority int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.running[priority]++
+	g.notifyLocked()
+}
+
+func (g *gate) leave(priority int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.running[priority]--
+	if g.running[priority] == 0 {
+		delete(g.running, priority)
+	}
+	g.notifyLocked()
+}
+
+func (g *gate) notifyLocked() {
+	close(g.changed)
+	g.changed = make(chan struct{})
+}
+
+// higherRunning reports whether a job with a priority above priority is running
+func (g *gate) higherRunning(priority int) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.higherRunningLocked(priority)
+}
+
+func (g *gate) higherRunningLocked(priority int) bool {
+	for p := range g.running {
+		if p > priority {
+			return true
+		}
+	}
+	return false
+}
+
+// wait blocks until no job with a priority above priority is running or ctx is done
+func (g *gate) wait(ctx context.Context, priority int) error {
+	for {
+		g.mu.Lock()
+		if !g.higherRunningLocked(priority) {
+			g.mu.Unlock()
+			return nil
+		}
+		changed := g.changed
+		g.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package scheduler
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/slinet/ehdb/internal/jobrun"
+	"go.uber.org/zap"
+)
+
+// mockFiring is the cron firing time the tests fire jobs with
+var mockFiring = time.Date(2026, 3, 30, 12, 0, 0, 0, time.UTC)
+
+// untracked runs fn directly instead of locking and recording it in the database
+func untracked(ctx context.Context, logger *zap.Logger, kind string, firedAt time.Time, params interface{}, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func newTestJob(g *gate, policy Policy, priority int, run func(ctx context.Context) error) *job {
+	return &job{
+		name:     "test",
+		kind:     "test",
+		policy:   policy,
+		priority: priority,
+		params:   func() interface{} { return nil },
+		run:      run,
+		track:    untracked,
+		logger:   zap.NewNop(),
+		gate:     g,
+		stats:    JobStats{Policy: policy, Priority: priority},
+	}
+}
+
+// blockingRun returns a run func that signals started and blocks until release is closed
+func blockingRun(started chan<- struct{}, release <-chan struct{}) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}
+}
+
+func waitSignal(t *testing.T, ch <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected %s", what)
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected Policy
+		wantErr  bool
+	}{
+		{value: "", expected: PolicySkip},
+		{value: "skip", expected: PolicySkip},
+		{value: "queue", expected: PolicyQueue},
+		{value: "parallel", expected: PolicyParallel},
+		{value: "wait", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParsePolicy(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %q", got)
+				}
+				return
+			}
+			if err != nil || got != tt.expected {
+				t.Fatalf("expected %q, got %q (%v)", tt.expected, got, err)
+			}
+		})
+	}
+}
+
+func TestJobPolicySkip(t *testing.T) {
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	j := newTestJob(newGate(), PolicySkip, 0, blockingRun(started, release))
+
+	done := make(chan struct{})
+	go func() {
+		j.fire(context.Background(), mockFiring)
+		close(done)
+	}()
+	waitSignal(t, started, "first run to start")
+
+	j.fire(context.Background(), mockFiring)
+	close(release)
+	waitSignal(t, done, "first run to finish")
+
+	stats := j.snapshot()
+	if stats.Started != 1 || stats.Skipped != 1 || stats.Running != 0 {
+		t.Fatalf("expected 1 started and 1 skipped, got %+v", stats)
+	}
+}
+
+func TestJobPolicyQueue(t *testing.T) {
+	started := make(chan struct{}, 3)
+	release := make(chan struct{})
+	j := newTestJob(newGate(), PolicyQueue, 0, blockingRun(started, release))
+	var firings []time.Time
+	j.track = func(ctx context.Context, logger *zap.Logger, kind string, firedAt time.Time, params interface{}, fn func(ctx context.Context) error) error {
+		firings = append(firings, firedAt)
+		return fn(ctx)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		j.fire(context.Background(), mockFiring)
+		close(done)
+	}()
+	waitSignal(t, started, "first run to start")
+
+	// The first extra firing is queued, the second is dropped
+	j.fire(context.Background(), mockFiring.Add(time.Minute))
+	j.fire(context.Background(), mockFiring.Add(2*time.Minute))
+	close(release)
+	waitSignal(t, done, "queued run to finish")
+
+	stats := j.snapshot()
+	if stats.Started != 2 || stats.Delayed != 1 || stats.Skipped != 1 || stats.Queued {
+		t.Fatalf("expected 2 started, 1 delayed and 1 skipped, got %+v", stats)
+	}
+
+	// The queued run claims the firing it was queued for
+	expected := []time.Time{mockFiring, mockFiring.Add(time.Minute)}
+	if !slices.Equal(firings, expected) {
+		t.Fatalf("expected runs for firings %v, got %v", expected, firings)
+	}
+}
+
+func TestJobYieldsToHigherPriority(t *testing.T) {
+	g := newGate()
+
+	highStarted := make(chan struct{}, 1)
+	highRelease := make(chan struct{})
+	high := newTestJob(g, PolicySkip, 10, blockingRun(highStarted, highRelease))
+
+	lowAtYield := make(chan struct{}, 1)
+	lowResumed := make(chan struct{})
+	low := newTestJob(g, PolicySkip, 0, func(ctx context.Context) error {
+		lowAtYield <- struct{}{}
+		<-highStarted // Let the high priority job start before the yield point
+		if err := jobrun.Yield(ctx); err != nil {
+			return err
+		}
+		close(lowResumed)
+		return nil
+	})
+
+	lowDone := make(chan struct{})
+	go func() {
+		low.fire(context.Background(), mockFiring)
+		close(lowDone)
+	}()
+	waitSignal(t, lowAtYield, "low priority run to start")
+
+	highDone := make(chan struct{})
+	go func() {
+		high.fire(context.Background(), mockFiring)
+		close(highDone)
+	}()
+
+	// The low priority job stays paused while the high priority one runs
+	select {
+	case <-lowResumed:
+		t.Fatalf("expected low priority job to pause while the high priority job runs")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(highRelease)
+	waitSignal(t, highDone, "high priority run to finish")
+	waitSignal(t, lowDone, "low priority run to resume and finish")
+
+	if stats := low.snapshot(); stats.Yielded != 1 {
+		t.Fatalf("expected 1 yield, got %+v", stats)
+	}
+}
@@ -2,111 +2,165 @@ package scheduler
 
 import (
 	"context"
-	"sync"
+	"fmt"
+	"time"
 
 	"github.com/robfig/cron/v3"
 	"github.com/slinet/ehdb/internal/config"
 	"github.com/slinet/ehdb/internal/crawler"
+	"github.com/slinet/ehdb/internal/jobrun"
 	"go.uber.org/zap"
 )
 
-// Scheduler manages scheduled tasks
+// Scheduler manages scheduled tasks. Each job runs independently under its
+// own concurrency policy; lower priority jobs pause for higher priority ones.
 type Scheduler struct {
 	cron   *cron.Cron
 	cfg    *config.Config
 	logger *zap.Logger
-	mu     sync.Mutex
+	gate   *gate
+	jobs   []*job
+
+	// ctx is passed to every run and cancelled by Stop
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 // New creates a new scheduler
 func New(cfg *config.Config, logger *zap.Logger) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Scheduler{
 		cron:   cron.New(),
 		cfg:    cfg,
 		logger: logger,
+		gate:   newGate(),
+		ctx:    ctx,
+		cancel: cancel,
 	}
 }
 
 // Start starts the scheduler
 func (s *Scheduler) Start() error {
+	sc := s.cfg.Scheduler
+
 	// Gallery sync
-	if s.cfg.Scheduler.GallerySyncEnabled {
-		_, err := s.cron.AddFunc(s.cfg.Scheduler.GallerySyncCron, func() {
-			s.mu.Lock()
-			defer s.mu.Unlock()
-
-			s.logger.Info("starting scheduled gallery sync", zap.Int("offset", s.cfg.Scheduler.GallerySyncOffset))
-			if err := s.syncGalleries(); err != nil {
-				s.logger.Error("gallery sync failed", zap.Error(err))
-			}
-			s.logger.Info("gallery sync completed")
-		})
+	if sc.GallerySyncEnabled {
+		err := s.register("gallery sync", "gallery-sync", sc.GallerySyncCron, sc.GallerySyncPolicy, sc.GallerySyncPriority,
+			func() interface{} { return map[string]int{"offset": sc.GallerySyncOffset} }, s.syncGalleries)
 		if err != nil {
 			return err
 		}
-		s.logger.Info("gallery sync task registered",
-			zap.String("cron", s.cfg.Scheduler.GallerySyncCron),
-			zap.Int("offset", s.cfg.Scheduler.GallerySyncOffset))
 	} else {
 		s.logger.Info("gallery sync task is disabled")
 	}
 
 	// Torrent sync
-	if s.cfg.Scheduler.TorrentSyncEnabled {
-		_, err := s.cron.AddFunc(s.cfg.Scheduler.TorrentSyncCron, func() {
-			s.mu.Lock()
-			defer s.mu.Unlock()
-
-			s.logger.Info("starting scheduled torrent sync")
-			if err := s.syncTorrents(); err != nil {
-				s.logger.Error("torrent sync failed", zap.Error(err))
-			}
-			s.logger.Info("torrent sync completed")
-		})
+	if sc.TorrentSyncEnabled {
+		err := s.register("torrent sync", "torrent-sync", sc.TorrentSyncCron, sc.TorrentSyncPolicy, sc.TorrentSyncPriority,
+			func() interface{} { return nil }, s.syncTorrents)
 		if err != nil {
 			return err
 		}
-		s.logger.Info("torrent sync task registered", zap.String("cron", s.cfg.Scheduler.TorrentSyncCron))
 	} else {
 		s.logger.Info("torrent sync task is disabled")
 	}
 
 	// Resync
-	if s.cfg.Scheduler.ResyncEnabled {
-		_, err := s.cron.AddFunc(s.cfg.Scheduler.ResyncCron, func() {
-			s.mu.Lock()
-			defer s.mu.Unlock()
-
-			s.logger.Info("starting scheduled resync", zap.Int("hours", s.cfg.Scheduler.ResyncHours))
-			if err := s.resyncGalleries(); err != nil {
-				s.logger.Error("resync failed", zap.Error(err))
-			}
-			s.logger.Info("resync completed")
-		})
+	if sc.ResyncEnabled {
+		err := s.register("resync", "resync", sc.ResyncCron, sc.ResyncPolicy, sc.ResyncPriority,
+			func() interface{} { return map[string]int{"hours": sc.ResyncHours} }, s.resyncGalleries)
 		if err != nil {
 			return err
 		}
-		s.logger.Info("resync task registered",
-			zap.String("cron", s.cfg.Scheduler.ResyncCron),
-			zap.Int("hours", s.cfg.Scheduler.ResyncHours))
 	} else {
 		s.logger.Info("resync task is disabled")
 	}
 
+	// Refresh
+	if sc.RefreshEnabled {
+		err := s.register("refresh", crawler.RefreshJobKind, sc.RefreshCron, sc.RefreshPolicy, sc.RefreshPriority,
+			func() interface{} {
+				return map[string]int{"galleries": sc.RefreshGalleries, "daily_budget": sc.RefreshDailyBudget}
+			}, s.refreshGalleries)
+		if err != nil {
+			return err
+		}
+	} else {
+		s.logger.Info("refresh task is disabled")
+	}
+
 	s.cron.Start()
 	s.logger.Info("scheduler started")
 
 	return nil
 }
 
-// Stop stops the scheduler
-func (s *Scheduler) Stop() {
-	s.cron.Stop()
-	s.logger.Info("scheduler stopped")
+// register adds a cron job with its concurrency policy and priority
+func (s *Scheduler) register(name, kind, spec, policyName string, priority int, params func() interface{}, run func(ctx context.Context) error) error {
+	policy, err := ParsePolicy(policyName)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	j := &job{
+		name:     name,
+		kind:     kind,
+		cron:     spec,
+		policy:   policy,
+		priority: priority,
+		params:   params,
+		run:      run,
+		track:    jobrun.TrackScheduled,
+		logger:   s.logger,
+		gate:     s.gate,
+		stats: JobStats{
+			Kind:     kind,
+			Cron:     spec,
+			Policy:   policy,
+			Priority: priority,
+		},
+	}
+
+	// Cron specs have minute resolution and fire on the minute, so every
+	// instance derives the same firing time from its own clock
+	if _, err := s.cron.AddFunc(spec, func() { j.fire(s.ctx, time.Now().Truncate(time.Minute)) }); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	s.jobs = append(s.jobs, j)
+
+	s.logger.Info(name+" task registered",
+		zap.String("cron", spec),
+		zap.String("policy", string(policy)),
+		zap.Int("priority", priority))
+	return nil
+}
+
+// Stats returns the counters of every registered job
+func (s *Scheduler) Stats() []JobStats {
+	stats := make([]JobStats, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		stats = append(stats, j.snapshot())
+	}
+	return stats
+}
+
+// Stop stops firing jobs, cancels running ones and waits for them to
+// return, or until ctx is done if a run is slow to stop
+func (s *Scheduler) Stop(ctx context.Context) error {
+	stopped := s.cron.Stop()
+	s.cancel()
+
+	select {
+	case <-stopped.Done():
+		s.logger.Info("scheduler stopped")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 // syncGalleries performs gallery synchronization
-func (s *Scheduler) syncGalleries() error {
+func (s *Scheduler) syncGalleries(ctx context.Context) error {
 	crawlerCfg := s.cfg.Crawler
 	crawlerCfg.Offset = s.cfg.Scheduler.GallerySyncOffset
 
@@ -115,24 +169,27 @@ func (s *Scheduler) syncGalleries() error {
 		return err
 	}
 
-	ctx := context.Background()
 	return crawler.Sync(ctx)
 }
 
 // syncTorrents performs torrent synchronization
-func (s *Scheduler) syncTorrents() error {
+func (s *Scheduler) syncTorrents(ctx context.Context) error {
 	crawler, err := crawler.NewTorrentCrawler(&s.cfg.Crawler, s.logger)
 	if err != nil {
 		return err
 	}
 
-	ctx := context.Background()
 	return crawler.Sync(ctx)
 }
 
 // resyncGalleries performs gallery resynchronization
-func (s *Scheduler) resyncGalleries() error {
+func (s *Scheduler) resyncGalleries(ctx context.Context) error {
 	resyncer := crawler.NewResyncer(&s.cfg.Crawler, s.logger)
-	ctx := context.Background()
 	return resyncer.Resync(ctx, s.cfg.Scheduler.ResyncHours)
 }
+
+// refreshGalleries resyncs the stalest galleries within the daily budget
+func (s *Scheduler) refreshGalleries(ctx context.Context) error {
+	refresher := crawler.NewRefresher(&s.cfg.Crawler, s.logger)
+	return refresher.Refresh(ctx, s.cfg.Scheduler.RefreshGalleries, s.cfg.Scheduler.RefreshDailyBudget)
+}
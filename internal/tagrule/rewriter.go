@@ -0,0 +1,152 @@
+package tagrule
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/slinet/ehdb/internal/database"
+	"github.com/slinet/ehdb/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// Rewriter rewrites stored gallery tags for aliases declared canonical
+type Rewriter struct {
+	logger *zap.Logger
+}
+
+// NewRewriter creates a new alias rewriter
+func NewRewriter(logger *zap.Logger) *Rewriter {
+	return &Rewriter{logger: logger}
+}
+
+type pendingAlias struct {
+	alias     string
+	canonical string
+}
+
+// Rewrite replaces every pending canonical alias inside gallery.tags with its
+// canonical name. An alias is pending when canonicalize = true and it has not
+// been rewritten since it was last changed. Each alias is rewritten in its own
+// transaction and marked done, so an interrupted run can simply be repeated.
+func (rw *Rewriter) Rewrite(ctx context.Context) error {
+	rw.logger.Info("starting tag alias rewrite")
+
+	aliases, err := rw.loadPendingAliases(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(aliases) == 0 {
+		rw.logger.Info("no pending tag aliases to rewrite")
+		return nil
+	}
+
+	rules := utils.CurrentTagRules()
+	var totalRows int64
+	for _, a := range aliases {
+		// Resolve chains (a -> b -> c) so stored tags land on the final name
+		canonical := rules.Canonical(a.canonical)
+
+		rows, err := rw.rewriteAlias(ctx, a.alias, canonical)
+		if err != nil {
+			return fmt.Errorf("rewrite alias %q: %w", a.alias, err)
+		}
+
+		totalRows += rows
+		rw.logger.Info("tag alias rewritten",
+			zap.String("alias", a.alias),
+			zap.String("canonical", canonical),
+			zap.Int64("galleries", rows),
+		)
+	}
+
+	rw.logger.Info("tag alias rewrite completed",
+		zap.Int("aliases", len(aliases)),
+		zap.Int64("galleries", totalRows),
+	)
+
+	if totalRows > 0 {
+		query := "SELECT refresh_all_stats(false)"
+		rw.logger.Debug("executing stats refresh", zap.String("sql", utils.FormatSQL(query)))
+		if _, err := database.GetPool().Exec(ctx, query); err != nil {
+			rw.logger.Error("failed to refresh stats", zap.Error(err))
+		}
+	}
+
+	return nil
+}
+
+func (rw *Rewriter) loadPendingAliases(ctx context.Context) ([]pendingAlias, error) {
+	pool := database.GetPool()
+
+	query := `
+		SELECT alias, canonical
+		FROM tag_alias
+		WHERE canonicalize = true
+		  AND (rewritten_at IS NULL OR rewritten_at < updated_at)
+		ORDER BY alias
+	`
+	rw.logger.Debug("executing query", zap.String("sql", utils.FormatSQL(query)))
+
+	rows, err := pool.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query pending aliases: %w", err)
+	}
+	defer rows.Close()
+
+	var aliases []pendingAlias
+	for rows.Next() {
+		var a pendingAlias
+		if err := rows.Scan(&a.alias, &a.canonical); err != nil {
+			return nil, fmt.Errorf("scan pending alias: %w", err)
+		}
+		aliases = append(aliases, a)
+	}
+
+	return aliases, rows.Err()
+}
+
+func (rw *Rewriter) rewriteAlias(ctx context.Context, alias, canonical string) (int64, error) {
+	pool := database.GetPool()
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tagQuery := `INSERT INTO tag (name) VALUES ($1) ON CONFLICT (name) DO NOTHING`
+	rw.logger.Debug("executing query", zap.String("sql", utils.FormatSQL(tagQuery, canonical)))
+	if _, err := tx.Exec(ctx, tagQuery, canonical); err != nil {
+		return 0, fmt.Errorf("upsert canonical tag: %w", err)
+	}
+
+	// Replace the alias element and drop duplicates if the canonical tag was already present
+	galleryQuery := `
+		UPDATE gallery
+		SET tags = (
+			SELECT COALESCE(jsonb_agg(DISTINCT t ORDER BY t), '[]'::jsonb)
+			FROM (
+				SELECT CASE WHEN elem = $1 THEN $2 ELSE elem END AS t
+				FROM jsonb_array_elements_text(gallery.tags) AS elem
+			) AS rewritten
+		)
+		WHERE tags ? $1
+	`
+	rw.logger.Debug("executing update query", zap.String("sql", utils.FormatSQL(galleryQuery, alias, canonical)))
+	result, err := tx.Exec(ctx, galleryQuery, alias, canonical)
+	if err != nil {
+		return 0, fmt.Errorf("rewrite gallery tags: %w", err)
+	}
+
+	markQuery := `UPDATE tag_alias SET rewritten_at = NOW() WHERE alias = $1`
+	rw.logger.Debug("executing query", zap.String("sql", utils.FormatSQL(markQuery, alias)))
+	if _, err := tx.Exec(ctx, markQuery, alias); err != nil {
+		return 0, fmt.Errorf("mark alias rewritten: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,181 @@
+package tagrule
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/slinet/ehdb/internal/database"
+	"github.com/slinet/ehdb/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// Load reads the tag_alias and tag_implication tables and builds a rule set
+func Load(ctx context.Context, logger *zap.Logger) (*utils.TagRules, error) {
+	pool := database.GetPool()
+
+	aliasQuery := `SELECT alias, canonical FROM tag_alias`
+	logger.Debug("executing query", zap.String("sql", utils.FormatSQL(aliasQuery)))
+
+	rows, err := pool.Query(ctx, aliasQuery)
+	if err != nil {
+		return nil, fmt.Errorf("query tag aliases: %w", err)
+	}
+
+	aliases := make(map[string]string)
+	for rows.Next() {
+		var alias, canonical string
+		if err := rows.Scan(&alias, &canonical); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan tag alias: %w", err)
+		}
+		aliases[alias] = canonical
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("read tag aliases: %w", err)
+	}
+
+	implicationQuery := `SELECT tag, implied_tag FROM tag_implication`
+	logger.Debug("executing query", zap.String("sql", utils.FormatSQL(implicationQuery)))
+
+	rows, err = pool.Query(ctx, implicationQuery)
+	if err != nil {
+		return nil, fmt.Errorf("query tag implications: %w", err)
+	}
+
+	implications := make(map[string][]string)
+	for rows.Next() {
+		var tag, implied string
+		if err := rows.Scan(&tag, &implied); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan tag implication: %w", err)
+		}
+		implications[tag] = append(implications[tag], implied)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("read tag implications: %w", err)
+	}
+
+	return utils.NewTagRules(aliases, implications), nil
+}
+
+// Refresh loads the current rules and installs them for NormalizeTag and search
+func Refresh(ctx context.Context, logger *zap.Logger) error {
+	rules, err := Load(ctx, logger)
+	if err != nil {
+		return err
+	}
+
+	utils.SetTagRules(rules)
+
+	aliases, implications := rules.Len()
+	logger.Debug("tag rules refreshed",
+		zap.Int("aliases", aliases),
+		zap.Int("implied_tags", implications),
+	)
+
+	return nil
+}
+
+// Watch refreshes the installed rules every interval until ctx is cancelled,
+// so edits to the rule tables reach a running API server without a restart
+func Watch(ctx context.Context, interval time.Duration, logger *zap.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := Refresh(ctx, logger); err != nil {
+				logger.Warn("failed to refresh tag rules, keeping previous rules", zap.Error(err))
+			}
+		}
+	}
+}
+
+// SetAlias creates or updates an alias. When canonicalize is true the alias is
+// queued for the next Rewriter run, which rewrites it inside gallery.tags.
+func SetAlias(ctx context.Context, logger *zap.Logger, alias, canonical string, canonicalize bool) error {
+	alias = utils.NormalizeTagName(alias)
+	canonical = utils.NormalizeTagName(canonical)
+	if alias == "" || canonical == "" {
+		return fmt.Errorf("alias and canonical tag are required")
+	}
+	if alias == canonical {
+		return fmt.Errorf("alias %q points to itself", alias)
+	}
+
+	query := `
+		INSERT INTO tag_alias (alias, canonical, canonicalize)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (alias) DO UPDATE SET
+			canonical = EXCLUDED.canonical,
+			canonicalize = EXCLUDED.canonicalize,
+			updated_at = NOW()
+	`
+	logger.Debug("executing query", zap.String("sql", utils.FormatSQL(query, alias, canonical, canonicalize)))
+
+	if _, err := database.GetPool().Exec(ctx, query, alias, canonical, canonicalize); err != nil {
+		return fmt.Errorf("upsert tag alias: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteAlias removes an alias. Tags already rewritten are left untouched.
+func DeleteAlias(ctx context.Context, logger *zap.Logger, alias string) error {
+	alias = utils.NormalizeTagName(alias)
+
+	query := `DELETE FROM tag_alias WHERE alias = $1`
+	logger.Debug("executing query", zap.String("sql", utils.FormatSQL(query, alias)))
+
+	if _, err := database.GetPool().Exec(ctx, query, alias); err != nil {
+		return fmt.Errorf("delete tag alias: %w", err)
+	}
+
+	return nil
+}
+
+// AddImplication declares that galleries tagged tag implicitly carry impliedTag
+func AddImplication(ctx context.Context, logger *zap.Logger, tag, impliedTag string) error {
+	tag = utils.NormalizeTagName(tag)
+	impliedTag = utils.NormalizeTagName(impliedTag)
+	if tag == "" || impliedTag == "" {
+		return fmt.Errorf("tag and implied tag are required")
+	}
+	if tag == impliedTag {
+		return fmt.Errorf("tag %q implies itself", tag)
+	}
+
+	query := `
+		INSERT INTO tag_implication (tag, implied_tag)
+		VALUES ($1, $2)
+		ON CONFLICT (tag, implied_tag) DO NOTHING
+	`
+	logger.Debug("executing query", zap.String("sql", utils.FormatSQL(query, tag, impliedTag)))
+
+	if _, err := database.GetPool().Exec(ctx, query, tag, impliedTag); err != nil {
+		return fmt.Errorf("insert tag implication: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteImplication removes an implication
+func DeleteImplication(ctx context.Context, logger *zap.Logger, tag, impliedTag string) error {
+	tag = utils.NormalizeTagName(tag)
+	impliedTag = utils.NormalizeTagName(impliedTag)
+
+	query := `DELETE FROM tag_implication WHERE tag = $1 AND implied_tag = $2`
+	logger.Debug("executing query", zap.String("sql", utils.FormatSQL(query, tag, impliedTag)))
+
+	if _, err := database.GetPool().Exec(ctx, query, tag, impliedTag); err != nil {
+		return fmt.Errorf("delete tag implication: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package tagtranslation
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/slinet/ehdb/internal/database"
+	"github.com/slinet/ehdb/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// Importer replaces the stored translations with a parsed dataset
+type Importer struct {
+	logger *zap.Logger
+}
+
+// NewImporter creates a new translation importer
+func NewImporter(logger *zap.Logger) *Importer {
+	return &Importer{logger: logger}
+}
+
+// Import swaps the contents of tag_translation_namespace and tag_translation
+// for db inside one transaction, so readers never see a partial dataset
+func (imp *Importer) Import(ctx context.Context, db *Database) error {
+	pool := database.GetPool()
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, table := range []string{"tag_translation_namespace", "tag_translation"} {
+		query := "DELETE FROM " + table
+		imp.logger.Debug("executing query", zap.String("sql", utils.FormatSQL(query)))
+		if _, err := tx.Exec(ctx, query); err != nil {
+			return fmt.Errorf("clear %s: %w", table, err)
+		}
+	}
+
+	namespaceRows := make([][]interface{}, 0, len(db.Namespaces))
+	for _, e := range db.Namespaces {
+		namespaceRows = append(namespaceRows, []interface{}{e.Key, e.Name, e.Intro, e.Links})
+	}
+	if _, err := tx.CopyFrom(ctx,
+		pgx.Identifier{"tag_translation_namespace"},
+		[]string{"namespace", "name", "intro", "links"},
+		pgx.CopyFromRows(namespaceRows),
+	); err != nil {
+		return fmt.Errorf("copy namespace translations: %w", err)
+	}
+
+	tagRows := make([][]interface{}, 0, len(db.Tags))
+	for _, e := range db.Tags {
+		tagRows = append(tagRows, []interface{}{e.Tag(), e.Namespace, e.Name, e.Intro, e.Links})
+	}
+	if _, err := tx.CopyFrom(ctx,
+		pgx.Identifier{"tag_translation"},
+		[]string{"tag", "namespace", "name", "intro", "links"},
+		pgx.CopyFromRows(tagRows),
+	); err != nil {
+		return fmt.Errorf("copy tag translations: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+
+	imp.logger.Info("tag translations imported",
+		zap.Int("version", db.Version),
+		zap.String("head", db.Head),
+		zap.Int("namespaces", len(db.Namespaces)),
+		zap.Int("tags", len(db.Tags)),
+	)
+
+	return nil
+}
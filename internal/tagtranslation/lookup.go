@@ -0,0 +1,191 @@
+package tagtranslation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/slinet/ehdb/internal/database"
+	"github.com/slinet/ehdb/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// Labels returns the translated name for each tag in tags that has one
+func Labels(ctx context.Context, logger *zap.Logger, tags []string) (map[string]string, error) {
+	labels := make(map[string]string)
+	if len(tags) == 0 {
+		return labels, nil
+	}
+
+	query := `SELECT tag, name FROM tag_translation WHERE tag = ANY($1)`
+	logger.Debug("executing tag translation query",
+		zap.String("sql", utils.FormatSQL(query, tags)),
+		zap.Int("tag_count", len(tags)),
+	)
+
+	rows, err := database.GetPool().Query(ctx, query, tags)
+	if err != nil {
+		return nil, fmt.Errorf("query tag translations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tag, name string
+		if err := rows.Scan(&tag, &name); err != nil {
+			return nil, fmt.Errorf("scan tag translation: %w", err)
+		}
+		labels[tag] = name
+	}
+
+	return labels, rows.Err()
+}
+
+// NeedsResolve reports whether tag may be written with translated names.
+// E-Hentai namespaces and tags are ASCII, so only tags containing other
+// characters are looked up.
+func NeedsResolve(tag string) bool {
+	return utf8.RuneCountInString(tag) != len(tag)
+}
+
+// AmbiguousError reports a tag written with a translated name that several
+// canonical tags of its namespace share
+type AmbiguousError struct {
+	Input string
+	Tags  []string
+}
+
+func (e *AmbiguousError) Error() string {
+	return fmt.Sprintf("translated tag %q matches several tags: %s", e.Input, strings.Join(e.Tags, ", "))
+}
+
+// splitTag is a tag to resolve, split into its canonical namespace and its
+// lowercased value
+type splitTag struct {
+	input     string
+	namespace string
+	value     string
+}
+
+// Resolve maps tags written with translated namespace and/or tag names
+// (e.g. "女性:巨乳" or "female:巨乳") back to their canonical tags
+// ("female:big breasts"). Tags without a translation match are omitted from
+// the result. Name matching is case-insensitive. A name several tags of the
+// namespace share returns an *AmbiguousError.
+func Resolve(ctx context.Context, logger *zap.Logger, tags []string) (map[string]string, error) {
+	resolved := make(map[string]string)
+
+	var pending []string
+	for _, tag := range tags {
+		if NeedsResolve(tag) {
+			pending = append(pending, tag)
+		}
+	}
+	if len(pending) == 0 {
+		return resolved, nil
+	}
+
+	pool := database.GetPool()
+
+	namespaceQuery := `SELECT namespace, lower(name) FROM tag_translation_namespace`
+	logger.Debug("executing namespace translation query", zap.String("sql", utils.FormatSQL(namespaceQuery)))
+
+	rows, err := pool.Query(ctx, namespaceQuery)
+	if err != nil {
+		return nil, fmt.Errorf("query namespace translations: %w", err)
+	}
+	namespaces := make(map[string]string)
+	for rows.Next() {
+		var namespace, name string
+		if err := rows.Scan(&namespace, &name); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan namespace translation: %w", err)
+		}
+		namespaces[name] = namespace
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("read namespace translations: %w", err)
+	}
+
+	var split []splitTag
+	var names []string
+	for _, tag := range pending {
+		parts := strings.SplitN(tag, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		namespace, value := parts[0], strings.ToLower(parts[1])
+		if canonical, ok := namespaces[strings.ToLower(namespace)]; ok {
+			namespace = canonical
+		}
+		split = append(split, splitTag{input: tag, namespace: namespace, value: value})
+		if NeedsResolve(value) {
+			names = append(names, value)
+		}
+	}
+
+	// namespace -> lower(translated name) -> canonical tags
+	translated := make(map[string]map[string][]string)
+	if len(names) > 0 {
+		tagQuery := `
+			SELECT tag, namespace, lower(name)
+			FROM tag_translation
+			WHERE lower(name) = ANY($1)
+			ORDER BY tag
+		`
+		logger.Debug("executing tag translation query", zap.String("sql", utils.FormatSQL(tagQuery, names)))
+
+		rows, err := pool.Query(ctx, tagQuery, names)
+		if err != nil {
+			return nil, fmt.Errorf("query tag translations: %w", err)
+		}
+		for rows.Next() {
+			var tag, namespace, name string
+			if err := rows.Scan(&tag, &namespace, &name); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("scan tag translation: %w", err)
+			}
+			if translated[namespace] == nil {
+				translated[namespace] = make(map[string][]string)
+			}
+			translated[namespace][name] = append(translated[namespace][name], tag)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("read tag translations: %w", err)
+		}
+	}
+
+	resolved, err = resolveSplit(split, translated)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Debug("resolved translated tags",
+		zap.Int("candidates", len(pending)),
+		zap.Int("resolved", len(resolved)),
+	)
+
+	return resolved, nil
+}
+
+// resolveSplit maps split tags to canonical tags through translated, which
+// holds the canonical tags of each namespace by lowercased translated name
+func resolveSplit(split []splitTag, translated map[string]map[string][]string) (map[string]string, error) {
+	resolved := make(map[string]string)
+	for _, t := range split {
+		if tags := translated[t.namespace][t.value]; len(tags) > 0 {
+			if len(tags) > 1 {
+				return nil, &AmbiguousError{Input: t.input, Tags: tags}
+			}
+			resolved[t.input] = tags[0]
+			continue
+		}
+		if !NeedsResolve(t.value) && t.namespace+":"+t.value != t.input {
+			// Only the namespace was translated
+			resolved[t.input] = t.namespace + ":" + t.value
+		}
+	}
+	return resolved, nil
+}
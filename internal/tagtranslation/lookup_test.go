@@ -0,0 +1,42 @@
+package tagtranslation
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestResolveSplit(t *testing.T) {
+	translated := map[string]map[string][]string{
+		"female": {
+			"巨乳": {"female:big breasts"},
+			"精灵": {"female:elf", "female:elf ears"},
+		},
+	}
+
+	resolved, err := resolveSplit([]splitTag{
+		{input: "女性:巨乳", namespace: "female", value: "巨乳"},
+		{input: "女性:elf", namespace: "female", value: "elf"},
+		{input: "female:未知", namespace: "female", value: "未知"},
+	}, translated)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	expected := map[string]string{
+		"女性:巨乳":  "female:big breasts",
+		"女性:elf": "female:elf",
+	}
+	if !reflect.DeepEqual(resolved, expected) {
+		t.Fatalf("expected %v, got %v", expected, resolved)
+	}
+
+	// A name several tags share is reported rather than resolved to one
+	_, err = resolveSplit([]splitTag{{input: "female:精灵", namespace: "female", value: "精灵"}}, translated)
+	var ambiguous *AmbiguousError
+	if !errors.As(err, &ambiguous) {
+		t.Fatalf("expected an AmbiguousError, got %v", err)
+	}
+	if ambiguous.Input != "female:精灵" || len(ambiguous.Tags) != 2 {
+		t.Fatalf("expected both elf tags for female:精灵, got %+v", ambiguous)
+	}
+}
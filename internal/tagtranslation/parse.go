@@ -0,0 +1,130 @@
+package tagtranslation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// namespaceRows is the pseudo namespace that holds translations of the namespaces themselves
+const namespaceRows = "rows"
+
+// Entry is a single translated tag or namespace
+type Entry struct {
+	Namespace string // Tag namespace, e.g. "female" (empty for namespace entries)
+	Key       string // Original name, e.g. "big breasts" or "female" for namespace entries
+	Name      string // Translated name (plain text)
+	Intro     string // Description (markdown)
+	Links     string // Reference links (markdown)
+}
+
+// Tag returns the canonical "namespace:key" tag this entry translates
+func (e Entry) Tag() string {
+	return e.Namespace + ":" + e.Key
+}
+
+// Database is a parsed EhTagTranslation dataset
+type Database struct {
+	Version    int
+	Head       string // Commit SHA of the dataset
+	Namespaces []Entry
+	Tags       []Entry
+}
+
+type rawDatabase struct {
+	Version int `json:"version"`
+	Head    struct {
+		SHA string `json:"sha"`
+	} `json:"head"`
+	Data []struct {
+		Namespace string                     `json:"namespace"`
+		Data      map[string]rawEntryContent `json:"data"`
+	} `json:"data"`
+}
+
+type rawEntryContent struct {
+	Name  json.RawMessage `json:"name"`
+	Intro json.RawMessage `json:"intro"`
+	Links json.RawMessage `json:"links"`
+}
+
+// Parse decodes an EhTagTranslation database file. Both the single-format
+// releases (db.text.json, db.raw.json, ...) and db.full.json, whose fields
+// carry every format at once, are accepted.
+func Parse(r io.Reader) (*Database, error) {
+	var raw rawDatabase
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("decode translation database: %w", err)
+	}
+
+	if len(raw.Data) == 0 {
+		return nil, fmt.Errorf("translation database has no namespaces")
+	}
+
+	db := &Database{
+		Version: raw.Version,
+		Head:    raw.Head.SHA,
+	}
+
+	for _, ns := range raw.Data {
+		namespace := strings.ToLower(strings.TrimSpace(ns.Namespace))
+		if namespace == "" {
+			continue
+		}
+
+		for key, content := range ns.Data {
+			key = strings.ToLower(strings.TrimSpace(key))
+			if key == "" {
+				continue
+			}
+
+			entry := Entry{
+				Key:   key,
+				Name:  decodeField(content.Name, "text"),
+				Intro: decodeField(content.Intro, "raw"),
+				Links: decodeField(content.Links, "raw"),
+			}
+			if entry.Name == "" {
+				continue
+			}
+
+			if namespace == namespaceRows {
+				db.Namespaces = append(db.Namespaces, entry)
+				continue
+			}
+
+			entry.Namespace = namespace
+			db.Tags = append(db.Tags, entry)
+		}
+	}
+
+	return db, nil
+}
+
+// decodeField reads a field that is either a plain string or, in db.full.json,
+// an object keyed by format ("raw", "text", "html", "ast"). preferred selects
+// the format to use from such objects, falling back to the other text formats.
+func decodeField(value json.RawMessage, preferred string) string {
+	if len(value) == 0 {
+		return ""
+	}
+
+	var text string
+	if err := json.Unmarshal(value, &text); err == nil {
+		return strings.TrimSpace(text)
+	}
+
+	var formats map[string]json.RawMessage
+	if err := json.Unmarshal(value, &formats); err != nil {
+		return ""
+	}
+
+	for _, format := range []string{preferred, "text", "raw"} {
+		if err := json.Unmarshal(formats[format], &text); err == nil && strings.TrimSpace(text) != "" {
+			return strings.TrimSpace(text)
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,85 @@
+package tagtranslation
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	input := `{
+		"version": 6,
+		"head": {"sha": "abc123"},
+		"data": [
+			{
+				"namespace": "rows",
+				"data": {
+					"female": {"name": "女性", "intro": "女性角色", "links": ""}
+				}
+			},
+			{
+				"namespace": "female",
+				"data": {
+					"big breasts": {
+						"name": {"raw": "![图](# \"x\")巨乳", "text": "巨乳", "html": "<p>巨乳</p>"},
+						"intro": {"raw": "**大**胸部", "text": "大胸部"},
+						"links": {"raw": "[Wiki](https://example.org)", "text": "Wiki"}
+					},
+					"untranslated": {"name": "", "intro": "", "links": ""}
+				}
+			}
+		]
+	}`
+
+	db, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if db.Version != 6 || db.Head != "abc123" {
+		t.Fatalf("expected version 6 head abc123, got %d %q", db.Version, db.Head)
+	}
+
+	if len(db.Namespaces) != 1 || db.Namespaces[0].Key != "female" || db.Namespaces[0].Name != "女性" {
+		t.Fatalf("unexpected namespaces: %+v", db.Namespaces)
+	}
+
+	if len(db.Tags) != 1 {
+		t.Fatalf("expected 1 tag, got %d", len(db.Tags))
+	}
+
+	tag := db.Tags[0]
+	if tag.Tag() != "female:big breasts" {
+		t.Fatalf("expected tag female:big breasts, got %q", tag.Tag())
+	}
+	if tag.Name != "巨乳" {
+		t.Fatalf("expected plain text name, got %q", tag.Name)
+	}
+	if tag.Intro != "**大**胸部" || tag.Links != "[Wiki](https://example.org)" {
+		t.Fatalf("expected raw markdown intro and links, got %q %q", tag.Intro, tag.Links)
+	}
+}
+
+func TestParseRejectsEmptyDatabase(t *testing.T) {
+	if _, err := Parse(strings.NewReader(`{"data": []}`)); err == nil {
+		t.Fatalf("expected error for database without namespaces")
+	}
+}
+
+func TestNeedsResolve(t *testing.T) {
+	tests := []struct {
+		tag      string
+		expected bool
+	}{
+		{tag: "female:big breasts", expected: false},
+		{tag: "female:巨乳", expected: true},
+		{tag: "女性:big breasts", expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			if got := NeedsResolve(tt.tag); got != tt.expected {
+				t.Fatalf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+-- ============================================================================
+-- Schema update 001: tag alias and implication rules
+-- ============================================================================
+-- Function: Admin-managed tag aliases (old/merged name -> canonical name) and
+--           implications (tag A implies tag B), applied at search time
+--
+-- Execution:
+--   psql -U user -d ehentai_db -f schema/001_tag_rules.sql
+--
+-- Safe to run repeatedly
+-- ============================================================================
+
+BEGIN;
+
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+-- Step 1: Create rule tables
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+CREATE TABLE IF NOT EXISTS tag_alias (
+    alias           VARCHAR(200) PRIMARY KEY,
+    canonical       VARCHAR(200) NOT NULL,
+    canonicalize    BOOLEAN NOT NULL DEFAULT FALSE,
+    rewritten_at    TIMESTAMPTZ DEFAULT NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (alias <> canonical)
+);
+
+CREATE TABLE IF NOT EXISTS tag_implication (
+    tag             VARCHAR(200) NOT NULL,
+    implied_tag     VARCHAR(200) NOT NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (tag, implied_tag),
+    CHECK (tag <> implied_tag)
+);
+
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+-- Step 2: Create indexes
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+CREATE INDEX IF NOT EXISTS idx_tag_alias_canonical ON tag_alias (canonical);
+CREATE INDEX IF NOT EXISTS idx_tag_implication_implied ON tag_implication (implied_tag);
+
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+-- Step 3: Add table comments
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+COMMENT ON TABLE tag_alias IS 'Tag aliases, searches for alias are answered with canonical';
+COMMENT ON COLUMN tag_alias.canonicalize IS 'When true, ehdb-sync tag-rewrite replaces alias with canonical inside gallery.tags';
+COMMENT ON COLUMN tag_alias.rewritten_at IS 'Last time gallery.tags were rewritten for this alias';
+COMMENT ON TABLE tag_implication IS 'Tag implications, a search for implied_tag also matches galleries tagged with tag';
+
+COMMIT;
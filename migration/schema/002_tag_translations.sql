@@ -0,0 +1,50 @@
+-- ============================================================================
+-- Schema update 002: EhTagTranslation tag translations
+-- ============================================================================
+-- Function: Translated namespace and tag names imported from the community
+--           EhTagTranslation dataset by ehdb-sync import-tag-translations
+--
+-- Execution:
+--   psql -U user -d ehentai_db -f schema/002_tag_translations.sql
+--
+-- Safe to run repeatedly
+-- ============================================================================
+
+BEGIN;
+
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+-- Step 1: Create translation tables
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+CREATE TABLE IF NOT EXISTS tag_translation_namespace (
+    namespace       VARCHAR(50) PRIMARY KEY,
+    name            TEXT NOT NULL,
+    intro           TEXT NOT NULL DEFAULT '',
+    links           TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS tag_translation (
+    tag             VARCHAR(200) PRIMARY KEY,
+    namespace       VARCHAR(50) NOT NULL,
+    name            TEXT NOT NULL,
+    intro           TEXT NOT NULL DEFAULT '',
+    links           TEXT NOT NULL DEFAULT ''
+);
+
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+-- Step 2: Create indexes
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+-- Reverse lookup of translated names in /api/search
+CREATE INDEX IF NOT EXISTS idx_tag_translation_name_lower ON tag_translation (lower(name));
+
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+-- Step 3: Add table comments
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+COMMENT ON TABLE tag_translation_namespace IS 'Translated namespace names from EhTagTranslation';
+COMMENT ON TABLE tag_translation IS 'Translated tag names from EhTagTranslation, keyed by canonical tag (namespace:name)';
+COMMENT ON COLUMN tag_translation.intro IS 'Tag description (markdown)';
+COMMENT ON COLUMN tag_translation.links IS 'Reference links (markdown)';
+
+COMMIT;
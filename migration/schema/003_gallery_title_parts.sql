@@ -0,0 +1,55 @@
+-- ============================================================================
+-- Schema update 003: structured gallery title parts
+-- ============================================================================
+-- Function: Event, circle, artist, clean title, parody, language and flags
+--           parsed from gallery titles by the importer, so translations of
+--           the same work can be grouped
+--
+-- Execution:
+--   psql -U user -d ehentai_db -f schema/003_gallery_title_parts.sql
+--
+-- Existing rows are filled by: ehdb-sync parse-titles
+--
+-- Safe to run repeatedly
+-- ============================================================================
+
+BEGIN;
+
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+-- Step 1: Add parsed title columns
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+ALTER TABLE gallery
+    ADD COLUMN IF NOT EXISTS title_event      VARCHAR(512) DEFAULT NULL,
+    ADD COLUMN IF NOT EXISTS title_circle     VARCHAR(512) DEFAULT NULL,
+    ADD COLUMN IF NOT EXISTS title_artist     VARCHAR(512) DEFAULT NULL,
+    ADD COLUMN IF NOT EXISTS title_base       VARCHAR(512) DEFAULT NULL,
+    ADD COLUMN IF NOT EXISTS title_parody     VARCHAR(512) DEFAULT NULL,
+    ADD COLUMN IF NOT EXISTS title_language   VARCHAR(20) DEFAULT NULL,
+    ADD COLUMN IF NOT EXISTS title_digital    BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS title_decensored BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS title_ongoing    BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+-- Step 2: Create indexes
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+CREATE INDEX IF NOT EXISTS idx_gallery_title_event ON gallery (title_event) WHERE title_event IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_gallery_title_circle ON gallery (title_circle) WHERE title_circle IS NOT NULL;
+-- Groups translations of the same work
+CREATE INDEX IF NOT EXISTS idx_gallery_title_artist_base ON gallery (title_artist, title_base) WHERE title_artist IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_gallery_title_parody ON gallery (title_parody) WHERE title_parody IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_gallery_title_language ON gallery (title_language) WHERE title_language IS NOT NULL;
+
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+-- Step 3: Add column comments
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+COMMENT ON COLUMN gallery.title_event IS 'Event parsed from the title, e.g. C102 from "(C102) [Circle (Artist)] Title"';
+COMMENT ON COLUMN gallery.title_circle IS 'Circle parsed from the title';
+COMMENT ON COLUMN gallery.title_artist IS 'Artist parsed from the title';
+COMMENT ON COLUMN gallery.title_base IS 'Title without bracketed groups (NULL until parsed)';
+COMMENT ON COLUMN gallery.title_parody IS 'Parody parsed from the title';
+COMMENT ON COLUMN gallery.title_language IS 'Language marker parsed from the title, e.g. english';
+
+COMMIT;
@@ -0,0 +1,120 @@
+-- ============================================================================
+-- Schema update 004: gallery primary language
+-- ============================================================================
+-- Function: Primary language and translated/rewrite flags derived from the
+--           language: tags by the importer, with per-language statistics
+--           for the lang filter on listing endpoints
+--
+-- Execution:
+--   psql -U user -d ehentai_db -f schema/004_gallery_language.sql
+--
+-- Existing rows are filled by: ehdb-sync derive-language
+--
+-- Safe to run repeatedly; only a gallery_stats_mv without language rows is
+-- rebuilt, so reruns leave the statistics view alone
+-- ============================================================================
+
+BEGIN;
+
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+-- Step 1: Add language columns
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+ALTER TABLE gallery
+    ADD COLUMN IF NOT EXISTS language   VARCHAR(20) DEFAULT NULL,
+    ADD COLUMN IF NOT EXISTS translated BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS rewrite    BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+-- Step 2: Create indexes
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+-- Matches the ORDER BY posted DESC, gid DESC used by listing endpoints
+CREATE INDEX IF NOT EXISTS idx_gallery_language_posted ON gallery (language, posted DESC, gid DESC) WHERE expunged = FALSE;
+
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+-- Step 3: Recreate gallery_stats_mv with per-language rows
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+-- Only a view without language rows is rebuilt, so reruns leave it alone
+DO $$
+BEGIN
+    IF NOT EXISTS (
+           SELECT 1 FROM pg_matviews
+           WHERE matviewname = 'gallery_stats_mv' AND definition NOT LIKE '%''language\_''%'
+       ) THEN
+        RETURN;
+    END IF;
+
+    DROP MATERIALIZED VIEW IF EXISTS gallery_stats_mv;
+
+    CREATE MATERIALIZED VIEW gallery_stats_mv AS
+    SELECT
+        'total_active' AS stat_key,
+        COUNT(*) AS stat_value,
+        NOW() AS updated_at
+    FROM gallery
+    WHERE expunged = FALSE
+
+    UNION ALL
+
+    SELECT
+        'total_removed' AS stat_key,
+        COUNT(*) AS stat_value,
+        NOW() AS updated_at
+    FROM gallery
+    WHERE removed = TRUE
+
+    UNION ALL
+
+    SELECT
+        'total_replaced' AS stat_key,
+        COUNT(*) AS stat_value,
+        NOW() AS updated_at
+    FROM gallery
+    WHERE replaced = TRUE
+
+    UNION ALL
+
+    SELECT
+        'total_expunged' AS stat_key,
+        COUNT(*) AS stat_value,
+        NOW() AS updated_at
+    FROM gallery
+    WHERE expunged = TRUE
+
+    UNION ALL
+
+    SELECT
+        CONCAT('category_', LOWER(category)) AS stat_key,
+        COUNT(*) AS stat_value,
+        NOW() AS updated_at
+    FROM gallery
+    WHERE expunged = FALSE
+    GROUP BY category
+
+    UNION ALL
+
+    SELECT
+        CONCAT('language_', language) AS stat_key,
+        COUNT(*) AS stat_value,
+        NOW() AS updated_at
+    FROM gallery
+    WHERE expunged = FALSE AND language IS NOT NULL
+    GROUP BY language;
+
+    CREATE UNIQUE INDEX idx_gallery_stats_mv_key ON gallery_stats_mv (stat_key);
+
+    COMMENT ON MATERIALIZED VIEW gallery_stats_mv IS 'Gallery statistics materialized view';
+END;
+$$;
+
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+-- Step 4: Add comments
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+COMMENT ON COLUMN gallery.language IS 'Primary language from language: tags, e.g. english (NULL when unknown)';
+COMMENT ON COLUMN gallery.translated IS 'Gallery is tagged language:translated';
+COMMENT ON COLUMN gallery.rewrite IS 'Gallery is tagged language:rewrite';
+
+COMMIT;
@@ -0,0 +1,51 @@
+-- ============================================================================
+-- Schema update 005: job run history
+-- ============================================================================
+-- Function: One row per scheduled, CLI or admin job run with its parameters,
+--           outcome, error chain and counters, shown by ehdb-sync jobs and
+--           GET /admin/jobs
+--
+-- Execution:
+--   psql -U user -d ehentai_db -f schema/005_job_runs.sql
+--
+-- Safe to run repeatedly
+-- ============================================================================
+
+BEGIN;
+
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+-- Step 1: Create job_run table
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+CREATE TABLE IF NOT EXISTS job_run (
+    id          BIGSERIAL PRIMARY KEY,
+    kind        VARCHAR(50) NOT NULL,
+    source      VARCHAR(20) NOT NULL,
+    params      JSONB DEFAULT NULL,
+    status      VARCHAR(20) NOT NULL,
+    error       TEXT DEFAULT NULL,
+    error_chain JSONB DEFAULT NULL,
+    counters    JSONB NOT NULL DEFAULT '{}'::jsonb,
+    started_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMP WITH TIME ZONE DEFAULT NULL
+);
+
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+-- Step 2: Create indexes
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+CREATE INDEX IF NOT EXISTS idx_job_run_kind_id ON job_run (kind, id DESC);
+CREATE INDEX IF NOT EXISTS idx_job_run_status_id ON job_run (status, id DESC);
+
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+-- Step 3: Add comments
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+COMMENT ON TABLE job_run IS 'History of scheduled, CLI and admin job runs';
+COMMENT ON COLUMN job_run.kind IS 'Job type, e.g. gallery-sync, torrent-sync, resync';
+COMMENT ON COLUMN job_run.source IS 'What started the run: scheduler, cli or admin';
+COMMENT ON COLUMN job_run.status IS 'running, succeeded, failed or cancelled (running rows without finished_at after a crash stay running)';
+COMMENT ON COLUMN job_run.error_chain IS 'Messages of the error and every error it wraps, outermost first';
+COMMENT ON COLUMN job_run.counters IS 'Counters such as galleries_discovered, galleries_imported, torrents_added, bans_hit';
+
+COMMIT;
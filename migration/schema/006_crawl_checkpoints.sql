@@ -0,0 +1,34 @@
+-- ============================================================================
+-- Schema update 006: crawl checkpoints
+-- ============================================================================
+-- Function: One row per long crawl kind (backfill, resync, torrent-import)
+--           holding the position reached by its latest unfinished run, so
+--           ehdb-sync <command> -resume continues where that run died
+--
+-- Execution:
+--   psql -U user -d ehentai_db -f schema/006_crawl_checkpoints.sql
+--
+-- Safe to run repeatedly
+-- ============================================================================
+
+BEGIN;
+
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+-- Step 1: Create crawl_checkpoint table
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+CREATE TABLE IF NOT EXISTS crawl_checkpoint (
+    kind       VARCHAR(50) PRIMARY KEY,
+    state      JSONB NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+-- Step 2: Add comments
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+COMMENT ON TABLE crawl_checkpoint IS 'Resume position of the latest unfinished run of each long crawl; deleted when a run completes';
+COMMENT ON COLUMN crawl_checkpoint.kind IS 'Crawl type: backfill, resync or torrent-import';
+COMMENT ON COLUMN crawl_checkpoint.state IS 'Run parameters plus list cursor, processed gid range or import position, depending on kind';
+
+COMMIT;
@@ -0,0 +1,49 @@
+-- ============================================================================
+-- Schema update 007: gallery detail page fields
+-- ============================================================================
+-- Function: Fields only shown on the /g/gid/token/ page and not returned by
+--           the gdata API: favorite and rating counts, the parent gallery,
+--           newer versions and visibility. Filled by resync and fetch when
+--           run with -details (or crawler.fetch_details)
+--
+-- Execution:
+--   psql -U user -d ehentai_db -f schema/007_gallery_details.sql
+--
+-- Safe to run repeatedly
+-- ============================================================================
+
+BEGIN;
+
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+-- Step 1: Add detail columns
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+ALTER TABLE gallery
+    ADD COLUMN IF NOT EXISTS favorite_count   INTEGER DEFAULT NULL,
+    ADD COLUMN IF NOT EXISTS rating_count     INTEGER DEFAULT NULL,
+    ADD COLUMN IF NOT EXISTS parent_gid       INTEGER DEFAULT NULL,
+    ADD COLUMN IF NOT EXISTS newer_gids       INTEGER[] DEFAULT NULL,
+    ADD COLUMN IF NOT EXISTS visible          BOOLEAN DEFAULT NULL,
+    ADD COLUMN IF NOT EXISTS hidden_reason    VARCHAR(30) DEFAULT NULL,
+    ADD COLUMN IF NOT EXISTS detail_synced_at TIMESTAMP WITH TIME ZONE DEFAULT NULL;
+
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+-- Step 2: Create indexes
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+-- Child lookups: galleries whose parent is a given gid
+CREATE INDEX IF NOT EXISTS idx_gallery_parent_gid ON gallery (parent_gid) WHERE parent_gid IS NOT NULL;
+
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+-- Step 3: Add comments
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+COMMENT ON COLUMN gallery.favorite_count IS 'Times favorited, from the gallery page (NULL until details are fetched)';
+COMMENT ON COLUMN gallery.rating_count IS 'Number of ratings, from the gallery page';
+COMMENT ON COLUMN gallery.parent_gid IS 'Parent gallery this one is a newer version of (NULL without a parent)';
+COMMENT ON COLUMN gallery.newer_gids IS 'Newer versions of this gallery, oldest first (NULL or empty without any)';
+COMMENT ON COLUMN gallery.visible IS 'Gallery is visible in searches';
+COMMENT ON COLUMN gallery.hidden_reason IS 'Why the gallery is not visible, e.g. Replaced or Expunged';
+COMMENT ON COLUMN gallery.detail_synced_at IS 'When the gallery page fields were last fetched';
+
+COMMIT;
@@ -0,0 +1,55 @@
+-- ============================================================================
+-- Schema update 008: gallery comments
+-- ============================================================================
+-- Function: Comments from the gallery page, including the uploader comment.
+--           Filled by resync and fetch when run with -comments (or
+--           crawler.fetch_comments); each fetch replaces the stored comments
+--           of the gallery
+--
+-- Execution:
+--   psql -U user -d ehentai_db -f schema/008_gallery_comments.sql
+--
+-- Safe to run repeatedly
+-- ============================================================================
+
+BEGIN;
+
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+-- Step 1: Create comment table
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+CREATE TABLE IF NOT EXISTS gallery_comment (
+    gid         INTEGER NOT NULL,
+    comment_id  INTEGER NOT NULL,
+    author      VARCHAR(100) NOT NULL,
+    uploader    BOOLEAN NOT NULL DEFAULT FALSE,
+    score       INTEGER DEFAULT NULL,
+    posted      TIMESTAMP WITH TIME ZONE NOT NULL,
+    edited      TIMESTAMP WITH TIME ZONE DEFAULT NULL,
+    body        TEXT NOT NULL,
+    PRIMARY KEY (gid, comment_id)
+);
+
+ALTER TABLE gallery
+    ADD COLUMN IF NOT EXISTS comments_synced_at TIMESTAMP WITH TIME ZONE DEFAULT NULL;
+
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+-- Step 2: Create indexes
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+-- Comments by author, e.g. for moderation lookups
+CREATE INDEX IF NOT EXISTS idx_gallery_comment_author ON gallery_comment (author);
+
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+-- Step 3: Add comments
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+COMMENT ON TABLE gallery_comment IS 'Comments from gallery pages, replaced on each fetch';
+COMMENT ON COLUMN gallery_comment.comment_id IS 'Site comment ID, 0 for the uploader comment';
+COMMENT ON COLUMN gallery_comment.uploader IS 'Comment is the uploader comment';
+COMMENT ON COLUMN gallery_comment.score IS 'Comment score (NULL for the uploader comment)';
+COMMENT ON COLUMN gallery_comment.edited IS 'When the comment was last edited (NULL if never)';
+COMMENT ON COLUMN gallery_comment.body IS 'Comment text, one line per line break';
+COMMENT ON COLUMN gallery.comments_synced_at IS 'When the gallery comments were last fetched (NULL if never)';
+
+COMMIT;
@@ -0,0 +1,42 @@
+-- ============================================================================
+-- Schema update 009: gallery removal tracking
+-- ============================================================================
+-- Function: When, why and by what a gallery was marked removed. Set by
+--           resync and fetch when gdata reports a gallery missing and its
+--           page confirms it, and by torrent-import for unavailable torrent
+--           pages; removals from gdata are cleared when gdata returns the
+--           gallery again
+--
+-- Execution:
+--   psql -U user -d ehentai_db -f schema/009_gallery_removal.sql
+--
+-- Safe to run repeatedly
+-- ============================================================================
+
+BEGIN;
+
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+-- Step 1: Add removal columns
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+ALTER TABLE gallery
+    ADD COLUMN IF NOT EXISTS removed_at     TIMESTAMP WITH TIME ZONE DEFAULT NULL,
+    ADD COLUMN IF NOT EXISTS removed_reason VARCHAR(255) DEFAULT NULL,
+    ADD COLUMN IF NOT EXISTS removed_source VARCHAR(20) DEFAULT NULL;
+
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+-- Step 2: Create indexes
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+-- Recent removals, newest first
+CREATE INDEX IF NOT EXISTS idx_gallery_removed_at ON gallery (removed_at DESC) WHERE removed_at IS NOT NULL;
+
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+-- Step 3: Add comments
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+COMMENT ON COLUMN gallery.removed_at IS 'When the gallery was marked removed (NULL if not removed, or removed before tracking)';
+COMMENT ON COLUMN gallery.removed_reason IS 'Why the gallery was marked removed, e.g. the gdata error and the gallery page state';
+COMMENT ON COLUMN gallery.removed_source IS 'What marked the gallery removed: gdata or torrent (NULL if not removed, or removed before tracking); only gdata removals are cleared when gdata returns the gallery';
+
+COMMIT;
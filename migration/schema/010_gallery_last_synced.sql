@@ -0,0 +1,36 @@
+-- ============================================================================
+-- Schema update 010: gallery last synced time
+-- ============================================================================
+-- Function: When gdata last answered for each gallery, set by every import. The
+--           refresh job resyncs the galleries synced longest ago first, so
+--           repeated runs cycle through the whole catalog
+--
+-- Execution:
+--   psql -U user -d ehentai_db -f schema/010_gallery_last_synced.sql
+--
+-- Safe to run repeatedly
+-- ============================================================================
+
+BEGIN;
+
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+-- Step 1: Add last synced column
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+ALTER TABLE gallery
+    ADD COLUMN IF NOT EXISTS last_synced_at TIMESTAMP WITH TIME ZONE DEFAULT NULL;
+
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+-- Step 2: Create indexes
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+-- Stalest galleries first, never synced ones in gid order
+CREATE INDEX IF NOT EXISTS idx_gallery_last_synced_at ON gallery (last_synced_at ASC NULLS FIRST, gid);
+
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+-- Step 3: Add comments
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+COMMENT ON COLUMN gallery.last_synced_at IS 'When gdata last answered for the gallery (NULL if not since this column was added)';
+
+COMMIT;
@@ -0,0 +1,246 @@
+-- ============================================================================
+-- Schema update 011: incrementally maintained gallery statistics
+-- ============================================================================
+-- Function: Replaces gallery_stats_mv and uploader_stats_mv, which every
+--           import rebuilt from the whole gallery table, with counter tables
+--           that triggers on gallery keep exact as rows are inserted, updated
+--           and deleted. The triggers run once per statement over its
+--           transition tables, so a batch upsert of 1000 galleries costs one
+--           counter update per affected key. refresh_all_stats() now only
+--           refreshes tag_stats_mv.
+--
+-- Execution:
+--   psql -U user -d ehentai_db -f schema/011_gallery_stat_counters.sql
+--
+-- Safe to run repeatedly; only the first run creates the triggers and counts
+-- the galleries, later runs leave gallery alone. Run
+-- SELECT recount_gallery_stats(); to repair counters that drifted.
+-- ============================================================================
+
+BEGIN;
+
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+-- Step 1: Create counter tables
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+CREATE TABLE IF NOT EXISTS gallery_stat (
+    stat_key    VARCHAR(64) PRIMARY KEY,
+    stat_value  BIGINT NOT NULL DEFAULT 0,
+    updated_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS uploader_stat (
+    uploader      VARCHAR(50) PRIMARY KEY,
+    gallery_count BIGINT NOT NULL DEFAULT 0,
+    total_pages   BIGINT NOT NULL DEFAULT 0,
+    total_size    NUMERIC NOT NULL DEFAULT 0,
+    updated_at    TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_uploader_stat_count ON uploader_stat (gallery_count DESC);
+
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+-- Step 2: Create counter functions
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+-- The gallery_stat keys a gallery counts towards, the same keys
+-- gallery_stats_mv had
+CREATE OR REPLACE FUNCTION gallery_stat_keys(
+    expunged BOOLEAN,
+    removed BOOLEAN,
+    replaced BOOLEAN,
+    category TEXT,
+    language TEXT
+)
+RETURNS TEXT[] AS $$
+    SELECT array_remove(ARRAY[
+        CASE WHEN NOT expunged THEN 'total_active' END,
+        CASE WHEN removed THEN 'total_removed' END,
+        CASE WHEN replaced THEN 'total_replaced' END,
+        CASE WHEN expunged THEN 'total_expunged' END,
+        CASE WHEN NOT expunged THEN 'category_' || LOWER(category) END,
+        CASE WHEN NOT expunged AND language IS NOT NULL THEN 'language_' || language END
+    ], NULL)
+$$ LANGUAGE sql IMMUTABLE;
+
+-- Applies the rows a statement changed to the counters: rows of new_rows
+-- count +1 and rows of old_rows -1, so an update moves a gallery between
+-- keys and updates that change none of the counted columns, like touching
+-- last_synced_at, add nothing. Counter rows are written in key order so
+-- concurrent imports lock them in the same order.
+CREATE OR REPLACE FUNCTION gallery_stats_apply()
+RETURNS TRIGGER AS $$
+DECLARE
+    counted_columns CONSTANT TEXT := 'expunged, removed, replaced, category, language, uploader, filecount, filesize';
+    changes TEXT;
+BEGIN
+    IF TG_OP = 'TRUNCATE' THEN
+        DELETE FROM gallery_stat;
+        DELETE FROM uploader_stat;
+        RETURN NULL;
+    ELSIF TG_OP = 'INSERT' THEN
+        changes := format('SELECT 1 AS sign, %s FROM new_rows', counted_columns);
+    ELSIF TG_OP = 'UPDATE' THEN
+        changes := format('SELECT 1 AS sign, %s FROM new_rows UNION ALL SELECT -1 AS sign, %s FROM old_rows',
+                          counted_columns, counted_columns);
+    ELSE
+        changes := format('SELECT -1 AS sign, %s FROM old_rows', counted_columns);
+    END IF;
+
+    EXECUTE format($sql$
+        INSERT INTO gallery_stat AS s (stat_key, stat_value, updated_at)
+        SELECT stat_key, SUM(c.sign), NOW()
+        FROM (%s) c
+        CROSS JOIN LATERAL unnest(gallery_stat_keys(c.expunged, c.removed, c.replaced, c.category, c.language)) AS stat_key
+        GROUP BY stat_key
+        HAVING SUM(c.sign) <> 0
+        ORDER BY stat_key
+        ON CONFLICT (stat_key) DO UPDATE
+        SET stat_value = s.stat_value + EXCLUDED.stat_value,
+            updated_at = EXCLUDED.updated_at
+    $sql$, changes);
+
+    EXECUTE format($sql$
+        INSERT INTO uploader_stat AS s (uploader, gallery_count, total_pages, total_size, updated_at)
+        SELECT c.uploader, SUM(c.sign), SUM(c.sign * c.filecount), SUM(c.sign * c.filesize), NOW()
+        FROM (%s) c
+        WHERE c.expunged = FALSE AND c.uploader IS NOT NULL
+        GROUP BY c.uploader
+        HAVING SUM(c.sign) <> 0 OR SUM(c.sign * c.filecount) <> 0 OR SUM(c.sign * c.filesize) <> 0
+        ORDER BY c.uploader
+        ON CONFLICT (uploader) DO UPDATE
+        SET gallery_count = s.gallery_count + EXCLUDED.gallery_count,
+            total_pages = s.total_pages + EXCLUDED.total_pages,
+            total_size = s.total_size + EXCLUDED.total_size,
+            updated_at = EXCLUDED.updated_at
+    $sql$, changes);
+
+    -- Uploaders left without galleries
+    EXECUTE format($sql$
+        DELETE FROM uploader_stat
+        WHERE gallery_count <= 0
+          AND uploader IN (SELECT c.uploader FROM (%s) c WHERE c.uploader IS NOT NULL)
+    $sql$, changes);
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Rebuilds the counters from gallery, blocking writes to gallery until the
+-- calling transaction ends. Use it to repair counters after changing gallery
+-- with the triggers disabled, e.g. session_replication_role = replica.
+CREATE OR REPLACE FUNCTION recount_gallery_stats()
+RETURNS VOID AS $$
+BEGIN
+    LOCK TABLE gallery IN SHARE MODE;
+
+    DELETE FROM gallery_stat;
+    INSERT INTO gallery_stat (stat_key, stat_value, updated_at)
+    SELECT stat_key, COUNT(*), NOW()
+    FROM gallery g
+    CROSS JOIN LATERAL unnest(gallery_stat_keys(g.expunged, g.removed, g.replaced, g.category, g.language)) AS stat_key
+    GROUP BY stat_key;
+
+    DELETE FROM uploader_stat;
+    INSERT INTO uploader_stat (uploader, gallery_count, total_pages, total_size, updated_at)
+    SELECT uploader, COUNT(*), SUM(filecount), SUM(filesize), NOW()
+    FROM gallery
+    WHERE expunged = FALSE AND uploader IS NOT NULL
+    GROUP BY uploader;
+END;
+$$ LANGUAGE plpgsql;
+
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+-- Step 3: Create triggers and fill the counters
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+-- Transition tables need one trigger per event. The triggers are created,
+-- and the counters filled, only on the first run: creating a trigger locks
+-- gallery against writes and the recount scans it, which reruns from the
+-- scheduled workflows must not pay.
+DO $$
+DECLARE
+    created BOOLEAN := FALSE;
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgrelid = 'gallery'::regclass AND tgname = 'gallery_stats_insert') THEN
+        CREATE TRIGGER gallery_stats_insert
+            AFTER INSERT ON gallery
+            REFERENCING NEW TABLE AS new_rows
+            FOR EACH STATEMENT EXECUTE FUNCTION gallery_stats_apply();
+        created := TRUE;
+    END IF;
+
+    IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgrelid = 'gallery'::regclass AND tgname = 'gallery_stats_update') THEN
+        CREATE TRIGGER gallery_stats_update
+            AFTER UPDATE ON gallery
+            REFERENCING OLD TABLE AS old_rows NEW TABLE AS new_rows
+            FOR EACH STATEMENT EXECUTE FUNCTION gallery_stats_apply();
+        created := TRUE;
+    END IF;
+
+    IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgrelid = 'gallery'::regclass AND tgname = 'gallery_stats_delete') THEN
+        CREATE TRIGGER gallery_stats_delete
+            AFTER DELETE ON gallery
+            REFERENCING OLD TABLE AS old_rows
+            FOR EACH STATEMENT EXECUTE FUNCTION gallery_stats_apply();
+        created := TRUE;
+    END IF;
+
+    IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgrelid = 'gallery'::regclass AND tgname = 'gallery_stats_truncate') THEN
+        CREATE TRIGGER gallery_stats_truncate
+            AFTER TRUNCATE ON gallery
+            FOR EACH STATEMENT EXECUTE FUNCTION gallery_stats_apply();
+        created := TRUE;
+    END IF;
+
+    -- Creating a trigger locks gallery against writes until COMMIT, so no
+    -- change is missed by the recount or counted twice
+    IF created THEN
+        PERFORM recount_gallery_stats();
+    END IF;
+END $$;
+
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+-- Step 4: Drop the replaced materialized views from refresh_all_stats
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+CREATE OR REPLACE FUNCTION refresh_all_stats(concurrent_mode BOOLEAN DEFAULT TRUE)
+RETURNS TEXT AS $$
+DECLARE
+    start_time TIMESTAMP;
+    result_text TEXT;
+BEGIN
+    start_time := clock_timestamp();
+
+    IF concurrent_mode THEN
+        REFRESH MATERIALIZED VIEW CONCURRENTLY tag_stats_mv;
+        result_text := 'All stats refreshed concurrently';
+    ELSE
+        REFRESH MATERIALIZED VIEW tag_stats_mv;
+        result_text := 'All stats refreshed';
+    END IF;
+
+    RETURN result_text || ' in ' ||
+           EXTRACT(EPOCH FROM (clock_timestamp() - start_time))::TEXT || ' seconds';
+END;
+$$ LANGUAGE plpgsql;
+
+-- Dropping gallery_stats_mv is final: 004 only rebuilds an existing view
+-- that lacks language rows, so rerunning every script in order does not
+-- bring it back
+DROP MATERIALIZED VIEW IF EXISTS gallery_stats_mv;
+DROP MATERIALIZED VIEW IF EXISTS uploader_stats_mv;
+
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+-- Step 5: Add comments
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+COMMENT ON TABLE gallery_stat IS 'Gallery counts by status, category and language, kept exact by triggers on gallery';
+COMMENT ON COLUMN gallery_stat.stat_key IS 'total_active, total_removed, total_replaced, total_expunged, category_<category> or language_<language>; active, category and language counts exclude expunged galleries';
+COMMENT ON TABLE uploader_stat IS 'Non-expunged gallery counts per uploader, kept exact by triggers on gallery';
+COMMENT ON FUNCTION gallery_stat_keys IS 'The gallery_stat keys a gallery with the given flags, category and language counts towards';
+COMMENT ON FUNCTION gallery_stats_apply IS 'Statement trigger applying inserted, updated and deleted galleries to gallery_stat and uploader_stat';
+COMMENT ON FUNCTION recount_gallery_stats IS 'Rebuilds gallery_stat and uploader_stat from gallery';
+COMMENT ON FUNCTION refresh_all_stats IS 'Refreshes tag_stats_mv; gallery and uploader counts are maintained by triggers';
+
+COMMIT;
@@ -0,0 +1,36 @@
+-- ============================================================================
+-- Schema update 012: one scheduled run per cron firing
+-- ============================================================================
+-- Function: Records the cron firing each scheduled run belongs to. Every
+--           scheduler instance fires the same cron entry; the first to insert
+--           the firing's row runs it and the others skip it, however late a
+--           priority wait or queued run makes them.
+--
+-- Execution:
+--   psql -U user -d ehentai_db -f schema/012_job_run_firings.sql
+--
+-- Safe to run repeatedly
+-- ============================================================================
+
+BEGIN;
+
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+-- Step 1: Add firing column
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+ALTER TABLE job_run ADD COLUMN IF NOT EXISTS scheduled_for TIMESTAMP WITH TIME ZONE DEFAULT NULL;
+
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+-- Step 2: Create indexes
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+-- CLI and admin runs leave scheduled_for NULL, which never conflicts
+CREATE UNIQUE INDEX IF NOT EXISTS idx_job_run_kind_scheduled_for ON job_run (kind, scheduled_for);
+
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+-- Step 3: Add comments
+-- ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+COMMENT ON COLUMN job_run.scheduled_for IS 'Cron firing a scheduled run belongs to, unique per kind; NULL for CLI and admin runs';
+
+COMMIT;
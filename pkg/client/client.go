@@ -0,0 +1,343 @@
+// Package client is a Go client for the ehdb HTTP API.
+//
+//	c := client.New("http://localhost:8880", nil)
+//	page, err := c.Search(ctx, client.SearchOptions{Keyword: "female:elf"})
+//
+// Every listing method has an Iter counterpart that follows next_cursor
+// until the results run out.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slinet/ehdb/internal/database"
+)
+
+// Gallery, Torrent and Comment are the API's gallery, torrent and comment records
+type (
+	Gallery = database.Gallery
+	Torrent = database.Torrent
+	Comment = database.Comment
+)
+
+// defaultTimeout is used when New is given no HTTP client
+const defaultTimeout = 30 * time.Second
+
+// Client calls the ehdb API
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// New creates a client for the API served at baseURL (e.g. "http://localhost:8880").
+// A nil httpClient uses a client with a 30 second timeout.
+func New(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: defaultTimeout}
+	}
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: httpClient,
+	}
+}
+
+// Page is one page of galleries from a listing endpoint
+type Page struct {
+	Galleries []Gallery
+	Total     int64
+	// NextCursor is passed as ListOptions.Cursor to fetch the following page.
+	// It is empty when the page has no results.
+	NextCursor string
+}
+
+// ListOptions are the pagination and output parameters shared by every listing endpoint
+type ListOptions struct {
+	Page      int    // Page number for page-based pagination (ignored when Cursor is set)
+	Limit     int    // Results per page (server default when 0)
+	Cursor    string // "timestamp,gid" cursor from a previous Page
+	Lang      string // Only galleries with this primary language, e.g. "english"
+	Translate bool   // Fill Gallery.TagTranslations
+}
+
+func (o ListOptions) values() url.Values {
+	v := url.Values{}
+	if o.Cursor != "" {
+		v.Set("cursor", o.Cursor)
+	} else if o.Page > 0 {
+		v.Set("page", strconv.Itoa(o.Page))
+	}
+	if o.Limit > 0 {
+		v.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Lang != "" {
+		v.Set("lang", o.Lang)
+	}
+	if o.Translate {
+		v.Set("translate", "1")
+	}
+	return v
+}
+
+// SearchOptions are the parameters of GET /api/search
+type SearchOptions struct {
+	ListOptions
+	Keyword    string   // Search keyword using the search syntax
+	Categories []string // Category names, e.g. "Doujinshi"
+	Expunged   bool     // Include expunged galleries
+	Removed    bool     // Include removed galleries
+	Replaced   bool     // Include replaced galleries
+	MinPage    int
+	MaxPage    int
+	MinRating  float64
+	MinDate    time.Time
+	MaxDate    time.Time
+}
+
+func (o SearchOptions) values() url.Values {
+	v := o.ListOptions.values()
+	if o.Keyword != "" {
+		v.Set("keyword", o.Keyword)
+	}
+	if len(o.Categories) > 0 {
+		v.Set("category", strings.Join(o.Categories, ","))
+	}
+	if o.Expunged {
+		v.Set("expunged", "1")
+	}
+	if o.Removed {
+		v.Set("removed", "1")
+	}
+	if o.Replaced {
+		v.Set("replaced", "1")
+	}
+	if o.MinPage > 0 {
+		v.Set("minpage", strconv.Itoa(o.MinPage))
+	}
+	if o.MaxPage > 0 {
+		v.Set("maxpage", strconv.Itoa(o.MaxPage))
+	}
+	if o.MinRating > 0 {
+		v.Set("minrating", strconv.FormatFloat(o.MinRating, 'f', -1, 64))
+	}
+	if !o.MinDate.IsZero() {
+		v.Set("mindate", strconv.FormatInt(o.MinDate.Unix(), 10))
+	}
+	if !o.MaxDate.IsZero() {
+		v.Set("maxdate", strconv.FormatInt(o.MaxDate.Unix(), 10))
+	}
+	return v
+}
+
+// Health calls GET /health and returns nil when the server is up
+func (c *Client) Health(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/health", nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request /health: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return &APIError{StatusCode: resp.StatusCode, Code: resp.StatusCode, Message: resp.Status}
+	}
+	return nil
+}
+
+// Gallery calls GET /api/gallery/:gid/:token
+func (c *Client) Gallery(ctx context.Context, gid int, token string, translate bool) (*Gallery, error) {
+	v := url.Values{}
+	if translate {
+		v.Set("translate", "1")
+	}
+
+	var gallery Gallery
+	path := "/api/gallery/" + strconv.Itoa(gid) + "/" + url.PathEscape(token)
+	if _, err := c.get(ctx, path, v, &gallery); err != nil {
+		return nil, err
+	}
+	return &gallery, nil
+}
+
+// Comments calls GET /api/gallery/:gid/comments. Galleries never crawled
+// with comments enabled have none.
+func (c *Client) Comments(ctx context.Context, gid int) ([]Comment, error) {
+	var comments []Comment
+	if _, err := c.get(ctx, "/api/gallery/"+strconv.Itoa(gid)+"/comments", nil, &comments); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+// List calls GET /api/list
+func (c *Client) List(ctx context.Context, opts ListOptions) (*Page, error) {
+	return c.page(ctx, "/api/list", opts.values())
+}
+
+// Search calls GET /api/search
+func (c *Client) Search(ctx context.Context, opts SearchOptions) (*Page, error) {
+	return c.page(ctx, "/api/search", opts.values())
+}
+
+// Tag calls GET /api/tag/:tag. Galleries must carry every tag in tags.
+func (c *Client) Tag(ctx context.Context, tags []string, opts ListOptions) (*Page, error) {
+	return c.page(ctx, "/api/tag/"+url.PathEscape(strings.Join(tags, ",")), opts.values())
+}
+
+// Category calls GET /api/category/:category. Galleries may be in any of categories.
+func (c *Client) Category(ctx context.Context, categories []string, opts ListOptions) (*Page, error) {
+	return c.page(ctx, "/api/category/"+url.PathEscape(strings.Join(categories, ",")), opts.values())
+}
+
+// Uploader calls GET /api/uploader/:uploader
+func (c *Client) Uploader(ctx context.Context, uploader string, opts ListOptions) (*Page, error) {
+	return c.page(ctx, "/api/uploader/"+url.PathEscape(uploader), opts.values())
+}
+
+// Language calls GET /api/language/:lang
+func (c *Client) Language(ctx context.Context, lang string, opts ListOptions) (*Page, error) {
+	return c.page(ctx, "/api/language/"+url.PathEscape(lang), opts.values())
+}
+
+// ListIter iterates over every gallery returned by GET /api/list
+func (c *Client) ListIter(opts ListOptions) *Iterator {
+	return newIterator(func(ctx context.Context, cursor string) (*Page, error) {
+		opts.Cursor = cursor
+		return c.List(ctx, opts)
+	}, opts.Cursor)
+}
+
+// SearchIter iterates over every gallery returned by GET /api/search
+func (c *Client) SearchIter(opts SearchOptions) *Iterator {
+	return newIterator(func(ctx context.Context, cursor string) (*Page, error) {
+		opts.Cursor = cursor
+		return c.Search(ctx, opts)
+	}, opts.Cursor)
+}
+
+// TagIter iterates over every gallery returned by GET /api/tag/:tag
+func (c *Client) TagIter(tags []string, opts ListOptions) *Iterator {
+	return newIterator(func(ctx context.Context, cursor string) (*Page, error) {
+		opts.Cursor = cursor
+		return c.Tag(ctx, tags, opts)
+	}, opts.Cursor)
+}
+
+// CategoryIter iterates over every gallery returned by GET /api/category/:category
+func (c *Client) CategoryIter(categories []string, opts ListOptions) *Iterator {
+	return newIterator(func(ctx context.Context, cursor string) (*Page, error) {
+		opts.Cursor = cursor
+		return c.Category(ctx, categories, opts)
+	}, opts.Cursor)
+}
+
+// UploaderIter iterates over every gallery returned by GET /api/uploader/:uploader
+func (c *Client) UploaderIter(uploader string, opts ListOptions) *Iterator {
+	return newIterator(func(ctx context.Context, cursor string) (*Page, error) {
+		opts.Cursor = cursor
+		return c.Uploader(ctx, uploader, opts)
+	}, opts.Cursor)
+}
+
+// LanguageIter iterates over every gallery returned by GET /api/language/:lang
+func (c *Client) LanguageIter(lang string, opts ListOptions) *Iterator {
+	return newIterator(func(ctx context.Context, cursor string) (*Page, error) {
+		opts.Cursor = cursor
+		return c.Language(ctx, lang, opts)
+	}, opts.Cursor)
+}
+
+// response mirrors database.APIResponse with the data left undecoded
+type response struct {
+	Data       json.RawMessage `json:"data"`
+	Code       int             `json:"code"`
+	Message    string          `json:"message"`
+	Total      *int64          `json:"total"`
+	NextCursor *string         `json:"next_cursor"`
+}
+
+// page fetches a listing endpoint
+func (c *Client) page(ctx context.Context, path string, query url.Values) (*Page, error) {
+	var galleries []Gallery
+	resp, err := c.get(ctx, path, query, &galleries)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &Page{Galleries: galleries}
+	if resp.Total != nil {
+		page.Total = *resp.Total
+	}
+	if resp.NextCursor != nil {
+		page.NextCursor = *resp.NextCursor
+	}
+	return page, nil
+}
+
+// get performs a GET request and decodes the envelope's data into out.
+// Responses whose code is not 200 are returned as *APIError.
+func (c *Client) get(ctx context.Context, path string, query url.Values, out interface{}) (*response, error) {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	httpResp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request %s: %w", path, err)
+	}
+	defer httpResp.Body.Close()
+
+	body, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read %s response: %w", path, err)
+	}
+
+	var resp response
+	if err := json.Unmarshal(body, &resp); err != nil {
+		// Not an API envelope, e.g. a proxy error page
+		return nil, &APIError{
+			StatusCode: httpResp.StatusCode,
+			Code:       httpResp.StatusCode,
+			Message:    fmt.Sprintf("unexpected response: %s", truncate(string(body), 200)),
+		}
+	}
+
+	if resp.Code != http.StatusOK {
+		return nil, &APIError{StatusCode: httpResp.StatusCode, Code: resp.Code, Message: resp.Message}
+	}
+
+	if out != nil && len(resp.Data) > 0 && string(resp.Data) != "null" {
+		if err := json.Unmarshal(resp.Data, out); err != nil {
+			return nil, fmt.Errorf("decode %s data: %w", path, err)
+		}
+	}
+
+	return &resp, nil
+}
+
+// truncate shortens s to at most n bytes for error messages
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
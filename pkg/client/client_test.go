@@ -0,0 +1,286 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/slinet/ehdb/internal/config"
+	"github.com/slinet/ehdb/internal/database"
+	"github.com/slinet/ehdb/internal/handler"
+	"github.com/slinet/ehdb/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// newAPIServer runs the real API routes behind an httptest server
+func newAPIServer(t *testing.T) *Client {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler.RegisterRoutes(router, zap.NewNop())
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	return New(server.URL, server.Client())
+}
+
+func TestClientHealth(t *testing.T) {
+	c := newAPIServer(t)
+	if err := c.Health(context.Background()); err != nil {
+		t.Fatalf("expected healthy server, got %v", err)
+	}
+}
+
+// TestClientErrors covers requests rejected by the handlers before they reach the database
+func TestClientErrors(t *testing.T) {
+	c := newAPIServer(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		call    func() error
+		message string
+	}{
+		{
+			name: "gallery token",
+			call: func() error {
+				_, err := c.Gallery(ctx, 123, "not-a-token", false)
+				return err
+			},
+			message: "gid or token is invalid",
+		},
+		{
+			name: "comments gid",
+			call: func() error {
+				_, err := c.Comments(ctx, -1)
+				return err
+			},
+			message: "gid is invalid",
+		},
+		{
+			name: "list limit",
+			call: func() error {
+				_, err := c.List(ctx, ListOptions{Limit: 100000})
+				return err
+			},
+			message: "limit is too large",
+		},
+		{
+			name: "list cursor",
+			call: func() error {
+				_, err := c.List(ctx, ListOptions{Cursor: "yesterday"})
+				return err
+			},
+			message: "invalid cursor format, expected 'timestamp,gid'",
+		},
+		{
+			name: "search limit",
+			call: func() error {
+				_, err := c.Search(ctx, SearchOptions{Keyword: "female:elf", ListOptions: ListOptions{Limit: 100000}})
+				return err
+			},
+			message: "limit is too large",
+		},
+		{
+			name: "empty tag",
+			call: func() error {
+				_, err := c.Tag(ctx, []string{" "}, ListOptions{})
+				return err
+			},
+			message: "tag is not defined",
+		},
+		{
+			name: "empty category",
+			call: func() error {
+				_, err := c.Category(ctx, []string{" ", " "}, ListOptions{})
+				return err
+			},
+			message: "invalid category",
+		},
+		{
+			name: "uploader cursor",
+			call: func() error {
+				_, err := c.Uploader(ctx, "someone", ListOptions{Cursor: "1704067200,abc"})
+				return err
+			},
+			message: "invalid cursor gid",
+		},
+		{
+			name: "invalid language",
+			call: func() error {
+				_, err := c.Language(ctx, "english; drop", ListOptions{})
+				return err
+			},
+			message: "lang is invalid",
+		},
+		{
+			name: "invalid language filter",
+			call: func() error {
+				_, err := c.List(ctx, ListOptions{Lang: "123"})
+				return err
+			},
+			message: "lang is invalid",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("expected *APIError, got %v", err)
+			}
+			if apiErr.StatusCode != http.StatusBadRequest || apiErr.Code != http.StatusBadRequest {
+				t.Fatalf("expected status and code 400, got %d and %d", apiErr.StatusCode, apiErr.Code)
+			}
+			if apiErr.Message != tt.message {
+				t.Fatalf("expected message %q, got %q", tt.message, apiErr.Message)
+			}
+			if !errors.Is(err, ErrBadRequest) || errors.Is(err, ErrNotFound) {
+				t.Fatalf("expected error to match only ErrBadRequest, got %v", err)
+			}
+		})
+	}
+}
+
+// TestIteratorFollowsCursor serves three pages in the API envelope and
+// checks that the iterator passes each next_cursor back
+func TestIteratorFollowsCursor(t *testing.T) {
+	pages := map[string][]database.Gallery{
+		"":             {{Gid: 5, Token: "aaaaaaaaaa"}, {Gid: 4, Token: "bbbbbbbbbb"}},
+		"1700000004,4": {{Gid: 3, Token: "cccccccccc"}, {Gid: 2, Token: "dddddddddd"}},
+		"1700000002,2": {{Gid: 1, Token: "eeeeeeeeee"}},
+		"1700000001,1": {},
+	}
+
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/tag/female:elf,female:dark skin" {
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+		cursor := r.URL.Query().Get("cursor")
+		requested = append(requested, cursor)
+
+		galleries := pages[cursor]
+		for i := range galleries {
+			galleries[i].Posted = database.UnixTime{Time: time.Unix(int64(1700000000+galleries[i].Gid), 0)}
+		}
+
+		total := int64(5)
+		gin.SetMode(gin.TestMode)
+		c, _ := gin.CreateTestContext(w)
+		if len(galleries) == 0 {
+			c.JSON(200, utils.GetResponse(galleries, 200, "success", &total))
+			return
+		}
+		last := galleries[len(galleries)-1]
+		next := strconv.FormatInt(last.Posted.Unix(), 10) + "," + strconv.Itoa(last.Gid)
+		c.JSON(200, utils.GetResponseWithCursor(galleries, 200, "success", &total, &next))
+	}))
+	defer server.Close()
+
+	c := New(server.URL, server.Client())
+	it := c.TagIter([]string{"female:elf", "female:dark skin"}, ListOptions{Limit: 2})
+
+	var gids []int
+	for it.Next(context.Background()) {
+		g := it.Gallery()
+		if g.Posted.Unix() != int64(1700000000+g.Gid) {
+			t.Fatalf("expected posted %d for gid %d, got %d", 1700000000+g.Gid, g.Gid, g.Posted.Unix())
+		}
+		gids = append(gids, g.Gid)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(gids) != 5 || gids[0] != 5 || gids[4] != 1 {
+		t.Fatalf("expected gids 5..1, got %v", gids)
+	}
+	if len(requested) != 4 || requested[3] != "1700000001,1" {
+		t.Fatalf("expected 4 requests ending with cursor 1700000001,1, got %v", requested)
+	}
+}
+
+func TestIteratorStopsOnError(t *testing.T) {
+	c := newAPIServer(t)
+	it := c.ListIter(ListOptions{Limit: 100000})
+
+	if it.Next(context.Background()) {
+		t.Fatalf("expected no galleries")
+	}
+	if !errors.Is(it.Err(), ErrBadRequest) {
+		t.Fatalf("expected ErrBadRequest, got %v", it.Err())
+	}
+}
+
+// TestClientWithDatabase runs read-only requests against a populated
+// database. Set EHDB_TEST_CONFIG to a config file path to enable it.
+func TestClientWithDatabase(t *testing.T) {
+	configPath := os.Getenv("EHDB_TEST_CONFIG")
+	if configPath == "" {
+		t.Skip("EHDB_TEST_CONFIG not set")
+	}
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if err := database.Init(&cfg.Database, zap.NewNop()); err != nil {
+		t.Fatalf("failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	c := newAPIServer(t)
+	ctx := context.Background()
+
+	it := c.ListIter(ListOptions{Limit: 5})
+	var galleries []Gallery
+	for len(galleries) < 12 && it.Next(ctx) {
+		galleries = append(galleries, it.Gallery())
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(galleries) == 0 {
+		t.Skip("database has no galleries")
+	}
+
+	for i := 1; i < len(galleries); i++ {
+		prev, cur := galleries[i-1], galleries[i]
+		if cur.Posted.After(prev.Posted.Time) || (cur.Posted.Equal(prev.Posted.Time) && cur.Gid >= prev.Gid) {
+			t.Fatalf("expected descending (posted, gid) order, got %d after %d", cur.Gid, prev.Gid)
+		}
+	}
+
+	first := galleries[0]
+	gallery, err := c.Gallery(ctx, first.Gid, first.Token, false)
+	if err != nil {
+		t.Fatalf("expected gallery %d, got %v", first.Gid, err)
+	}
+	if gallery.Gid != first.Gid || gallery.Title != first.Title {
+		t.Fatalf("expected gallery %d %q, got %d %q", first.Gid, first.Title, gallery.Gid, gallery.Title)
+	}
+
+	_, err = c.Gallery(ctx, 1<<30, "0000000000", false)
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	if _, err := c.Comments(ctx, first.Gid); err != nil {
+		t.Fatalf("expected comments of gallery %d, got %v", first.Gid, err)
+	}
+	_, err = c.Comments(ctx, 1<<30)
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Sentinel errors matched by APIError codes with errors.Is
+var (
+	ErrBadRequest = errors.New("bad request")
+	ErrNotFound   = errors.New("not found")
+	ErrServer     = errors.New("server error")
+)
+
+// APIError is returned when the API responds with a non-success code
+type APIError struct {
+	StatusCode int    // HTTP status code
+	Code       int    // Code from the response envelope
+	Message    string // Message from the response envelope
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("ehdb api error %d: %s", e.Code, e.Message)
+}
+
+// Is maps the envelope code onto the sentinel errors, so callers can write
+// errors.Is(err, client.ErrNotFound)
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.Code == http.StatusBadRequest
+	case ErrNotFound:
+		return e.Code == http.StatusNotFound
+	case ErrServer:
+		return e.Code >= http.StatusInternalServerError
+	default:
+		return false
+	}
+}
@@ -0,0 +1,68 @@
+package client
+
+import "context"
+
+// Iterator walks a listing endpoint page by page following next_cursor.
+//
+//	it := c.TagIter([]string{"female:elf"}, client.ListOptions{Limit: 100})
+//	for it.Next(ctx) {
+//		g := it.Gallery()
+//	}
+//	if err := it.Err(); err != nil { ... }
+type Iterator struct {
+	fetch   func(ctx context.Context, cursor string) (*Page, error)
+	cursor  string
+	page    []Gallery
+	index   int
+	current Gallery
+	done    bool
+	err     error
+}
+
+func newIterator(fetch func(ctx context.Context, cursor string) (*Page, error), cursor string) *Iterator {
+	return &Iterator{fetch: fetch, cursor: cursor}
+}
+
+// Next advances to the next gallery, fetching the following page when the
+// current one is exhausted. It returns false when the results run out or a
+// request fails; check Err afterwards.
+func (it *Iterator) Next(ctx context.Context) bool {
+	for it.index >= len(it.page) {
+		if it.done || it.err != nil {
+			return false
+		}
+
+		page, err := it.fetch(ctx, it.cursor)
+		if err != nil {
+			it.err = err
+			return false
+		}
+
+		it.page = page.Galleries
+		it.index = 0
+		if page.NextCursor == "" || page.NextCursor == it.cursor || len(page.Galleries) == 0 {
+			it.done = true
+		}
+		it.cursor = page.NextCursor
+	}
+
+	it.current = it.page[it.index]
+	it.index++
+	return true
+}
+
+// Gallery returns the gallery Next advanced to
+func (it *Iterator) Gallery() Gallery {
+	return it.current
+}
+
+// Cursor returns the cursor of the next page to fetch, which can be stored
+// to resume iteration later through ListOptions.Cursor
+func (it *Iterator) Cursor() string {
+	return it.cursor
+}
+
+// Err returns the error that stopped iteration, if any
+func (it *Iterator) Err() error {
+	return it.err
+}
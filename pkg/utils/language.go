@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+)
+
+// GalleryLanguage is the language information derived from a gallery's language: tags
+type GalleryLanguage struct {
+	Language   string // Primary language, e.g. "english" (empty when unknown)
+	Translated bool   // Tagged language:translated
+	Rewrite    bool   // Tagged language:rewrite
+}
+
+// languageFlagTags are language: namespace entries that describe the text
+// rather than name a language
+var languageFlagTags = map[string]struct{}{
+	"translated":   {},
+	"rewrite":      {},
+	"text cleaned": {},
+	"speechless":   {},
+}
+
+// implicitJapaneseCategories are categories where E-Hentai treats galleries
+// without a language tag as Japanese
+var implicitJapaneseCategories = map[string]struct{}{
+	"doujinshi": {},
+	"manga":     {},
+	"artist cg": {},
+	"game cg":   {},
+}
+
+var languagePattern = regexp.MustCompile(`^[a-z]+( [a-z]+)*$`)
+
+// DeriveLanguage picks the primary language of a gallery from its tags. When
+// a translated gallery lists several languages the first non-Japanese one
+// wins, since Japanese is then usually the source language. Galleries without
+// a language tag in text-bearing categories default to Japanese.
+func DeriveLanguage(tags []string, category string) GalleryLanguage {
+	var result GalleryLanguage
+	var languages []string
+
+	for _, tag := range tags {
+		value, ok := strings.CutPrefix(tag, "language:")
+		if !ok {
+			continue
+		}
+
+		switch value {
+		case "translated":
+			result.Translated = true
+		case "rewrite":
+			result.Rewrite = true
+		}
+
+		if _, isFlag := languageFlagTags[value]; isFlag || value == "" {
+			continue
+		}
+		languages = append(languages, value)
+	}
+
+	for _, language := range languages {
+		if result.Language == "" {
+			result.Language = language
+		}
+		if result.Translated && result.Language == "japanese" && language != "japanese" {
+			result.Language = language
+		}
+	}
+
+	if result.Language == "" {
+		if _, ok := implicitJapaneseCategories[strings.ToLower(category)]; ok {
+			result.Language = "japanese"
+		}
+	}
+
+	return result
+}
+
+// NormalizeLanguage lowercases and validates a language name from a request.
+// It returns false for values that cannot be a language tag.
+func NormalizeLanguage(language string) (string, bool) {
+	language = strings.Join(strings.Fields(strings.ToLower(language)), " ")
+	language = strings.TrimPrefix(language, "language:")
+	if language == "" || len(language) > 20 || !languagePattern.MatchString(language) {
+		return "", false
+	}
+	return language, true
+}
@@ -0,0 +1,82 @@
+package utils
+
+import "testing"
+
+func TestDeriveLanguage(t *testing.T) {
+	tests := []struct {
+		name     string
+		tags     []string
+		category string
+		expected GalleryLanguage
+	}{
+		{
+			name:     "single language",
+			tags:     []string{"language:english", "female:elf"},
+			category: "Manga",
+			expected: GalleryLanguage{Language: "english"},
+		},
+		{
+			name:     "translated with japanese source listed first",
+			tags:     []string{"language:japanese", "language:translated", "language:chinese"},
+			category: "Doujinshi",
+			expected: GalleryLanguage{Language: "chinese", Translated: true},
+		},
+		{
+			name:     "rewrite flag",
+			tags:     []string{"language:english", "language:rewrite"},
+			category: "Doujinshi",
+			expected: GalleryLanguage{Language: "english", Rewrite: true},
+		},
+		{
+			name:     "speechless is not a language",
+			tags:     []string{"language:speechless"},
+			category: "Image Set",
+			expected: GalleryLanguage{},
+		},
+		{
+			name:     "untagged text category defaults to japanese",
+			tags:     []string{"female:elf"},
+			category: "Artist CG",
+			expected: GalleryLanguage{Language: "japanese"},
+		},
+		{
+			name:     "untagged image set stays unknown",
+			tags:     nil,
+			category: "Image Set",
+			expected: GalleryLanguage{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DeriveLanguage(tt.tags, tt.category)
+			if got != tt.expected {
+				t.Fatalf("expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestNormalizeLanguage(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		ok       bool
+	}{
+		{input: "English", expected: "english", ok: true},
+		{input: "language:chinese", expected: "chinese", ok: true},
+		{input: "  portuguese ", expected: "portuguese", ok: true},
+		{input: "", ok: false},
+		{input: "english'; --", ok: false},
+		{input: "averyveryverylonglanguagename", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, ok := NormalizeLanguage(tt.input)
+			if ok != tt.ok || got != tt.expected {
+				t.Fatalf("expected (%q, %v), got (%q, %v)", tt.expected, tt.ok, got, ok)
+			}
+		})
+	}
+}
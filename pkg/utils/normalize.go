@@ -24,8 +24,15 @@ var shortMap = map[string]string{
 	"r":      "reclass",
 }
 
-// NormalizeTag normalizes a tag by expanding shortcuts and converting to lowercase
+// NormalizeTag normalizes a tag by expanding shortcuts and converting to lowercase,
+// then rewrites it to its canonical name when a tag alias is loaded
 func NormalizeTag(tag string) string {
+	return CurrentTagRules().Canonical(NormalizeTagName(tag))
+}
+
+// NormalizeTagName performs the alias-independent part of NormalizeTag,
+// for callers that manage the aliases themselves
+func NormalizeTagName(tag string) string {
 	// Trim whitespace
 	tag = strings.TrimSpace(tag)
 
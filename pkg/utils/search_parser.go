@@ -314,3 +314,49 @@ func ExpandTagPrefixes(tagPrefixes []string, tagFetcher func(string) []string) [
 	}
 	return expandedTags
 }
+
+// Markers used for tag terms inside Excludes and OrGroups
+const (
+	tagExactMarker  = "TAG_EXACT:"
+	tagPrefixMarker = "TAG_PREFIX:"
+)
+
+// AllTags returns every exact and prefix tag referenced by the query,
+// including excluded and OR tags, without their markers
+func (q *SearchQuery) AllTags() []string {
+	var tags []string
+	q.MapTags(func(tag string) string {
+		tags = append(tags, tag)
+		return tag
+	})
+	return tags
+}
+
+// MapTags replaces every exact and prefix tag referenced by the query,
+// including excluded and OR tags, with mapper(tag). Title terms are untouched.
+func (q *SearchQuery) MapTags(mapper func(string) string) {
+	for i, tag := range q.Tags {
+		q.Tags[i] = mapper(tag)
+	}
+	for i, tag := range q.TagPrefixes {
+		q.TagPrefixes[i] = mapper(tag)
+	}
+
+	mapTerm := func(term string) string {
+		for _, marker := range []string{tagExactMarker, tagPrefixMarker} {
+			if strings.HasPrefix(term, marker) {
+				return marker + mapper(strings.TrimPrefix(term, marker))
+			}
+		}
+		return term
+	}
+
+	for i, term := range q.Excludes {
+		q.Excludes[i] = mapTerm(term)
+	}
+	for _, group := range q.OrGroups {
+		for i, term := range group {
+			group[i] = mapTerm(term)
+		}
+	}
+}
@@ -453,3 +453,34 @@ func TestNormalizeTag(t *testing.T) {
 		})
 	}
 }
+
+func TestSearchQueryMapTags(t *testing.T) {
+	query := ParseSearchKeyword(`f:巨乳$ female:elf -female:巨乳 ~female:巨乳$ ~title comic`)
+
+	query.MapTags(func(tag string) string {
+		if tag == "female:巨乳" {
+			return "female:big breasts"
+		}
+		return tag
+	})
+
+	if !reflect.DeepEqual(query.Tags, []string{"female:big breasts"}) {
+		t.Fatalf("expected mapped exact tag, got %v", query.Tags)
+	}
+	if !reflect.DeepEqual(query.TagPrefixes, []string{"female:elf"}) {
+		t.Fatalf("expected untouched prefix tag, got %v", query.TagPrefixes)
+	}
+	if !reflect.DeepEqual(query.Excludes, []string{"TAG_PREFIX:female:big breasts"}) {
+		t.Fatalf("expected mapped excluded tag, got %v", query.Excludes)
+	}
+	if !reflect.DeepEqual(query.OrGroups, [][]string{{"TAG_EXACT:female:big breasts", "title"}}) {
+		t.Fatalf("expected mapped OR tag, got %v", query.OrGroups)
+	}
+	if !reflect.DeepEqual(query.Keywords, []string{"comic"}) {
+		t.Fatalf("expected untouched keywords, got %v", query.Keywords)
+	}
+
+	if got := query.AllTags(); len(got) != 4 {
+		t.Fatalf("expected 4 tags, got %v", got)
+	}
+}
@@ -0,0 +1,163 @@
+package utils
+
+import (
+	"sort"
+	"sync/atomic"
+)
+
+// maxAliasDepth bounds alias chain resolution so a misconfigured cycle
+// (a -> b -> a) cannot loop forever.
+const maxAliasDepth = 16
+
+// TagRules holds admin-managed tag aliases and implications.
+//
+// Aliases rewrite an old or merged tag name to its canonical name
+// (e.g. "female:old name" -> "female:new name"). Implications declare that a
+// gallery carrying one tag implicitly carries another
+// (e.g. "parody:x" implies "character:y"), so a search for the implied tag
+// should also match galleries tagged only with the implying tag.
+type TagRules struct {
+	aliases   map[string]string   // alias -> canonical
+	aliasesOf map[string][]string // canonical -> aliases that resolve to it
+	impliedBy map[string][]string // implied tag -> tags that directly imply it
+}
+
+// NewTagRules builds a rule set from alias pairs (alias -> canonical) and
+// implication pairs (tag -> implied tags). Every tag is normalized first.
+func NewTagRules(aliases map[string]string, implications map[string][]string) *TagRules {
+	rules := &TagRules{
+		aliases:   make(map[string]string, len(aliases)),
+		aliasesOf: make(map[string][]string),
+		impliedBy: make(map[string][]string),
+	}
+
+	for alias, canonical := range aliases {
+		alias = NormalizeTagName(alias)
+		canonical = NormalizeTagName(canonical)
+		if alias == "" || canonical == "" || alias == canonical {
+			continue
+		}
+		rules.aliases[alias] = canonical
+	}
+
+	// Stored gallery tags keep their alias names until they are rewritten,
+	// so searches for a canonical tag must also match its aliases
+	for alias := range rules.aliases {
+		canonical := rules.Canonical(alias)
+		if canonical == alias {
+			continue
+		}
+		rules.aliasesOf[canonical] = append(rules.aliasesOf[canonical], alias)
+	}
+
+	for tag, impliedTags := range implications {
+		tag = rules.Canonical(NormalizeTagName(tag))
+		if tag == "" {
+			continue
+		}
+		for _, implied := range impliedTags {
+			implied = rules.Canonical(NormalizeTagName(implied))
+			if implied == "" || implied == tag {
+				continue
+			}
+			rules.impliedBy[implied] = append(rules.impliedBy[implied], tag)
+		}
+	}
+
+	return rules
+}
+
+// Len returns the number of aliases and implication targets in the rule set.
+func (r *TagRules) Len() (aliases int, implications int) {
+	if r == nil {
+		return 0, 0
+	}
+
+	return len(r.aliases), len(r.impliedBy)
+}
+
+// Canonical follows the alias chain for tag and returns the canonical name.
+// Tags without an alias are returned unchanged.
+func (r *TagRules) Canonical(tag string) string {
+	if r == nil || len(r.aliases) == 0 {
+		return tag
+	}
+
+	current := tag
+	for i := 0; i < maxAliasDepth; i++ {
+		next, ok := r.aliases[current]
+		if !ok {
+			return current
+		}
+		current = next
+	}
+
+	return current
+}
+
+// Expand returns tag together with every alias of it and every tag that
+// implies it, directly or transitively. The result is sorted and always
+// contains tag itself.
+func (r *TagRules) Expand(tag string) []string {
+	if r == nil || (len(r.aliasesOf) == 0 && len(r.impliedBy) == 0) {
+		return []string{tag}
+	}
+
+	seen := map[string]struct{}{tag: {}}
+	queue := []string{tag}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for _, related := range [][]string{r.aliasesOf[current], r.impliedBy[current]} {
+			for _, t := range related {
+				if _, ok := seen[t]; ok {
+					continue
+				}
+				seen[t] = struct{}{}
+				queue = append(queue, t)
+			}
+		}
+	}
+
+	expanded := make([]string, 0, len(seen))
+	for t := range seen {
+		expanded = append(expanded, t)
+	}
+	sort.Strings(expanded)
+
+	return expanded
+}
+
+// ExpandAll expands every tag in tags through implications and returns the
+// deduplicated, sorted union.
+func (r *TagRules) ExpandAll(tags []string) []string {
+	seen := make(map[string]struct{}, len(tags))
+	var expanded []string
+	for _, tag := range tags {
+		for _, t := range r.Expand(tag) {
+			if _, ok := seen[t]; ok {
+				continue
+			}
+			seen[t] = struct{}{}
+			expanded = append(expanded, t)
+		}
+	}
+	sort.Strings(expanded)
+
+	return expanded
+}
+
+var currentTagRules atomic.Pointer[TagRules]
+
+// SetTagRules installs the rule set used by NormalizeTag and search expansion.
+// Passing nil disables alias and implication handling.
+func SetTagRules(rules *TagRules) {
+	currentTagRules.Store(rules)
+}
+
+// CurrentTagRules returns the installed rule set, or nil when none is loaded.
+// All TagRules methods are safe to call on a nil receiver.
+func CurrentTagRules() *TagRules {
+	return currentTagRules.Load()
+}
@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTagRulesCanonical(t *testing.T) {
+	rules := NewTagRules(map[string]string{
+		"f:Old Name":         "female:middle name",
+		"female:middle name": "female:new name",
+		"female:loop a":      "female:loop b",
+		"female:loop b":      "female:loop a",
+	}, nil)
+
+	tests := []struct {
+		name     string
+		tag      string
+		expected string
+	}{
+		{name: "no alias", tag: "female:elf", expected: "female:elf"},
+		{name: "direct alias", tag: "female:middle name", expected: "female:new name"},
+		{name: "alias chain", tag: "female:old name", expected: "female:new name"},
+		{name: "cycle terminates", tag: "female:loop a", expected: "female:loop a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rules.Canonical(tt.tag); got != tt.expected {
+				t.Fatalf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestTagRulesExpand(t *testing.T) {
+	rules := NewTagRules(map[string]string{
+		"character:old y": "character:y",
+	}, map[string][]string{
+		"parody:x":       {"character:y"},
+		"parody:x movie": {"parody:x"},
+		"p:z":            {"character:old y"},
+	})
+
+	tests := []struct {
+		name     string
+		tag      string
+		expected []string
+	}{
+		{name: "no rules", tag: "female:elf", expected: []string{"female:elf"}},
+		{
+			name:     "aliases and transitive implications",
+			tag:      "character:y",
+			expected: []string{"character:old y", "character:y", "parody:x", "parody:x movie", "parody:z"},
+		},
+		{name: "direct implication", tag: "parody:x", expected: []string{"parody:x", "parody:x movie"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rules.Expand(tt.tag); !reflect.DeepEqual(got, tt.expected) {
+				t.Fatalf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestNormalizeTagUsesInstalledRules(t *testing.T) {
+	SetTagRules(NewTagRules(map[string]string{"female:old name": "female:new name"}, nil))
+	t.Cleanup(func() { SetTagRules(nil) })
+
+	if got := NormalizeTag("f:Old  Name"); got != "female:new name" {
+		t.Fatalf("expected %q, got %q", "female:new name", got)
+	}
+
+	var nilRules *TagRules
+	if got := nilRules.Expand("female:elf"); !reflect.DeepEqual(got, []string{"female:elf"}) {
+		t.Fatalf("expected nil rules to return the tag unchanged, got %v", got)
+	}
+}
@@ -0,0 +1,260 @@
+package utils
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// ParsedTitle holds the structured parts of an E-Hentai gallery title such as
+// "(C102) [Circle (Artist)] Title (Parody) [English] [Digital]"
+type ParsedTitle struct {
+	Event      string // Convention or event, e.g. "C102"
+	Circle     string // Circle name
+	Artist     string // Artist name
+	Title      string // Title with every bracketed group removed
+	Parody     string // Parodied work
+	Language   string // Lowercase language name, e.g. "english"
+	Digital    bool
+	Decensored bool
+	Ongoing    bool
+}
+
+// titleLanguages maps lowercase language markers found in title groups to
+// language names used by the language: tag namespace
+var titleLanguages = map[string]string{
+	"english":    "english",
+	"英訳":         "english",
+	"英語":         "english",
+	"chinese":    "chinese",
+	"中国翻訳":       "chinese",
+	"中国語":        "chinese",
+	"中文":         "chinese",
+	"korean":     "korean",
+	"韓国翻訳":       "korean",
+	"韓国語":        "korean",
+	"japanese":   "japanese",
+	"日本語":        "japanese",
+	"spanish":    "spanish",
+	"french":     "french",
+	"german":     "german",
+	"italian":    "italian",
+	"portuguese": "portuguese",
+	"russian":    "russian",
+	"thai":       "thai",
+	"vietnamese": "vietnamese",
+	"indonesian": "indonesian",
+	"polish":     "polish",
+}
+
+var (
+	titleDigitalMarkers    = []string{"digital", "dl版"}
+	titleDecensoredMarkers = []string{"decensored", "uncensored", "無修正"}
+	titleOngoingMarkers    = []string{"ongoing", "進行中", "連載中"}
+)
+
+var titleBracketPairs = map[rune]rune{
+	'(': ')',
+	'[': ']',
+	'{': '}',
+	'（': '）',
+	'【': '】',
+}
+
+// ParseTitle splits an E-Hentai gallery title into its conventional parts.
+// Leading groups are read as "(Event) [Circle (Artist)]", trailing
+// parentheses as the parody and trailing square brackets as language, flags
+// or translator credits. Parts that are absent are left empty.
+func ParseTitle(title string) ParsedTitle {
+	var parsed ParsedTitle
+
+	rest := strings.TrimSpace(title)
+
+	// Leading groups: (Event) [Circle (Artist)]
+	for rest != "" {
+		open, content, remaining, ok := cutLeadingGroup(rest)
+		if !ok {
+			break
+		}
+
+		if open == '(' || open == '（' {
+			if parsed.Event != "" || parsed.Circle != "" || parsed.Artist != "" {
+				break
+			}
+			parsed.Event = content
+		} else if open == '[' || open == '【' {
+			if parsed.Circle != "" || parsed.Artist != "" {
+				break
+			}
+			parsed.Circle, parsed.Artist = splitCircleArtist(content)
+		} else {
+			break
+		}
+
+		rest = remaining
+	}
+
+	// Trailing groups: (Parody) [Language] [Flags] [Translator]
+	for rest != "" {
+		open, content, remaining, ok := cutTrailingGroup(rest)
+		if !ok || strings.TrimSpace(remaining) == "" {
+			break
+		}
+
+		if !parsed.applyMarkers(content) && (open == '(' || open == '（') && parsed.Parody == "" {
+			parsed.Parody = content
+		}
+
+		rest = remaining
+	}
+
+	parsed.Title = strings.TrimSpace(rest)
+	return parsed
+}
+
+// ParseGalleryTitles parses both gallery titles and merges the results. The
+// event, circle, artist and parody are taken together from title when it has
+// any of them and from title_jpn otherwise, so a gallery never pairs parts of
+// both. Title and language fall back to title_jpn when missing, and flags are
+// set when either title carries them.
+func ParseGalleryTitles(title, titleJpn string) ParsedTitle {
+	parsed := ParseTitle(title)
+	if strings.TrimSpace(titleJpn) == "" {
+		return parsed
+	}
+
+	jpn := ParseTitle(titleJpn)
+	if !parsed.hasCredits() {
+		parsed.Event = jpn.Event
+		parsed.Circle = jpn.Circle
+		parsed.Artist = jpn.Artist
+		parsed.Parody = jpn.Parody
+	}
+	if parsed.Title == "" {
+		parsed.Title = jpn.Title
+	}
+	if parsed.Language == "" {
+		parsed.Language = jpn.Language
+	}
+	parsed.Digital = parsed.Digital || jpn.Digital
+	parsed.Decensored = parsed.Decensored || jpn.Decensored
+	parsed.Ongoing = parsed.Ongoing || jpn.Ongoing
+
+	return parsed
+}
+
+// hasCredits reports whether any of the event, circle, artist or parody was
+// parsed
+func (p ParsedTitle) hasCredits() bool {
+	return p.Event != "" || p.Circle != "" || p.Artist != "" || p.Parody != ""
+}
+
+// applyMarkers records the language and flag markers of a trailing group and
+// reports whether it is a marker group. A group counts only when every word
+// in it is a known marker, so parodies such as "(Digital Monster)" or
+// "(Chinese Paladin)" are left alone.
+func (p *ParsedTitle) applyMarkers(content string) bool {
+	words := strings.FieldsFunc(strings.ToLower(content), func(r rune) bool {
+		return unicode.IsSpace(r) || r == '/' || r == ',' || r == '|'
+	})
+	if len(words) == 0 {
+		return false
+	}
+	for _, word := range words {
+		_, language := titleLanguages[word]
+		if !language && !slices.Contains(titleDigitalMarkers, word) &&
+			!slices.Contains(titleDecensoredMarkers, word) && !slices.Contains(titleOngoingMarkers, word) {
+			return false
+		}
+	}
+
+	for _, word := range words {
+		if language, ok := titleLanguages[word]; ok && p.Language == "" {
+			p.Language = language
+		}
+		p.Digital = p.Digital || slices.Contains(titleDigitalMarkers, word)
+		p.Decensored = p.Decensored || slices.Contains(titleDecensoredMarkers, word)
+		p.Ongoing = p.Ongoing || slices.Contains(titleOngoingMarkers, word)
+	}
+
+	return true
+}
+
+// splitCircleArtist splits "Circle (Artist)" into its parts. A group without
+// parentheses names the artist only.
+func splitCircleArtist(content string) (string, string) {
+	for _, pair := range [][2]rune{{'(', ')'}, {'（', '）'}} {
+		openRune, closeRune := pair[0], pair[1]
+		start := strings.IndexRune(content, openRune)
+		if start <= 0 || !strings.HasSuffix(content, string(closeRune)) {
+			continue
+		}
+		circle := strings.TrimSpace(content[:start])
+		artist := strings.TrimSpace(strings.TrimSuffix(content[start+len(string(openRune)):], string(closeRune)))
+		if circle != "" && artist != "" {
+			return circle, artist
+		}
+	}
+
+	return "", strings.TrimSpace(content)
+}
+
+// cutLeadingGroup removes a bracketed group from the start of s and returns
+// its opening bracket, trimmed content and the remaining string
+func cutLeadingGroup(s string) (rune, string, string, bool) {
+	runes := []rune(s)
+	closeRune, ok := titleBracketPairs[runes[0]]
+	if !ok {
+		return 0, "", "", false
+	}
+
+	depth := 0
+	for i, r := range runes {
+		switch r {
+		case runes[0]:
+			depth++
+		case closeRune:
+			depth--
+			if depth == 0 {
+				content := strings.TrimSpace(string(runes[1:i]))
+				return runes[0], content, strings.TrimSpace(string(runes[i+1:])), content != ""
+			}
+		}
+	}
+
+	return 0, "", "", false
+}
+
+// cutTrailingGroup removes a bracketed group from the end of s and returns
+// its opening bracket, trimmed content and the remaining string
+func cutTrailingGroup(s string) (rune, string, string, bool) {
+	runes := []rune(s)
+	last := runes[len(runes)-1]
+
+	var openRune rune
+	for open, closeRune := range titleBracketPairs {
+		if closeRune == last {
+			openRune = open
+			break
+		}
+	}
+	if openRune == 0 {
+		return 0, "", "", false
+	}
+
+	depth := 0
+	for i := len(runes) - 1; i >= 0; i-- {
+		switch runes[i] {
+		case last:
+			depth++
+		case openRune:
+			depth--
+			if depth == 0 {
+				content := strings.TrimSpace(string(runes[i+1 : len(runes)-1]))
+				return openRune, content, strings.TrimSpace(string(runes[:i])), content != ""
+			}
+		}
+	}
+
+	return 0, "", "", false
+}
@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTitle(t *testing.T) {
+	tests := []struct {
+		name     string
+		title    string
+		expected ParsedTitle
+	}{
+		{
+			name:  "full english title",
+			title: "(C102) [Circle (Artist)] Some Title (Original Parody) [English] [Digital]",
+			expected: ParsedTitle{
+				Event:    "C102",
+				Circle:   "Circle",
+				Artist:   "Artist",
+				Title:    "Some Title",
+				Parody:   "Original Parody",
+				Language: "english",
+				Digital:  true,
+			},
+		},
+		{
+			name:  "japanese title with translator credit",
+			title: "(COMIC1☆15) [サークル (作者)] タイトル (東方Project) [中国翻訳] [某汉化组] [無修正] [DL版]",
+			expected: ParsedTitle{
+				Event:      "COMIC1☆15",
+				Circle:     "サークル",
+				Artist:     "作者",
+				Title:      "タイトル",
+				Parody:     "東方Project",
+				Language:   "chinese",
+				Digital:    true,
+				Decensored: true,
+			},
+		},
+		{
+			name:  "artist only and ongoing",
+			title: "[Artist] Long Series Ch. 1-5 [Korean] [Ongoing]",
+			expected: ParsedTitle{
+				Artist:   "Artist",
+				Title:    "Long Series Ch. 1-5",
+				Language: "korean",
+				Ongoing:  true,
+			},
+		},
+		{
+			name:     "plain title",
+			title:    "Just a title",
+			expected: ParsedTitle{Title: "Just a title"},
+		},
+		{
+			name:     "fully bracketed title keeps last group as title",
+			title:    "[Artist] [Title Only]",
+			expected: ParsedTitle{Artist: "Artist", Title: "[Title Only]"},
+		},
+		{
+			name:     "parody containing marker words",
+			title:    "[Artist] Title (Digital Monster) [English]",
+			expected: ParsedTitle{Artist: "Artist", Title: "Title", Parody: "Digital Monster", Language: "english"},
+		},
+		{
+			name:     "parody containing a language word",
+			title:    "[Artist] Title (Chinese Paladin)",
+			expected: ParsedTitle{Artist: "Artist", Title: "Title", Parody: "Chinese Paladin"},
+		},
+		{
+			name:     "combined marker group",
+			title:    "[Artist] Title [English/Digital]",
+			expected: ParsedTitle{Artist: "Artist", Title: "Title", Language: "english", Digital: true},
+		},
+		{
+			name:     "nested parentheses in parody",
+			title:    "[Artist] Title (Series (2019))",
+			expected: ParsedTitle{Artist: "Artist", Title: "Title", Parody: "Series (2019)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseTitle(tt.title)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Fatalf("expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseGalleryTitles(t *testing.T) {
+	tests := []struct {
+		name     string
+		title    string
+		titleJpn string
+		expected ParsedTitle
+	}{
+		{
+			name:     "credits come from one title",
+			title:    "[Artist] English Title [English]",
+			titleJpn: "(C99) [サークル (作者)] 日本語タイトル (オリジナル) [英訳] [DL版]",
+			expected: ParsedTitle{
+				Artist:   "Artist",
+				Title:    "English Title",
+				Language: "english",
+				Digital:  true,
+			},
+		},
+		{
+			name:     "title_jpn credits when title has none",
+			title:    "English Title",
+			titleJpn: "(C99) [サークル (作者)] 日本語タイトル (オリジナル) [DL版]",
+			expected: ParsedTitle{
+				Event:   "C99",
+				Circle:  "サークル",
+				Artist:  "作者",
+				Title:   "English Title",
+				Parody:  "オリジナル",
+				Digital: true,
+			},
+		},
+		{
+			name:     "empty title_jpn",
+			title:    "[Artist] Title",
+			titleJpn: "",
+			expected: ParsedTitle{Artist: "Artist", Title: "Title"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseGalleryTitles(tt.title, tt.titleJpn)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Fatalf("expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}